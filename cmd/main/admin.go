@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/arturskrzydlo/chat-room/internal/app"
+	"github.com/arturskrzydlo/chat-room/internal/coordinator"
+	"github.com/arturskrzydlo/chat-room/internal/server"
+)
+
+// requireAdmin rejects requests that don't carry "Authorization: Bearer <token>"
+func requireAdmin(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got := bearerToken(r)
+		if got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// bearerToken returns the token from "Authorization: Bearer <token>", or ""
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// closeRoomHandler serves POST /admin/rooms/{id}/close, answering 404 for a
+// room that doesn't exist
+func closeRoomHandler(closeRoom func(roomID string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := closeRoom(r.PathValue("id"))
+		switch {
+		case errors.Is(err, app.ErrRoomNotFound):
+			http.Error(w, "room not found", http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// issueAPIKeyHandler serves POST /admin/rooms/{id}/api-key, answering with a
+// fresh key integrations use to post to the room
+func issueAPIKeyHandler(issue func(roomID string) (string, error), logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := issue(r.PathValue("id"))
+		switch {
+		case errors.Is(err, app.ErrRoomNotFound):
+			http.Error(w, "room not found", http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]string{"api_key": key}); err != nil {
+			logger.Error("admin: encode api key", "error", err)
+		}
+	}
+}
+
+type debugState struct {
+	Rooms   []coordinator.RoomState `json:"rooms"`
+	Clients []server.ClientState    `json:"clients"`
+}
+
+// debugStateHandler serves GET /debug/state: each room's members and each
+// connection's rooms. The two are read separately, so a join in progress may
+// show on one side only.
+func debugStateHandler(rooms func() []coordinator.RoomState, clients func() []server.ClientState, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state := debugState{Rooms: rooms(), Clients: clients()}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(state); err != nil {
+			logger.Error("debug state: encode", "error", err)
+		}
+	}
+}
+
+type announceRequest struct {
+	Content string `json:"content"`
+}
+
+// announceHandler serves POST /admin/announce, sending {"content": "..."} to
+// every room
+func announceHandler(announce func(content string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req announceRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBotRequestSize)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		err := announce(req.Content)
+		switch {
+		case errors.Is(err, app.ErrEmptyContent):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, app.ErrContentTooLong):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}
+}
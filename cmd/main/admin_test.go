@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/coordinator"
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/arturskrzydlo/chat-room/internal/server"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseRoomEndpoint(t *testing.T) {
+	coord := coordinator.NewCoordinator()
+	send := make(chan interface{}, 10)
+	require.NoError(t, coord.CreateRoom("room_1", "author1", "Room One", "", send))
+	require.NoError(t, coord.CreateRoom("room_2", "author1", "Room Two", "", send))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/rooms/{id}/close", requireAdmin("secret", closeRoomHandler(coord.CloseRoom)))
+
+	post := func(roomID, auth string) int {
+		req := httptest.NewRequest(http.MethodPost, "/admin/rooms/"+roomID+"/close", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, post("room_1", ""))
+	assert.Equal(t, http.StatusUnauthorized, post("room_1", "Bearer wrong"))
+	assert.Equal(t, http.StatusNotFound, post("missing", "Bearer secret"))
+
+	require.Equal(t, http.StatusNoContent, post("room_1", "Bearer secret"))
+	rooms := coord.ListRooms(coordinator.RoomFilter{})
+	require.Len(t, rooms, 1)
+	assert.Equal(t, "room_2", rooms[0].ID)
+
+	// closing it again finds nothing
+	assert.Equal(t, http.StatusNotFound, post("room_1", "Bearer secret"))
+}
+
+func TestDebugStateEndpoint(t *testing.T) {
+	coord := coordinator.NewCoordinator()
+	ws := server.NewWsServer(context.Background(), coord)
+
+	mux := http.NewServeMux()
+	mux.Handle("/ws", ws)
+	mux.HandleFunc("GET /debug/state", requireAdmin("secret", debugStateHandler(coord.RoomStates, ws.ClientStates, slog.New(slog.DiscardHandler))))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	payload, err := json.Marshal(messages.CreateRoomPayload{RoomID: "room_1", RoomName: "Room One", UserID: "user1", UserName: "User One"})
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteJSON(messages.WsMessage{Type: messages.MessageActionTypeCreateRoom, Payload: payload}))
+
+	getState := func(t *testing.T, auth string) (int, debugState) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/debug/state", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", auth)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var state debugState
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&state))
+		}
+		return resp.StatusCode, state
+	}
+
+	code, _ := getState(t, "Bearer wrong")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// the room loop applies the join asynchronously
+	require.Eventually(t, func() bool {
+		_, state := getState(t, "Bearer secret")
+		return len(state.Rooms) == 1 && len(state.Rooms[0].UserIDs) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	code, state := getState(t, "Bearer secret")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []coordinator.RoomState{{RoomID: "room_1", UserIDs: []string{"user1"}}}, state.Rooms)
+	assert.Equal(t, []server.ClientState{{UserID: "user1", Rooms: []string{"room_1"}}}, state.Clients)
+}
+
+func TestAnnounceEndpoint(t *testing.T) {
+	coord := coordinator.NewCoordinator()
+	send1 := make(chan interface{}, 10)
+	send2 := make(chan interface{}, 10)
+	require.NoError(t, coord.CreateRoom("room_1", "author1", "Room One", "", send1))
+	require.NoError(t, coord.CreateRoom("room_2", "author2", "Room Two", "", send2))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/announce", requireAdmin("secret", announceHandler(coord.BroadcastSystemAnnouncement)))
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/admin/announce", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, post(`{"content":""}`))
+	require.Equal(t, http.StatusAccepted, post(`{"content":"maintenance at 22:00"}`))
+
+	for roomID, ch := range map[string]chan interface{}{"room_1": send1, "room_2": send2} {
+		deadline := time.After(time.Second)
+	wait:
+		for {
+			select {
+			case ev := <-ch:
+				a, ok := ev.(messages.SystemAnnouncementEvent)
+				if !ok {
+					continue
+				}
+				assert.Equal(t, roomID, a.RoomID)
+				assert.Equal(t, "maintenance at 22:00", a.Content)
+				break wait
+			case <-deadline:
+				require.FailNow(t, "expected an announcement", "room %s", roomID)
+			}
+		}
+	}
+}
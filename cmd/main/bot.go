@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/arturskrzydlo/chat-room/internal/app"
+)
+
+// maxBotRequestSize bounds the request body; the room's message size limit
+// is enforced by the coordinator
+const maxBotRequestSize = 64 * 1024
+
+type botMessageRequest struct {
+	BotName string `json:"bot_name"`
+	Content string `json:"content"`
+}
+
+// roomPoster is the part of the coordinator integrations post through
+type roomPoster interface {
+	VerifyRoomAPIKey(roomID, key string) error
+	SendSystemMessage(roomID, botName, content string) error
+}
+
+// botMessageHandler serves POST /rooms/{id}/messages for integrations
+// authenticated with the room's API key as a bearer token
+func botMessageHandler(rooms roomPoster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := r.PathValue("id")
+		if err := rooms.VerifyRoomAPIKey(roomID, bearerToken(r)); err != nil {
+			writeBotError(w, err)
+			return
+		}
+
+		var req botMessageRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBotRequestSize)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.BotName == "" {
+			http.Error(w, "bot_name is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := rooms.SendSystemMessage(roomID, req.BotName, req.Content); err != nil {
+			writeBotError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func writeBotError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, app.ErrRoomNotFound):
+		http.Error(w, "room not found", http.StatusNotFound)
+	case errors.Is(err, app.ErrInvalidAPIKey):
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	case errors.Is(err, app.ErrEmptyContent):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, app.ErrContentTooLong):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, app.ErrRoomBusy):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/coordinator"
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBotMessageEndpoint(t *testing.T) {
+	coord := coordinator.NewCoordinator()
+	send := make(chan interface{}, 10)
+	require.NoError(t, coord.CreateRoom("room_1", "author1", "Room One", "", send))
+	key, err := coord.IssueRoomAPIKey("room_1")
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /rooms/{id}/messages", botMessageHandler(coord))
+
+	post := func(roomID, key, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/rooms/"+roomID+"/messages", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("valid key", func(t *testing.T) {
+		require.Equal(t, http.StatusAccepted, post("room_1", key, `{"bot_name":"ci","content":"build #42 passed"}`))
+
+		deadline := time.After(time.Second)
+		for {
+			select {
+			case ev := <-send:
+				msg, ok := ev.(messages.RoomMessageEvent)
+				if !ok {
+					continue
+				}
+				assert.True(t, msg.System)
+				assert.Equal(t, "ci", msg.UserName)
+				assert.Empty(t, msg.UserID)
+				assert.Equal(t, "build #42 passed", msg.Message.Message)
+				return
+			case <-deadline:
+				require.FailNow(t, "expected the system message to be broadcast")
+			}
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, post("room_1", "wrong", `{"bot_name":"ci","content":"hi"}`))
+	})
+
+	t.Run("nonexistent room", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, post("missing", key, `{"bot_name":"ci","content":"hi"}`))
+	})
+
+	t.Run("size limit", func(t *testing.T) {
+		body := `{"bot_name":"ci","content":"` + strings.Repeat("a", 11*1024) + `"}`
+		assert.Equal(t, http.StatusRequestEntityTooLarge, post("room_1", key, body))
+	})
+}
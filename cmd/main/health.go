@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type healthStatus struct {
+	Status        string `json:"status"` // "healthy" or "shutting_down"
+	Rooms         int    `json:"rooms"`
+	Clients       int    `json:"clients"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+}
+
+// livezHandler answers 200 for as long as the process can serve HTTP at all
+func livezHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// readyzHandler answers 503 once draining reports true, so orchestrators stop
+// routing new connections while existing ones are shut down
+func readyzHandler(draining func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if draining() {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	}
+}
+
+// healthHandler reports live room and client counts. Once ctx is cancelled the
+// process is shutting down and the handler answers 503 so load balancers stop
+// routing to it.
+func healthHandler(ctx context.Context, started time.Time, rooms, clients func() int, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := healthStatus{
+			Status:        "healthy",
+			Rooms:         rooms(),
+			Clients:       clients(),
+			UptimeSeconds: int64(time.Since(started).Seconds()),
+		}
+		code := http.StatusOK
+		if ctx.Err() != nil {
+			status.Status = "shutting_down"
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			logger.Error("health: encode", "error", err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/coordinator"
+	"github.com/arturskrzydlo/chat-room/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := healthHandler(ctx, time.Now().Add(-90*time.Second),
+		func() int { return 3 },
+		func() int { return 7 },
+		slog.New(slog.DiscardHandler),
+	)
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, map[string]interface{}{
+		"status":         "healthy",
+		"rooms":          float64(3),
+		"clients":        float64(7),
+		"uptime_seconds": float64(90),
+	}, got)
+}
+
+func TestHealthHandlerShuttingDown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	h := healthHandler(ctx, time.Now(), func() int { return 0 }, func() int { return 0 }, slog.New(slog.DiscardHandler))
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var got healthStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "shutting_down", got.Status)
+}
+
+func TestLivezAndReadyz(t *testing.T) {
+	ws := server.NewWsServer(context.Background(), coordinator.NewCoordinator())
+	readyz := readyzHandler(ws.Draining)
+
+	get := func(h http.HandlerFunc, path string) int {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get(livezHandler, "/livez"))
+	assert.Equal(t, http.StatusOK, get(readyz, "/readyz"))
+
+	ws.Drain()
+
+	assert.Equal(t, http.StatusServiceUnavailable, get(readyz, "/readyz"))
+	assert.Equal(t, http.StatusOK, get(livezHandler, "/livez"), "liveness ignores draining")
+	require.NoError(t, ws.Shutdown(context.Background()))
+}
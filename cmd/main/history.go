@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+)
+
+// historySource is the part of the coordinator room history is read from
+type historySource interface {
+	roomAccess
+	GetHistory(roomID string, beforeSeq uint64, limit int) ([]messages.RoomMessageEvent, error)
+}
+
+// historyHandler serves GET /rooms/{id}/messages?before=<seq>&limit=<n>,
+// answering with the same history_batch a websocket history request gets, so
+// clients can load history before connecting. Both parameters are optional
+// and limit is capped like the websocket's. Private rooms answer 403 (see
+// readRoom).
+func historyHandler(rooms historySource, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := r.PathValue("id")
+
+		var before uint64
+		if v := r.URL.Query().Get("before"); v != "" {
+			seq, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				http.Error(w, "before must be a sequence number", http.StatusBadRequest)
+				return
+			}
+			before = seq
+		}
+		limit, ok := parseLimit(w, r)
+		if !ok {
+			return
+		}
+
+		var page []messages.RoomMessageEvent
+		if !readRoom(w, rooms, roomID, func() (err error) {
+			page, err = rooms.GetHistory(roomID, before, limit)
+			return err
+		}) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(messages.NewHistoryBatchEvent(roomID, page)); err != nil {
+			logger.Error("history: encode", "room_id", roomID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/arturskrzydlo/chat-room/internal/coordinator"
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryEndpoint(t *testing.T) {
+	get := serveRoomReads(t, "GET /rooms/{id}/messages", func(coord *coordinator.Coordinator) http.HandlerFunc {
+		return historyHandler(coord, slog.New(slog.DiscardHandler))
+	}, "msg 1", "msg 2", "msg 3", "msg 4", "msg 5")
+
+	t.Run("populated room", func(t *testing.T) {
+		var batch messages.HistoryBatchEvent
+		require.Equal(t, http.StatusOK, get(t, "/rooms/room_1/messages", &batch))
+		assert.Equal(t, messages.EventHistoryBatch, batch.Type)
+		assert.Equal(t, "room_1", batch.RoomID)
+		assert.Equal(t, []string{"msg 5", "msg 4", "msg 3", "msg 2", "msg 1"}, messageTexts(batch.Messages))
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		var first messages.HistoryBatchEvent
+		require.Equal(t, http.StatusOK, get(t, "/rooms/room_1/messages?limit=2", &first))
+		require.Equal(t, []string{"msg 5", "msg 4"}, messageTexts(first.Messages))
+
+		oldest := first.Messages[len(first.Messages)-1].Seq
+		var next messages.HistoryBatchEvent
+		require.Equal(t, http.StatusOK, get(t, fmt.Sprintf("/rooms/room_1/messages?before=%d&limit=2", oldest), &next))
+		assert.Equal(t, []string{"msg 3", "msg 2"}, messageTexts(next.Messages))
+	})
+
+	t.Run("missing room", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get(t, "/rooms/nope/messages", nil))
+	})
+
+	t.Run("private room", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, get(t, "/rooms/secret/messages", nil))
+	})
+
+	t.Run("bad parameters", func(t *testing.T) {
+		for _, query := range []string{"?before=x", "?limit=0", "?limit=-3", "?limit=many"} {
+			assert.Equal(t, http.StatusBadRequest, get(t, "/rooms/room_1/messages"+query, nil), query)
+		}
+	})
+}
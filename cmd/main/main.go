@@ -3,15 +3,20 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/arturskrzydlo/chat-room/internal/broadcast"
 	"github.com/arturskrzydlo/chat-room/internal/coordinator"
+	"github.com/arturskrzydlo/chat-room/internal/metrics"
 	"github.com/arturskrzydlo/chat-room/internal/server"
+	"github.com/redis/go-redis/v9"
 )
 
 const (
@@ -20,23 +25,83 @@ const (
 	serverWriteTimeout    = 15 * time.Second
 	serverShutdownTimeout = 30 * time.Second
 	serverMaxHeaderBytes  = 1 * 1024 * 1024 // 1MB
+	clientResumeTTL       = 30 * time.Second
 )
 
 func main() {
-	coord := coordinator.NewCoordinator()
+	started := time.Now()
+
+	var level slog.Level
+	// LOG_LEVEL is debug, info, warn or error; unset means info
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			slog.Error("invalid LOG_LEVEL, using info", "value", v, "error", err)
+		}
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+
+	coordOpts := []coordinator.Option{coordinator.WithLogger(logger)}
+	// EXCLUDE_SENDER=true stops chat messages from being echoed to their sender
+	if v := os.Getenv("EXCLUDE_SENDER"); v != "" {
+		exclude, err := strconv.ParseBool(v)
+		if err != nil {
+			logger.Error("invalid EXCLUDE_SENDER, echoing messages to their sender", "value", v, "error", err)
+		}
+		coordOpts = append(coordOpts, coordinator.WithExcludeSender(exclude))
+	}
+	// REDIS_ADDR enables fan-out of room broadcasts across instances
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		b := broadcast.NewRedisBroadcaster(redis.NewClient(&redis.Options{Addr: addr}), broadcast.WithLogger(logger))
+		defer b.Close()
+		coordOpts = append(coordOpts, coordinator.WithBroadcaster(b))
+		logger.Info("redis fan-out enabled", "addr", addr)
+	}
+
+	// WEBHOOK_URL receives room and membership lifecycle events as JSON POSTs
+	if url := os.Getenv("WEBHOOK_URL"); url != "" {
+		n := coordinator.NewWebhookNotifier(url, coordinator.WithWebhookLogger(logger))
+		defer n.Close()
+		coordOpts = append(coordOpts, coordinator.WithWebhookNotifier(n))
+		logger.Info("lifecycle webhooks enabled", "url", url)
+	}
+
+	coord := coordinator.NewCoordinator(coordOpts...)
 	rootCtx, rootCancel := context.WithCancel(context.Background())
 	defer rootCancel()
 
-	wsServer := server.NewWsServer(rootCtx, coord)
+	wsOpts := []server.Option{server.WithResumeTTL(clientResumeTTL), server.WithLogger(logger)}
+	// ALLOWED_ORIGINS is a comma-separated allowlist; unset accepts every origin
+	if origins := os.Getenv("ALLOWED_ORIGINS"); origins != "" {
+		wsOpts = append(wsOpts, server.WithAllowedOrigins(strings.Split(origins, ",")))
+	}
+
+	wsServer := server.NewWsServer(rootCtx, coord, wsOpts...)
 
 	http.Handle("/ws", wsServer)
 
-	// Optional: Add health check endpoint
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(`{"status":"healthy"}`))
-	})
+	metrics.RegisterGauges(coord.RoomCount, wsServer.ClientCount)
+	http.Handle("/metrics", metrics.Handler())
+
+	http.HandleFunc("/health", healthHandler(rootCtx, started, coord.RoomCount, wsServer.ClientCount, logger))
+	http.HandleFunc("/livez", livezHandler)
+	http.HandleFunc("/readyz", readyzHandler(wsServer.Draining))
+
+	http.HandleFunc("/rooms", roomsHandler(coord.ListRooms, logger))
+
+	http.HandleFunc("GET /rooms/{id}/messages", historyHandler(coord, logger))
+	http.HandleFunc("GET /rooms/{id}/search", searchHandler(coord, logger))
+
+	// integrations post with a room API key issued through the admin endpoint
+	http.HandleFunc("POST /rooms/{id}/messages", botMessageHandler(coord))
+
+	// ADMIN_TOKEN enables the admin endpoints, authenticated as a bearer token
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		http.HandleFunc("POST /admin/rooms/{id}/close", requireAdmin(token, closeRoomHandler(coord.CloseRoom)))
+		http.HandleFunc("POST /admin/rooms/{id}/api-key", requireAdmin(token, issueAPIKeyHandler(coord.IssueRoomAPIKey, logger)))
+		http.HandleFunc("POST /admin/announce", requireAdmin(token, announceHandler(coord.BroadcastSystemAnnouncement)))
+		http.HandleFunc("GET /debug/state", requireAdmin(token, debugStateHandler(coord.RoomStates, wsServer.ClientStates, logger)))
+	}
 
 	srv := &http.Server{
 		Addr:           serverAddr,
@@ -52,31 +117,33 @@ func main() {
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 
-		log.Println("Shutting down server...")
+		logger.Info("shutting down server")
 
 		ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
 		defer cancel()
 
-		// Stop accepting new WS and close all clients
+		// Fail /readyz, stop accepting new WS and close all clients
 		if err := wsServer.Shutdown(ctx); err != nil {
-			log.Printf("WebSocket server shutdown error: %v", err)
+			logger.Error("websocket server shutdown", "error", err)
 		}
+		// /health reports 503 from here on
+		rootCancel()
 
 		// Shutdown all rooms
 		if err := coord.Shutdown(ctx); err != nil {
-			log.Printf("Coordinator shutdown error: %v", err)
+			logger.Error("coordinator shutdown", "error", err)
 		}
 
 		// Shutdown HTTP server
 		if err := srv.Shutdown(ctx); err != nil {
-			log.Printf("Server shutdown error: %v", err)
+			logger.Error("http server shutdown", "error", err)
 		}
 	}()
 
-	log.Printf("Chat room server started at http://localhost%s\n", serverAddr)
-	log.Printf("WebSocket endpoint: ws://localhost%s/ws\n", serverAddr)
+	logger.Info("chat room server started", "url", "http://localhost"+serverAddr, "ws", "ws://localhost"+serverAddr+"/ws")
 
 	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		log.Fatalf("Server error: %v", err)
+		logger.Error("server error", "error", err)
+		os.Exit(1)
 	}
 }
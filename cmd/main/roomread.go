@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/arturskrzydlo/chat-room/internal/app"
+)
+
+// roomAccess is the check the public room read endpoints share
+type roomAccess interface {
+	IsRoomPrivate(roomID string) bool
+}
+
+// parseLimit reads the optional limit parameter, 0 when absent. Anything but
+// a positive number answers 400 and reports false.
+func parseLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+// readRoom runs read for roomID unless the room is private, which answers
+// 403: its messages are for members who joined with the password. Errors
+// from read answer 404 for an unknown room, 400 for an empty query and 500
+// otherwise. It reports whether read succeeded, leaving the response to the
+// caller.
+func readRoom(w http.ResponseWriter, rooms roomAccess, roomID string, read func() error) bool {
+	if rooms.IsRoomPrivate(roomID) {
+		http.Error(w, "room is private", http.StatusForbidden)
+		return false
+	}
+	switch err := read(); {
+	case errors.Is(err, app.ErrEmptyQuery):
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return false
+	case errors.Is(err, app.ErrRoomNotFound):
+		http.Error(w, "room not found", http.StatusNotFound)
+		return false
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
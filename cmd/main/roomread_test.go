@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/coordinator"
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveRoomReads sets up the public room_1 holding texts, sent in order, and
+// the private room secret, and serves newHandler's handler at pattern. The
+// returned get answers the status code, decoding a 200 response into out.
+func serveRoomReads(t *testing.T, pattern string, newHandler func(*coordinator.Coordinator) http.HandlerFunc, texts ...string) func(t *testing.T, url string, out interface{}) int {
+	t.Helper()
+	coord := coordinator.NewCoordinator()
+	send := make(chan interface{}, 50)
+	require.NoError(t, coord.CreateRoom("room_1", "author1", "Room One", "", send))
+	require.NoError(t, coord.CreateRoom("secret", "author1", "Secret", "s3cret", send))
+	require.Eventually(t, func() bool {
+		return len(coord.GetRoom("room_1").GetUsers()) == 1
+	}, time.Second, 5*time.Millisecond)
+	for _, text := range texts {
+		require.NoError(t, coord.SendMessage("room_1", "author1", text, "", ""))
+	}
+	require.Eventually(t, func() bool {
+		page, _ := coord.GetHistory("room_1", 0, len(texts)+1)
+		return len(page) == len(texts)
+	}, time.Second, 5*time.Millisecond)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, newHandler(coord))
+
+	return func(t *testing.T, url string, out interface{}) int {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code == http.StatusOK && out != nil {
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), out))
+		}
+		return rec.Code
+	}
+}
+
+// messageTexts returns each message's content, in order
+func messageTexts(msgs []messages.RoomMessageEvent) []string {
+	var out []string
+	for _, m := range msgs {
+		out = append(out, m.Message.Message)
+	}
+	return out
+}
+
+func TestParseLimit(t *testing.T) {
+	for query, want := range map[string]int{"": 0, "?limit=1": 1, "?limit=50": 50} {
+		rec := httptest.NewRecorder()
+		limit, ok := parseLimit(rec, httptest.NewRequest(http.MethodGet, "/"+query, nil))
+		require.True(t, ok, query)
+		assert.Equal(t, want, limit, query)
+	}
+	for _, query := range []string{"?limit=0", "?limit=-3", "?limit=many"} {
+		rec := httptest.NewRecorder()
+		_, ok := parseLimit(rec, httptest.NewRequest(http.MethodGet, "/"+query, nil))
+		assert.False(t, ok, query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
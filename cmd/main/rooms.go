@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/arturskrzydlo/chat-room/internal/coordinator"
+)
+
+// roomsHandler serves GET /rooms?q=<substring>&author=<id>&min_users=<n>,
+// listing the active rooms that match. Every parameter is optional: q matches
+// room names ignoring case, author the room author and min_users the
+// occupancy at the time of the request. Failures writing the response are
+// logged to logger.
+func roomsHandler(list func(coordinator.RoomFilter) []coordinator.RoomSummary, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		filter := coordinator.RoomFilter{
+			NameContains: query.Get("q"),
+			AuthorID:     query.Get("author"),
+		}
+		if v := query.Get("min_users"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "min_users must be a non-negative number", http.StatusBadRequest)
+				return
+			}
+			filter.MinUsers = n
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list(filter)); err != nil {
+			logger.Error("rooms: encode", "error", err)
+		}
+	}
+}
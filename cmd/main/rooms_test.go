@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arturskrzydlo/chat-room/internal/coordinator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoomsEndpoint(t *testing.T) {
+	var got coordinator.RoomFilter
+	handler := roomsHandler(func(filter coordinator.RoomFilter) []coordinator.RoomSummary {
+		got = filter
+		return []coordinator.RoomSummary{{ID: "room_1", Name: "Room One", AuthorID: "author1", UserCount: 2}}
+	}, slog.New(slog.DiscardHandler))
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		return rec
+	}
+
+	t.Run("no filter", func(t *testing.T) {
+		rec := get("/rooms")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, coordinator.RoomFilter{}, got)
+
+		var rooms []coordinator.RoomSummary
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rooms))
+		require.Len(t, rooms, 1)
+		assert.Equal(t, "room_1", rooms[0].ID)
+	})
+
+	t.Run("every parameter", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, get("/rooms?q=one&author=author1&min_users=2").Code)
+		assert.Equal(t, coordinator.RoomFilter{NameContains: "one", AuthorID: "author1", MinUsers: 2}, got)
+	})
+
+	t.Run("bad min_users", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("/rooms?min_users=lots").Code)
+		assert.Equal(t, http.StatusBadRequest, get("/rooms?min_users=-1").Code)
+	})
+
+	t.Run("wrong method", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rooms", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, http.MethodGet, rec.Header().Get("Allow"))
+	})
+}
+
+func TestRoomsEndpointFiltersCoordinatorRooms(t *testing.T) {
+	coord := coordinator.NewCoordinator()
+	send := make(chan interface{}, 10)
+	require.NoError(t, coord.CreateRoom("room_1", "author1", "Room One", "", send))
+	require.NoError(t, coord.CreateRoom("room_2", "author2", "Room Two", "", send))
+
+	rec := httptest.NewRecorder()
+	roomsHandler(coord.ListRooms, slog.New(slog.DiscardHandler)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rooms?author=author2", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var rooms []coordinator.RoomSummary
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rooms))
+	require.Len(t, rooms, 1)
+	assert.Equal(t, "room_2", rooms[0].ID)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+)
+
+// searchSource is the part of the coordinator room messages are searched in
+type searchSource interface {
+	roomAccess
+	SearchMessages(roomID, query string, limit int) ([]messages.RoomMessageEvent, error)
+}
+
+// searchResults is the body of a search response
+type searchResults struct {
+	RoomID   string                      `json:"room_id"`
+	Query    string                      `json:"query"`
+	Messages []messages.RoomMessageEvent `json:"messages"`
+}
+
+// searchHandler serves GET /rooms/{id}/search?q=<text>&limit=<n>, answering
+// with the room's buffered messages that contain q, newest first. q is
+// required; limit is optional and capped like history's. Private rooms answer
+// 403, as they do for history (see readRoom).
+func searchHandler(rooms searchSource, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := r.PathValue("id")
+		query := r.URL.Query().Get("q")
+
+		limit, ok := parseLimit(w, r)
+		if !ok {
+			return
+		}
+
+		var found []messages.RoomMessageEvent
+		if !readRoom(w, rooms, roomID, func() (err error) {
+			found, err = rooms.SearchMessages(roomID, query, limit)
+			return err
+		}) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(searchResults{RoomID: roomID, Query: query, Messages: found}); err != nil {
+			logger.Error("search: encode", "room_id", roomID, "error", err)
+		}
+	}
+}
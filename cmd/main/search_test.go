@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/arturskrzydlo/chat-room/internal/coordinator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchEndpoint(t *testing.T) {
+	get := serveRoomReads(t, "GET /rooms/{id}/search", func(coord *coordinator.Coordinator) http.HandlerFunc {
+		return searchHandler(coord, slog.New(slog.DiscardHandler))
+	}, "first deploy", "coffee", "second deploy")
+
+	t.Run("matches", func(t *testing.T) {
+		var results searchResults
+		require.Equal(t, http.StatusOK, get(t, "/rooms/room_1/search?q=Deploy", &results))
+		assert.Equal(t, "room_1", results.RoomID)
+		assert.Equal(t, "Deploy", results.Query)
+		assert.Equal(t, []string{"second deploy", "first deploy"}, messageTexts(results.Messages))
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		var results searchResults
+		require.Equal(t, http.StatusOK, get(t, "/rooms/room_1/search?q=deploy&limit=1", &results))
+		assert.Equal(t, []string{"second deploy"}, messageTexts(results.Messages))
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		var results searchResults
+		require.Equal(t, http.StatusOK, get(t, "/rooms/room_1/search?q=tea", &results))
+		assert.NotNil(t, results.Messages)
+		assert.Empty(t, results.Messages)
+	})
+
+	t.Run("missing room", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get(t, "/rooms/nope/search?q=deploy", nil))
+	})
+
+	t.Run("private room", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, get(t, "/rooms/secret/search?q=deploy", nil))
+	})
+
+	t.Run("bad parameters", func(t *testing.T) {
+		for _, query := range []string{"", "?q=", "?q=deploy&limit=0", "?q=deploy&limit=many"} {
+			assert.Equal(t, http.StatusBadRequest, get(t, "/rooms/room_1/search"+query, nil), query)
+		}
+	})
+}
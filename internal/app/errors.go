@@ -0,0 +1,52 @@
+// Package app holds the error values and input rules shared by the
+// coordinator and the transport layer, so both enforce the same limits and
+// callers can match failures with errors.Is instead of comparing strings.
+package app
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrRoomNotFound      = errors.New("room not found")
+	ErrRoomExists        = errors.New("room already exists")
+	ErrRoomRequired      = errors.New("room_name is required")
+	ErrInvalidRoomID     = errors.New("invalid room id")
+	ErrInvalidRoomName   = errors.New("invalid room name")
+	ErrUserNotInRoom     = errors.New("user not in room")
+	ErrUserAlreadyInRoom = errors.New("user already in room")
+	ErrNameTaken         = errors.New("name taken")
+	ErrInvalidPassword   = errors.New("invalid password")
+	ErrInvalidAPIKey     = errors.New("invalid api key")
+	ErrIdentityRequired  = errors.New("user_id and user_name are required")
+	ErrInvalidIdentity   = errors.New("invalid identity")
+	ErrEmptyContent      = errors.New("message content cannot be empty")
+	ErrContentTooLong    = errors.New("message exceeds size limit")
+	ErrContentRejected   = errors.New("message rejected by content filter")
+	ErrShuttingDown      = errors.New("coordinator is shutting down")
+	ErrRoomBusy          = errors.New("room is busy, try again")
+	ErrRoomLimitReached  = errors.New("room limit reached")
+	ErrEditWindowExpired = errors.New("edit window expired")
+	ErrMuted             = errors.New("muted in this room")
+	ErrEmptyQuery        = errors.New("search query cannot be empty")
+	ErrInvalidRoomMeta   = errors.New("invalid room metadata")
+
+	// ErrDuplicateMessage is returned by SendMessage for a client message id
+	// that was already sent to the room by the same user within the dedup window.
+	ErrDuplicateMessage = errors.New("duplicate message")
+)
+
+// ContentTooLongError is returned for content over a size limit, carrying the
+// limit and the rejected size so callers can report both. It matches
+// ErrContentTooLong with errors.Is.
+type ContentTooLongError struct {
+	Limit int // bytes allowed
+	Size  int // bytes sent
+}
+
+func (e *ContentTooLongError) Error() string {
+	return fmt.Sprintf("%s: %d bytes, limit is %d", ErrContentTooLong, e.Size, e.Limit)
+}
+
+func (e *ContentTooLongError) Unwrap() error { return ErrContentTooLong }
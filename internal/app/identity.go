@@ -0,0 +1,32 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxIdentityLength is the most characters a user id or name may have
+const MaxIdentityLength = 64
+
+// NormalizeIdentity cleans a client-supplied user id or name, field being
+// its name in errors: control characters are removed and surrounding
+// whitespace trimmed. What is left must be non-empty and at most
+// MaxIdentityLength characters, or ErrInvalidIdentity is returned.
+func NormalizeIdentity(field, value string) (string, error) {
+	value = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value))
+
+	if value == "" {
+		return "", fmt.Errorf("%w: %s is blank", ErrInvalidIdentity, field)
+	}
+	if utf8.RuneCountInString(value) > MaxIdentityLength {
+		return "", fmt.Errorf("%w: %s exceeds %d characters", ErrInvalidIdentity, field, MaxIdentityLength)
+	}
+	return value, nil
+}
@@ -0,0 +1,23 @@
+package app
+
+// DefaultMaxMessageSize is the default limit, in bytes, on chat message
+// content. The server's websocket frame limit starts from the same value, so
+// the two agree unless one of them is configured.
+const DefaultMaxMessageSize = 10 * 1024
+
+// Default limits on room ids and names, in characters. Room ids also appear
+// in URLs, so they are restricted to letters, digits, '-' and '_'.
+const (
+	DefaultMaxRoomIDLength   = 64
+	DefaultMaxRoomNameLength = 100
+)
+
+// Limits on room metadata, in characters
+const (
+	MaxRoomTopicLength       = 200
+	MaxRoomDescriptionLength = 2000
+)
+
+// MaxMessageExpirySeconds is the longest a disappearing message may live, a
+// week. It also keeps the expiry well inside what a time.Duration can hold.
+const MaxMessageExpirySeconds = 7 * 24 * 60 * 60
@@ -0,0 +1,22 @@
+package broadcast
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Envelope carries one room broadcast between instances
+type Envelope struct {
+	Origin  string          `json:"origin"` // instance that produced the event
+	RoomID  string          `json:"room_id"`
+	Payload json.RawMessage `json:"payload"` // JSON-encoded event as sent to clients
+}
+
+// Broadcaster fans room events out to every instance sharing the backend
+type Broadcaster interface {
+	Publish(ctx context.Context, env Envelope) error
+	// Subscribe delivers envelopes for all rooms until ctx is cancelled or Close is called.
+	// The subscription is active by the time Subscribe returns.
+	Subscribe(ctx context.Context) (<-chan Envelope, error)
+	Close() error
+}
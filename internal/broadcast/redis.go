@@ -0,0 +1,100 @@
+package broadcast
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultChannelPrefix = "chat-room:room:"
+
+// RedisBroadcaster publishes each room's events on its own Redis pub/sub channel
+type RedisBroadcaster struct {
+	client *redis.Client
+	prefix string
+	logger *slog.Logger
+}
+
+// RedisOption configures a RedisBroadcaster
+type RedisOption func(*RedisBroadcaster)
+
+// WithLogger sets the logger for problems with received envelopes; it
+// defaults to slog.Default()
+func WithLogger(l *slog.Logger) RedisOption {
+	return func(b *RedisBroadcaster) {
+		if l != nil {
+			b.logger = l
+		}
+	}
+}
+
+func NewRedisBroadcaster(client *redis.Client, opts ...RedisOption) *RedisBroadcaster {
+	b := &RedisBroadcaster{
+		client: client,
+		prefix: defaultChannelPrefix,
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func (b *RedisBroadcaster) channel(roomID string) string {
+	return b.prefix + roomID
+}
+
+func (b *RedisBroadcaster) Publish(ctx context.Context, env Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshal envelope: %w", err)
+	}
+	return b.client.Publish(ctx, b.channel(env.RoomID), data).Err()
+}
+
+func (b *RedisBroadcaster) Subscribe(ctx context.Context) (<-chan Envelope, error) {
+	pubsub := b.client.PSubscribe(ctx, b.prefix+"*")
+
+	// wait for the subscription to be confirmed so no publish is missed after we return
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("subscribe: %w", err)
+	}
+
+	out := make(chan Envelope, 128)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				var env Envelope
+				if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+					b.logger.Warn("broadcast: dropping malformed envelope", "channel", msg.Channel, "error", err)
+					continue
+				}
+				select {
+				case out <- env:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (b *RedisBroadcaster) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,81 @@
+package broadcast
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisBroadcasterRoundTrip(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	pub := NewRedisBroadcaster(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	sub := NewRedisBroadcaster(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	defer pub.Close()
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	envs, err := sub.Subscribe(ctx)
+	require.NoError(t, err)
+
+	sent := Envelope{
+		Origin:  "instance-a",
+		RoomID:  "room_1",
+		Payload: json.RawMessage(`{"type":"new_message"}`),
+	}
+	require.NoError(t, pub.Publish(ctx, sent))
+
+	select {
+	case got := <-envs:
+		assert.Equal(t, sent.Origin, got.Origin)
+		assert.Equal(t, sent.RoomID, got.RoomID)
+		assert.JSONEq(t, string(sent.Payload), string(got.Payload))
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "envelope not received")
+	}
+
+	// cancelling the subscription closes the channel
+	cancel()
+	require.Eventually(t, func() bool {
+		_, open := <-envs
+		return !open
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestRedisBroadcasterSkipsMalformedEnvelopes(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	var logs bytes.Buffer
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	sub := NewRedisBroadcaster(client, WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	envs, err := sub.Subscribe(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, client.Publish(ctx, defaultChannelPrefix+"room_1", "{not json").Err())
+	require.NoError(t, sub.Publish(ctx, Envelope{Origin: "instance-a", RoomID: "room_1", Payload: json.RawMessage(`{}`)}))
+
+	select {
+	case got := <-envs:
+		assert.Equal(t, "instance-a", got.Origin)
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "envelope not received")
+	}
+	// the malformed one was received, and logged, before the valid one
+	assert.Contains(t, logs.String(), `"msg":"broadcast: dropping malformed envelope"`)
+	assert.Contains(t, logs.String(), `"channel":"chat-room:room:room_1"`)
+}
@@ -2,55 +2,416 @@ package coordinator
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/arturskrzydlo/chat-room/internal/app"
+	"github.com/arturskrzydlo/chat-room/internal/broadcast"
 	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/google/uuid"
+	"github.com/rivo/uniseg"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	publishTimeout        = time.Second
+	defaultEnqueueTimeout = 500 * time.Millisecond
+	defaultEmptyRoomGrace = 60 * time.Second
+	maxHistoryPage        = 100
 )
 
 type Coordinator struct {
-	rooms *roomStore
+	rooms         *roomStore
+	store         MessageStore // optional; nil means no persistence
+	historySize   int
+	queueSize     int           // per-room event queue; 0 uses the room default
+	enqueueWait   time.Duration // how long a member's message waits for queue space
+	maxMessage    int           // content limit for rooms without their own and for direct messages
+	maxRoomID     int           // characters allowed in a room id
+	maxRoomName   int           // characters allowed in a room name
+	excludeSender bool
+	emptyGrace    time.Duration
+	sweepEvery    time.Duration   // how often rooms look for being left empty; 0 uses the room default
+	idleTimeout   time.Duration   // rooms without activity for this long are closed; 0 never
+	editWindow    time.Duration   // how long after posting authors may delete their messages; 0 is unlimited
+	retention     RetentionPolicy // starting policy for new rooms
+	dedup         *dedupCache
+	online        *onlineRegistry
+	filter        ContentFilter    // nil sends messages unchanged
+	webhooks      *WebhookNotifier // nil sends no lifecycle notifications
+	ids           IDGenerator      // generated room ids and message ids
+	logger        *slog.Logger
+	running       sync.WaitGroup // room loops that have not exited yet
+	lifecycleMu   sync.Mutex     // orders room creation against Shutdown
+	closing       bool           // set by Shutdown; guarded by lifecycleMu
+	maxAuthored   int            // rooms one user may have created at once; 0 is unlimited
+	authored      map[string]int // userID -> rooms they created that still exist; guarded by lifecycleMu
+
+	// cross-instance fan-out; rooms themselves are still per instance
+	instanceID      string
+	broadcaster     broadcast.Broadcaster
+	cancelBroadcast context.CancelFunc
+}
+
+// Option configures optional Coordinator settings
+type Option func(*Coordinator)
+
+// WithLogger sets the logger for room lifecycle and broadcast events. The
+// default is a text handler on stderr at info level.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Coordinator) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// WithWebhookNotifier sends room and membership lifecycle events to n. The
+// caller still owns n and closes it after Shutdown.
+func WithWebhookNotifier(n *WebhookNotifier) Option {
+	return func(c *Coordinator) {
+		c.webhooks = n
+	}
+}
+
+// WithRoomQueueSize sets how many events each room buffers for its loop
+func WithRoomQueueSize(size int) Option {
+	return func(c *Coordinator) {
+		c.queueSize = size
+	}
+}
+
+// WithRoomSweepInterval sets how often each room prunes history past its
+// retention age and checks whether it was left empty without its close being
+// scheduled, removing itself if so
+func WithRoomSweepInterval(d time.Duration) Option {
+	return func(c *Coordinator) {
+		c.sweepEvery = d
+	}
+}
+
+// WithIdleRoomTimeout closes rooms, members and all, once nobody has joined,
+// left or sent a message in them for timeout. It is checked on each room
+// sweep, so a room may outlive the timeout by up to one sweep interval.
+// Disabled by default.
+func WithIdleRoomTimeout(timeout time.Duration) Option {
+	return func(c *Coordinator) {
+		c.idleTimeout = timeout
+	}
+}
+
+// WithRoomRetention sets the history retention policy rooms start with;
+// SetRoomRetention changes it for one room
+func WithRoomRetention(policy RetentionPolicy) Option {
+	return func(c *Coordinator) {
+		c.retention = policy
+	}
+}
+
+// WithEditWindow limits members to deleting their own messages within window
+// of posting; later attempts fail with app.ErrEditWindowExpired. The room
+// author can still remove anyone's message. A window of 0, the default,
+// never expires.
+func WithEditWindow(window time.Duration) Option {
+	return func(c *Coordinator) {
+		c.editWindow = window
+	}
+}
+
+// WithEnqueueTimeout sets how long SendMessage and SendAttachment wait for
+// room in a full room queue before failing with app.ErrRoomBusy. They are
+// called from a connection's read pump, which stalls for as long as they wait.
+func WithEnqueueTimeout(d time.Duration) Option {
+	return func(c *Coordinator) {
+		c.enqueueWait = d
+	}
+}
+
+// WithRoomHistorySize sets how many recent messages each room replays to new joiners
+func WithRoomHistorySize(size int) Option {
+	return func(c *Coordinator) {
+		c.historySize = size
+	}
 }
 
-func NewCoordinator() *Coordinator {
-	return &Coordinator{
-		rooms: newRoomStore(),
+// WithMaxMessageSize sets the largest chat message, in bytes, a room accepts
+// unless SetRoomMaxMessageSize overrides it. It also limits direct messages.
+// Messages must still fit in the server's websocket frame limit.
+func WithMaxMessageSize(size int) Option {
+	return func(c *Coordinator) {
+		if size > 0 {
+			c.maxMessage = size
+		}
+	}
+}
+
+// WithRoomLimits sets the longest room id and room name, in characters,
+// CreateRoom accepts. A limit <= 0 keeps the default.
+func WithRoomLimits(maxIDLength, maxNameLength int) Option {
+	return func(c *Coordinator) {
+		if maxIDLength > 0 {
+			c.maxRoomID = maxIDLength
+		}
+		if maxNameLength > 0 {
+			c.maxRoomName = maxNameLength
+		}
+	}
+}
+
+// WithMaxRoomsPerUser caps how many rooms one user may have created and not
+// yet seen deleted. CreateRoom fails with app.ErrRoomLimitReached beyond it;
+// 0, the default, is unlimited.
+func WithMaxRoomsPerUser(n int) Option {
+	return func(c *Coordinator) {
+		c.maxAuthored = n
+	}
+}
+
+// WithExcludeSender stops chat messages from being echoed back to their sender,
+// for clients that render their own messages optimistically
+func WithExcludeSender(exclude bool) Option {
+	return func(c *Coordinator) {
+		c.excludeSender = exclude
+	}
+}
+
+// WithContentFilter runs every chat message through f before it is stored or
+// broadcast; f may mask it or reject it with app.ErrContentRejected
+func WithContentFilter(f ContentFilter) Option {
+	return func(c *Coordinator) {
+		c.filter = f
+	}
+}
+
+// WithDedupWindow sets how long client message ids are remembered for
+// dropping resent messages. A window <= 0 disables deduplication.
+func WithDedupWindow(window time.Duration) Option {
+	return func(c *Coordinator) {
+		if window <= 0 {
+			c.dedup = nil
+			return
+		}
+		c.dedup = newDedupCache(window)
+	}
+}
+
+// WithEmptyRoomGrace sets how long an empty room is kept, with its history,
+// before it is deleted. Anyone joining within the grace period keeps it open;
+// a grace of 0 deletes rooms as soon as they empty.
+func WithEmptyRoomGrace(grace time.Duration) Option {
+	return func(c *Coordinator) {
+		c.emptyGrace = grace
+	}
+}
+
+// WithBroadcaster fans room broadcasts out to other instances sharing b.
+// Each instance delivers remote events to its local members of a room with the
+// same ID and ignores the events it published itself.
+func WithBroadcaster(b broadcast.Broadcaster) Option {
+	return func(c *Coordinator) {
+		c.broadcaster = b
+	}
+}
+
+// WithIDGenerator sets how generated room ids and message ids are made. The
+// default is UUIDGenerator.
+func WithIDGenerator(g IDGenerator) Option {
+	return func(c *Coordinator) {
+		if g != nil {
+			c.ids = g
+		}
 	}
 }
 
+func NewCoordinator(opts ...Option) *Coordinator {
+	c := &Coordinator{
+		rooms:       newRoomStore(),
+		historySize: defaultHistorySize,
+		maxMessage:  app.DefaultMaxMessageSize,
+		maxRoomID:   app.DefaultMaxRoomIDLength,
+		maxRoomName: app.DefaultMaxRoomNameLength,
+		emptyGrace:  defaultEmptyRoomGrace,
+		enqueueWait: defaultEnqueueTimeout,
+		dedup:       newDedupCache(defaultDedupWindow),
+		online:      newOnlineRegistry(),
+		authored:    make(map[string]int),
+		ids:         UUIDGenerator{},
+		instanceID:  uuid.NewString(),
+		logger:      slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.broadcaster != nil {
+		c.startBroadcastSubscriber()
+	}
+	return c
+}
+
+// NewCoordinatorWithStore creates a Coordinator that persists every chat message
+// to store and seeds new rooms' history from it
+func NewCoordinatorWithStore(store MessageStore, opts ...Option) *Coordinator {
+	c := NewCoordinator(opts...)
+	c.store = store
+	return c
+}
+
+// CreateRoomWithGeneratedID creates a room, like CreateRoom, under an id
+// from the coordinator's IDGenerator and returns that id
+func (c *Coordinator) CreateRoomWithGeneratedID(
+	authorID string,
+	roomName string,
+	password string,
+	send chan<- interface{},
+) (string, error) {
+	roomID := c.ids.NewID()
+	if err := c.CreateRoom(roomID, authorID, roomName, password, send); err != nil {
+		return "", err
+	}
+	return roomID, nil
+}
+
+// validateRoom checks a new room's id and name against the coordinator's
+// limits. Ids may only hold ASCII letters, digits, '-' and '_', so they can
+// be used in URLs unescaped; names may hold anything but control characters.
+func (c *Coordinator) validateRoom(roomID, roomName string) error {
+	if utf8.RuneCountInString(roomID) > c.maxRoomID {
+		return fmt.Errorf("%w: longer than %d characters", app.ErrInvalidRoomID, c.maxRoomID)
+	}
+	for _, r := range roomID {
+		if !isRoomIDChar(r) {
+			return fmt.Errorf("%w: %q is not allowed, use letters, digits, '-' or '_'", app.ErrInvalidRoomID, r)
+		}
+	}
+
+	if utf8.RuneCountInString(roomName) > c.maxRoomName {
+		return fmt.Errorf("%w: longer than %d characters", app.ErrInvalidRoomName, c.maxRoomName)
+	}
+	if strings.IndexFunc(roomName, unicode.IsControl) >= 0 {
+		return fmt.Errorf("%w: contains control characters", app.ErrInvalidRoomName)
+	}
+	return nil
+}
+
+func isRoomIDChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_'
+}
+
+// CreateRoom creates a room and auto-joins its author. A non-empty password
+// makes the room private: joiners must then supply the same password. An
+// empty roomID has one generated, as CreateRoomWithGeneratedID does; the
+// author finds it in the new_room event.
 func (c *Coordinator) CreateRoom(
 	roomID string,
 	authorID string,
 	roomName string,
+	password string,
 	send chan<- interface{},
 ) error {
-	if roomID == "" || roomName == "" {
-		return fmt.Errorf("room_id and room_name are required")
+	if roomID == "" {
+		_, err := c.CreateRoomWithGeneratedID(authorID, roomName, password, send)
+		return err
+	}
+	if roomName == "" {
+		return app.ErrRoomRequired
+	}
+	return c.createRoom(roomID, &User{ID: authorID, Name: authorID}, roomName, password, send)
+}
+
+// createRoom registers and starts a room with author as its first member.
+// It fails with app.ErrRoomExists if the id is taken, however close the race.
+func (c *Coordinator) createRoom(roomID string, author *User, roomName, password string, send chan<- interface{}) error {
+	authorID := author.ID
+	if err := c.validateRoom(roomID, roomName); err != nil {
+		return err
 	}
 
 	if _, exists := c.rooms.Load(roomID); exists {
-		return fmt.Errorf("room with id %s already exists", roomID)
+		return fmt.Errorf("%w: %s", app.ErrRoomExists, roomID)
 	}
 
-	room := NewRoom(roomID, roomName, authorID)
-	c.rooms.Store(roomID, room)
+	opts := []RoomOption{
+		WithHistorySize(c.historySize),
+		WithEmptyGrace(c.emptyGrace, c.forgetRoom),
+		WithPanicHandler(c.failRoom),
+		WithSweepInterval(c.sweepEvery),
+		WithIdleTimeout(c.idleTimeout, c.reapIdleRoom),
+		WithEventQueueSize(c.queueSize),
+	}
+	if c.broadcaster != nil {
+		opts = append(opts, WithPublisher(c.publisher(roomID)))
+	}
+	if c.store != nil {
+		history, err := c.store.Load(roomID, c.historySize)
+		if err != nil {
+			return fmt.Errorf("load history for room %s: %w", roomID, err)
+		}
+		opts = append(opts, WithHistory(history), WithPersister(c.persister(roomID)))
+	}
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("hash room password: %w", err)
+		}
+		opts = append(opts, WithPasswordHash(hash))
+	}
+	// after WithHistory, so stored history is pruned too
+	opts = append(opts, WithRetention(c.retention))
 
-	go room.Run()
+	room := NewRoom(roomID, roomName, authorID, opts...)
+
+	// the check above is only a fast path; another create for the same id may
+	// have won the race while the password was being hashed
+	c.lifecycleMu.Lock()
+	if c.closing {
+		c.lifecycleMu.Unlock()
+		return app.ErrShuttingDown
+	}
+	if c.maxAuthored > 0 && c.authored[authorID] >= c.maxAuthored {
+		c.lifecycleMu.Unlock()
+		return fmt.Errorf("%w: %d rooms", app.ErrRoomLimitReached, c.maxAuthored)
+	}
+	if !c.rooms.StoreIfAbsent(roomID, room) {
+		c.lifecycleMu.Unlock()
+		return fmt.Errorf("%w: %s", app.ErrRoomExists, roomID)
+	}
+	c.authored[authorID]++
+	c.running.Add(1)
+	c.lifecycleMu.Unlock()
+
+	go func() {
+		defer c.running.Done()
+		room.Run()
+	}()
 
 	// Auto-join author into the room
-	authorUser := &User{ID: authorID, Name: authorID}
 	roomClient := &RoomClient{
 		UserID: authorID,
-		User:   authorUser,
+		User:   author,
 		Send:   send,
 	}
 	room.EnqueueJoin(roomClient)
 
-	log.Printf("CreateRoom: roomID=%s author=%s", roomID, authorID)
+	c.logger.Info("room created", "event", "create_room", "room_id", roomID, "user_id", authorID)
 
-	send <- messages.NewRoom(roomID, authorID, roomName)
+	created := messages.NewRoom(roomID, authorID, roomName)
+	c.notify(created)
+	send <- created
 
-	log.Printf("CreateRoom: sent new_room to author")
+	c.logger.Debug("sent new_room to author", "room_id", roomID, "user_id", authorID)
 
 	return nil
 }
@@ -60,24 +421,114 @@ func (c *Coordinator) GetRoom(roomID string) *Room {
 	return r
 }
 
+// IsRoomPrivate reports whether joining roomID takes a password; unknown rooms
+// are not private
+func (c *Coordinator) IsRoomPrivate(roomID string) bool {
+	room := c.GetRoom(roomID)
+	return room != nil && room.IsPrivate()
+}
+
+// RoomCount returns the number of active rooms
+func (c *Coordinator) RoomCount() int {
+	return c.rooms.Len()
+}
+
+// RoomState is a read-only view of one room's membership for debugging
+type RoomState struct {
+	RoomID  string   `json:"room_id"`
+	UserIDs []string `json:"user_ids"`
+}
+
+// RoomStates reports every room and the users currently in it
+func (c *Coordinator) RoomStates() []RoomState {
+	rooms := c.rooms.Snapshot()
+	states := make([]RoomState, 0, len(rooms))
+	for _, r := range rooms {
+		users := r.GetUsers()
+		userIDs := make([]string, 0, len(users))
+		for userID := range users {
+			userIDs = append(userIDs, userID)
+		}
+		sort.Strings(userIDs)
+		states = append(states, RoomState{RoomID: r.ID, UserIDs: userIDs})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].RoomID < states[j].RoomID })
+	return states
+}
+
+// ListRooms returns summaries of the active rooms that match filter
+func (c *Coordinator) ListRooms(filter RoomFilter) []RoomSummary {
+	summaries := make([]RoomSummary, 0)
+	c.rooms.Range(func(r *Room) bool {
+		if s := r.Summary(); filter.matches(s) {
+			summaries = append(summaries, s)
+		}
+		return true
+	})
+	return summaries
+}
+
+// JoinOrCreateRoom joins roomID, first creating it with the joiner as author
+// if it doesn't exist. The room is named after its id and guarded by
+// password, if one is given. When two callers race to create the same room
+// one wins and the other joins it as usual.
+func (c *Coordinator) JoinOrCreateRoom(roomID, userID, userName, password string, send chan<- interface{}) error {
+	if c.GetRoom(roomID) == nil {
+		if userID == "" || userName == "" {
+			return app.ErrIdentityRequired
+		}
+		var err error
+		if userID, err = app.NormalizeIdentity("user_id", userID); err != nil {
+			return err
+		}
+		if userName, err = app.NormalizeIdentity("user_name", userName); err != nil {
+			return err
+		}
+		err = c.createRoom(roomID, &User{ID: userID, Name: userName}, roomID, password, send)
+		if !errors.Is(err, app.ErrRoomExists) {
+			return err
+		}
+	}
+	return c.JoinRoom(roomID, userID, userName, password, send)
+}
+
 func (c *Coordinator) JoinRoom(
 	roomID string,
 	userID string,
 	userName string,
+	password string,
 	send chan<- interface{},
 ) error {
 	room := c.GetRoom(roomID)
 	if room == nil {
-		return fmt.Errorf("room %s not found", roomID)
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
 	}
 
 	if userID == "" || userName == "" {
-		return fmt.Errorf("user_id and user_name are required")
+		return app.ErrIdentityRequired
+	}
+	var err error
+	if userID, err = app.NormalizeIdentity("user_id", userID); err != nil {
+		return err
+	}
+	if userName, err = app.NormalizeIdentity("user_name", userName); err != nil {
+		return err
+	}
+
+	if !room.CheckPassword(password) {
+		return fmt.Errorf("%w for room %s", app.ErrInvalidPassword, roomID)
 	}
 
 	users := room.GetUsers()
 	if _, exists := users[userID]; exists {
-		return fmt.Errorf("user %s already in room", userID)
+		return fmt.Errorf("%w: %s", app.ErrUserAlreadyInRoom, userID)
+	}
+	if room.UniqueNames() {
+		for _, u := range users {
+			if strings.EqualFold(u.Name, userName) {
+				return fmt.Errorf("%w: %s", app.ErrNameTaken, userName)
+			}
+		}
 	}
 
 	user := &User{ID: userID, Name: userName}
@@ -90,6 +541,7 @@ func (c *Coordinator) JoinRoom(
 	room.EnqueueJoin(roomClient)
 	joinMessage := messages.NewUserJoinedEvent(roomID, userID, userName)
 	room.EnqueueBroadcast(joinMessage)
+	c.notify(joinMessage)
 
 	return nil
 }
@@ -100,74 +552,946 @@ func (c *Coordinator) LeaveRoom(
 ) error {
 	room := c.GetRoom(roomID)
 	if room == nil {
-		return fmt.Errorf("room %s not found", roomID)
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
 	}
 
 	users := room.GetUsers()
 	user, exists := users[userID]
 	if !exists {
-		return fmt.Errorf("user %s not in room %s", userID, roomID)
+		return fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, userID, roomID)
 	}
 
-	room.EnqueueLeave(userID)
+	room.Leave(userID)
 	leaveMessage := messages.NewUserLeftEvent(roomID, userID, user.Name)
 	room.EnqueueBroadcast(leaveMessage)
+	c.notify(leaveMessage)
+
+	return nil
+}
+
+// DetachClient keeps userID in the room but stops delivering to their channel,
+// e.g. while a dropped connection waits to be resumed
+func (c *Coordinator) DetachClient(
+	roomID string,
+	userID string,
+) error {
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	if _, exists := room.GetUsers()[userID]; !exists {
+		return fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, userID, roomID)
+	}
 
-	c.deleteRoomIfEmpty(roomID)
+	room.EnqueueDetach(userID)
 
 	return nil
 }
 
-func (c *Coordinator) SendMessage(
+// ReattachClient resumes delivery to a detached member on a new send channel
+// without broadcasting a join
+func (c *Coordinator) ReattachClient(
 	roomID string,
 	userID string,
+	send chan<- interface{},
+) error {
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	user, exists := room.GetUsers()[userID]
+	if !exists {
+		return fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, userID, roomID)
+	}
+
+	room.EnqueueAttach(&RoomClient{
+		UserID: userID,
+		User:   user,
+		Send:   send,
+	})
+
+	return nil
+}
+
+// KickUser removes targetID from the room. Only the room author may kick.
+// The kick event is broadcast before the leave so the target sees it too.
+func (c *Coordinator) KickUser(
+	roomID string,
+	requesterID string,
+	targetID string,
+) error {
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	if !room.CanModerate(requesterID) {
+		return fmt.Errorf("only the room author or a moderator can kick users")
+	}
+
+	if targetID == requesterID {
+		return fmt.Errorf("cannot kick yourself")
+	}
+	if targetID == room.Author() {
+		return fmt.Errorf("cannot kick the room author")
+	}
+
+	users := room.GetUsers()
+	target, exists := users[targetID]
+	if !exists {
+		return fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, targetID, roomID)
+	}
+
+	kicked := messages.NewUserKickedEvent(roomID, targetID, target.Name, requesterID)
+	room.EnqueueBroadcast(kicked)
+	room.EnqueueLeave(targetID)
+	c.notify(kicked)
+
+	c.logger.Info("user kicked", "event", "kick", "room_id", roomID, "user_id", targetID, "by", requesterID)
+
+	return nil
+}
+
+// GrantModerator makes targetID, a member of the room, a moderator on behalf
+// of requesterID, who must be the room author or a moderator. Moderators may
+// kick members other than the author and delete any message. The room
+// broadcasts a ModeratorChangedEvent.
+func (c *Coordinator) GrantModerator(roomID, requesterID, targetID string) error {
+	return c.setModerator(roomID, requesterID, targetID, true)
+}
+
+// RevokeModerator takes the moderator role from targetID; the same members
+// who may grant it may revoke it
+func (c *Coordinator) RevokeModerator(roomID, requesterID, targetID string) error {
+	return c.setModerator(roomID, requesterID, targetID, false)
+}
+
+func (c *Coordinator) setModerator(roomID, requesterID, targetID string, grant bool) error {
+	if targetID == "" {
+		return fmt.Errorf("target_user_id is required")
+	}
+
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+	if err := room.SetModerator(requesterID, targetID, grant); err != nil {
+		return err
+	}
+
+	c.logger.Info("moderator changed", "event", "moderator", "room_id", roomID, "user_id", targetID, "by", requesterID, "granted", grant)
+	return nil
+}
+
+// SetRoomMeta replaces the room's topic and description on behalf of
+// requesterID, who must be the room author or a moderator; empty values clear
+// them. Values over the app limits or containing control characters other
+// than newlines in the description are refused with app.ErrInvalidRoomMeta.
+// The room broadcasts a RoomMetaUpdatedEvent.
+func (c *Coordinator) SetRoomMeta(roomID, requesterID, topic, description string) error {
+	topic, description = strings.TrimSpace(topic), strings.TrimSpace(description)
+	if err := validateRoomMeta(topic, description); err != nil {
+		return err
+	}
+
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+	if err := room.SetMeta(requesterID, topic, description); err != nil {
+		return err
+	}
+
+	c.logger.Info("room metadata changed", "event", "room_meta", "room_id", roomID, "by", requesterID)
+	return nil
+}
+
+func validateRoomMeta(topic, description string) error {
+	if utf8.RuneCountInString(topic) > app.MaxRoomTopicLength {
+		return fmt.Errorf("%w: topic longer than %d characters", app.ErrInvalidRoomMeta, app.MaxRoomTopicLength)
+	}
+	if strings.IndexFunc(topic, unicode.IsControl) >= 0 {
+		return fmt.Errorf("%w: topic contains control characters", app.ErrInvalidRoomMeta)
+	}
+	if utf8.RuneCountInString(description) > app.MaxRoomDescriptionLength {
+		return fmt.Errorf("%w: description longer than %d characters", app.ErrInvalidRoomMeta, app.MaxRoomDescriptionLength)
+	}
+	if strings.IndexFunc(description, func(r rune) bool { return r != '\n' && unicode.IsControl(r) }) >= 0 {
+		return fmt.Errorf("%w: description contains control characters", app.ErrInvalidRoomMeta)
+	}
+	return nil
+}
+
+// MuteUser stops targetID posting messages and attachments to the room,
+// without removing them, on behalf of requesterID, who must be the room
+// author or a moderator. Sends from a muted user fail with app.ErrMuted. The
+// room broadcasts a UserMutedEvent.
+func (c *Coordinator) MuteUser(roomID, requesterID, targetID string) error {
+	return c.setMuted(roomID, requesterID, targetID, true)
+}
+
+// UnmuteUser lets a muted user post again; the room broadcasts a
+// UserUnmutedEvent
+func (c *Coordinator) UnmuteUser(roomID, requesterID, targetID string) error {
+	return c.setMuted(roomID, requesterID, targetID, false)
+}
+
+func (c *Coordinator) setMuted(roomID, requesterID, targetID string, muted bool) error {
+	if targetID == "" {
+		return fmt.Errorf("target_user_id is required")
+	}
+
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+	if err := room.SetMuted(requesterID, targetID, muted); err != nil {
+		return err
+	}
+
+	c.logger.Info("mute changed", "event", "mute", "room_id", roomID, "user_id", targetID, "by", requesterID, "muted", muted)
+	return nil
+}
+
+// DeleteMessage replaces a message with a tombstone in the room history and
+// tells the room. Only the message's author, the room author or a moderator
+// may delete it, and only while it is still in the history buffer.
+func (c *Coordinator) DeleteMessage(
+	roomID string,
+	requesterID string,
+	messageID string,
+) error {
+	if messageID == "" {
+		return fmt.Errorf("message_id is required")
+	}
+
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	if _, inRoom := room.GetUsers()[requesterID]; !inRoom {
+		return fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, requesterID, roomID)
+	}
+
+	msg, found := room.FindMessage(messageID)
+	if !found {
+		return fmt.Errorf("message %s not found", messageID)
+	}
+
+	moderator := room.CanModerate(requesterID)
+	if requesterID != msg.UserID && !moderator {
+		return fmt.Errorf("only the message author, room author or a moderator can delete messages")
+	}
+	if requesterID == msg.UserID && !moderator && !c.withinEditWindow(msg) {
+		return fmt.Errorf("%w: message %s is older than %s", app.ErrEditWindowExpired, messageID, c.editWindow)
+	}
+
+	if msg.Deleted {
+		return nil
+	}
+
+	// the room loop tombstones the history entry as it broadcasts the event
+	room.EnqueueBroadcast(messages.NewMessageDeletedEvent(roomID, messageID, requesterID))
+
+	c.logger.Info("message deleted", "event", "delete_message", "room_id", roomID, "message_id", messageID, "user_id", requesterID)
+
+	return nil
+}
+
+// React toggles userID's emoji reaction on a message still in the room history.
+// The room broadcasts a ReactionEvent saying whether it was added or removed.
+func (c *Coordinator) React(
+	roomID string,
+	userID string,
+	messageID string,
+	emoji string,
+) error {
+	if messageID == "" {
+		return fmt.Errorf("message_id is required")
+	}
+
+	if uniseg.GraphemeClusterCount(emoji) != 1 {
+		return fmt.Errorf("emoji must be a single character")
+	}
+
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	if _, inRoom := room.GetUsers()[userID]; !inRoom {
+		return fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, userID, roomID)
+	}
+
+	if _, found := room.FindMessage(messageID); !found {
+		return fmt.Errorf("message %s not found", messageID)
+	}
+
+	room.EnqueueReact(userID, messageID, emoji)
+
+	return nil
+}
+
+// RegisterClient marks one of userID's connections online so it can receive
+// direct messages. The user's first connection is announced to presence
+// subscribers.
+func (c *Coordinator) RegisterClient(userID string, send chan<- interface{}) {
+	c.online.Add(userID, send)
+}
+
+// UnregisterClient removes a connection registered with RegisterClient.
+// Presence subscribers hear the user went offline once the last one is gone.
+func (c *Coordinator) UnregisterClient(userID string, send chan<- interface{}) {
+	c.online.Remove(userID, send)
+}
+
+// SubscribePresence sends a PresenceEvent to send whenever any user comes
+// online or goes offline
+func (c *Coordinator) SubscribePresence(send chan<- interface{}) {
+	c.online.Subscribe(send)
+}
+
+func (c *Coordinator) UnsubscribePresence(send chan<- interface{}) {
+	c.online.Unsubscribe(send)
+}
+
+// SendDirect delivers a 1:1 message to every connection of toID and echoes it
+// to every connection of fromID. It fails if toID has no connection online.
+func (c *Coordinator) SendDirect(
+	fromID string,
+	fromName string,
+	toID string,
 	content string,
 ) error {
+	if toID == "" {
+		return fmt.Errorf("to_user_id is required")
+	}
+
+	if toID == fromID {
+		return fmt.Errorf("cannot send a direct message to yourself")
+	}
+
 	if content == "" {
-		return fmt.Errorf("message content cannot be empty")
+		return app.ErrEmptyContent
+	}
+
+	if len(content) > c.maxMessage {
+		return &app.ContentTooLongError{Limit: c.maxMessage, Size: len(content)}
+	}
+
+	recipients := c.online.Conns(toID)
+	if len(recipients) == 0 {
+		return fmt.Errorf("user %s is offline", toID)
+	}
+
+	ev := messages.NewDirectMessageEvent(fromID, fromName, toID, content)
+	for _, send := range recipients {
+		deliver(send, ev)
+	}
+	for _, send := range c.online.Conns(fromID) {
+		deliver(send, ev)
 	}
 
-	// 10KB limit
-	if len(content) > 10*1024 {
-		return fmt.Errorf("message exceeds 10KB limit")
+	return nil
+}
+
+// RenameUser changes the user's display name in every room they are in.
+// Each room applies the rename in its event loop and broadcasts UserRenamedEvent.
+func (c *Coordinator) RenameUser(
+	userID string,
+	newName string,
+) error {
+	if userID == "" || newName == "" {
+		return fmt.Errorf("user_id and new_name are required")
+	}
+	newName, err := app.NormalizeIdentity("new_name", newName)
+	if err != nil {
+		return err
 	}
 
+	c.rooms.Range(func(r *Room) bool {
+		if _, inRoom := r.GetUsers()[userID]; inRoom {
+			r.EnqueueRename(userID, newName)
+		}
+		return true
+	})
+
+	return nil
+}
+
+// ListMembers returns the users currently in a room, ordered by user ID
+func (c *Coordinator) ListMembers(roomID string) ([]messages.Member, error) {
 	room := c.GetRoom(roomID)
 	if room == nil {
-		return fmt.Errorf("room %s not found", roomID)
+		return nil, fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	users := room.GetUsers()
+	members := make([]messages.Member, 0, len(users))
+	for _, u := range users {
+		members = append(members, messages.Member{UserID: u.ID, UserName: u.Name})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
+
+	return members, nil
+}
+
+// GetHistory pages backwards through the room's history buffer: it returns up
+// to limit messages with a sequence number below beforeSeq, newest first.
+// A beforeSeq of 0 starts from the newest message; limit is capped at 100.
+func (c *Coordinator) GetHistory(roomID string, beforeSeq uint64, limit int) ([]messages.RoomMessageEvent, error) {
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return nil, fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	if limit <= 0 || limit > maxHistoryPage {
+		limit = maxHistoryPage
+	}
+
+	page := room.HistoryBefore(beforeSeq, limit)
+	for i := range page {
+		page[i].Historical = true
+	}
+	return page, nil
+}
+
+// SearchMessages returns the room's buffered messages whose text contains
+// query, ignoring case, newest first. Like GetHistory it only sees the
+// history buffer, and limit is capped the same way.
+func (c *Coordinator) SearchMessages(roomID, query string, limit int) ([]messages.RoomMessageEvent, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, app.ErrEmptyQuery
+	}
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return nil, fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	if limit <= 0 || limit > maxHistoryPage {
+		limit = maxHistoryPage
+	}
+
+	found := room.SearchHistory(query, limit)
+	for i := range found {
+		found[i].Historical = true
+	}
+	return found, nil
+}
+
+// SendMessage broadcasts content to the room. A non-empty clientMsgID is echoed
+// in the broadcast and makes the send idempotent: repeating it within the
+// dedup window returns app.ErrDuplicateMessage without broadcasting again.
+// A non-empty parentMessageID makes the message a reply; the parent must still
+// be in the room history.
+func (c *Coordinator) SendMessage(
+	roomID string,
+	userID string,
+	content string,
+	clientMsgID string,
+	parentMessageID string,
+) error {
+	return c.SendDisappearingMessage(roomID, userID, content, clientMsgID, parentMessageID, 0)
+}
+
+// SendDisappearingMessage is SendMessage for a message that expires
+// expiresInSeconds after it is sent, when the room tells members with a
+// MessageExpiredEvent and drops it from history. Such messages are never
+// persisted. 0 sends an ordinary message.
+func (c *Coordinator) SendDisappearingMessage(
+	roomID string,
+	userID string,
+	content string,
+	clientMsgID string,
+	parentMessageID string,
+	expiresInSeconds int,
+) error {
+	return c.sendMessage(roomID, userID, content, clientMsgID, parentMessageID, expiresInSeconds, nil)
+}
+
+// SendMessageAcked is SendDisappearingMessage that also has the room send the
+// sender a MessageAck, carrying requestID, with the message's id and sequence
+// number as soon as it is assigned. The ack goes to the sender's channel in
+// the room, ahead of the broadcast.
+func (c *Coordinator) SendMessageAcked(
+	roomID string,
+	userID string,
+	content string,
+	clientMsgID string,
+	parentMessageID string,
+	expiresInSeconds int,
+	requestID string,
+) error {
+	return c.sendMessage(roomID, userID, content, clientMsgID, parentMessageID, expiresInSeconds, &requestID)
+}
+
+func (c *Coordinator) sendMessage(
+	roomID string,
+	userID string,
+	content string,
+	clientMsgID string,
+	parentMessageID string,
+	expiresInSeconds int,
+	ackRequestID *string,
+) (err error) {
+	if expiresInSeconds < 0 {
+		return fmt.Errorf("expires_in_seconds must not be negative")
+	}
+	if expiresInSeconds > app.MaxMessageExpirySeconds {
+		return fmt.Errorf("expires_in_seconds must be at most %d", app.MaxMessageExpirySeconds)
+	}
+	if content == "" {
+		return app.ErrEmptyContent
+	}
+
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	if err := c.checkSize(room, content); err != nil {
+		return err
 	}
 
 	users := room.GetUsers()
 	user, exists := users[userID]
 	if !exists {
-		return fmt.Errorf("user %s not in room %s", userID, roomID)
+		return fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, userID, roomID)
+	}
+	if room.IsMuted(userID) {
+		return fmt.Errorf("%w: %s in %s", app.ErrMuted, userID, roomID)
+	}
+
+	if parentMessageID != "" {
+		if _, found := room.FindMessage(parentMessageID); !found {
+			return fmt.Errorf("parent not found")
+		}
+	}
+
+	if c.filter != nil {
+		filtered, ok := c.filter.Filter(content)
+		if !ok {
+			return app.ErrContentRejected
+		}
+		content = filtered
+	}
+
+	if clientMsgID != "" && c.dedup != nil {
+		key := dedupKey{roomID: roomID, userID: userID, clientMsgID: clientMsgID}
+		if c.dedup.Seen(key) {
+			return app.ErrDuplicateMessage
+		}
+		// only a message that actually went out counts as sent
+		defer func() {
+			if err != nil {
+				c.dedup.Forget(key)
+			}
+		}()
 	}
 
 	msg := messages.NewRoomMessageEvent(roomID, userID, user.Name, content)
-	room.EnqueueBroadcast(msg)
+	msg.MessageID = c.ids.NewID()
+	msg.Message.ClientMsgID = clientMsgID
+	msg.Message.ParentMessageID = parentMessageID
+	msg.Message.ExpiresInSeconds = expiresInSeconds
+
+	// the room persists the message once it is sequenced, so one a busy room
+	// refuses never turns up in stored history
+	exclude := ""
+	if c.excludeSender {
+		exclude = userID
+	}
+	if ackRequestID != nil {
+		err = room.EnqueueAckedMessageTimeout(msg, exclude, *ackRequestID, c.enqueueWait)
+	} else {
+		err = room.EnqueueBroadcastTimeout(msg, exclude, c.enqueueWait)
+	}
+	return err
+}
+
+// SendSystemMessage posts content to a room on behalf of an integration such
+// as a CI bot. The sender isn't a member, so there is no membership check, but
+// the room's size limit still applies, as does the wait for queue space: a
+// room too busy to take the message fails with app.ErrRoomBusy.
+func (c *Coordinator) SendSystemMessage(roomID, botName, content string) error {
+	if content == "" {
+		return app.ErrEmptyContent
+	}
+
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+	if err := c.checkSize(room, content); err != nil {
+		return err
+	}
+
+	msg := messages.NewRoomMessageEvent(roomID, "", botName, content)
+	msg.MessageID = c.ids.NewID()
+	msg.System = true
+	return room.EnqueueBroadcastTimeout(msg, "", c.enqueueWait)
+}
+
+// BroadcastSystemAnnouncement sends content to the members of every room on
+// this instance. Each room gets its own copy on its own queue, and a room
+// whose queue is full is skipped rather than waited on; the announcement is
+// not sent to other instances.
+func (c *Coordinator) BroadcastSystemAnnouncement(content string) error {
+	if content == "" {
+		return app.ErrEmptyContent
+	}
+	if len(content) > c.maxMessage {
+		return &app.ContentTooLongError{Limit: c.maxMessage, Size: len(content)}
+	}
+
+	rooms, busy := 0, 0
+	c.rooms.Range(func(r *Room) bool {
+		if err := r.EnqueueAnnouncement(messages.NewSystemAnnouncementEvent(r.ID, content)); err != nil {
+			busy++
+		} else {
+			rooms++
+		}
+		return true
+	})
+	c.logger.Info("system announcement sent", "event", "announce", "rooms", rooms, "skipped_busy", busy)
+	return nil
+}
+
+// withinEditWindow reports whether msg was posted recently enough to be
+// changed by its author. Messages without a readable time are let through.
+func (c *Coordinator) withinEditWindow(msg messages.RoomMessageEvent) bool {
+	if c.editWindow <= 0 {
+		return true
+	}
+	posted, ok := postedAt(msg)
+	if !ok {
+		return true
+	}
+	return time.Since(posted) <= c.editWindow
+}
+
+// checkSize enforces the room's message size limit, or the coordinator's when
+// the room has none
+func (c *Coordinator) checkSize(room *Room, content string) error {
+	limit := room.MaxMessageSize()
+	if limit == 0 {
+		limit = c.maxMessage
+	}
+	if len(content) > limit {
+		return &app.ContentTooLongError{Limit: limit, Size: len(content)}
+	}
+	return nil
+}
+
+// IssueRoomAPIKey generates a new integration API key for a room, replacing
+// any earlier one. The key is returned once; the room keeps only its hash.
+func (c *Coordinator) IssueRoomAPIKey(roomID string) (string, error) {
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return "", fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate api key: %w", err)
+	}
+	key := hex.EncodeToString(buf)
+	room.SetAPIKey(key)
+	return key, nil
+}
+
+// VerifyRoomAPIKey checks an integration API key against the room's
+func (c *Coordinator) VerifyRoomAPIKey(roomID, key string) error {
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+	if !room.CheckAPIKey(key) {
+		return app.ErrInvalidAPIKey
+	}
+	return nil
+}
+
+// MarkRead records that userID has read roomID up to seq and sends the room a
+// ReadReceiptEvent
+func (c *Coordinator) MarkRead(roomID, userID string, seq uint64) error {
+	if seq == 0 {
+		return fmt.Errorf("seq is required")
+	}
+
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	if _, inRoom := room.GetUsers()[userID]; !inRoom {
+		return fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, userID, roomID)
+	}
 
+	return room.EnqueueMarkRead(userID, seq)
+}
+
+// BroadcastTyping tells the other room members that userID started or stopped typing.
+// Typing events are ephemeral: not kept in history and dropped under backpressure.
+func (c *Coordinator) BroadcastTyping(
+	roomID string,
+	userID string,
+	userName string,
+	isTyping bool,
+) error {
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	if _, exists := room.GetUsers()[userID]; !exists {
+		return fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, userID, roomID)
+	}
+
+	room.EnqueueEphemeral(messages.NewTypingEvent(roomID, userID, userName, isTyping), userID)
+
+	return nil
+}
+
+// SetRoomUniqueNames makes JoinRoom refuse, with app.ErrNameTaken, a joiner
+// whose name matches a present member's, ignoring case. Renames aren't checked.
+func (c *Coordinator) SetRoomUniqueNames(roomID string, unique bool) error {
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	room.SetUniqueNames(unique)
+	return nil
+}
+
+// SetRoomRetention changes how much history a room keeps; see
+// RetentionPolicy. Messages the new policy rules out go straight away.
+func (c *Coordinator) SetRoomRetention(roomID string, policy RetentionPolicy) error {
+	if policy.MaxMessages < 0 || policy.MaxAge < 0 {
+		return fmt.Errorf("retention limits cannot be negative")
+	}
+
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	room.SetRetention(policy)
 	return nil
 }
 
-func (c *Coordinator) deleteRoomIfEmpty(roomID string) {
+// SetRoomMaxMessageSize overrides the coordinator-wide message size limit for
+// one room, in either direction. A size of 0 restores the default.
+func (c *Coordinator) SetRoomMaxMessageSize(roomID string, size int) error {
+	if size < 0 {
+		return fmt.Errorf("max message size cannot be negative")
+	}
+
 	room := c.GetRoom(roomID)
 	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	room.SetMaxMessageSize(size)
+	return nil
+}
+
+// SendAttachment broadcasts an image attachment to the room, sender included.
+// Attachments are not kept in history.
+func (c *Coordinator) SendAttachment(
+	roomID string,
+	userID string,
+	userName string,
+	contentType string,
+	data []byte,
+) error {
+	room := c.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+
+	if _, exists := room.GetUsers()[userID]; !exists {
+		return fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, userID, roomID)
+	}
+	if room.IsMuted(userID) {
+		return fmt.Errorf("%w: %s in %s", app.ErrMuted, userID, roomID)
+	}
+
+	return room.EnqueueBroadcastTimeout(messages.NewAttachmentEvent(roomID, userID, userName, contentType, data), "", c.enqueueWait)
+}
+
+// persister returns the hook a room stores its chat messages with. It runs on
+// the room's persister goroutine, and members get the message whatever the
+// store makes of it, so a store failure is only logged.
+func (c *Coordinator) persister(roomID string) func(messages.RoomMessageEvent) {
+	return func(ev messages.RoomMessageEvent) {
+		if err := c.store.Append(roomID, ev); err != nil {
+			c.logger.Error("persist message", "event", "send_message", "room_id", roomID, "user_id", ev.UserID, "error", err)
+		}
+	}
+}
+
+// publisher returns the hook a room uses to forward its broadcasts to other
+// instances. It runs on the room's publisher goroutine, not the room loop.
+func (c *Coordinator) publisher(roomID string) func(msg interface{}) {
+	return func(msg interface{}) {
+		payload, err := json.Marshal(msg)
+		if err != nil {
+			c.logger.Error("broadcast: marshal event", "room_id", roomID, "error", err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		defer cancel()
+
+		env := broadcast.Envelope{Origin: c.instanceID, RoomID: roomID, Payload: payload}
+		if err := c.broadcaster.Publish(ctx, env); err != nil {
+			c.logger.Warn("broadcast: publish failed", "room_id", roomID, "error", err)
+		}
+	}
+}
+
+func (c *Coordinator) startBroadcastSubscriber() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancelBroadcast = cancel
+
+	envs, err := c.broadcaster.Subscribe(ctx)
+	if err != nil {
+		c.logger.Error("broadcast: subscribe failed, remote events disabled", "error", err)
 		return
 	}
 
-	if room.GetUserCount() == 0 {
-		c.rooms.Delete(roomID)
-		room.EnqueueClose()
+	go func() {
+		for env := range envs {
+			// we already delivered our own events locally
+			if env.Origin == c.instanceID {
+				continue
+			}
+
+			room := c.GetRoom(env.RoomID)
+			if room == nil {
+				continue
+			}
+			if !room.EnqueueRemote(decodeRemoteEvent(env.Payload)) {
+				c.logger.Warn("room queue full, dropping remote event", "event", "broadcast_subscribe", "room_id", env.RoomID)
+			}
+		}
+	}()
+}
+
+// decodeRemoteEvent restores chat messages and deletions to their Go types so
+// they update room history, and joins and leaves so they are renumbered with
+// the local sequence; other events are forwarded to clients as raw JSON.
+func decodeRemoteEvent(payload json.RawMessage) interface{} {
+	var head struct {
+		Type messages.EventType `json:"type"`
+	}
+	if err := json.Unmarshal(payload, &head); err != nil {
+		return payload
+	}
+
+	switch head.Type {
+	case messages.EventNewMessage:
+		var ev messages.RoomMessageEvent
+		if err := json.Unmarshal(payload, &ev); err == nil {
+			return ev
+		}
+	case messages.EventMessageDeleted:
+		var ev messages.MessageDeletedEvent
+		if err := json.Unmarshal(payload, &ev); err == nil {
+			return ev
+		}
+	case messages.EventUserJoinedRoom:
+		var ev messages.UserJoinedEvent
+		if err := json.Unmarshal(payload, &ev); err == nil {
+			return ev
+		}
+	case messages.EventUserLeftRoom:
+		var ev messages.UserLeftEvent
+		if err := json.Unmarshal(payload, &ev); err == nil {
+			return ev
+		}
 	}
+	return payload
 }
 
+// forgetRoom unregisters a room whose empty grace period ran out, or that a
+// sweep found empty. It runs on the room's loop just before the loop exits.
+func (c *Coordinator) forgetRoom(room *Room) {
+	if c.rooms.CompareAndDelete(room.ID, room) {
+		c.releaseAuthored(room)
+		c.logger.Info("room closed after being empty", "event", "room_closed", "room_id", room.ID, "grace", c.emptyGrace)
+		c.notify(messages.NewRoomClosedEvent(room.ID))
+	}
+}
+
+// reapIdleRoom unregisters a room closed for being idle. It runs on the
+// room's loop just before the loop exits.
+func (c *Coordinator) reapIdleRoom(room *Room) {
+	if c.rooms.CompareAndDelete(room.ID, room) {
+		c.releaseAuthored(room)
+		c.logger.Info("room closed after being idle", "event", "room_closed", "room_id", room.ID, "idle_timeout", c.idleTimeout)
+		c.notify(messages.NewRoomClosedEvent(room.ID))
+	}
+}
+
+// failRoom unregisters a room whose loop panicked. Like forgetRoom it runs on
+// the room's loop just before the loop exits.
+func (c *Coordinator) failRoom(room *Room, recovered interface{}) {
+	c.logger.Error("room loop panicked", "event", "room_panic", "room_id", room.ID,
+		"error", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+	if c.rooms.CompareAndDelete(room.ID, room) {
+		c.releaseAuthored(room)
+		c.notify(messages.NewRoomClosedEvent(room.ID))
+	}
+}
+
+// CloseRoom removes a room straight away, whoever is still in it. Members are
+// sent a RoomClosedEvent before the room loop stops.
+func (c *Coordinator) CloseRoom(roomID string) error {
+	room, ok := c.rooms.Load(roomID)
+	if !ok || !c.rooms.CompareAndDelete(roomID, room) {
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, roomID)
+	}
+	c.releaseAuthored(room)
+	room.EnqueueClose()
+	c.logger.Info("room closed", "event", "close_room", "room_id", roomID)
+	c.notify(messages.NewRoomClosedEvent(roomID))
+	return nil
+}
+
+// releaseAuthored gives a deleted room back to its creator's room allowance
+func (c *Coordinator) releaseAuthored(room *Room) {
+	c.lifecycleMu.Lock()
+	defer c.lifecycleMu.Unlock()
+	if c.authored[room.CreatedBy]--; c.authored[room.CreatedBy] <= 0 {
+		delete(c.authored, room.CreatedBy)
+	}
+}
+
+// notify hands a lifecycle event to the webhook notifier, if there is one
+func (c *Coordinator) notify(ev interface{}) {
+	if c.webhooks != nil {
+		c.webhooks.Notify(ev)
+	}
+}
+
+// Shutdown closes every room and waits for their loops to exit, or for ctx
+// to be done. CreateRoom fails with app.ErrShuttingDown once it has started.
 func (c *Coordinator) Shutdown(ctx context.Context) error {
-	rooms := make([]*Room, 0)
-	for _, room := range c.rooms.rooms {
-		rooms = append(rooms, room)
+	if c.cancelBroadcast != nil {
+		c.cancelBroadcast()
 	}
 
-	for _, room := range rooms {
+	c.lifecycleMu.Lock()
+	c.closing = true
+	c.lifecycleMu.Unlock()
+
+	for _, room := range c.rooms.Snapshot() {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -175,5 +1499,16 @@ func (c *Coordinator) Shutdown(ctx context.Context) error {
 			room.EnqueueClose()
 		}
 	}
-	return nil
+
+	drained := make(chan struct{})
+	go func() {
+		c.running.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
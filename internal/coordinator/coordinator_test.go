@@ -2,10 +2,23 @@ package coordinator
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/arturskrzydlo/chat-room/internal/app"
+	"github.com/arturskrzydlo/chat-room/internal/broadcast"
 	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/arturskrzydlo/chat-room/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -14,7 +27,7 @@ func TestCoordinatorCreateRoom(t *testing.T) {
 	c := NewCoordinator()
 	send := make(chan interface{}, 10)
 
-	err := c.CreateRoom("room_1", "author1", "Room One", send)
+	err := c.CreateRoom("room_1", "author1", "Room One", "", send)
 	require.NoError(t, err)
 
 	// Room exists with correct fields.
@@ -22,7 +35,7 @@ func TestCoordinatorCreateRoom(t *testing.T) {
 	require.NotNil(t, room)
 	assert.Equal(t, "room_1", room.ID)
 	assert.Equal(t, "Room One", room.Name)
-	assert.Equal(t, "author1", room.AuthorID)
+	assert.Equal(t, "author1", room.Author())
 
 	// Author is auto-joined.
 	time.Sleep(10 * time.Millisecond)
@@ -43,31 +56,40 @@ func TestCoordinatorCreateRoom(t *testing.T) {
 	}
 
 	// Creating the same room again should fail.
-	err = c.CreateRoom("room_1", "author1", "Room One", send)
-	require.Error(t, err)
+	err = c.CreateRoom("room_1", "author1", "Room One", "", send)
+	require.ErrorIs(t, err, app.ErrRoomExists)
 }
 
 func TestCoordinatorCreateRoomValidation(t *testing.T) {
 	c := NewCoordinator()
-	send := make(chan interface{}, 1)
+	send := make(chan interface{}, 32)
 
 	tests := []struct {
 		name     string
 		roomID   string
 		author   string
 		roomName string
-		wantErr  bool
+		wantErr  error
 	}{
-		{"ok", "room_ok", "author1", "Room", false},
-		{"empty room id", "", "author1", "Room", true},
-		{"empty room name", "room_no_name", "author1", "", true},
+		{"ok", "room_ok", "author1", "Room", nil},
+		{"empty room id is generated", "", "author1", "Room", nil},
+		{"empty room name", "room_no_name", "author1", "", app.ErrRoomRequired},
+		{"id with dash and digits", "Room-42_b", "author1", "Room", nil},
+		{"id at max length", strings.Repeat("r", app.DefaultMaxRoomIDLength), "author1", "Room", nil},
+		{"id too long", strings.Repeat("r", app.DefaultMaxRoomIDLength+1), "author1", "Room", app.ErrInvalidRoomID},
+		{"id with slash", "a/b", "author1", "Room", app.ErrInvalidRoomID},
+		{"id with space", "my room", "author1", "Room", app.ErrInvalidRoomID},
+		{"id with non-ascii letter", "pokój", "author1", "Room", app.ErrInvalidRoomID},
+		{"name with unicode", "room_unicode", "author1", "Pokój 🎉", nil},
+		{"name too long", "room_long_name", "author1", strings.Repeat("n", app.DefaultMaxRoomNameLength+1), app.ErrInvalidRoomName},
+		{"name with control char", "room_ctrl", "author1", "Room\nTwo", app.ErrInvalidRoomName},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := c.CreateRoom(tt.roomID, tt.author, tt.roomName, send)
-			if tt.wantErr {
-				require.Error(t, err)
+			err := c.CreateRoom(tt.roomID, tt.author, tt.roomName, "", send)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
 			} else {
 				require.NoError(t, err)
 			}
@@ -77,10 +99,223 @@ func TestCoordinatorCreateRoomValidation(t *testing.T) {
 	// duplicate id case (fresh coordinator)
 	c = NewCoordinator()
 	send = make(chan interface{}, 1)
-	err := c.CreateRoom("dup", "author", "Room", send)
+	err := c.CreateRoom("dup", "author", "Room", "", send)
 	require.NoError(t, err)
-	err = c.CreateRoom("dup", "author", "Room", send)
-	require.Error(t, err)
+	err = c.CreateRoom("dup", "author", "Room", "", send)
+	require.ErrorIs(t, err, app.ErrRoomExists)
+}
+
+func TestCoordinatorCreateRoomGeneratedID(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 1)
+
+	roomID, err := c.CreateRoomWithGeneratedID("author1", "Room", "", send)
+	require.NoError(t, err)
+	require.NotEmpty(t, roomID)
+	require.NotNil(t, c.GetRoom(roomID))
+	created, ok := (<-send).(messages.RoomCreateEvent)
+	require.True(t, ok)
+	assert.Equal(t, roomID, created.RoomID)
+
+	// CreateRoom generates one too when given none
+	require.NoError(t, c.CreateRoom("", "author1", "Room", "", send))
+	created, ok = (<-send).(messages.RoomCreateEvent)
+	require.True(t, ok)
+	assert.NotEqual(t, roomID, created.RoomID)
+	require.NotNil(t, c.GetRoom(created.RoomID))
+
+	_, err = c.CreateRoomWithGeneratedID("author1", "", "", send)
+	require.ErrorIs(t, err, app.ErrRoomRequired)
+
+	// explicit ids keep working alongside generated ones
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room", "", send))
+	<-send
+}
+
+func TestCoordinatorGeneratedRoomIDsAreUnique(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 1)
+
+	const n = 500
+	seen := make(map[string]struct{}, n)
+	for i := 0; i < n; i++ {
+		roomID, err := c.CreateRoomWithGeneratedID("author1", "Room", "", send)
+		require.NoError(t, err)
+		<-send
+		seen[roomID] = struct{}{}
+	}
+	assert.Len(t, seen, n)
+	assert.Len(t, c.ListRooms(RoomFilter{}), n)
+}
+
+func TestCoordinatorIDGenerator(t *testing.T) {
+	c := NewCoordinator(WithIDGenerator(NewSequentialIDGenerator("id-")))
+	send := make(chan interface{}, 20)
+
+	roomID, err := c.CreateRoomWithGeneratedID("user1", "Room", "", send)
+	require.NoError(t, err)
+	assert.Equal(t, "id-1", roomID)
+	waitForUserInRoom(t, c, roomID, "user1")
+
+	require.NoError(t, c.SendMessage(roomID, "user1", "first", "", ""))
+	require.NoError(t, c.SendMessage(roomID, "user1", "second", "", ""))
+	assert.Equal(t, "id-2", nextChat(t, send).MessageID)
+	assert.Equal(t, "id-3", nextChat(t, send).MessageID)
+
+	require.NoError(t, c.CreateRoom("", "user1", "Another", "", send))
+	assert.NotNil(t, c.GetRoom("id-4"))
+}
+
+func TestCoordinatorMaxRoomsPerUser(t *testing.T) {
+	c := NewCoordinator(WithMaxRoomsPerUser(2), WithEmptyRoomGrace(0))
+	send := make(chan interface{}, 32)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	require.NoError(t, c.CreateRoom("room_2", "author1", "Room Two", "", send))
+	err := c.CreateRoom("room_3", "author1", "Room Three", "", send)
+	require.ErrorIs(t, err, app.ErrRoomLimitReached)
+	assert.Contains(t, err.Error(), "room limit reached")
+	assert.Nil(t, c.GetRoom("room_3"))
+
+	// the limit is per user
+	require.NoError(t, c.CreateRoom("other", "author2", "Other", "", send))
+
+	t.Run("closing a room frees a slot", func(t *testing.T) {
+		require.NoError(t, c.CloseRoom("room_1"))
+		require.NoError(t, c.CreateRoom("room_3", "author1", "Room Three", "", send))
+		require.ErrorIs(t, c.CreateRoom("room_4", "author1", "Room Four", "", send), app.ErrRoomLimitReached)
+	})
+
+	t.Run("a room deleted once empty frees a slot", func(t *testing.T) {
+		waitForUserInRoom(t, c, "room_2", "author1")
+		require.NoError(t, c.LeaveRoom("room_2", "author1"))
+		require.Eventually(t, func() bool { return c.GetRoom("room_2") == nil }, time.Second, 5*time.Millisecond)
+		require.NoError(t, c.CreateRoom("room_4", "author1", "Room Four", "", send))
+	})
+}
+
+func TestCoordinatorRoomLimits(t *testing.T) {
+	c := NewCoordinator(WithRoomLimits(4, 5))
+	send := make(chan interface{}, 1)
+
+	require.NoError(t, c.CreateRoom("abcd", "author1", "Short", "", send))
+	require.ErrorIs(t, c.CreateRoom("abcde", "author1", "Short", "", send), app.ErrInvalidRoomID)
+	require.ErrorIs(t, c.CreateRoom("wxyz", "author1", "Longer", "", send), app.ErrInvalidRoomName)
+	assert.Nil(t, c.GetRoom("wxyz"))
+}
+
+func TestCoordinatorListRooms(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 10)
+
+	assert.Empty(t, c.ListRooms(RoomFilter{}))
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	require.NoError(t, c.CreateRoom("room_2", "author2", "Room Two", "", send))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", send))
+	waitForUserInRoom(t, c, "room_1", "user2")
+	waitForUserInRoom(t, c, "room_2", "author2")
+
+	byID := make(map[string]RoomSummary)
+	for _, s := range c.ListRooms(RoomFilter{}) {
+		byID[s.ID] = s
+	}
+
+	require.Len(t, byID, 2)
+	assert.Equal(t, "Room One", byID["room_1"].Name)
+	assert.Equal(t, "author1", byID["room_1"].AuthorID)
+	assert.Equal(t, 2, byID["room_1"].UserCount)
+	assert.False(t, byID["room_1"].CreatedAt.IsZero())
+	assert.Equal(t, 1, byID["room_2"].UserCount)
+}
+
+func TestCoordinatorListRoomsFilter(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	require.NoError(t, c.CreateRoom("room_2", "author2", "Room Two", "", send))
+	require.NoError(t, c.CreateRoom("lobby", "author1", "Lobby", "", send))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", send))
+	waitForUserInRoom(t, c, "room_1", "user2")
+	waitForUserInRoom(t, c, "room_2", "author2")
+	waitForUserInRoom(t, c, "lobby", "author1")
+
+	ids := func(filter RoomFilter) []string {
+		out := make([]string, 0)
+		for _, s := range c.ListRooms(filter) {
+			out = append(out, s.ID)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		filter RoomFilter
+		want   []string
+	}{
+		{"none", RoomFilter{}, []string{"lobby", "room_1", "room_2"}},
+		{"name", RoomFilter{NameContains: "room"}, []string{"room_1", "room_2"}},
+		{"name ignores case", RoomFilter{NameContains: "LOB"}, []string{"lobby"}},
+		{"author", RoomFilter{AuthorID: "author1"}, []string{"lobby", "room_1"}},
+		{"min users", RoomFilter{MinUsers: 2}, []string{"room_1"}},
+		{"name and author", RoomFilter{NameContains: "room", AuthorID: "author1"}, []string{"room_1"}},
+		{"author and min users", RoomFilter{AuthorID: "author2", MinUsers: 2}, []string{}},
+		{"all three", RoomFilter{NameContains: "one", AuthorID: "author1", MinUsers: 2}, []string{"room_1"}},
+		{"no match", RoomFilter{NameContains: "missing"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ids(tt.filter))
+		})
+	}
+}
+
+func TestCoordinatorListMembers(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", send))
+	waitForUserInRoom(t, c, "room_1", "user2")
+
+	members, err := c.ListMembers("room_1")
+	require.NoError(t, err)
+	assert.Equal(t, []messages.Member{
+		{UserID: "author1", UserName: "author1"},
+		{UserID: "user2", UserName: "User Two"},
+	}, members)
+
+	_, err = c.ListMembers("no_room")
+	require.ErrorIs(t, err, app.ErrRoomNotFound)
+}
+
+func TestCoordinatorRoomStatsDebounced(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", make(chan interface{}, 20)))
+	require.NoError(t, c.JoinRoom("room_1", "user3", "User Three", "", make(chan interface{}, 20)))
+
+	var stats []messages.RoomStatsEvent
+	deadline := time.After(2 * defaultStatsDebounce)
+	for done := false; !done; {
+		select {
+		case ev := <-sendAuthor:
+			if st, ok := ev.(messages.RoomStatsEvent); ok {
+				stats = append(stats, st)
+			}
+		case <-deadline:
+			done = true
+		}
+	}
+
+	// the three joins land in one debounce window
+	require.Len(t, stats, 1)
+	assert.Equal(t, "room_1", stats[0].RoomID)
+	assert.Equal(t, 3, stats[0].UserCount)
 }
 
 func TestCoordinatorJoinRoom(t *testing.T) {
@@ -88,10 +323,10 @@ func TestCoordinatorJoinRoom(t *testing.T) {
 	sendAuthor := make(chan interface{}, 10)
 	sendUser2 := make(chan interface{}, 10)
 
-	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", sendAuthor))
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
 
 	// Join second user.
-	err := c.JoinRoom("room_1", "user2", "User Two", sendUser2)
+	err := c.JoinRoom("room_1", "user2", "User Two", "", sendUser2)
 	require.NoError(t, err)
 
 	room := c.GetRoom("room_1")
@@ -125,166 +360,2255 @@ func TestCoordinatorJoinRoom(t *testing.T) {
 	assert.True(t, gotJoinEvent, "expected UserJoinedEvent broadcast to at least one client")
 
 	// Joining same user again should error.
-	err = c.JoinRoom("room_1", "user2", "User Two", sendUser2)
-	require.Error(t, err)
+	err = c.JoinRoom("room_1", "user2", "User Two", "", sendUser2)
+	require.ErrorIs(t, err, app.ErrUserAlreadyInRoom)
 }
 
-func TestCoordinatorSendMessage(t *testing.T) {
+func TestCoordinatorJoinRoomErrors(t *testing.T) {
 	c := NewCoordinator()
-	sendAuthor := make(chan interface{}, 10)
-	sendUser2 := make(chan interface{}, 10)
+	send := make(chan interface{}, 10)
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	require.NoError(t, c.CreateRoom("private", "author1", "Private", "s3cret", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+
+	tests := []struct {
+		name    string
+		roomID  string
+		userID  string
+		pw      string
+		wantErr error
+	}{
+		{"nonexistent room", "nope", "user1", "", app.ErrRoomNotFound},
+		{"already in room", "room_1", "author1", "", app.ErrUserAlreadyInRoom},
+		{"wrong password", "private", "user1", "guess", app.ErrInvalidPassword},
+		{"missing identity", "room_1", "", "", app.ErrIdentityRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.JoinRoom(tt.roomID, tt.userID, tt.userID, tt.pw, send)
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
 
-	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", sendAuthor))
-	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", sendUser2))
+func TestCoordinatorJoinRoomNormalizesIdentity(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 10)
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
 
-	// wait until room.Run has processed the join
-	waitForUserInRoom(t, c, "room_1", "user2")
+	require.ErrorIs(t, c.JoinRoom("room_1", "user1", "\t \n", "", send), app.ErrInvalidIdentity)
+	require.ErrorIs(t, c.JoinRoom("room_1", "user1", strings.Repeat("n", 65), "", send), app.ErrInvalidIdentity)
 
-	// Happy path: user2 sends message.
-	require.NoError(t, c.SendMessage("room_1", "user2", "hello"))
+	require.NoError(t, c.JoinRoom("room_1", " user1 ", "Alice\x00 ", "", send))
+	waitForUserInRoom(t, c, "room_1", "user1")
+	assert.Equal(t, "Alice", c.GetRoom("room_1").GetUsers()["user1"].Name)
+}
 
-	expectChatFrom(t, sendAuthor, "user2", "User Two", "hello")
-	expectChatFrom(t, sendUser2, "user2", "User Two", "hello")
+func TestCoordinatorJoinOrCreateRoom(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 10)
 
-	// Validation cases.
-	require.Error(t, c.SendMessage("room_1", "user2", ""))                              // empty
-	require.Error(t, c.SendMessage("room_1", "ghost", "hi"))                            // not in room
-	require.Error(t, c.SendMessage("no_room", "user2", "hi"))                           // no such room
-	require.Error(t, c.SendMessage("room_1", "user2", string(make([]byte, 10*1024+1)))) // too long
+	require.ErrorIs(t, c.JoinRoom("lobby", "user1", "User One", "", send), app.ErrRoomNotFound)
+	require.NoError(t, c.JoinOrCreateRoom("lobby", "user1", "User One", "", send))
+	waitForUserInRoom(t, c, "lobby", "user1")
+
+	room := c.GetRoom("lobby")
+	require.NotNil(t, room)
+	assert.Equal(t, "user1", room.Author())
+	assert.Equal(t, "User One", room.GetUsers()["user1"].Name)
+	created, ok := (<-send).(messages.RoomCreateEvent)
+	require.True(t, ok)
+	assert.Equal(t, "lobby", created.RoomID)
+
+	// an existing room is simply joined
+	send2 := make(chan interface{}, 10)
+	require.NoError(t, c.JoinOrCreateRoom("lobby", "user2", "User Two", "", send2))
+	waitForUserInRoom(t, c, "lobby", "user2")
+	assert.Equal(t, "user1", room.Author())
 }
 
-func TestCoordinatorSendMessageValidation(t *testing.T) {
+func TestCoordinatorJoinOrCreateRoomConcurrent(t *testing.T) {
+	c := NewCoordinator()
+	const joiners = 8
+
+	var wg sync.WaitGroup
+	errs := make([]error, joiners)
+	for i := 0; i < joiners; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("user%d", i)
+			errs[i] = c.JoinOrCreateRoom("lobby", id, id, "", make(chan interface{}, 32))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		assert.NoError(t, err, "joiner %d", i)
+	}
+	for i := 0; i < joiners; i++ {
+		waitForUserInRoom(t, c, "lobby", fmt.Sprintf("user%d", i))
+	}
+	assert.Len(t, c.ListRooms(RoomFilter{}), 1)
+}
+
+func TestCoordinatorJoinUniqueNames(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("unique", "author1", "Unique", "", send))
+	require.NoError(t, c.CreateRoom("shared", "author1", "Shared", "", send))
+	require.NoError(t, c.SetRoomUniqueNames("unique", true))
+	assert.True(t, c.GetRoom("unique").UniqueNames())
+	assert.False(t, c.GetRoom("shared").UniqueNames())
+
+	for _, roomID := range []string{"unique", "shared"} {
+		require.NoError(t, c.JoinRoom(roomID, "user1", "Alice", "", send))
+		waitForUserInRoom(t, c, roomID, "user1")
+	}
+
+	t.Run("flag on rejects a taken name", func(t *testing.T) {
+		err := c.JoinRoom("unique", "user2", "alice", "", send)
+		require.ErrorIs(t, err, app.ErrNameTaken)
+		assert.NotContains(t, c.GetRoom("unique").GetUsers(), "user2")
+
+		require.NoError(t, c.JoinRoom("unique", "user3", "Bob", "", send))
+		waitForUserInRoom(t, c, "unique", "user3")
+	})
+
+	t.Run("flag off allows a taken name", func(t *testing.T) {
+		require.NoError(t, c.JoinRoom("shared", "user2", "Alice", "", send))
+		waitForUserInRoom(t, c, "shared", "user2")
+	})
+
+	require.ErrorIs(t, c.SetRoomUniqueNames("missing", true), app.ErrRoomNotFound)
+}
+
+func TestCoordinatorJoinPrivateRoom(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("private", "author1", "Private", "s3cret", send))
+	require.NoError(t, c.CreateRoom("open", "author1", "Open", "", send))
+
 	tests := []struct {
-		name    string
-		roomID  string
-		userID  string
-		content string
-		wantErr bool
+		name     string
+		roomID   string
+		userID   string
+		password string
+		wantErr  bool
 	}{
-		{"ok", "room_1", "user1", "hello", false},
-		{"empty content", "room_1", "user1", "", true},
-		{"too long", "room_1", "user1", string(make([]byte, 10*1024+1)), true},
-		{"no such room", "no_room", "user1", "hi", true},
-		{"user not in room", "room_1", "ghost", "hi", true},
+		{"correct password", "private", "user1", "s3cret", false},
+		{"wrong password", "private", "user2", "guess", true},
+		{"missing password", "private", "user3", "", true},
+		{"open room without password", "open", "user4", "", false},
+		{"open room ignores password", "open", "user5", "anything", false},
 	}
 
 	for _, tt := range tests {
-		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			c := NewCoordinator()
-			send := make(chan interface{}, 1)
-
-			// base room + user
-			require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", send))
-			require.NoError(t, c.JoinRoom("room_1", "user1", "User One", send))
-
-			// wait until join is processed by room.Run
-			waitForUserInRoom(t, c, "room_1", "user1")
-
-			err := c.SendMessage(tt.roomID, tt.userID, tt.content)
+			err := c.JoinRoom(tt.roomID, tt.userID, tt.userID, tt.password, send)
 			if tt.wantErr {
-				require.Error(t, err)
-			} else {
-				require.NoError(t, err)
+				require.ErrorIs(t, err, app.ErrInvalidPassword)
+				return
 			}
+			require.NoError(t, err)
+			waitForUserInRoom(t, c, tt.roomID, tt.userID)
 		})
 	}
+
+	assert.True(t, c.GetRoom("private").IsPrivate())
+	assert.False(t, c.GetRoom("open").IsPrivate())
 }
 
-func TestCoordinatorLeaveRoom(t *testing.T) {
+func TestCoordinatorSendMessage(t *testing.T) {
 	c := NewCoordinator()
 	sendAuthor := make(chan interface{}, 10)
 	sendUser2 := make(chan interface{}, 10)
 
-	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", sendAuthor))
-	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", sendUser2))
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
 
+	// wait until room.Run has processed the join
 	waitForUserInRoom(t, c, "room_1", "user2")
 
-	require.NoError(t, c.LeaveRoom("room_1", "user2"))
+	// Happy path: user2 sends message.
+	require.NoError(t, c.SendMessage("room_1", "user2", "hello", "", ""))
 
-	// UserLeftEvent to author
-	expectUserLeftEvent(t, sendAuthor, "room_1", "user2", "User Two")
+	expectChatFrom(t, sendAuthor, "user2", "User Two", "hello")
+	expectChatFrom(t, sendUser2, "user2", "User Two", "hello")
 
-	// author can still leave without error
-	require.NoError(t, c.LeaveRoom("room_1", "author1"))
+	// Validation cases.
+	require.Error(t, c.SendMessage("room_1", "user2", "", "", ""))                              // empty
+	require.Error(t, c.SendMessage("room_1", "ghost", "hi", "", ""))                            // not in room
+	require.Error(t, c.SendMessage("no_room", "user2", "hi", "", ""))                           // no such room
+	require.Error(t, c.SendMessage("room_1", "user2", string(make([]byte, 10*1024+1)), "", "")) // too long
 }
 
-func TestCoordinatorShutdown(t *testing.T) {
+func TestCoordinatorSendMessageDeduplicatesClientMsgID(t *testing.T) {
 	c := NewCoordinator()
-	send := make(chan interface{}, 10)
+	sendAuthor := make(chan interface{}, 10)
+	sendUser2 := make(chan interface{}, 10)
 
-	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", send))
-	require.NoError(t, c.CreateRoom("room_2", "author2", "Room Two", send))
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+	waitForUserInRoom(t, c, "room_1", "user2")
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+	require.NoError(t, c.SendMessage("room_1", "user2", "hello", "k1", ""))
+	err := c.SendMessage("room_1", "user2", "hello", "k1", "")
+	require.ErrorIs(t, err, app.ErrDuplicateMessage)
 
-	err := c.Shutdown(ctx)
-	require.NoError(t, err)
+	// the same key from another user is a different message
+	require.NoError(t, c.SendMessage("room_1", "author1", "hi", "k1", ""))
+
+	var got []messages.RoomMessageEvent
+	for len(got) < 2 {
+		select {
+		case ev := <-sendAuthor:
+			if msg, ok := ev.(messages.RoomMessageEvent); ok {
+				got = append(got, msg)
+			}
+		case <-time.After(200 * time.Millisecond):
+			require.FailNow(t, "expected two chat messages")
+		}
+	}
+	assert.Equal(t, "hello", got[0].Message.Message)
+	assert.Equal(t, "k1", got[0].Message.ClientMsgID, "broadcast should echo the client message id")
+	assert.Equal(t, "hi", got[1].Message.Message)
+
+	// nothing else was broadcast
+	require.NoError(t, c.SendMessage("room_1", "author1", "marker", "", ""))
+	expectChatFrom(t, sendAuthor, "author1", "author1", "marker")
+	assert.Empty(t, sendAuthor)
 }
 
-func waitForUserInRoom(t *testing.T, c *Coordinator, roomID, userID string) {
-	t.Helper()
-	deadline := time.Now().Add(200 * time.Millisecond)
-	for time.Now().Before(deadline) {
-		room := c.GetRoom(roomID)
-		require.NotNil(t, room)
-		if _, ok := room.GetUsers()[userID]; ok {
-			return
+func TestCoordinatorBroadcastSequenceNumbers(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 20)
+	sendUser2 := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+	waitForUserInRoom(t, c, "room_1", "user2")
+
+	for _, text := range []string{"one", "two", "three"} {
+		require.NoError(t, c.SendMessage("room_1", "author1", text, "", ""))
+	}
+	require.NoError(t, c.LeaveRoom("room_1", "user2"))
+
+	var seqs []uint64
+	for len(seqs) < 5 {
+		select {
+		case ev := <-sendAuthor:
+			switch e := ev.(type) {
+			case messages.UserJoinedEvent:
+				seqs = append(seqs, e.Seq)
+			case messages.RoomMessageEvent:
+				seqs = append(seqs, e.Seq)
+			case messages.UserLeftEvent:
+				seqs = append(seqs, e.Seq)
+			}
+		case <-time.After(200 * time.Millisecond):
+			require.FailNow(t, "expected join, three messages and leave", "got seqs %v", seqs)
 		}
-		time.Sleep(5 * time.Millisecond)
 	}
-	require.Failf(t, "user not in room", "user %s was not in room %s in time", userID, roomID)
+
+	require.NotZero(t, seqs[0])
+	for i := 1; i < len(seqs); i++ {
+		assert.Equal(t, seqs[i-1]+1, seqs[i], "sequence should increase by one: %v", seqs)
+	}
 }
 
-func expectChatFrom(t *testing.T, ch <-chan interface{}, wantUserID, wantUserName, wantMsg string) {
+// nextChat returns the next chat message on ch, skipping other events
+func nextChat(t *testing.T, ch <-chan interface{}) messages.RoomMessageEvent {
 	t.Helper()
-	deadline := time.Now().Add(200 * time.Millisecond)
-	for time.Now().Before(deadline) {
+	for {
 		select {
 		case ev := <-ch:
-			// Only care about RoomMessageEvent; skip others (e.g. RoomCreateEvent, UserJoinedEvent, UserLeftEvent).
-			msg, ok := ev.(messages.RoomMessageEvent)
-			if !ok {
-				continue
-			}
-			if msg.Type != messages.EventNewMessage {
-				continue
-			}
-			if msg.UserID == wantUserID && msg.UserName == wantUserName && msg.Message.Message == wantMsg {
-				return
+			if msg, ok := ev.(messages.RoomMessageEvent); ok {
+				return msg
 			}
-		default:
-			time.Sleep(5 * time.Millisecond)
+		case <-time.After(200 * time.Millisecond):
+			require.FailNow(t, "expected a chat message")
 		}
 	}
-	require.Failf(t, "did not see expected chat message",
-		"expected message from %s (%s) with content %q", wantUserID, wantUserName, wantMsg)
 }
 
-func expectUserLeftEvent(t *testing.T, ch <-chan interface{}, roomID, userID, userName string) {
+func expectMessageDeletedEvent(t *testing.T, ch <-chan interface{}, messageID, deletedBy string) {
 	t.Helper()
-	deadline := time.Now().Add(200 * time.Millisecond)
-
-	for time.Now().Before(deadline) {
+	for {
 		select {
 		case ev := <-ch:
-			ul, ok := ev.(messages.UserLeftEvent)
-			if !ok {
-				continue
-			}
-			if ul.Type == messages.EventUserLeftRoom &&
-				ul.RoomID == roomID &&
-				ul.UserID == userID &&
-				ul.UserName == userName {
+			if del, ok := ev.(messages.MessageDeletedEvent); ok {
+				assert.Equal(t, messageID, del.MessageID)
+				assert.Equal(t, deletedBy, del.DeletedBy)
 				return
 			}
-		default:
-			time.Sleep(5 * time.Millisecond)
+		case <-time.After(200 * time.Millisecond):
+			require.FailNow(t, "expected MessageDeletedEvent")
 		}
 	}
+}
 
-	assert.Failf(t, "expected UserLeftEvent to be broadcast",
-		"did not see UserLeftEvent for room=%q userID=%q userName=%q", roomID, userID, userName)
+func TestCoordinatorRetentionMaxMessages(t *testing.T) {
+	c := NewCoordinator(WithRoomRetention(RetentionPolicy{MaxMessages: 3}))
+	send := make(chan interface{}, 50)
+	require.NoError(t, c.CreateRoom("room_1", "user1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "user1")
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, c.SendMessage("room_1", "user1", fmt.Sprintf("msg %d", i), "", ""))
+	}
+	require.Eventually(t, func() bool {
+		h, _ := c.GetHistory("room_1", 0, 10)
+		return len(h) == 3 && h[0].Message.Message == "msg 5"
+	}, time.Second, 5*time.Millisecond)
+	h, err := c.GetHistory("room_1", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "msg 3", h[2].Message.Message)
+
+	// tightening the policy prunes at once
+	require.NoError(t, c.SetRoomRetention("room_1", RetentionPolicy{MaxMessages: 1}))
+	h, err = c.GetHistory("room_1", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, h, 1)
+	assert.Equal(t, "msg 5", h[0].Message.Message)
+
+	assert.Error(t, c.SetRoomRetention("room_1", RetentionPolicy{MaxMessages: -1}))
+	assert.ErrorIs(t, c.SetRoomRetention("missing", RetentionPolicy{}), app.ErrRoomNotFound)
+}
+
+func TestCoordinatorRetentionMaxAge(t *testing.T) {
+	c := NewCoordinator(
+		WithRoomRetention(RetentionPolicy{MaxAge: 100 * time.Millisecond}),
+		WithRoomSweepInterval(20*time.Millisecond),
+	)
+	send := make(chan interface{}, 50)
+	require.NoError(t, c.CreateRoom("room_1", "user1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "user1")
+
+	require.NoError(t, c.SendMessage("room_1", "user1", "old", "", ""))
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, c.SendMessage("room_1", "user1", "newer", "", ""))
+
+	// the old message ages out on a sweep while the newer one is still kept
+	require.Eventually(t, func() bool {
+		h, _ := c.GetHistory("room_1", 0, 10)
+		return len(h) == 1 && h[0].Message.Message == "newer"
+	}, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		h, _ := c.GetHistory("room_1", 0, 10)
+		return len(h) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestCoordinatorDisappearingMessage(t *testing.T) {
+	store := NewMemoryMessageStore()
+	c := NewCoordinatorWithStore(store)
+	send := make(chan interface{}, 20)
+	require.NoError(t, c.CreateRoom("room_1", "user1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "user1")
+
+	require.NoError(t, c.SendMessage("room_1", "user1", "stays", "", ""))
+	require.NoError(t, c.SendDisappearingMessage("room_1", "user1", "gone soon", "", "", 1))
+	stays := nextChat(t, send)
+	gone := nextChat(t, send)
+	assert.Equal(t, 1, gone.Message.ExpiresInSeconds)
+	assert.ErrorContains(t, c.SendDisappearingMessage("room_1", "user1", "x", "", "", -1), "negative")
+	// far past the limit, where multiplying out to a Duration would overflow
+	assert.ErrorContains(t, c.SendDisappearingMessage("room_1", "user1", "x", "", "", math.MaxInt64/1000), "at most")
+	assert.ErrorContains(t, c.SendDisappearingMessage("room_1", "user1", "x", "", "", app.MaxMessageExpirySeconds+1), "at most")
+
+	deadline := time.After(3 * time.Second)
+	var expired messages.MessageExpiredEvent
+wait:
+	for {
+		select {
+		case ev := <-send:
+			var ok bool
+			if expired, ok = ev.(messages.MessageExpiredEvent); ok {
+				break wait
+			}
+		case <-deadline:
+			require.FailNow(t, "expected a message_expired event")
+		}
+	}
+	assert.Equal(t, "room_1", expired.RoomID)
+	assert.Equal(t, gone.MessageID, expired.MessageID)
+
+	// late joiners only get the message that stays, and it was the only one stored
+	late := make(chan interface{}, 20)
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", late))
+	replayed := nextChat(t, late)
+	assert.Equal(t, stays.MessageID, replayed.MessageID)
+	history, err := c.GetHistory("room_1", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, stays.MessageID, history[0].MessageID)
+	stored, err := store.Load("room_1", 10)
+	require.NoError(t, err)
+	assert.Len(t, stored, 1)
+}
+
+func TestCoordinatorSendMessageAcked(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 20)
+	require.NoError(t, c.CreateRoom("room_1", "user1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "user1")
+
+	require.NoError(t, c.SendMessageAcked("room_1", "user1", "hello", "", "", 0, "req-1"))
+	var ack messages.MessageAck
+	require.Eventually(t, func() bool {
+		select {
+		case ev := <-send:
+			var ok bool
+			ack, ok = ev.(messages.MessageAck)
+			return ok
+		default:
+			return false
+		}
+	}, time.Second, 5*time.Millisecond)
+	chat := nextChat(t, send)
+	assert.Equal(t, "req-1", ack.RequestID)
+	assert.Equal(t, "room_1", ack.RoomID)
+	assert.Equal(t, chat.MessageID, ack.MessageID)
+	assert.Equal(t, chat.Seq, ack.Seq)
+
+	t.Run("sender excluded from the broadcast", func(t *testing.T) {
+		c := NewCoordinator(WithExcludeSender(true))
+		send := make(chan interface{}, 20)
+		require.NoError(t, c.CreateRoom("room_1", "user1", "Room One", "", send))
+		waitForUserInRoom(t, c, "room_1", "user1")
+
+		require.NoError(t, c.SendMessageAcked("room_1", "user1", "hello", "", "", 0, "req-2"))
+		require.Eventually(t, func() bool {
+			select {
+			case ev := <-send:
+				ack, ok := ev.(messages.MessageAck)
+				return ok && ack.RequestID == "req-2" && ack.Seq > 0
+			default:
+				return false
+			}
+		}, time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestCoordinatorDeleteMessageEditWindow(t *testing.T) {
+	c := NewCoordinator(WithEditWindow(100 * time.Millisecond))
+	sendAuthor := make(chan interface{}, 20)
+	sendUser2 := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+	waitForUserInRoom(t, c, "room_1", "user2")
+
+	require.NoError(t, c.SendMessage("room_1", "user2", "quick", "", ""))
+	require.NoError(t, c.SendMessage("room_1", "user2", "slow", "", ""))
+	quick := nextChat(t, sendUser2)
+	slow := nextChat(t, sendUser2)
+
+	// within the window
+	require.NoError(t, c.DeleteMessage("room_1", "user2", quick.MessageID))
+
+	time.Sleep(150 * time.Millisecond)
+	err := c.DeleteMessage("room_1", "user2", slow.MessageID)
+	require.ErrorIs(t, err, app.ErrEditWindowExpired)
+	got, found := c.GetRoom("room_1").FindMessage(slow.MessageID)
+	require.True(t, found)
+	assert.False(t, got.Deleted)
+
+	// moderation isn't time limited
+	require.NoError(t, c.DeleteMessage("room_1", "author1", slow.MessageID))
+}
+
+func TestCoordinatorDeleteMessage(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 20)
+	sendUser2 := make(chan interface{}, 20)
+	sendUser3 := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+	require.NoError(t, c.JoinRoom("room_1", "user3", "User Three", "", sendUser3))
+	waitForUserInRoom(t, c, "room_1", "user3")
+
+	require.NoError(t, c.SendMessage("room_1", "user2", "mine", "", ""))
+	require.NoError(t, c.SendMessage("room_1", "user2", "moderated", "", ""))
+	own := nextChat(t, sendUser3)
+	moderated := nextChat(t, sendUser3)
+	require.NotEmpty(t, own.MessageID)
+	require.NotEqual(t, own.MessageID, moderated.MessageID)
+
+	t.Run("message author", func(t *testing.T) {
+		require.NoError(t, c.DeleteMessage("room_1", "user2", own.MessageID))
+		expectMessageDeletedEvent(t, sendUser3, own.MessageID, "user2")
+	})
+
+	t.Run("room author", func(t *testing.T) {
+		require.NoError(t, c.DeleteMessage("room_1", "author1", moderated.MessageID))
+		expectMessageDeletedEvent(t, sendUser3, moderated.MessageID, "author1")
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		require.NoError(t, c.SendMessage("room_1", "user2", "not yours", "", ""))
+		other := nextChat(t, sendUser3)
+
+		err := c.DeleteMessage("room_1", "user3", other.MessageID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "only the message author, room author or a moderator")
+
+		got, found := c.GetRoom("room_1").FindMessage(other.MessageID)
+		require.True(t, found)
+		assert.False(t, got.Deleted)
+	})
+
+	require.Error(t, c.DeleteMessage("room_1", "author1", "no-such-id"))
+	require.Error(t, c.DeleteMessage("no_room", "author1", own.MessageID))
+
+	// late joiners see tombstones in place of the deleted text
+	sendLate := make(chan interface{}, 20)
+	require.NoError(t, c.JoinRoom("room_1", "late", "Late User", "", sendLate))
+	replayed := []messages.RoomMessageEvent{nextChat(t, sendLate), nextChat(t, sendLate), nextChat(t, sendLate)}
+	assert.True(t, replayed[0].Deleted)
+	assert.Empty(t, replayed[0].Message.Message)
+	assert.Equal(t, own.MessageID, replayed[0].MessageID)
+	assert.True(t, replayed[1].Deleted)
+	assert.False(t, replayed[2].Deleted)
+	assert.Equal(t, "not yours", replayed[2].Message.Message)
+}
+
+func TestCoordinatorSendMessageReply(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 20)
+	sendUser2 := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+	waitForUserInRoom(t, c, "room_1", "user2")
+
+	require.NoError(t, c.SendMessage("room_1", "author1", "question?", "", ""))
+	parent := nextChat(t, sendUser2)
+
+	require.NoError(t, c.SendMessage("room_1", "user2", "answer", "", parent.MessageID))
+	reply := nextChat(t, sendUser2)
+	assert.Equal(t, "answer", reply.Message.Message)
+	assert.Equal(t, parent.MessageID, reply.Message.ParentMessageID)
+	assert.Empty(t, parent.Message.ParentMessageID)
+
+	err := c.SendMessage("room_1", "user2", "orphan", "", "no-such-id")
+	require.Error(t, err)
+	assert.Equal(t, "parent not found", err.Error())
+
+	// parents are looked up per room
+	require.NoError(t, c.CreateRoom("room_2", "author1", "Room Two", "", sendAuthor))
+	waitForUserInRoom(t, c, "room_2", "author1")
+	err = c.SendMessage("room_2", "author1", "elsewhere", "", parent.MessageID)
+	require.Error(t, err)
+	assert.Equal(t, "parent not found", err.Error())
+}
+
+func expectReactionEvent(t *testing.T, ch <-chan interface{}) messages.ReactionEvent {
+	t.Helper()
+	for {
+		select {
+		case ev := <-ch:
+			if re, ok := ev.(messages.ReactionEvent); ok {
+				return re
+			}
+		case <-time.After(200 * time.Millisecond):
+			require.FailNow(t, "expected ReactionEvent")
+		}
+	}
+}
+
+func TestCoordinatorReactToggles(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 20)
+	sendUser2 := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+	waitForUserInRoom(t, c, "room_1", "user2")
+
+	require.NoError(t, c.SendMessage("room_1", "author1", "ship it", "", ""))
+	msg := nextChat(t, sendUser2)
+
+	require.NoError(t, c.React("room_1", "user2", msg.MessageID, "👍"))
+	on := expectReactionEvent(t, sendAuthor)
+	assert.Equal(t, msg.MessageID, on.MessageID)
+	assert.Equal(t, "user2", on.UserID)
+	assert.Equal(t, "👍", on.Emoji)
+	assert.True(t, on.Added)
+
+	require.NoError(t, c.React("room_1", "user2", msg.MessageID, "👍"))
+	off := expectReactionEvent(t, sendAuthor)
+	assert.False(t, off.Added, "same emoji twice should toggle the reaction off")
+
+	// multi-codepoint emoji are still a single grapheme
+	require.NoError(t, c.React("room_1", "user2", msg.MessageID, "👍🏽"))
+	assert.True(t, expectReactionEvent(t, sendAuthor).Added)
+}
+
+func TestCoordinatorReactValidation(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	waitForUserInRoom(t, c, "room_1", "author1")
+	require.NoError(t, c.SendMessage("room_1", "author1", "hi", "", ""))
+	msg := nextChat(t, sendAuthor)
+
+	require.Error(t, c.React("room_1", "author1", msg.MessageID, ""))   // empty emoji
+	require.Error(t, c.React("room_1", "author1", msg.MessageID, "👍👎")) // two graphemes
+	require.Error(t, c.React("room_1", "ghost", msg.MessageID, "👍"))    // not in room
+	require.Error(t, c.React("room_1", "author1", "no-such-id", "👍"))   // unknown message
+	require.Error(t, c.React("no_room", "author1", msg.MessageID, "👍")) // no such room
+}
+
+func TestCoordinatorSendDirect(t *testing.T) {
+	c := NewCoordinator()
+	alice := make(chan interface{}, 5)
+	bobPhone := make(chan interface{}, 5)
+	bobLaptop := make(chan interface{}, 5)
+
+	c.RegisterClient("alice", alice)
+	c.RegisterClient("bob", bobPhone)
+	c.RegisterClient("bob", bobLaptop)
+
+	require.NoError(t, c.SendDirect("alice", "Alice", "bob", "psst"))
+
+	for _, ch := range []chan interface{}{bobPhone, bobLaptop, alice} {
+		select {
+		case ev := <-ch:
+			dm, ok := ev.(messages.DirectMessageEvent)
+			require.True(t, ok, "expected DirectMessageEvent, got %T", ev)
+			assert.Equal(t, messages.EventDirectMessage, dm.Type)
+			assert.Equal(t, "alice", dm.FromUserID)
+			assert.Equal(t, "Alice", dm.FromUserName)
+			assert.Equal(t, "bob", dm.ToUserID)
+			assert.Equal(t, "psst", dm.Message)
+		case <-time.After(200 * time.Millisecond):
+			require.FailNow(t, "expected direct message on every connection")
+		}
+	}
+}
+
+func TestCoordinatorSendDirectOffline(t *testing.T) {
+	c := NewCoordinator()
+	alice := make(chan interface{}, 5)
+	bob := make(chan interface{}, 5)
+	c.RegisterClient("alice", alice)
+	c.RegisterClient("bob", bob)
+	c.UnregisterClient("bob", bob)
+
+	err := c.SendDirect("alice", "Alice", "bob", "are you there?")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "offline")
+	assert.Empty(t, alice, "no echo when delivery failed")
+
+	require.Error(t, c.SendDirect("alice", "Alice", "alice", "me")) // self
+	require.Error(t, c.SendDirect("alice", "Alice", "", "nobody"))  // no recipient
+	c.RegisterClient("bob", bob)
+	require.Error(t, c.SendDirect("alice", "Alice", "bob", "")) // empty
+}
+
+func TestCoordinatorJoinReplaysHistory(t *testing.T) {
+	c := NewCoordinator(WithRoomHistorySize(2))
+	sendAuthor := make(chan interface{}, 10)
+	sendLate := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	waitForUserInRoom(t, c, "room_1", "author1")
+
+	require.NoError(t, c.SendMessage("room_1", "author1", "first", "", ""))
+	require.NoError(t, c.SendMessage("room_1", "author1", "second", "", ""))
+	require.NoError(t, c.SendMessage("room_1", "author1", "third", "", ""))
+	expectChatFrom(t, sendAuthor, "author1", "author1", "third")
+
+	require.NoError(t, c.JoinRoom("room_1", "late", "Late User", "", sendLate))
+
+	// Oldest message was evicted; the rest are replayed in order before the join broadcast.
+	var replayed []string
+	for done := false; !done; {
+		select {
+		case ev := <-sendLate:
+			if _, ok := ev.(messages.UserJoinedEvent); ok {
+				done = true
+				continue
+			}
+			msg, ok := ev.(messages.RoomMessageEvent)
+			require.True(t, ok, "expected RoomMessageEvent before join event, got %T", ev)
+			assert.True(t, msg.Historical, "replayed message should be marked historical")
+			replayed = append(replayed, msg.Message.Message)
+		case <-time.After(200 * time.Millisecond):
+			require.Fail(t, "expected UserJoinedEvent after history replay")
+		}
+	}
+	assert.Equal(t, []string{"second", "third"}, replayed)
+}
+
+func TestCoordinatorReplayKeepsTimestamps(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 10)
+	sendLate := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "author1", "Author", "", sendAuthor))
+	waitForUserInRoom(t, c, "room_1", "author1")
+
+	nextChat := func(ch <-chan interface{}) messages.RoomMessageEvent {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case ev := <-ch:
+				if msg, ok := ev.(messages.RoomMessageEvent); ok {
+					return msg
+				}
+			case <-timeout:
+				require.FailNow(t, "expected a RoomMessageEvent")
+			}
+		}
+	}
+
+	require.NoError(t, c.SendMessage("room_1", "author1", "hello", "", ""))
+	live := nextChat(sendAuthor)
+
+	received, err := time.Parse(time.RFC3339Nano, live.ServerReceivedAt)
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, received.Location())
+	sent, err := time.Parse(time.RFC3339, live.MessageTime)
+	require.NoError(t, err)
+	assert.Equal(t, received.Truncate(time.Second), sent)
+
+	// a different second makes any restamping visible
+	time.Sleep(1100 * time.Millisecond)
+
+	require.NoError(t, c.JoinRoom("room_1", "late", "Late User", "", sendLate))
+	replayed := nextChat(sendLate)
+	assert.True(t, replayed.Historical)
+	assert.Equal(t, live.MessageTime, replayed.MessageTime)
+	assert.Equal(t, live.ServerReceivedAt, replayed.ServerReceivedAt)
+
+	page, err := c.GetHistory("room_1", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, live.MessageTime, page[0].MessageTime)
+	assert.Equal(t, live.ServerReceivedAt, page[0].ServerReceivedAt)
+}
+
+func TestCoordinatorSendMessageExcludeSender(t *testing.T) {
+	c := NewCoordinator(WithExcludeSender(true))
+	sendAuthor := make(chan interface{}, 10)
+	sendUser2 := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+	waitForUserInRoom(t, c, "room_1", "user2")
+
+	require.NoError(t, c.SendMessage("room_1", "user2", "hello", "", ""))
+
+	expectChatFrom(t, sendAuthor, "user2", "User Two", "hello")
+
+	// the room loop has processed the broadcast by now; the sender got nothing
+	for len(sendUser2) > 0 {
+		_, isChat := (<-sendUser2).(messages.RoomMessageEvent)
+		assert.False(t, isChat, "sender should not receive its own message")
+	}
+}
+
+func TestCoordinatorStoreSurvivesRoomRecreate(t *testing.T) {
+	store := NewMemoryMessageStore()
+	c := NewCoordinatorWithStore(store, WithEmptyRoomGrace(0))
+	sendAuthor := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	waitForUserInRoom(t, c, "room_1", "author1")
+	require.NoError(t, c.SendMessage("room_1", "author1", "persist me", "", ""))
+	expectChatFrom(t, sendAuthor, "author1", "author1", "persist me")
+
+	stored, err := store.Load("room_1", 0)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+
+	// with no grace period the last user leaving deletes the room
+	require.NoError(t, c.LeaveRoom("room_1", "author1"))
+	require.Eventually(t, func() bool { return c.GetRoom("room_1") == nil }, 200*time.Millisecond, 5*time.Millisecond)
+
+	// recreating it replays the stored message to the new author
+	sendNew := make(chan interface{}, 10)
+	require.NoError(t, c.CreateRoom("room_1", "author2", "Room One Again", "", sendNew))
+
+	var replayed *messages.RoomMessageEvent
+	require.Eventually(t, func() bool {
+		for len(sendNew) > 0 {
+			if msg, ok := (<-sendNew).(messages.RoomMessageEvent); ok {
+				replayed = &msg
+			}
+		}
+		return replayed != nil
+	}, 200*time.Millisecond, 5*time.Millisecond)
+	assert.Equal(t, "persist me", replayed.Message.Message)
+	assert.True(t, replayed.Historical)
+}
+
+func TestCoordinatorStoreKeepsSeqAcrossRestart(t *testing.T) {
+	store := NewMemoryMessageStore()
+	send := make(chan interface{}, 20)
+
+	first := NewCoordinatorWithStore(store)
+	require.NoError(t, first.CreateRoom("room_1", "author1", "Room One", "", send))
+	waitForUserInRoom(t, first, "room_1", "author1")
+	for _, text := range []string{"m1", "m2", "m3"} {
+		require.NoError(t, first.SendMessage("room_1", "author1", text, "", ""))
+	}
+	expectChatFrom(t, send, "author1", "author1", "m3")
+	require.NoError(t, first.Shutdown(context.Background()))
+
+	stored, err := store.Load("room_1", 0)
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	for _, ev := range stored {
+		assert.NotZero(t, ev.Seq, "messages are stored with their sequence number")
+	}
+
+	// a restarted coordinator picks the counter up from stored history
+	restarted := NewCoordinatorWithStore(store)
+	sendNew := make(chan interface{}, 20)
+	require.NoError(t, restarted.CreateRoom("room_1", "author1", "Room One", "", sendNew))
+	waitForUserInRoom(t, restarted, "room_1", "author1")
+	require.NoError(t, restarted.SendMessage("room_1", "author1", "m4", "", ""))
+	fresh := nextChat(t, sendNew)
+	for fresh.Historical {
+		fresh = nextChat(t, sendNew)
+	}
+	assert.Equal(t, "m4", fresh.Message.Message)
+	assert.Greater(t, fresh.Seq, stored[2].Seq)
+
+	// persisting is off the room loop, so m4 is stored shortly after delivery
+	require.Eventually(t, func() bool {
+		stored, err = store.Load("room_1", 0)
+		return err == nil && len(stored) == 4
+	}, time.Second, 5*time.Millisecond)
+	for i := 1; i < len(stored); i++ {
+		assert.Greater(t, stored[i].Seq, stored[i-1].Seq)
+	}
+}
+
+// stalledStore holds every Append until release is closed
+type stalledStore struct {
+	*MemoryMessageStore
+	release chan struct{}
+}
+
+func (s stalledStore) Append(roomID string, ev messages.RoomMessageEvent) error {
+	<-s.release
+	return s.MemoryMessageStore.Append(roomID, ev)
+}
+
+func TestCoordinatorSlowStoreDoesNotDelayMembers(t *testing.T) {
+	store := stalledStore{MemoryMessageStore: NewMemoryMessageStore(), release: make(chan struct{})}
+	c := NewCoordinatorWithStore(store)
+	send := make(chan interface{}, 20)
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+
+	for _, text := range []string{"m1", "m2", "m3"} {
+		require.NoError(t, c.SendMessage("room_1", "author1", text, "", ""))
+		assert.Equal(t, text, nextChat(t, send).Message.Message)
+	}
+
+	// shutting down waits for the store to catch up
+	close(store.release)
+	require.NoError(t, c.Shutdown(context.Background()))
+	stored, err := store.Load("room_1", 0)
+	require.NoError(t, err)
+	assert.Len(t, stored, 3)
+}
+
+func TestCoordinatorGetHistoryPages(t *testing.T) {
+	c := NewCoordinator(WithRoomHistorySize(30))
+	send := make(chan interface{}, 64)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+	for i := 1; i <= 25; i++ {
+		require.NoError(t, c.SendMessage("room_1", "author1", fmt.Sprintf("m%d", i), "", ""))
+	}
+	// the last message has been through the room loop once the author sees it
+	expectChatFrom(t, send, "author1", "author1", "m25")
+
+	var got []string
+	var before uint64
+	pages := 0
+	for {
+		page, err := c.GetHistory("room_1", before, 10)
+		require.NoError(t, err)
+		pages++
+		for _, ev := range page {
+			assert.True(t, ev.Historical)
+			if before != 0 {
+				assert.Less(t, ev.Seq, before)
+			}
+			got = append(got, ev.Message.Message)
+		}
+		if len(page) < 10 {
+			break
+		}
+		before = page[len(page)-1].Seq
+	}
+
+	assert.Equal(t, 3, pages)
+	require.Len(t, got, 25)
+	assert.Equal(t, "m25", got[0])
+	assert.Equal(t, "m1", got[24])
+
+	_, err := c.GetHistory("no_room", 0, 10)
+	require.ErrorIs(t, err, app.ErrRoomNotFound)
+}
+
+func TestCoordinatorSearchMessages(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 64)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+	for _, text := range []string{"deploy at noon", "lunch?", "Deploy went fine", "redeploying now"} {
+		require.NoError(t, c.SendMessage("room_1", "author1", text, "", ""))
+	}
+	expectChatFrom(t, send, "author1", "author1", "redeploying now")
+
+	texts := func(evs []messages.RoomMessageEvent) []string {
+		out := make([]string, 0, len(evs))
+		for _, ev := range evs {
+			assert.True(t, ev.Historical)
+			out = append(out, ev.Message.Message)
+		}
+		return out
+	}
+
+	t.Run("matches newest first", func(t *testing.T) {
+		found, err := c.SearchMessages("room_1", "DEPLOY", 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"redeploying now", "Deploy went fine", "deploy at noon"}, texts(found))
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		found, err := c.SearchMessages("room_1", "deploy", 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"redeploying now", "Deploy went fine"}, texts(found))
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		found, err := c.SearchMessages("room_1", "dinner", 10)
+		require.NoError(t, err)
+		assert.Empty(t, found)
+	})
+
+	t.Run("empty query", func(t *testing.T) {
+		_, err := c.SearchMessages("room_1", "  ", 10)
+		require.ErrorIs(t, err, app.ErrEmptyQuery)
+	})
+
+	t.Run("missing room", func(t *testing.T) {
+		_, err := c.SearchMessages("no_room", "deploy", 10)
+		require.ErrorIs(t, err, app.ErrRoomNotFound)
+	})
+}
+
+func TestCoordinatorSendMessageValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		roomID  string
+		userID  string
+		content string
+		wantErr error
+	}{
+		{"ok", "room_1", "user1", "hello", nil},
+		{"empty content", "room_1", "user1", "", app.ErrEmptyContent},
+		{"too long", "room_1", "user1", string(make([]byte, 10*1024+1)), app.ErrContentTooLong},
+		{"no such room", "no_room", "user1", "hi", app.ErrRoomNotFound},
+		{"user not in room", "room_1", "ghost", "hi", app.ErrUserNotInRoom},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCoordinator()
+			send := make(chan interface{}, 1)
+
+			// base room + user
+			require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+			require.NoError(t, c.JoinRoom("room_1", "user1", "User One", "", send))
+
+			// wait until join is processed by room.Run
+			waitForUserInRoom(t, c, "room_1", "user1")
+
+			err := c.SendMessage(tt.roomID, tt.userID, tt.content, "", "")
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCoordinatorLeaveRoom(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 10)
+	sendUser2 := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+
+	waitForUserInRoom(t, c, "room_1", "user2")
+
+	require.NoError(t, c.LeaveRoom("room_1", "user2"))
+
+	// UserLeftEvent to author
+	expectUserLeftEvent(t, sendAuthor, "room_1", "user2", "User Two")
+
+	// author can still leave without error
+	require.NoError(t, c.LeaveRoom("room_1", "author1"))
+}
+
+func TestCoordinatorNotifiesDroppedMessages(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 20)
+	sendSlow := make(chan interface{}, 1)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "slow", "Slow", "", sendSlow))
+	waitForUserInRoom(t, c, "room_1", "slow")
+
+	// keep the slow client's one-slot buffer full while the room broadcasts
+	select {
+	case sendSlow <- "filler":
+	default:
+	}
+	for i := 0; i < 4; i++ {
+		require.NoError(t, c.SendMessage("room_1", "author1", fmt.Sprintf("msg %d", i), "", ""))
+	}
+	// the room handles broadcasts in order, so once the author has the fourth
+	// message the slow client has missed at least the first three
+	for got := 0; got < 4; {
+		select {
+		case ev := <-sendAuthor:
+			if _, ok := ev.(messages.RoomMessageEvent); ok {
+				got++
+			}
+		case <-time.After(time.Second):
+			require.FailNow(t, "expected the author to get every message")
+		}
+	}
+
+	// once the client drains, the room tells it what it missed without
+	// waiting for another broadcast
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-sendSlow:
+			dropped, ok := ev.(messages.MessagesDroppedEvent)
+			if !ok {
+				continue
+			}
+			assert.Equal(t, messages.EventMessagesDropped, dropped.Type)
+			assert.Equal(t, "room_1", dropped.RoomID)
+			assert.GreaterOrEqual(t, dropped.Count, 3)
+			return
+		case <-deadline:
+			require.FailNow(t, "expected a messages_dropped event")
+		}
+	}
+}
+
+func TestRoomEventQueueOverflow(t *testing.T) {
+	// the loop isn't running, so nothing drains the queue
+	room := NewRoom("room_1", "Room", "author1", WithEventQueueSize(2))
+	room.EnqueueBroadcast("one")
+	room.EnqueueBroadcast("two")
+
+	dropped := func(kind string) float64 {
+		return testutil.ToFloat64(metrics.RoomEventsDropped.WithLabelValues(kind))
+	}
+	before := map[string]float64{}
+	for _, kind := range []string{"broadcast", "ephemeral", "remote", "announcement"} {
+		before[kind] = dropped(kind)
+	}
+
+	// none of these may block on the full queue
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		assert.ErrorIs(t, room.TryEnqueueBroadcast("three", ""), app.ErrRoomBusy)
+		room.EnqueueEphemeral("typing", "user1")
+		assert.False(t, room.EnqueueRemote("remote"))
+		assert.ErrorIs(t, room.EnqueueAnnouncement("notice"), app.ErrRoomBusy)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		require.FailNow(t, "enqueue blocked on a full queue")
+	}
+	for kind, n := range before {
+		assert.Equal(t, n+1, dropped(kind), kind)
+	}
+
+	// a join waits for space instead of being dropped
+	joined := make(chan struct{})
+	go func() {
+		room.EnqueueJoin(&RoomClient{UserID: "user2", User: &User{ID: "user2", Name: "User Two"}, Send: make(chan interface{}, 10)})
+		close(joined)
+	}()
+	select {
+	case <-joined:
+		require.FailNow(t, "join should wait while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	go room.Run()
+	defer room.EnqueueClose()
+	select {
+	case <-joined:
+	case <-time.After(time.Second):
+		require.FailNow(t, "join not queued once the room drained")
+	}
+}
+
+func TestCoordinatorSendMessageRoomBusy(t *testing.T) {
+	c := NewCoordinator(WithEnqueueTimeout(20 * time.Millisecond))
+	// a room whose loop never runs, with its one queue slot taken
+	room := NewRoom("room_1", "Room", "author1", WithEventQueueSize(1))
+	room.users["author1"] = &User{ID: "author1", Name: "Author"}
+	c.rooms.Store("room_1", room)
+	room.EnqueueBroadcast("filler")
+
+	started := time.Now()
+	err := c.SendMessage("room_1", "author1", "hello", "", "")
+	assert.ErrorIs(t, err, app.ErrRoomBusy)
+	assert.Less(t, time.Since(started), time.Second)
+
+	err = c.SendAttachment("room_1", "author1", "Author", "image/png", []byte{1})
+	assert.ErrorIs(t, err, app.ErrRoomBusy)
+
+	started = time.Now()
+	err = c.SendSystemMessage("room_1", "ci", "build passed")
+	assert.ErrorIs(t, err, app.ErrRoomBusy)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+// nextReceipt skips other events until a read receipt for userID arrives.
+// Receipts go to the whole room, so members also get their own.
+func nextReceipt(t *testing.T, ch <-chan interface{}, userID string) messages.ReadReceiptEvent {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case ev := <-ch:
+			if receipt, ok := ev.(messages.ReadReceiptEvent); ok && receipt.UserID == userID {
+				return receipt
+			}
+		case <-deadline:
+			require.FailNow(t, "expected a read_receipt event")
+		}
+	}
+}
+
+func TestCoordinatorReadReceipts(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 20)
+	sendUser := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser))
+	waitForUserInRoom(t, c, "room_1", "user2")
+	require.NoError(t, c.SendMessage("room_1", "author1", "first", "", ""))
+	require.NoError(t, c.SendMessage("room_1", "author1", "second", "", ""))
+	nextChat(t, sendUser)
+	last := nextChat(t, sendUser)
+
+	require.NoError(t, c.MarkRead("room_1", "user2", last.Seq))
+	receipt := nextReceipt(t, sendAuthor, "user2")
+	assert.Equal(t, messages.NewReadReceiptEvent("room_1", "user2", last.Seq), receipt)
+
+	// the author reads only as far as the first message; a position past the
+	// newest broadcast is capped at it
+	require.NoError(t, c.MarkRead("room_1", "author1", last.Seq-1))
+	assert.Equal(t, last.Seq-1, nextReceipt(t, sendUser, "author1").Seq)
+
+	require.NoError(t, c.MarkRead("room_1", "author1", last.Seq+10))
+	assert.Equal(t, last.Seq, nextReceipt(t, sendUser, "author1").Seq)
+
+	assert.Equal(t, map[string]uint64{"author1": last.Seq, "user2": last.Seq}, c.GetRoom("room_1").ReadPositions())
+
+	assert.Error(t, c.MarkRead("room_1", "user2", 0))
+	assert.ErrorIs(t, c.MarkRead("room_1", "stranger", 1), app.ErrUserNotInRoom)
+}
+
+func TestCoordinatorMessageSizeLimits(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 10)
+	require.NoError(t, c.CreateRoom("room_1", "user1", "Room", "", send))
+	waitForUserInRoom(t, c, "room_1", "user1")
+
+	// default
+	require.NoError(t, c.SendMessage("room_1", "user1", strings.Repeat("a", app.DefaultMaxMessageSize), "", ""))
+	err := c.SendMessage("room_1", "user1", strings.Repeat("a", app.DefaultMaxMessageSize+1), "", "")
+	require.ErrorIs(t, err, app.ErrContentTooLong)
+	var tooLong *app.ContentTooLongError
+	require.ErrorAs(t, err, &tooLong)
+	assert.Equal(t, app.DefaultMaxMessageSize, tooLong.Limit)
+	assert.Equal(t, app.DefaultMaxMessageSize+1, tooLong.Size)
+
+	// smaller per-room limit
+	require.NoError(t, c.SetRoomMaxMessageSize("room_1", 5))
+	require.NoError(t, c.SendMessage("room_1", "user1", "12345", "", ""))
+	require.ErrorIs(t, c.SendMessage("room_1", "user1", "123456", "", ""), app.ErrContentTooLong)
+
+	// larger per-room limit
+	require.NoError(t, c.SetRoomMaxMessageSize("room_1", 2*app.DefaultMaxMessageSize))
+	require.NoError(t, c.SendMessage("room_1", "user1", strings.Repeat("a", app.DefaultMaxMessageSize+1), "", ""))
+
+	// back to the coordinator-wide limit
+	require.NoError(t, c.SetRoomMaxMessageSize("room_1", 0))
+	require.ErrorIs(t, c.SendMessage("room_1", "user1", strings.Repeat("a", app.DefaultMaxMessageSize+1), "", ""), app.ErrContentTooLong)
+
+	require.ErrorIs(t, c.SetRoomMaxMessageSize("missing", 5), app.ErrRoomNotFound)
+}
+
+func TestCoordinatorSendSystemMessage(t *testing.T) {
+	c := NewCoordinator(WithMaxMessageSize(16))
+	send := make(chan interface{}, 10)
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room", "", send))
+
+	// the bot isn't a member, yet its message goes out flagged as system
+	require.NoError(t, c.SendSystemMessage("room_1", "ci", "build passed"))
+	msg := nextChat(t, send)
+	assert.True(t, msg.System)
+	assert.Equal(t, "ci", msg.UserName)
+	assert.Equal(t, "build passed", msg.Message.Message)
+
+	assert.ErrorIs(t, c.SendSystemMessage("room_1", "ci", strings.Repeat("a", 17)), app.ErrContentTooLong)
+	assert.ErrorIs(t, c.SendSystemMessage("room_1", "ci", ""), app.ErrEmptyContent)
+	assert.ErrorIs(t, c.SendSystemMessage("missing", "ci", "hi"), app.ErrRoomNotFound)
+
+	key, err := c.IssueRoomAPIKey("room_1")
+	require.NoError(t, err)
+	assert.NoError(t, c.VerifyRoomAPIKey("room_1", key))
+	assert.ErrorIs(t, c.VerifyRoomAPIKey("room_1", "wrong"), app.ErrInvalidAPIKey)
+}
+
+func TestCoordinatorMaxMessageSizeOption(t *testing.T) {
+	c := NewCoordinator(WithMaxMessageSize(3))
+	send := make(chan interface{}, 10)
+	require.NoError(t, c.CreateRoom("room_1", "user1", "Room", "", send))
+	waitForUserInRoom(t, c, "room_1", "user1")
+
+	require.NoError(t, c.SendMessage("room_1", "user1", "abc", "", ""))
+	require.ErrorIs(t, c.SendMessage("room_1", "user1", "abcd", "", ""), app.ErrContentTooLong)
+}
+
+func TestCoordinatorContentFilter(t *testing.T) {
+	c := NewCoordinator(WithContentFilter(NewWordlistFilter([]string{"darn"}, false)))
+	send := make(chan interface{}, 10)
+	require.NoError(t, c.CreateRoom("room_1", "user1", "Room", "", send))
+	waitForUserInRoom(t, c, "room_1", "user1")
+
+	require.NoError(t, c.SendMessage("room_1", "user1", "darn it", "", ""))
+	msg := nextChat(t, send)
+	assert.Equal(t, "**** it", msg.Message.Message)
+
+	c.filter = NewWordlistFilter([]string{"darn"}, true)
+	err := c.SendMessage("room_1", "user1", "darn it", "", "")
+	require.ErrorIs(t, err, app.ErrContentRejected)
+
+	history := c.GetRoom("room_1").HistoryBefore(0, 10)
+	require.Len(t, history, 1, "rejected messages are not recorded")
+}
+
+func TestCoordinatorLeaveOneRoomKeepsSharedChannel(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 20)
+	sendUser := make(chan interface{}, 20) // one connection in two rooms
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.CreateRoom("room_2", "author1", "Room Two", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser))
+	require.NoError(t, c.JoinRoom("room_2", "user2", "User Two", "", sendUser))
+	waitForUserInRoom(t, c, "room_1", "user2")
+	waitForUserInRoom(t, c, "room_2", "user2")
+
+	require.NoError(t, c.LeaveRoom("room_1", "user2"))
+	require.NoError(t, c.SendMessage("room_2", "author1", "still here", "", ""))
+	require.NoError(t, c.SendMessage("room_1", "author1", "not for you", "", ""))
+
+	msg := nextChat(t, sendUser)
+	assert.Equal(t, "room_2", msg.RoomID)
+	assert.Equal(t, "still here", msg.Message.Message)
+	for len(sendUser) > 0 {
+		ev := <-sendUser
+		if chat, ok := ev.(messages.RoomMessageEvent); ok {
+			assert.NotEqual(t, "room_1", chat.RoomID)
+		}
+	}
+}
+
+func TestCoordinatorEmptyRoomRejoinWithinGrace(t *testing.T) {
+	c := NewCoordinator(WithEmptyRoomGrace(100 * time.Millisecond))
+	send := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+	require.NoError(t, c.SendMessage("room_1", "author1", "still here?", "", ""))
+	require.NoError(t, c.LeaveRoom("room_1", "author1"))
+
+	time.Sleep(50 * time.Millisecond)
+	rejoin := make(chan interface{}, 20)
+	require.NoError(t, c.JoinRoom("room_1", "author1", "Author", "", rejoin))
+	waitForUserInRoom(t, c, "room_1", "author1")
+
+	// the pending close was cancelled, so the room and its history survive
+	time.Sleep(150 * time.Millisecond)
+	require.NotNil(t, c.GetRoom("room_1"))
+	msg := nextChat(t, rejoin)
+	assert.Equal(t, "still here?", msg.Message.Message)
+	assert.True(t, msg.Historical)
+}
+
+func TestCoordinatorEmptyRoomDeletedAfterGrace(t *testing.T) {
+	c := NewCoordinator(WithEmptyRoomGrace(50 * time.Millisecond))
+	send := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+	room := c.GetRoom("room_1")
+	require.NoError(t, c.LeaveRoom("room_1", "author1"))
+
+	// kept during the grace period
+	require.NotNil(t, c.GetRoom("room_1"))
+
+	select {
+	case <-room.done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "room loop did not exit after the grace period")
+	}
+	assert.Nil(t, c.GetRoom("room_1"))
+	assert.ErrorIs(t, c.JoinRoom("room_1", "user2", "User Two", "", send), app.ErrRoomNotFound)
+}
+
+func TestCoordinatorSweepReapsOrphanedRoom(t *testing.T) {
+	c := NewCoordinator(WithEmptyRoomGrace(time.Hour), WithRoomSweepInterval(20*time.Millisecond))
+	send := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	require.NoError(t, c.CreateRoom("room_2", "author2", "Room Two", "", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+	waitForUserInRoom(t, c, "room_2", "author2")
+	orphan := c.GetRoom("room_1")
+
+	// the member disappears without a leave ever reaching the loop, so no
+	// empty-room close is scheduled
+	orphan.mu.Lock()
+	delete(orphan.users, "author1")
+	delete(orphan.clients, "author1")
+	orphan.mu.Unlock()
+
+	select {
+	case <-orphan.done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "orphaned room was not reaped")
+	}
+	assert.Nil(t, c.GetRoom("room_1"))
+	// rooms with members are left alone
+	assert.NotNil(t, c.GetRoom("room_2"))
+}
+
+func TestCoordinatorIdleRoomReaped(t *testing.T) {
+	c := NewCoordinator(WithIdleRoomTimeout(150*time.Millisecond), WithRoomSweepInterval(20*time.Millisecond))
+	sendIdle := make(chan interface{}, 20)
+	sendActive := make(chan interface{}, 100)
+
+	require.NoError(t, c.CreateRoom("idle", "author1", "Idle", "", sendIdle))
+	require.NoError(t, c.CreateRoom("active", "author2", "Active", "", sendActive))
+	waitForUserInRoom(t, c, "idle", "author1")
+	waitForUserInRoom(t, c, "active", "author2")
+	idle := c.GetRoom("idle")
+
+	// keep one room busy past the other's timeout
+	deadline := time.After(300 * time.Millisecond)
+	tick := time.NewTicker(30 * time.Millisecond)
+	defer tick.Stop()
+keepBusy:
+	for {
+		select {
+		case <-tick.C:
+			require.NoError(t, c.SendMessage("active", "author2", "still here", "", ""))
+		case <-deadline:
+			break keepBusy
+		}
+	}
+
+	select {
+	case <-idle.done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "idle room was not reaped")
+	}
+	assert.Nil(t, c.GetRoom("idle"))
+	assert.NotNil(t, c.GetRoom("active"))
+
+	for {
+		select {
+		case ev := <-sendIdle:
+			if closed, ok := ev.(messages.RoomClosedEvent); ok {
+				assert.Equal(t, "idle", closed.RoomID)
+				return
+			}
+		default:
+			require.FailNow(t, "expected a room_closed event")
+		}
+	}
+}
+
+func TestCoordinatorAuthorLeaveTransfersOwnership(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 20)
+	sendUser2 := make(chan interface{}, 20)
+	sendUser3 := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+	waitForUserInRoom(t, c, "room_1", "user2")
+	require.NoError(t, c.JoinRoom("room_1", "user3", "User Three", "", sendUser3))
+	waitForUserInRoom(t, c, "room_1", "user3")
+
+	require.NoError(t, c.LeaveRoom("room_1", "author1"))
+
+	// user2 has been in the room longest
+	for _, ch := range []chan interface{}{sendUser2, sendUser3} {
+		var changed *messages.AuthorChangedEvent
+		require.Eventually(t, func() bool {
+			for len(ch) > 0 {
+				if ev, ok := (<-ch).(messages.AuthorChangedEvent); ok {
+					changed = &ev
+				}
+			}
+			return changed != nil
+		}, 200*time.Millisecond, 5*time.Millisecond)
+		assert.Equal(t, "room_1", changed.RoomID)
+		assert.Equal(t, "user2", changed.NewAuthorID)
+	}
+	assert.Equal(t, "user2", c.GetRoom("room_1").Author())
+
+	// the new author can moderate
+	require.NoError(t, c.KickUser("room_1", "user2", "user3"))
+}
+
+func TestCoordinatorAuthorLeaveEmptyRoomDeletes(t *testing.T) {
+	c := NewCoordinator(WithEmptyRoomGrace(0))
+	send := make(chan interface{}, 20)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+	require.NoError(t, c.LeaveRoom("room_1", "author1"))
+
+	require.Eventually(t, func() bool { return c.GetRoom("room_1") == nil }, 200*time.Millisecond, 5*time.Millisecond)
+	for len(send) > 0 {
+		_, changed := (<-send).(messages.AuthorChangedEvent)
+		assert.False(t, changed, "nobody is left to take over")
+	}
+}
+
+func TestCoordinatorKickUser(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 10)
+	sendUser2 := make(chan interface{}, 10)
+	sendUser3 := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+	require.NoError(t, c.JoinRoom("room_1", "user3", "User Three", "", sendUser3))
+	waitForUserInRoom(t, c, "room_1", "user3")
+
+	// non-author cannot kick
+	err := c.KickUser("room_1", "user2", "user3")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only the room author")
+
+	// author cannot kick themselves or someone absent
+	require.Error(t, c.KickUser("room_1", "author1", "author1"))
+	require.Error(t, c.KickUser("room_1", "author1", "ghost"))
+	require.Error(t, c.KickUser("no_room", "author1", "user2"))
+
+	require.NoError(t, c.KickUser("room_1", "author1", "user2"))
+
+	// both the remaining members and the target see the kick
+	expectUserKickedEvent(t, sendAuthor, "room_1", "user2", "author1")
+	expectUserKickedEvent(t, sendUser2, "room_1", "user2", "author1")
+
+	require.Eventually(t, func() bool {
+		_, inRoom := c.GetRoom("room_1").GetUsers()["user2"]
+		return !inRoom
+	}, 200*time.Millisecond, 5*time.Millisecond, "kicked user should be removed from room")
+}
+
+func TestCoordinatorModerators(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 50)
+	sendMod := make(chan interface{}, 50)
+	sendUser3 := make(chan interface{}, 50)
+	sendUser4 := make(chan interface{}, 50)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "mod", "Mod", "", sendMod))
+	require.NoError(t, c.JoinRoom("room_1", "user3", "User Three", "", sendUser3))
+	require.NoError(t, c.JoinRoom("room_1", "user4", "User Four", "", sendUser4))
+	waitForUserInRoom(t, c, "room_1", "user4")
+	room := c.GetRoom("room_1")
+
+	// members can't moderate, or make themselves moderators
+	err := c.KickUser("room_1", "mod", "user3")
+	assert.ErrorContains(t, err, "only the room author or a moderator")
+	assert.ErrorContains(t, c.GrantModerator("room_1", "mod", "mod"), "only the room author or a moderator")
+
+	require.NoError(t, c.GrantModerator("room_1", "author1", "mod"))
+	assert.True(t, room.IsModerator("mod"))
+	assert.True(t, room.CanModerate("author1"))
+	assert.False(t, room.CanModerate("user3"))
+	expectModeratorChangedEvent(t, sendUser3, "mod", true, "author1")
+
+	// granting twice changes nothing; the author and absent users can't be granted
+	require.NoError(t, c.GrantModerator("room_1", "author1", "mod"))
+	assert.ErrorContains(t, c.GrantModerator("room_1", "mod", "author1"), "always a moderator")
+	assert.ErrorIs(t, c.GrantModerator("room_1", "author1", "ghost"), app.ErrUserNotInRoom)
+	assert.ErrorIs(t, c.GrantModerator("no_room", "author1", "mod"), app.ErrRoomNotFound)
+
+	// a moderator deletes others' messages and kicks members, but not the author
+	require.NoError(t, c.SendMessage("room_1", "user3", "spam", "", ""))
+	spam := nextChat(t, sendMod)
+	require.NoError(t, c.DeleteMessage("room_1", "mod", spam.MessageID))
+	expectMessageDeletedEvent(t, sendUser3, spam.MessageID, "mod")
+	assert.ErrorContains(t, c.KickUser("room_1", "mod", "author1"), "cannot kick the room author")
+	require.NoError(t, c.KickUser("room_1", "mod", "user3"))
+	expectUserKickedEvent(t, sendAuthor, "room_1", "user3", "mod")
+
+	// moderators may grant and revoke too
+	require.NoError(t, c.GrantModerator("room_1", "mod", "user4"))
+	expectModeratorChangedEvent(t, sendAuthor, "user4", true, "mod")
+	require.NoError(t, c.RevokeModerator("room_1", "user4", "mod"))
+	expectModeratorChangedEvent(t, sendAuthor, "mod", false, "user4")
+	assert.False(t, room.IsModerator("mod"))
+	assert.ErrorContains(t, c.KickUser("room_1", "mod", "user4"), "only the room author or a moderator")
+
+	// leaving gives the role up
+	require.NoError(t, c.LeaveRoom("room_1", "user4"))
+	require.Eventually(t, func() bool { return !room.IsModerator("user4") }, time.Second, 5*time.Millisecond)
+}
+
+func TestCoordinatorMuteUser(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 50)
+	sendMod := make(chan interface{}, 50)
+	sendUser3 := make(chan interface{}, 50)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "mod", "Mod", "", sendMod))
+	require.NoError(t, c.JoinRoom("room_1", "user3", "User Three", "", sendUser3))
+	waitForUserInRoom(t, c, "room_1", "user3")
+
+	// members can't mute; moderators can, but not each other or the author
+	assert.ErrorContains(t, c.MuteUser("room_1", "mod", "user3"), "only the room author or a moderator")
+	require.NoError(t, c.GrantModerator("room_1", "author1", "mod"))
+	assert.ErrorContains(t, c.MuteUser("room_1", "mod", "author1"), "cannot mute")
+	assert.ErrorContains(t, c.MuteUser("room_1", "author1", "mod"), "cannot mute")
+	assert.ErrorIs(t, c.MuteUser("room_1", "mod", "ghost"), app.ErrUserNotInRoom)
+
+	require.NoError(t, c.MuteUser("room_1", "mod", "user3"))
+	expectEvent[messages.UserMutedEvent](t, sendAuthor)
+	err := c.SendMessage("room_1", "user3", "let me talk", "", "")
+	assert.ErrorIs(t, err, app.ErrMuted)
+	assert.ErrorIs(t, c.SendAttachment("room_1", "user3", "User Three", "image/png", []byte("png")), app.ErrMuted)
+
+	// rejoining doesn't lift a mute
+	require.NoError(t, c.LeaveRoom("room_1", "user3"))
+	require.NoError(t, c.JoinRoom("room_1", "user3", "User Three", "", sendUser3))
+	waitForUserInRoom(t, c, "room_1", "user3")
+	assert.ErrorIs(t, c.SendMessage("room_1", "user3", "still here", "", ""), app.ErrMuted)
+
+	require.NoError(t, c.UnmuteUser("room_1", "author1", "user3"))
+	unmuted := expectEvent[messages.UserUnmutedEvent](t, sendMod)
+	assert.Equal(t, "user3", unmuted.UserID)
+	assert.Equal(t, "author1", unmuted.UnmutedBy)
+	require.NoError(t, c.SendMessage("room_1", "user3", "thanks", "", ""))
+	expectChatFrom(t, sendAuthor, "user3", "User Three", "thanks")
+}
+
+func TestCoordinatorSetRoomMeta(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 50)
+	sendMod := make(chan interface{}, 50)
+	sendUser3 := make(chan interface{}, 50)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "mod", "Mod", "", sendMod))
+	require.NoError(t, c.JoinRoom("room_1", "user3", "User Three", "", sendUser3))
+	waitForUserInRoom(t, c, "room_1", "user3")
+
+	t.Run("authorization", func(t *testing.T) {
+		assert.ErrorContains(t, c.SetRoomMeta("room_1", "user3", "mine now", ""), "only the room author or a moderator")
+		assert.ErrorIs(t, c.SetRoomMeta("no_room", "author1", "topic", ""), app.ErrRoomNotFound)
+		topic, _ := c.GetRoom("room_1").Meta()
+		assert.Empty(t, topic)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		assert.ErrorIs(t, c.SetRoomMeta("room_1", "author1", strings.Repeat("t", app.MaxRoomTopicLength+1), ""), app.ErrInvalidRoomMeta)
+		assert.ErrorIs(t, c.SetRoomMeta("room_1", "author1", "two\nlines", ""), app.ErrInvalidRoomMeta)
+		assert.ErrorIs(t, c.SetRoomMeta("room_1", "author1", "", strings.Repeat("d", app.MaxRoomDescriptionLength+1)), app.ErrInvalidRoomMeta)
+	})
+
+	t.Run("author sets and members are told", func(t *testing.T) {
+		require.NoError(t, c.SetRoomMeta("room_1", "author1", " Release planning ", "Line one\nline two"))
+		for _, ch := range []chan interface{}{sendAuthor, sendMod, sendUser3} {
+			ev := expectEvent[messages.RoomMetaUpdatedEvent](t, ch)
+			assert.Equal(t, "room_1", ev.RoomID)
+			assert.Equal(t, "Release planning", ev.Topic)
+			assert.Equal(t, "Line one\nline two", ev.Description)
+			assert.Equal(t, "author1", ev.UpdatedBy)
+		}
+
+		summaries := c.ListRooms(RoomFilter{})
+		require.Len(t, summaries, 1)
+		assert.Equal(t, "Release planning", summaries[0].Topic)
+		assert.Equal(t, "Line one\nline two", summaries[0].Description)
+	})
+
+	t.Run("moderator clears the description", func(t *testing.T) {
+		require.NoError(t, c.GrantModerator("room_1", "author1", "mod"))
+		require.NoError(t, c.SetRoomMeta("room_1", "mod", "Release planning", ""))
+		ev := expectEvent[messages.RoomMetaUpdatedEvent](t, sendUser3)
+		assert.Empty(t, ev.Description)
+		assert.Equal(t, "mod", ev.UpdatedBy)
+	})
+
+	t.Run("unchanged values send nothing", func(t *testing.T) {
+		require.NoError(t, c.SetRoomMeta("room_1", "author1", "Release planning", ""))
+		require.NoError(t, c.SendMessage("room_1", "author1", "after", "", ""))
+		for {
+			ev := expectEvent[interface{}](t, sendUser3)
+			_, isMeta := ev.(messages.RoomMetaUpdatedEvent)
+			require.False(t, isMeta)
+			if chat, ok := ev.(messages.RoomMessageEvent); ok && chat.Message.Message == "after" {
+				break
+			}
+		}
+	})
+
+	t.Run("joiners get it ahead of history", func(t *testing.T) {
+		sendJoiner := make(chan interface{}, 50)
+		require.NoError(t, c.JoinRoom("room_1", "user4", "User Four", "", sendJoiner))
+		meta, ok := (<-sendJoiner).(messages.RoomMetaUpdatedEvent)
+		require.True(t, ok)
+		assert.Equal(t, "Release planning", meta.Topic)
+		assert.Equal(t, "mod", meta.UpdatedBy)
+		history, ok := (<-sendJoiner).(messages.RoomMessageEvent)
+		require.True(t, ok)
+		assert.True(t, history.Historical)
+	})
+}
+
+// expectEvent waits for the next event of type T on ch, skipping others
+func expectEvent[T any](t *testing.T, ch <-chan interface{}) T {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case ev := <-ch:
+			if got, ok := ev.(T); ok {
+				return got
+			}
+		case <-deadline:
+			var zero T
+			require.FailNowf(t, "event not received", "expected a %T", zero)
+			return zero
+		}
+	}
+}
+
+func expectModeratorChangedEvent(t *testing.T, ch <-chan interface{}, userID string, isModerator bool, changedBy string) {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case ev := <-ch:
+			mc, ok := ev.(messages.ModeratorChangedEvent)
+			if !ok {
+				continue
+			}
+			assert.Equal(t, userID, mc.UserID)
+			assert.Equal(t, isModerator, mc.IsModerator)
+			assert.Equal(t, changedBy, mc.ChangedBy)
+			return
+		case <-deadline:
+			require.FailNow(t, "expected a moderator_changed event")
+		}
+	}
+}
+
+func TestCoordinatorRenameUser(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 10)
+	sendUser2 := make(chan interface{}, 10)
+	sendOther := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.CreateRoom("room_2", "author2", "Room Two", "", sendOther))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+	waitForUserInRoom(t, c, "room_1", "user2")
+
+	require.Error(t, c.RenameUser("user2", ""))
+	require.NoError(t, c.RenameUser("user2", "Second User"))
+
+	expectUserRenamedEvent(t, sendAuthor, "room_1", "user2", "User Two", "Second User")
+
+	require.Eventually(t, func() bool {
+		u := c.GetRoom("room_1").GetUsers()["user2"]
+		return u != nil && u.Name == "Second User"
+	}, 200*time.Millisecond, 5*time.Millisecond)
+
+	// rooms the user is not in are untouched
+	for len(sendOther) > 0 {
+		_, renamed := (<-sendOther).(messages.UserRenamedEvent)
+		assert.False(t, renamed, "room_2 should not see a rename for user2")
+	}
+}
+
+func TestCoordinatorBroadcastTyping(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 10)
+	sendUser2 := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendUser2))
+	waitForUserInRoom(t, c, "room_1", "user2")
+
+	require.Error(t, c.BroadcastTyping("room_1", "ghost", "Ghost", true))
+	require.NoError(t, c.BroadcastTyping("room_1", "user2", "User Two", true))
+
+	// the other member receives the typing event
+	var got *messages.TypingEvent
+	require.Eventually(t, func() bool {
+		for len(sendAuthor) > 0 {
+			if te, ok := (<-sendAuthor).(messages.TypingEvent); ok {
+				got = &te
+			}
+		}
+		return got != nil
+	}, 200*time.Millisecond, 5*time.Millisecond)
+	assert.Equal(t, messages.EventTyping, got.Type)
+	assert.Equal(t, "user2", got.UserID)
+	assert.True(t, got.IsTyping)
+
+	// the sender does not
+	for len(sendUser2) > 0 {
+		_, isTyping := (<-sendUser2).(messages.TypingEvent)
+		assert.False(t, isTyping, "sender should not receive its own typing event")
+	}
+
+	// and typing never lands in history
+	room := c.GetRoom("room_1")
+	room.mu.RLock()
+	assert.Zero(t, room.history.Len())
+	room.mu.RUnlock()
+}
+
+func TestCoordinatorDetachAndReattach(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 10)
+	sendOld := make(chan interface{}, 10)
+	sendNew := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendOld))
+	waitForUserInRoom(t, c, "room_1", "user2")
+
+	require.NoError(t, c.DetachClient("room_1", "user2"))
+	require.Error(t, c.DetachClient("room_1", "ghost"))
+
+	// detached user stays a member but stops receiving
+	require.NoError(t, c.SendMessage("room_1", "author1", "while away", "", ""))
+	expectChatFrom(t, sendAuthor, "author1", "author1", "while away")
+	assert.Equal(t, 2, c.GetRoom("room_1").GetUserCount())
+	for len(sendOld) > 0 {
+		_, isChat := (<-sendOld).(messages.RoomMessageEvent)
+		assert.False(t, isChat, "detached channel should not receive broadcasts")
+	}
+
+	require.NoError(t, c.ReattachClient("room_1", "user2", sendNew))
+	require.NoError(t, c.SendMessage("room_1", "author1", "welcome back", "", ""))
+	expectChatFrom(t, sendNew, "author1", "author1", "welcome back")
+
+	// no join was broadcast for the reattach
+	for len(sendAuthor) > 0 {
+		_, isJoin := (<-sendAuthor).(messages.UserJoinedEvent)
+		assert.False(t, isJoin, "reattach must not broadcast a join")
+	}
+}
+
+func TestRemoteEventsFollowLocalSeq(t *testing.T) {
+	room := NewRoom("room_1", "Room One", "author1")
+	go room.Run()
+	defer room.EnqueueClose()
+	send := make(chan interface{}, 10)
+	room.EnqueueJoin(&RoomClient{User: &User{ID: "author1", Name: "Author"}, UserID: "author1", Send: send})
+	room.EnqueueBroadcast(messages.NewRoomMessageEvent("room_1", "author1", "Author", "local"))
+	assert.Equal(t, uint64(1), nextChat(t, send).Seq)
+
+	// the origin numbered these with its own, unrelated sequence
+	joined := messages.NewUserJoinedEvent("room_1", "user2", "User Two")
+	joined.Seq = 40
+	left := messages.NewUserLeftEvent("room_1", "user2", "User Two")
+	left.Seq = 41
+	for _, ev := range []interface{}{joined, left} {
+		payload, err := json.Marshal(ev)
+		require.NoError(t, err)
+		require.True(t, room.EnqueueRemote(decodeRemoteEvent(payload)))
+	}
+
+	var seqs []uint64
+	for len(seqs) < 2 {
+		select {
+		case ev := <-send:
+			switch e := ev.(type) {
+			case messages.UserJoinedEvent:
+				seqs = append(seqs, e.Seq)
+			case messages.UserLeftEvent:
+				seqs = append(seqs, e.Seq)
+			}
+		case <-time.After(time.Second):
+			require.FailNow(t, "remote join and leave not delivered", "got %v", seqs)
+		}
+	}
+	assert.Equal(t, []uint64{2, 3}, seqs)
+}
+
+func TestCoordinatorBroadcastAcrossInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	newInstance := func() *Coordinator {
+		b := broadcast.NewRedisBroadcaster(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		t.Cleanup(func() { _ = b.Close() })
+		return NewCoordinator(WithBroadcaster(b))
+	}
+
+	a := newInstance()
+	b := newInstance()
+
+	sendA := make(chan interface{}, 10)
+	sendB := make(chan interface{}, 10)
+	require.NoError(t, a.CreateRoom("room_1", "alice", "Room One", "", sendA))
+	require.NoError(t, b.CreateRoom("room_1", "bob", "Room One", "", sendB))
+	waitForUserInRoom(t, a, "room_1", "alice")
+	waitForUserInRoom(t, b, "room_1", "bob")
+
+	require.NoError(t, a.SendMessage("room_1", "alice", "hello from a", "", ""))
+
+	countChats := func(ch <-chan interface{}) int {
+		n := 0
+		deadline := time.After(300 * time.Millisecond)
+		for {
+			select {
+			case ev := <-ch:
+				if msg, ok := ev.(messages.RoomMessageEvent); ok && msg.Message.Message == "hello from a" {
+					n++
+				}
+			case <-deadline:
+				return n
+			}
+		}
+	}
+
+	// delivered once to each instance's member: no echo of our own publish
+	assert.Equal(t, 1, countChats(sendB), "remote member should receive the message once")
+	assert.Equal(t, 1, countChats(sendA), "local member should not get a duplicate from redis")
+
+	// the remote copy is recorded in B's history for late joiners
+	room := b.GetRoom("room_1")
+	room.mu.RLock()
+	assert.Equal(t, 1, room.history.Len())
+	room.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, a.Shutdown(ctx))
+	require.NoError(t, b.Shutdown(ctx))
+}
+
+func TestCoordinatorShutdown(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	require.NoError(t, c.CreateRoom("room_2", "author2", "Room Two", "", send))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := c.Shutdown(ctx)
+	require.NoError(t, err)
+}
+
+func TestCoordinatorShutdownWaitsForRooms(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 10)
+
+	var rooms []*Room
+	for _, id := range []string{"room_1", "room_2", "room_3"} {
+		require.NoError(t, c.CreateRoom(id, "author1", "Room", "", send))
+		room, ok := c.rooms.Load(id)
+		require.True(t, ok)
+		rooms = append(rooms, room)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, c.Shutdown(ctx))
+
+	for _, room := range rooms {
+		select {
+		case <-room.done:
+		default:
+			assert.Failf(t, "room still running after Shutdown", "room %s", room.ID)
+		}
+	}
+}
+
+func TestCoordinatorShutdownDuringCreate(t *testing.T) {
+	c := NewCoordinator()
+
+	const creators = 8
+	var wg sync.WaitGroup
+	wg.Add(creators)
+	for g := 0; g < creators; g++ {
+		go func(g int) {
+			defer wg.Done()
+			send := make(chan interface{}, 256)
+			for i := 0; i < 50; i++ {
+				err := c.CreateRoom(fmt.Sprintf("room_%d_%d", g, i), "author1", "Room", "", send)
+				if errors.Is(err, app.ErrShuttingDown) {
+					return
+				}
+				assert.NoError(t, err)
+			}
+		}(g)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, c.Shutdown(ctx))
+	wg.Wait()
+
+	// every room that made it into the store was closed by Shutdown
+	for _, room := range c.rooms.Snapshot() {
+		select {
+		case <-room.done:
+		default:
+			assert.Failf(t, "room still running after Shutdown", "room %s", room.ID)
+		}
+	}
+	assert.ErrorIs(t, c.CreateRoom("late", "author1", "Room", "", make(chan interface{}, 1)), app.ErrShuttingDown)
+}
+
+func TestCoordinatorCloseRoom(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 10)
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+	room := c.GetRoom("room_1")
+
+	require.NoError(t, c.CloseRoom("room_1"))
+	assert.Nil(t, c.GetRoom("room_1"))
+	assert.ErrorIs(t, c.CloseRoom("room_1"), app.ErrRoomNotFound)
+
+	select {
+	case <-room.done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "room loop did not stop")
+	}
+	for {
+		select {
+		case ev := <-send:
+			if closed, ok := ev.(messages.RoomClosedEvent); ok {
+				assert.Equal(t, "room_1", closed.RoomID)
+				return
+			}
+		default:
+			require.FailNow(t, "expected a room_closed event")
+		}
+	}
+}
+
+// panickingBroadcaster panics when asked to publish a payload containing
+// trigger, standing in for any bug hit while handling a room's broadcasts
+type panickingBroadcaster struct{ trigger string }
+
+func (b panickingBroadcaster) Publish(_ context.Context, env broadcast.Envelope) error {
+	if strings.Contains(string(env.Payload), b.trigger) {
+		panic("publish exploded")
+	}
+	return nil
+}
+
+func (panickingBroadcaster) Subscribe(context.Context) (<-chan broadcast.Envelope, error) {
+	return make(chan broadcast.Envelope), nil
+}
+
+func (panickingBroadcaster) Close() error { return nil }
+
+// stalledBroadcaster blocks every publish until release is closed
+type stalledBroadcaster struct{ release chan struct{} }
+
+func (b stalledBroadcaster) Publish(ctx context.Context, _ broadcast.Envelope) error {
+	select {
+	case <-b.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (stalledBroadcaster) Subscribe(context.Context) (<-chan broadcast.Envelope, error) {
+	return make(chan broadcast.Envelope), nil
+}
+
+func (stalledBroadcaster) Close() error { return nil }
+
+func TestCoordinatorSlowPublishDoesNotDelayMembers(t *testing.T) {
+	b := stalledBroadcaster{release: make(chan struct{})}
+	defer close(b.release)
+	c := NewCoordinator(WithBroadcaster(b))
+	send := make(chan interface{}, 32)
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+
+	// each publish would hold the loop for the full publish timeout
+	started := time.Now()
+	for _, text := range []string{"one", "two", "three"} {
+		require.NoError(t, c.SendMessage("room_1", "author1", text, "", ""))
+		assert.Equal(t, text, nextChat(t, send).Message.Message)
+	}
+	assert.Less(t, time.Since(started), publishTimeout)
+}
+
+func TestCoordinatorRoomPanicClosesRoom(t *testing.T) {
+	c := NewCoordinator(WithBroadcaster(panickingBroadcaster{trigger: "boom"}))
+	send := make(chan interface{}, 32)
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+	room := c.GetRoom("room_1")
+
+	require.NoError(t, c.SendMessage("room_1", "author1", "boom", "", ""))
+
+	select {
+	case <-room.done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "room loop did not stop")
+	}
+	assert.Nil(t, c.GetRoom("room_1"), "a room whose loop died must not stay registered")
+
+	var gotError, gotClosed bool
+	for !gotClosed {
+		select {
+		case ev := <-send:
+			switch e := ev.(type) {
+			case messages.RoomErrorEvent:
+				assert.Equal(t, "room_1", e.RoomID)
+				gotError = true
+			case messages.RoomClosedEvent:
+				gotClosed = true
+			}
+		default:
+			require.FailNow(t, "expected a room_closed event")
+		}
+	}
+	assert.True(t, gotError, "room_error must come before room_closed")
+
+	// the id is free again and the creator's allowance was given back
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+}
+
+func TestCoordinatorRoomPanicUnderLockClosesRoom(t *testing.T) {
+	c := NewCoordinator()
+	send := make(chan interface{}, 32)
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", send))
+	waitForUserInRoom(t, c, "room_1", "author1")
+	room := c.GetRoom("room_1")
+
+	// a nil client panics in handleJoin while r.mu is held
+	room.EnqueueJoin(nil)
+
+	select {
+	case <-room.done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "room loop did not stop")
+	}
+	assert.Nil(t, c.GetRoom("room_1"), "a room whose loop died must not stay registered")
+	assert.Equal(t, 0, room.GetUserCount(), "the room lock is free again")
+
+	var gotClosed bool
+	for !gotClosed {
+		select {
+		case ev := <-send:
+			_, gotClosed = ev.(messages.RoomClosedEvent)
+		default:
+			require.FailNow(t, "expected a room_closed event")
+		}
+	}
+}
+
+func TestCoordinatorShutdownNotifiesMembers(t *testing.T) {
+	c := NewCoordinator()
+	sendAuthor := make(chan interface{}, 10)
+	sendMember := make(chan interface{}, 10)
+
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room One", "", sendAuthor))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", sendMember))
+	waitForUserInRoom(t, c, "room_1", "user2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, c.Shutdown(ctx))
+
+	for _, ch := range []chan interface{}{sendAuthor, sendMember} {
+		deadline := time.After(time.Second)
+	wait:
+		for {
+			select {
+			case ev := <-ch:
+				closed, ok := ev.(messages.RoomClosedEvent)
+				if !ok {
+					continue
+				}
+				assert.Equal(t, messages.EventRoomClosed, closed.Type)
+				assert.Equal(t, "room_1", closed.RoomID)
+				break wait
+			case <-deadline:
+				require.FailNow(t, "expected a room_closed event")
+			}
+		}
+	}
+}
+
+func waitForUserInRoom(t *testing.T, c *Coordinator, roomID, userID string) {
+	t.Helper()
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		room := c.GetRoom(roomID)
+		require.NotNil(t, room)
+		if _, ok := room.GetUsers()[userID]; ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	require.Failf(t, "user not in room", "user %s was not in room %s in time", userID, roomID)
+}
+
+func expectChatFrom(t *testing.T, ch <-chan interface{}, wantUserID, wantUserName, wantMsg string) {
+	t.Helper()
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		select {
+		case ev := <-ch:
+			// Only care about RoomMessageEvent; skip others (e.g. RoomCreateEvent, UserJoinedEvent, UserLeftEvent).
+			msg, ok := ev.(messages.RoomMessageEvent)
+			if !ok {
+				continue
+			}
+			if msg.Type != messages.EventNewMessage {
+				continue
+			}
+			if msg.UserID == wantUserID && msg.UserName == wantUserName && msg.Message.Message == wantMsg {
+				return
+			}
+		default:
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	require.Failf(t, "did not see expected chat message",
+		"expected message from %s (%s) with content %q", wantUserID, wantUserName, wantMsg)
+}
+
+func expectUserLeftEvent(t *testing.T, ch <-chan interface{}, roomID, userID, userName string) {
+	t.Helper()
+	deadline := time.Now().Add(200 * time.Millisecond)
+
+	for time.Now().Before(deadline) {
+		select {
+		case ev := <-ch:
+			ul, ok := ev.(messages.UserLeftEvent)
+			if !ok {
+				continue
+			}
+			if ul.Type == messages.EventUserLeftRoom &&
+				ul.RoomID == roomID &&
+				ul.UserID == userID &&
+				ul.UserName == userName {
+				return
+			}
+		default:
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	assert.Failf(t, "expected UserLeftEvent to be broadcast",
+		"did not see UserLeftEvent for room=%q userID=%q userName=%q", roomID, userID, userName)
+}
+
+func expectUserKickedEvent(t *testing.T, ch <-chan interface{}, roomID, userID, kickedBy string) {
+	t.Helper()
+	deadline := time.Now().Add(200 * time.Millisecond)
+
+	for time.Now().Before(deadline) {
+		select {
+		case ev := <-ch:
+			uk, ok := ev.(messages.UserKickedEvent)
+			if !ok {
+				continue
+			}
+			if uk.Type == messages.EventUserKicked &&
+				uk.RoomID == roomID &&
+				uk.UserID == userID &&
+				uk.KickedBy == kickedBy {
+				return
+			}
+		default:
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	assert.Failf(t, "expected UserKickedEvent to be broadcast",
+		"did not see UserKickedEvent for room=%q userID=%q kickedBy=%q", roomID, userID, kickedBy)
+}
+
+func expectUserRenamedEvent(t *testing.T, ch <-chan interface{}, roomID, userID, oldName, newName string) {
+	t.Helper()
+	deadline := time.Now().Add(200 * time.Millisecond)
+
+	for time.Now().Before(deadline) {
+		select {
+		case ev := <-ch:
+			ur, ok := ev.(messages.UserRenamedEvent)
+			if !ok {
+				continue
+			}
+			if ur.RoomID == roomID && ur.UserID == userID && ur.OldName == oldName && ur.NewName == newName {
+				return
+			}
+		default:
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	assert.Failf(t, "expected UserRenamedEvent to be broadcast",
+		"did not see UserRenamedEvent for room=%q userID=%q %q -> %q", roomID, userID, oldName, newName)
 }
@@ -0,0 +1,60 @@
+package coordinator
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultDedupWindow = 2 * time.Minute
+
+type dedupKey struct {
+	roomID, userID, clientMsgID string
+}
+
+// dedupCache remembers client message ids for a short window so resent
+// messages are broadcast only once. Expired entries are swept lazily, at most
+// once per window.
+type dedupCache struct {
+	mu        sync.Mutex
+	window    time.Duration
+	seen      map[dedupKey]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newDedupCache(window time.Duration) *dedupCache {
+	return &dedupCache{
+		window: window,
+		seen:   make(map[dedupKey]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Seen reports whether key was recorded within the window, recording it if not.
+func (d *dedupCache) Seen(key dedupKey) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if now.Sub(d.lastSweep) >= d.window {
+		for k, at := range d.seen {
+			if now.Sub(at) >= d.window {
+				delete(d.seen, k)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	if at, ok := d.seen[key]; ok && now.Sub(at) < d.window {
+		return true
+	}
+	d.seen[key] = now
+	return false
+}
+
+// Forget drops key so a message that failed to send can be retried.
+func (d *dedupCache) Forget(key dedupKey) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.seen, key)
+}
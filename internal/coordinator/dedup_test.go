@@ -0,0 +1,32 @@
+package coordinator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupCacheWindow(t *testing.T) {
+	now := time.Now()
+	d := newDedupCache(time.Minute)
+	d.now = func() time.Time { return now }
+
+	key := dedupKey{roomID: "room_1", userID: "user1", clientMsgID: "k1"}
+	assert.False(t, d.Seen(key))
+	assert.True(t, d.Seen(key))
+	assert.False(t, d.Seen(dedupKey{roomID: "room_2", userID: "user1", clientMsgID: "k1"}))
+
+	now = now.Add(time.Minute)
+	assert.False(t, d.Seen(key), "key should expire after the window")
+	assert.Len(t, d.seen, 1, "expired entries should be swept")
+}
+
+func TestDedupCacheForget(t *testing.T) {
+	d := newDedupCache(time.Minute)
+	key := dedupKey{roomID: "room_1", userID: "user1", clientMsgID: "k1"}
+
+	assert.False(t, d.Seen(key))
+	d.Forget(key)
+	assert.False(t, d.Seen(key))
+}
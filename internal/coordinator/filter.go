@@ -0,0 +1,73 @@
+package coordinator
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ContentFilter screens chat messages before they are stored or broadcast.
+// Filter returns the content to send, possibly masked, and false when the
+// message must be rejected instead.
+type ContentFilter interface {
+	Filter(content string) (string, bool)
+}
+
+// WordlistFilter masks listed words with asterisks, matching whole words
+// without regard to case. With reject set, any listed word rejects the whole
+// message instead.
+type WordlistFilter struct {
+	words  map[string]struct{}
+	reject bool
+}
+
+func NewWordlistFilter(words []string, reject bool) *WordlistFilter {
+	f := &WordlistFilter{words: make(map[string]struct{}, len(words)), reject: reject}
+	for _, w := range words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			f.words[w] = struct{}{}
+		}
+	}
+	return f
+}
+
+func (f *WordlistFilter) Filter(content string) (string, bool) {
+	var out strings.Builder
+	masked := false
+	rest := content
+	for rest != "" {
+		// copy everything up to the next word as is
+		start := strings.IndexFunc(rest, isWordRune)
+		if start < 0 {
+			out.WriteString(rest)
+			break
+		}
+		out.WriteString(rest[:start])
+		rest = rest[start:]
+
+		end := strings.IndexFunc(rest, func(r rune) bool { return !isWordRune(r) })
+		if end < 0 {
+			end = len(rest)
+		}
+		word := rest[:end]
+		rest = rest[end:]
+
+		if _, banned := f.words[strings.ToLower(word)]; !banned {
+			out.WriteString(word)
+			continue
+		}
+		if f.reject {
+			return "", false
+		}
+		out.WriteString(strings.Repeat("*", len([]rune(word))))
+		masked = true
+	}
+
+	if !masked {
+		return content, true
+	}
+	return out.String(), true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
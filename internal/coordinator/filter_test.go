@@ -0,0 +1,37 @@
+package coordinator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWordlistFilterMasks(t *testing.T) {
+	f := NewWordlistFilter([]string{"darn", "Heck"}, false)
+
+	tests := []struct {
+		in, want string
+	}{
+		{in: "hello there", want: "hello there"},
+		{in: "darn it", want: "**** it"},
+		{in: "oh HECK, darn!", want: "oh ****, ****!"},
+		{in: "darning is fine", want: "darning is fine"},
+		{in: "", want: ""},
+	}
+	for _, tt := range tests {
+		got, ok := f.Filter(tt.in)
+		assert.True(t, ok, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
+
+func TestWordlistFilterRejects(t *testing.T) {
+	f := NewWordlistFilter([]string{"darn"}, true)
+
+	_, ok := f.Filter("well, Darn.")
+	assert.False(t, ok)
+
+	got, ok := f.Filter("all good")
+	assert.True(t, ok)
+	assert.Equal(t, "all good", got)
+}
@@ -0,0 +1,164 @@
+package coordinator
+
+import (
+	"strings"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+)
+
+const defaultHistorySize = 50
+
+// messageHistory is a fixed-size ring buffer of the most recent room messages.
+// Appending to a full buffer overwrites the oldest entry, so eviction is O(1).
+type messageHistory struct {
+	buf   []messages.RoomMessageEvent
+	start int
+	size  int
+}
+
+func newMessageHistory(capacity int) *messageHistory {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &messageHistory{
+		buf: make([]messages.RoomMessageEvent, capacity),
+	}
+}
+
+func (h *messageHistory) Append(ev messages.RoomMessageEvent) {
+	if len(h.buf) == 0 {
+		return
+	}
+
+	if h.size < len(h.buf) {
+		h.buf[(h.start+h.size)%len(h.buf)] = ev
+		h.size++
+		return
+	}
+
+	// full: overwrite the oldest entry and advance start
+	h.buf[h.start] = ev
+	h.start = (h.start + 1) % len(h.buf)
+}
+
+// Snapshot returns the buffered messages ordered from oldest to newest.
+func (h *messageHistory) Snapshot() []messages.RoomMessageEvent {
+	out := make([]messages.RoomMessageEvent, 0, h.size)
+	for i := 0; i < h.size; i++ {
+		out = append(out, h.buf[(h.start+i)%len(h.buf)])
+	}
+	return out
+}
+
+// Before returns up to limit buffered messages with a sequence number below
+// beforeSeq, newest first. A beforeSeq of 0 starts from the newest message.
+func (h *messageHistory) Before(beforeSeq uint64, limit int) []messages.RoomMessageEvent {
+	out := make([]messages.RoomMessageEvent, 0, min(limit, h.size))
+	for i := h.size - 1; i >= 0 && len(out) < limit; i-- {
+		ev := h.buf[(h.start+i)%len(h.buf)]
+		if beforeSeq == 0 || ev.Seq < beforeSeq {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// Search returns up to limit buffered messages whose text contains query,
+// ignoring case, newest first. Deleted messages have no text and never match.
+func (h *messageHistory) Search(query string, limit int) []messages.RoomMessageEvent {
+	query = strings.ToLower(query)
+	out := make([]messages.RoomMessageEvent, 0, min(limit, h.size))
+	for i := h.size - 1; i >= 0 && len(out) < limit; i-- {
+		ev := h.buf[(h.start+i)%len(h.buf)]
+		if !ev.Deleted && strings.Contains(strings.ToLower(ev.Message.Message), query) {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func (h *messageHistory) Len() int {
+	return h.size
+}
+
+// Find returns the buffered message with messageID, if it is still buffered.
+func (h *messageHistory) Find(messageID string) (messages.RoomMessageEvent, bool) {
+	for i := 0; i < h.size; i++ {
+		if ev := h.buf[(h.start+i)%len(h.buf)]; ev.MessageID == messageID {
+			return ev, true
+		}
+	}
+	return messages.RoomMessageEvent{}, false
+}
+
+// Remove drops the message from the buffer altogether, keeping the order of
+// the rest. It reports whether the message was buffered.
+func (h *messageHistory) Remove(messageID string) bool {
+	kept := h.Snapshot()
+	for i, ev := range kept {
+		if ev.MessageID != messageID {
+			continue
+		}
+		kept = append(kept[:i], kept[i+1:]...)
+		clear(h.buf)
+		copy(h.buf, kept)
+		h.start, h.size = 0, len(kept)
+		return true
+	}
+	return false
+}
+
+// TrimTo drops the oldest messages until at most n are buffered
+func (h *messageHistory) TrimTo(n int) {
+	for h.size > max(n, 0) {
+		h.dropOldest()
+	}
+}
+
+// DropBefore drops the messages posted before cutoff, returning how many went.
+// Messages are buffered in posting order, so it stops at the first newer one.
+func (h *messageHistory) DropBefore(cutoff time.Time) int {
+	dropped := 0
+	for h.size > 0 {
+		posted, ok := postedAt(h.buf[h.start])
+		if !ok || !posted.Before(cutoff) {
+			break
+		}
+		h.dropOldest()
+		dropped++
+	}
+	return dropped
+}
+
+func (h *messageHistory) dropOldest() {
+	h.buf[h.start] = messages.RoomMessageEvent{}
+	h.start = (h.start + 1) % len(h.buf)
+	h.size--
+}
+
+// postedAt returns when the server accepted ev, falling back to the coarser
+// message_time for messages stored before server_received_at existed
+func postedAt(ev messages.RoomMessageEvent) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, ev.ServerReceivedAt); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, ev.MessageTime); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// MarkDeleted replaces the message with a tombstone, keeping its place, author
+// and sequence number. It reports whether the message was buffered.
+func (h *messageHistory) MarkDeleted(messageID string) bool {
+	for i := 0; i < h.size; i++ {
+		idx := (h.start + i) % len(h.buf)
+		if h.buf[idx].MessageID == messageID {
+			h.buf[idx].Deleted = true
+			h.buf[idx].Message.Message = ""
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package coordinator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageHistoryKeepsOrderBelowCapacity(t *testing.T) {
+	h := newMessageHistory(3)
+
+	h.Append(messages.NewRoomMessageEvent("room_1", "u1", "User One", "m1"))
+	h.Append(messages.NewRoomMessageEvent("room_1", "u1", "User One", "m2"))
+
+	got := h.Snapshot()
+	require.Len(t, got, 2)
+	assert.Equal(t, "m1", got[0].Message.Message)
+	assert.Equal(t, "m2", got[1].Message.Message)
+}
+
+func TestMessageHistoryEvictsOldest(t *testing.T) {
+	h := newMessageHistory(3)
+
+	for i := 1; i <= 5; i++ {
+		h.Append(messages.NewRoomMessageEvent("room_1", "u1", "User One", fmt.Sprintf("m%d", i)))
+	}
+
+	got := h.Snapshot()
+	require.Len(t, got, 3)
+	assert.Equal(t, 3, h.Len())
+	assert.Equal(t, "m3", got[0].Message.Message)
+	assert.Equal(t, "m4", got[1].Message.Message)
+	assert.Equal(t, "m5", got[2].Message.Message)
+}
+
+func TestMessageHistoryZeroCapacity(t *testing.T) {
+	h := newMessageHistory(0)
+
+	h.Append(messages.NewRoomMessageEvent("room_1", "u1", "User One", "m1"))
+
+	assert.Empty(t, h.Snapshot())
+}
+
+func TestMessageHistoryMarkDeleted(t *testing.T) {
+	h := newMessageHistory(2)
+	for _, id := range []string{"m1", "m2", "m3"} {
+		ev := messages.NewRoomMessageEvent("room_1", "user1", "User One", "text "+id)
+		ev.MessageID = id
+		h.Append(ev)
+	}
+
+	// m1 was evicted
+	assert.False(t, h.MarkDeleted("m1"))
+	_, found := h.Find("m1")
+	assert.False(t, found)
+
+	require.True(t, h.MarkDeleted("m2"))
+	ev, found := h.Find("m2")
+	require.True(t, found)
+	assert.True(t, ev.Deleted)
+	assert.Empty(t, ev.Message.Message)
+
+	snap := h.Snapshot()
+	require.Len(t, snap, 2)
+	assert.Equal(t, "m2", snap[0].MessageID, "tombstone keeps its place")
+	assert.Equal(t, "text m3", snap[1].Message.Message)
+}
+
+func TestMessageHistoryRemove(t *testing.T) {
+	h := newMessageHistory(3)
+	for _, id := range []string{"m1", "m2", "m3", "m4"} {
+		ev := messages.NewRoomMessageEvent("room_1", "user1", "User One", "text "+id)
+		ev.MessageID = id
+		h.Append(ev)
+	}
+
+	assert.False(t, h.Remove("m1"), "m1 was evicted")
+	require.True(t, h.Remove("m3"))
+	_, found := h.Find("m3")
+	assert.False(t, found)
+
+	// order survives and the freed slot is reused
+	next := messages.NewRoomMessageEvent("room_1", "user1", "User One", "text m5")
+	next.MessageID = "m5"
+	h.Append(next)
+	var ids []string
+	for _, ev := range h.Snapshot() {
+		ids = append(ids, ev.MessageID)
+	}
+	assert.Equal(t, []string{"m2", "m4", "m5"}, ids)
+}
+
+func TestMessageHistoryTrimAndDropBefore(t *testing.T) {
+	h := newMessageHistory(5)
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"m1", "m2", "m3", "m4"} {
+		ev := messages.NewRoomMessageEvent("room_1", "user1", "User One", "text "+id)
+		ev.MessageID = id
+		ev.ServerReceivedAt = base.Add(time.Duration(i) * time.Minute).Format(time.RFC3339Nano)
+		h.Append(ev)
+	}
+
+	assert.Equal(t, 1, h.DropBefore(base.Add(time.Minute)))
+	h.TrimTo(2)
+	var ids []string
+	for _, ev := range h.Snapshot() {
+		ids = append(ids, ev.MessageID)
+	}
+	assert.Equal(t, []string{"m3", "m4"}, ids)
+	assert.Zero(t, h.DropBefore(base), "nothing is that old")
+}
+
+func TestMessageHistoryBefore(t *testing.T) {
+	h := newMessageHistory(4)
+	for seq := uint64(1); seq <= 6; seq++ {
+		ev := messages.NewRoomMessageEvent("room_1", "u1", "User One", fmt.Sprintf("m%d", seq))
+		ev.Seq = seq
+		h.Append(ev)
+	}
+
+	seqs := func(evs []messages.RoomMessageEvent) []uint64 {
+		out := make([]uint64, 0, len(evs))
+		for _, ev := range evs {
+			out = append(out, ev.Seq)
+		}
+		return out
+	}
+
+	assert.Equal(t, []uint64{6, 5}, seqs(h.Before(0, 2)))
+	assert.Equal(t, []uint64{4, 3}, seqs(h.Before(5, 10))) // 1 and 2 were evicted
+	assert.Empty(t, h.Before(3, 10))
+}
+
+func TestMessageHistorySearch(t *testing.T) {
+	h := newMessageHistory(4)
+	for i, text := range []string{"hello there", "evicted hello", "Hello again", "nothing here", "say HELLO", "bye"} {
+		ev := messages.NewRoomMessageEvent("room_1", "u1", "User One", text)
+		ev.MessageID = fmt.Sprintf("m%d", i+1)
+		h.Append(ev)
+	}
+	require.True(t, h.MarkDeleted("m3"))
+
+	texts := func(evs []messages.RoomMessageEvent) []string {
+		out := make([]string, 0, len(evs))
+		for _, ev := range evs {
+			out = append(out, ev.Message.Message)
+		}
+		return out
+	}
+
+	// m1 and m2 were evicted and m3 is a tombstone
+	assert.Equal(t, []string{"say HELLO"}, texts(h.Search("hello", 10)))
+	assert.Equal(t, []string{"bye", "say HELLO"}, texts(h.Search("e", 2)))
+	assert.Empty(t, h.Search("missing", 10))
+}
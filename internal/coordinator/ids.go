@@ -0,0 +1,35 @@
+package coordinator
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator makes the ids the coordinator assigns to generated rooms and to
+// messages. NewID must be safe for concurrent use and never repeat an id.
+type IDGenerator interface {
+	NewID() string
+}
+
+// UUIDGenerator is the default IDGenerator: random version 4 UUIDs
+type UUIDGenerator struct{}
+
+func (UUIDGenerator) NewID() string { return uuid.NewString() }
+
+// SequentialIDGenerator numbers ids prefix1, prefix2, ... so tests can
+// predict them. Ids restart with every generator, so it doesn't suit
+// coordinators sharing a broadcaster or a message store.
+type SequentialIDGenerator struct {
+	prefix string
+	n      atomic.Uint64
+}
+
+func NewSequentialIDGenerator(prefix string) *SequentialIDGenerator {
+	return &SequentialIDGenerator{prefix: prefix}
+}
+
+func (g *SequentialIDGenerator) NewID() string {
+	return g.prefix + strconv.FormatUint(g.n.Add(1), 10)
+}
@@ -0,0 +1,39 @@
+package coordinator
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequentialIDGenerator(t *testing.T) {
+	g := NewSequentialIDGenerator("msg-")
+	assert.Equal(t, "msg-1", g.NewID())
+	assert.Equal(t, "msg-2", g.NewID())
+
+	// concurrent callers never get the same id
+	var wg sync.WaitGroup
+	ids := make(chan string, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids <- g.NewID()
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	seen := make(map[string]struct{})
+	for id := range ids {
+		seen[id] = struct{}{}
+	}
+	assert.Len(t, seen, 100)
+}
+
+func TestUUIDGenerator(t *testing.T) {
+	_, err := uuid.Parse(UUIDGenerator{}.NewID())
+	require.NoError(t, err)
+}
@@ -1,8 +1,32 @@
 package coordinator
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/app"
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/arturskrzydlo/chat-room/internal/metrics"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultStatsDebounce  = 250 * time.Millisecond
+	defaultEventQueueSize = 128
+	// broadcasts a room holds for its publisher before dropping them
+	defaultPublishQueueSize = 256
+	// chat messages a room holds for its persister before waiting on it
+	defaultPersistQueueSize = 256
+	// how often a room retries drop notices for clients that are still backed up
+	dropNoticeRetry = 250 * time.Millisecond
+	// how often a room checks whether it was left empty without a close pending
+	defaultSweepInterval = 30 * time.Second
 )
 
 type User struct {
@@ -16,54 +40,354 @@ const (
 	roomEventJoin roomEventType = iota
 	roomEventLeave
 	roomEventBroadcast
+	roomEventRename
+	roomEventEphemeral
+	roomEventDetach
+	roomEventAttach
+	roomEventRemote
+	roomEventReact
 	roomEventClose
+	roomEventAnnounce
+	roomEventMarkRead
+	roomEventModerator
+	roomEventMute
+	roomEventMeta
+	roomEventPanic
 )
 
 type roomEvent struct {
-	kind   roomEventType
-	client *RoomClient
-	userID string
-	msg    interface{}
+	kind          roomEventType
+	client        *RoomClient
+	userID        string
+	name          string
+	msg           interface{}   // for panic, what the publisher recovered
+	messageID     string        // react only
+	emoji         string        // react only
+	seq           uint64        // mark read only
+	targetID      string        // moderator and mute only: the member the change applies to
+	grant         bool          // moderator and mute only: grant or mute rather than revoke or unmute
+	result        chan error    // moderator, mute and meta only: receives the outcome
+	topic         string        // meta only
+	description   string        // meta only
+	excludeUserID string        // broadcast only: skip this user's channel
+	ackRequestID  *string       // broadcast only: when set, the author is sent a MessageAck carrying it
+	processed     chan struct{} // optional; closed once the loop has handled the event
 }
 
 // Room represents a chat room with multiple users
 type Room struct {
 	ID        string
 	Name      string
-	AuthorID  string
+	AuthorID  string // may move to another member when the author leaves; read it with Author
+	CreatedBy string // the user who created the room; unlike AuthorID it never changes
 	CreatedAt time.Time
 
-	mu      sync.RWMutex
-	users   map[string]*User              // userID -> User
-	clients map[string]chan<- interface{} // userID -> send channel
+	passwordHash []byte          // bcrypt hash; nil for open rooms
+	maxMessage   int             // content size limit in bytes, 0 uses the coordinator's; guarded by mu
+	retention    RetentionPolicy // guarded by mu
+	apiKeyHash   []byte          // sha256 of the integration API key; nil refuses every key; guarded by mu
+	uniqueNames  bool            // joiners may not reuse a present member's name; guarded by mu
+
+	mu         sync.RWMutex
+	users      map[string]*User              // userID -> User
+	clients    map[string]chan<- interface{} // userID -> send channel
+	joinOrder  map[string]uint64             // userID -> join counter, oldest member lowest
+	readPos    map[string]uint64             // userID -> highest seq the user has read
+	moderators map[string]struct{}           // members granted moderation besides the author; only Run writes it
+	muted      map[string]struct{}           // users who may not post; kept when they leave; only Run writes it
+	meta       messages.RoomMetaUpdatedEvent // the last topic and description change, sent to joiners; zero until one is made; only Run writes it
+	joins      uint64
+	history    *messageHistory                 // last N chat messages, replayed on join
+	publish    func(msg interface{})           // optional; forwards broadcasts to other instances
+	outbox     chan interface{}                // broadcasts waiting for publish; nil without it
+	persist    func(messages.RoomMessageEvent) // optional; stores chat messages once sequenced
+	persistQ   chan messages.RoomMessageEvent  // chat messages waiting for persist; nil without it
+	persisted  chan struct{}                   // closed when the persister goroutine exits
+	seq        uint64                          // last broadcast sequence number; only touched by Run
+	reactions  reactionSet                     // only touched by Run
+
+	statsDebounce time.Duration
+	statsDue      <-chan time.Time // non-nil while a stats event is pending; only touched by Run
+
+	dropped  map[string]int   // userID -> broadcasts missed since the last notice; only touched by Run
+	dropsDue <-chan time.Time // non-nil while drop notices wait for buffers to drain; only touched by Run
+
+	emptyGrace time.Duration
+	emptyDue   <-chan time.Time // non-nil while an empty room waits to close; only touched by Run
+	onEmpty    func(*Room)      // nil means the room never closes itself
+	onPanic    func(*Room, interface{})
+
+	sweepInterval time.Duration
+	idleTimeout   time.Duration // 0 never closes a room for being idle
+	onIdle        func(*Room)
+	lastActivity  time.Time // last join, leave or broadcast; only touched by Run
+
+	expiring  []expiry         // disappearing messages, soonest first; only touched by Run
+	expiry    *time.Timer      // fires when expiring[0] is due; only touched by Run
+	expireDue <-chan time.Time // the timer's channel, nil when nothing is expiring; only touched by Run
 
 	events chan roomEvent
+	done   chan struct{} // closed when Run exits
+}
+
+// RetentionPolicy limits the history a room keeps for replay. The zero value
+// keeps as many messages as the history buffer holds, however old.
+type RetentionPolicy struct {
+	MaxMessages int           // keep at most this many; 0 is the buffer size
+	MaxAge      time.Duration // drop messages older than this, checked on each sweep; 0 is no limit
+}
+
+// expiry is when a disappearing message is due to go
+type expiry struct {
+	at        time.Time
+	messageID string
+}
+
+// RoomOption configures optional Room settings
+type RoomOption func(*Room)
+
+// WithHistorySize sets how many recent chat messages the room keeps for replay.
+// A size of 0 disables history.
+func WithHistorySize(size int) RoomOption {
+	return func(r *Room) {
+		r.history = newMessageHistory(size)
+	}
+}
+
+// WithPublisher forwards every broadcast to publish after local delivery,
+// so other instances can deliver it to their members. publish runs in order
+// on a goroutine of its own, so a slow one never holds up local members;
+// broadcasts it falls too far behind on are dropped.
+func WithPublisher(publish func(msg interface{})) RoomOption {
+	return func(r *Room) {
+		r.publish = publish
+	}
+}
+
+// WithPersister has the room hand each chat message it broadcasts to persist
+// once it carries its sequence number, so stored history seeds the counter on
+// restart. Disappearing messages are never persisted. persist runs in order
+// on a goroutine of its own, so a slow store doesn't hold up members until it
+// falls a full queue behind; then the room waits for it rather than lose
+// messages. Run returns only once everything queued is stored.
+func WithPersister(persist func(messages.RoomMessageEvent)) RoomOption {
+	return func(r *Room) {
+		r.persist = persist
+	}
+}
+
+// WithHistory seeds the room's history buffer, oldest message first.
+// Apply it after WithHistorySize so the seed lands in the final buffer.
+func WithHistory(history []messages.RoomMessageEvent) RoomOption {
+	return func(r *Room) {
+		for _, ev := range history {
+			r.history.Append(ev)
+			if ev.Seq > r.seq {
+				r.seq = ev.Seq
+			}
+		}
+	}
+}
+
+// WithRetention sets the room's history retention policy
+func WithRetention(policy RetentionPolicy) RoomOption {
+	return func(r *Room) {
+		r.retention = policy
+		r.applyRetentionLocked(time.Now())
+	}
+}
+
+// WithStatsDebounce sets how long membership changes are coalesced before a
+// RoomStatsEvent goes out. A debounce of 0 emits one per change.
+func WithStatsDebounce(d time.Duration) RoomOption {
+	return func(r *Room) {
+		r.statsDebounce = d
+	}
+}
+
+// WithEmptyGrace closes the room once it has been empty for grace, calling
+// onEmpty from the room loop first so the owner can unregister it. A join
+// within the grace period keeps the room open.
+func WithEmptyGrace(grace time.Duration, onEmpty func(*Room)) RoomOption {
+	return func(r *Room) {
+		r.emptyGrace = grace
+		r.onEmpty = onEmpty
+	}
+}
+
+// WithSweepInterval sets how often the room prunes history past its retention
+// age and checks for having been left empty with no close scheduled, for
+// example by a leave path that never reached the loop; such a room closes
+// itself through the empty-room callback
+func WithSweepInterval(d time.Duration) RoomOption {
+	return func(r *Room) {
+		if d > 0 {
+			r.sweepInterval = d
+		}
+	}
+}
+
+// WithIdleTimeout closes the room once nobody has joined, left or broadcast
+// in it for timeout, checked every sweep interval. Members are sent a
+// RoomClosedEvent and onIdle is called from the room loop so the owner can
+// unregister it. A timeout of 0 disables idle closing.
+func WithIdleTimeout(timeout time.Duration, onIdle func(*Room)) RoomOption {
+	return func(r *Room) {
+		r.idleTimeout = timeout
+		r.onIdle = onIdle
+	}
+}
+
+// WithPanicHandler calls onPanic from the room loop when handling an event
+// panics, after members have been told and before the loop exits, so the
+// owner can log it and unregister the room
+func WithPanicHandler(onPanic func(r *Room, recovered interface{})) RoomOption {
+	return func(r *Room) {
+		r.onPanic = onPanic
+	}
+}
+
+// WithPasswordHash makes the room private, guarded by the given bcrypt hash
+func WithPasswordHash(hash []byte) RoomOption {
+	return func(r *Room) {
+		r.passwordHash = hash
+	}
+}
+
+// WithEventQueueSize sets how many events may wait for the room loop; see
+// EnqueueJoin for what happens when they don't fit
+func WithEventQueueSize(size int) RoomOption {
+	return func(r *Room) {
+		if size > 0 {
+			r.events = make(chan roomEvent, size)
+		}
+	}
+}
+
+// FindMessage returns a message that is still in the room's history buffer
+func (r *Room) FindMessage(messageID string) (messages.RoomMessageEvent, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.history.Find(messageID)
+}
+
+// HistoryBefore returns up to limit buffered messages older than beforeSeq, newest first
+func (r *Room) HistoryBefore(beforeSeq uint64, limit int) []messages.RoomMessageEvent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.history.Before(beforeSeq, limit)
+}
+
+// SearchHistory returns up to limit buffered messages containing query, newest first
+func (r *Room) SearchHistory(query string, limit int) []messages.RoomMessageEvent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.history.Search(query, limit)
+}
+
+// RoomSummary is a point-in-time view of a room used for listings
+type RoomSummary struct {
+	ID          string    `json:"room_id"`
+	Name        string    `json:"room_name"`
+	AuthorID    string    `json:"author_id"`
+	Topic       string    `json:"topic,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UserCount   int       `json:"user_count"`
+}
+
+// RoomFilter narrows ListRooms. Zero fields don't filter, so the zero
+// filter matches every room.
+type RoomFilter struct {
+	// NameContains matches rooms whose name contains it, ignoring case
+	NameContains string
+	AuthorID     string
+	MinUsers     int
+}
+
+// matches reports whether s passes the filter
+func (f RoomFilter) matches(s RoomSummary) bool {
+	if f.NameContains != "" && !strings.Contains(strings.ToLower(s.Name), strings.ToLower(f.NameContains)) {
+		return false
+	}
+	if f.AuthorID != "" && s.AuthorID != f.AuthorID {
+		return false
+	}
+	return s.UserCount >= f.MinUsers
 }
 
 // RoomClient wraps client info for joining a room
 type RoomClient struct {
 	UserID string
 	User   *User
-	Send   chan<- interface{}
+	// Send belongs to the connection and may be shared by all of its rooms;
+	// rooms only ever drop their reference to it, never close it.
+	Send chan<- interface{}
 }
 
-func NewRoom(id, name, authorID string) *Room {
+func NewRoom(id, name, authorID string, opts ...RoomOption) *Room {
 	room := &Room{
-		ID:        id,
-		Name:      name,
-		AuthorID:  authorID,
-		CreatedAt: time.Now().UTC(),
-		users:     make(map[string]*User),
-		clients:   make(map[string]chan<- interface{}),
-		events:    make(chan roomEvent, 128), // buffered to prevent blocking
+		ID:         id,
+		Name:       name,
+		AuthorID:   authorID,
+		CreatedBy:  authorID,
+		CreatedAt:  time.Now().UTC(),
+		users:      make(map[string]*User),
+		clients:    make(map[string]chan<- interface{}),
+		joinOrder:  make(map[string]uint64),
+		readPos:    make(map[string]uint64),
+		moderators: make(map[string]struct{}),
+		muted:      make(map[string]struct{}),
+		history:    newMessageHistory(defaultHistorySize),
+		reactions:  make(reactionSet),
+		dropped:    make(map[string]int),
+
+		statsDebounce: defaultStatsDebounce,
+		sweepInterval: defaultSweepInterval,
+		events:        make(chan roomEvent, defaultEventQueueSize),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(room)
+	}
+	if room.publish != nil {
+		room.outbox = make(chan interface{}, defaultPublishQueueSize)
+	}
+	if room.persist != nil {
+		room.persistQ = make(chan messages.RoomMessageEvent, defaultPersistQueueSize)
+		room.persisted = make(chan struct{})
 	}
 	return room
 }
 
-// Run starts the room's main event loop
+// Run starts the room's main event loop. A panic while handling an event
+// closes the room: members get a RoomErrorEvent and a RoomClosedEvent, and the
+// panic handler, if any, is called instead of taking the process down.
 func (r *Room) Run() {
+	defer close(r.done)
 	defer r.cleanup()
+	defer r.recoverPanic()
+	defer func() {
+		if r.expiry != nil {
+			r.expiry.Stop()
+		}
+	}()
+	if r.outbox != nil {
+		go r.runPublisher()
+		defer close(r.outbox)
+	}
+	if r.persistQ != nil {
+		go r.runPersister()
+		defer func() {
+			close(r.persistQ)
+			<-r.persisted
+		}()
+	}
 
+	sweep := time.NewTicker(r.sweepInterval)
+	defer sweep.Stop()
+
+	r.lastActivity = time.Now()
 	for {
 		select {
 		case ev, ok := <-r.events:
@@ -71,19 +395,90 @@ func (r *Room) Run() {
 				return
 			}
 			switch ev.kind {
+			case roomEventJoin, roomEventLeave, roomEventBroadcast, roomEventRemote:
+				r.lastActivity = time.Now()
+			}
+			switch ev.kind {
 			case roomEventJoin:
 				r.handleJoin(ev.client)
 			case roomEventLeave:
 				r.handleLeave(ev.userID)
 			case roomEventBroadcast:
-				r.handleBroadcast(ev.msg)
+				r.broadcast(ev.msg, ev.excludeUserID, ev.ackRequestID)
+			case roomEventRename:
+				r.handleRename(ev.userID, ev.name)
+			case roomEventEphemeral:
+				r.handleEphemeral(ev.msg, ev.userID)
+			case roomEventDetach:
+				r.handleDetach(ev.userID)
+			case roomEventAttach:
+				r.handleAttach(ev.client)
+			case roomEventRemote:
+				r.handleRemote(ev.msg)
+			case roomEventReact:
+				r.handleReact(ev.userID, ev.messageID, ev.emoji)
 			case roomEventClose:
+				r.handleClose()
+				return
+			case roomEventAnnounce:
+				r.deliverLocal(ev.msg, "")
+			case roomEventMarkRead:
+				r.handleMarkRead(ev.userID, ev.seq)
+			case roomEventModerator:
+				ev.result <- r.handleModerator(ev.userID, ev.targetID, ev.grant)
+			case roomEventMute:
+				ev.result <- r.handleMute(ev.userID, ev.targetID, ev.grant)
+			case roomEventMeta:
+				ev.result <- r.handleMeta(ev.userID, ev.topic, ev.description)
+			case roomEventPanic:
+				panic(ev.msg)
+			}
+			if ev.processed != nil {
+				close(ev.processed)
+			}
+		case <-r.statsDue:
+			r.statsDue = nil
+			r.emitStats()
+		case <-r.dropsDue:
+			r.dropsDue = nil
+			r.retryDropNotices()
+		case <-r.expireDue:
+			r.expireMessages()
+		case <-r.emptyDue:
+			r.emptyDue = nil
+			if r.GetUserCount() == 0 {
+				r.onEmpty(r)
+				return
+			}
+		case <-sweep.C:
+			r.applyRetention(time.Now())
+			if r.onEmpty != nil && r.emptyDue == nil && r.isStale() {
+				r.onEmpty(r)
+				return
+			}
+			if r.idleTimeout > 0 && time.Since(r.lastActivity) >= r.idleTimeout {
+				r.handleClose()
+				if r.onIdle != nil {
+					r.onIdle(r)
+				}
 				return
 			}
 		}
 	}
 }
 
+// EnqueueJoin adds c to the room, waiting for space in the event queue.
+//
+// The queue is bounded, and what happens when it is full depends
+// on the event. Membership changes (join, leave, detach, attach, rename,
+// close) and chat broadcasts wait for space, since losing one would leave
+// members with a wrong view of the room; chat messages and attachments sent
+// by members wait only so long (see EnqueueBroadcastTimeout), so the sender's
+// read pump isn't held up by a stalled room. Typing events and broadcasts from
+// other instances are dropped instead: waiting on them would stall a
+// sender's read pump, or the subscriber that feeds every room. Callers that
+// can report failure use TryEnqueueBroadcast to refuse rather than wait.
+// Every refused or dropped event counts in metrics.RoomEventsDropped.
 func (r *Room) EnqueueJoin(c *RoomClient) {
 	r.events <- roomEvent{kind: roomEventJoin, client: c}
 }
@@ -92,55 +487,956 @@ func (r *Room) EnqueueLeave(userID string) {
 	r.events <- roomEvent{kind: roomEventLeave, userID: userID}
 }
 
+// Leave removes userID and waits until the room loop has applied it,
+// so membership reads afterwards are accurate. Returns early if the room stopped.
+func (r *Room) Leave(userID string) {
+	processed := make(chan struct{})
+	r.events <- roomEvent{kind: roomEventLeave, userID: userID, processed: processed}
+
+	select {
+	case <-processed:
+	case <-r.done:
+	}
+}
+
 func (r *Room) EnqueueBroadcast(msg interface{}) {
 	r.events <- roomEvent{kind: roomEventBroadcast, msg: msg}
 }
 
+// EnqueueBroadcastExcept broadcasts msg to everyone except excludeUserID
+func (r *Room) EnqueueBroadcastExcept(msg interface{}, excludeUserID string) {
+	r.events <- roomEvent{kind: roomEventBroadcast, msg: msg, excludeUserID: excludeUserID}
+}
+
+func (r *Room) EnqueueRename(userID, newName string) {
+	r.events <- roomEvent{kind: roomEventRename, userID: userID, name: newName}
+}
+
+// TryEnqueueBroadcast broadcasts msg to everyone except excludeUserID, which
+// may be empty, or returns app.ErrRoomBusy at once if the queue is full
+func (r *Room) TryEnqueueBroadcast(msg interface{}, excludeUserID string) error {
+	select {
+	case r.events <- roomEvent{kind: roomEventBroadcast, msg: msg, excludeUserID: excludeUserID}:
+		return nil
+	default:
+		metrics.RoomEventsDropped.WithLabelValues("broadcast").Inc()
+		return app.ErrRoomBusy
+	}
+}
+
+// EnqueueBroadcastTimeout is TryEnqueueBroadcast that waits up to timeout for
+// space in the queue before giving up
+func (r *Room) EnqueueBroadcastTimeout(msg interface{}, excludeUserID string, timeout time.Duration) error {
+	return r.enqueueBroadcastTimeout(roomEvent{kind: roomEventBroadcast, msg: msg, excludeUserID: excludeUserID}, timeout)
+}
+
+// EnqueueAckedMessageTimeout is EnqueueBroadcastTimeout for a chat message
+// whose author is sent a MessageAck with requestID once the room has given
+// it a sequence number
+func (r *Room) EnqueueAckedMessageTimeout(msg messages.RoomMessageEvent, excludeUserID, requestID string, timeout time.Duration) error {
+	return r.enqueueBroadcastTimeout(roomEvent{
+		kind:          roomEventBroadcast,
+		msg:           msg,
+		excludeUserID: excludeUserID,
+		ackRequestID:  &requestID,
+	}, timeout)
+}
+
+func (r *Room) enqueueBroadcastTimeout(ev roomEvent, timeout time.Duration) error {
+	if timeout <= 0 {
+		select {
+		case r.events <- ev:
+			return nil
+		default:
+			metrics.RoomEventsDropped.WithLabelValues("broadcast").Inc()
+			return app.ErrRoomBusy
+		}
+	}
+
+	select {
+	case r.events <- ev:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r.events <- ev:
+		return nil
+	case <-timer.C:
+		metrics.RoomEventsDropped.WithLabelValues("broadcast").Inc()
+		return app.ErrRoomBusy
+	}
+}
+
+// EnqueueEphemeral queues a best-effort event for everyone except senderID.
+// If the room's queue is full the event is dropped rather than blocking.
+func (r *Room) EnqueueEphemeral(msg interface{}, senderID string) {
+	select {
+	case r.events <- roomEvent{kind: roomEventEphemeral, userID: senderID, msg: msg}:
+	default:
+		metrics.RoomEventsDropped.WithLabelValues("ephemeral").Inc()
+	}
+}
+
+// EnqueueRemote delivers a broadcast that originated on another instance.
+// If the room's queue is full the event is dropped rather than blocking.
+func (r *Room) EnqueueRemote(msg interface{}) bool {
+	select {
+	case r.events <- roomEvent{kind: roomEventRemote, msg: msg}:
+		return true
+	default:
+		metrics.RoomEventsDropped.WithLabelValues("remote").Inc()
+		return false
+	}
+}
+
+// EnqueueDetach stops delivery to userID while keeping them a member
+func (r *Room) EnqueueDetach(userID string) {
+	r.events <- roomEvent{kind: roomEventDetach, userID: userID}
+}
+
+// EnqueueAttach resumes delivery to an existing member on a new channel
+func (r *Room) EnqueueAttach(c *RoomClient) {
+	r.events <- roomEvent{kind: roomEventAttach, client: c}
+}
+
+// EnqueueReact toggles userID's emoji reaction on messageID
+func (r *Room) EnqueueReact(userID, messageID, emoji string) {
+	r.events <- roomEvent{kind: roomEventReact, userID: userID, messageID: messageID, emoji: emoji}
+}
+
+// EnqueueMarkRead records userID's read position. A newer position supersedes
+// it, so when the queue is full it is refused with app.ErrRoomBusy rather
+// than waited on.
+func (r *Room) EnqueueMarkRead(userID string, seq uint64) error {
+	select {
+	case r.events <- roomEvent{kind: roomEventMarkRead, userID: userID, seq: seq}:
+		return nil
+	default:
+		metrics.RoomEventsDropped.WithLabelValues("mark_read").Inc()
+		return app.ErrRoomBusy
+	}
+}
+
+// SetModerator grants or revokes targetID's moderator role on behalf of
+// requesterID. The room loop checks and applies it, so it can't race with
+// the target leaving or another change; it returns app.ErrRoomNotFound if
+// the room stopped first.
+func (r *Room) SetModerator(requesterID, targetID string, grant bool) error {
+	return r.request(roomEvent{kind: roomEventModerator, userID: requesterID, targetID: targetID, grant: grant})
+}
+
+// SetMuted mutes or unmutes targetID on behalf of requesterID, through the
+// room loop like SetModerator
+func (r *Room) SetMuted(requesterID, targetID string, muted bool) error {
+	return r.request(roomEvent{kind: roomEventMute, userID: requesterID, targetID: targetID, grant: muted})
+}
+
+// SetMeta replaces the room's topic and description on behalf of
+// requesterID, through the room loop like SetModerator
+func (r *Room) SetMeta(requesterID, topic, description string) error {
+	return r.request(roomEvent{kind: roomEventMeta, userID: requesterID, topic: topic, description: description})
+}
+
+// request hands ev to the room loop and waits for the loop's verdict on it
+func (r *Room) request(ev roomEvent) error {
+	ev.result = make(chan error, 1)
+	select {
+	case r.events <- ev:
+	case <-r.done:
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, r.ID)
+	}
+
+	select {
+	case err := <-ev.result:
+		return err
+	case <-r.done:
+		return fmt.Errorf("%w: %s", app.ErrRoomNotFound, r.ID)
+	}
+}
+
+// EnqueueClose stops the room once earlier events are handled. Members get a
+// RoomClosedEvent first; the loop then drops its references to their channels.
 func (r *Room) EnqueueClose() {
-	r.events <- roomEvent{kind: roomEventClose}
+	select {
+	case r.events <- roomEvent{kind: roomEventClose}:
+	case <-r.done:
+	}
+}
+
+// EnqueueAnnouncement delivers msg to local members only; it isn't sequenced,
+// kept in history or published. It returns app.ErrRoomBusy instead of waiting
+// when the queue is full, so one stalled room can't hold up the rest.
+func (r *Room) EnqueueAnnouncement(msg interface{}) error {
+	select {
+	case r.events <- roomEvent{kind: roomEventAnnounce, msg: msg}:
+		return nil
+	default:
+		metrics.RoomEventsDropped.WithLabelValues("announcement").Inc()
+		return app.ErrRoomBusy
+	}
+}
+
+// recoverPanic stops a panic in the room loop from escaping Run. The loop's
+// state can't be trusted afterwards, so the room is closed rather than
+// restarted. Recovery takes r.mu, which is why everything the loop runs
+// releases it with defer: a panic while it is held must not leave it locked.
+func (r *Room) recoverPanic() {
+	p := recover()
+	if p == nil {
+		return
+	}
+	metrics.RoomPanics.Inc()
+	r.deliverLocal(messages.NewRoomErrorEvent(r.ID, "room failed and was closed"), "")
+	r.handleClose()
+	if r.onPanic != nil {
+		r.onPanic(r, p)
+	}
+}
+
+// handleClose tells local members the room is going away. Each instance
+// closes its own copy of a room, so the event isn't published.
+func (r *Room) handleClose() {
+	r.deliverLocal(messages.NewRoomClosedEvent(r.ID), "")
 }
 
 func (r *Room) handleJoin(client *RoomClient) {
+	meta := r.addMember(client)
+	metrics.Joins.Inc()
+	r.scheduleStats()
+	r.emptyDue = nil // a rejoin cancels a pending close
+
+	// metadata and history go out before any live broadcast queued after this join
+	if meta.Type != "" {
+		deliver(client.Send, meta)
+	}
+	r.ReplayHistory(client.Send)
+}
+
+// addMember records client as present and returns the room metadata it is
+// owed on joining
+func (r *Room) addMember(client *RoomClient) messages.RoomMetaUpdatedEvent {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-
 	r.users[client.UserID] = client.User
 	r.clients[client.UserID] = client.Send
+	r.joins++
+	r.joinOrder[client.UserID] = r.joins
+	return r.meta
 }
 
+// handleLeave drops the room's reference to the user's send channel; the
+// channel stays open for the connection's other rooms.
 func (r *Room) handleLeave(userID string) {
+	exists, newAuthor := r.removeMember(userID)
+	if newAuthor != "" {
+		r.handleBroadcast(messages.NewAuthorChangedEvent(r.ID, newAuthor), "")
+	}
+	if exists {
+		metrics.Leaves.Inc()
+		r.scheduleStats()
+		if r.onEmpty != nil && r.GetUserCount() == 0 {
+			r.emptyDue = time.After(r.emptyGrace)
+		}
+	}
+}
+
+// removeMember forgets userID, reporting whether it was a member and who
+// took over as author if it was the author ("" if nobody did)
+func (r *Room) removeMember(userID string) (exists bool, newAuthor string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-
+	_, exists = r.users[userID]
 	delete(r.users, userID)
-	if _, exists := r.clients[userID]; exists {
-		delete(r.clients, userID)
+	delete(r.clients, userID)
+	delete(r.joinOrder, userID)
+	delete(r.readPos, userID)
+	delete(r.moderators, userID)
+	delete(r.dropped, userID)
+	if exists && userID == r.AuthorID {
+		newAuthor = r.longestPresentLocked()
+		if newAuthor != "" {
+			r.AuthorID = newAuthor
+		}
+	}
+	return exists, newAuthor
+}
+
+// longestPresentLocked returns the remaining member who joined first, or ""
+// if the room is empty. Callers hold r.mu.
+func (r *Room) longestPresentLocked() string {
+	oldest := ""
+	for userID, order := range r.joinOrder {
+		if oldest == "" || order < r.joinOrder[oldest] {
+			oldest = userID
+		}
+	}
+	return oldest
+}
+
+// scheduleStats arranges for a RoomStatsEvent once the debounce window
+// closes, so a burst of joins and leaves yields one event with the final count.
+func (r *Room) scheduleStats() {
+	if r.statsDebounce <= 0 {
+		r.emitStats()
+		return
+	}
+	if r.statsDue == nil {
+		r.statsDue = time.After(r.statsDebounce)
+	}
+}
+
+// emitStats sends the current occupancy to local members only; other
+// instances count their own members.
+func (r *Room) emitStats() {
+	r.deliverLocal(messages.NewRoomStatsEvent(r.ID, r.GetUserCount()), "")
+}
+
+func (r *Room) handleDetach(userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.clients, userID)
+}
+
+func (r *Room) handleAttach(client *RoomClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the member may have been removed (e.g. kicked) while detached
+	if _, exists := r.users[client.UserID]; !exists {
+		return
+	}
+	r.clients[client.UserID] = client.Send
+}
+
+func (r *Room) handleRename(userID, newName string) {
+	oldName, exists := r.renameMember(userID, newName)
+	if !exists {
+		return
+	}
+	r.handleBroadcast(messages.NewUserRenamedEvent(r.ID, userID, oldName, newName), "")
+}
+
+// renameMember gives userID newName and returns the name it replaced
+func (r *Room) renameMember(userID, newName string) (oldName string, exists bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, exists := r.users[userID]
+	if !exists {
+		return "", false
+	}
+	// replace rather than mutate: GetUsers hands out these pointers
+	r.users[userID] = &User{ID: userID, Name: newName}
+	return user.Name, true
+}
+
+func (r *Room) handleBroadcast(msg interface{}, excludeUserID string) {
+	r.broadcast(msg, excludeUserID, nil)
+}
+
+// broadcast is handleBroadcast that, given an ackRequestID, first sends the
+// chat message's author a MessageAck, so it arrives ahead of their own copy
+func (r *Room) broadcast(msg interface{}, excludeUserID string, ackRequestID *string) {
+	metrics.MessagesBroadcast.Inc()
+	msg = r.stampSeq(msg)
+	r.recordHistory(msg)
+	if chat, ok := msg.(messages.RoomMessageEvent); ok && r.persistQ != nil && chat.Message.ExpiresInSeconds == 0 {
+		select {
+		case r.persistQ <- chat:
+		case <-r.persisted:
+			// the persister died of a panic the loop is about to re-raise
+		}
+	}
+	if chat, ok := msg.(messages.RoomMessageEvent); ok && ackRequestID != nil {
+		if send, ok := r.clientSend(chat.UserID); ok {
+			ack := messages.NewMessageAck(r.ID, chat.MessageID, chat.Seq)
+			ack.RequestID = *ackRequestID
+			deliver(send, ack)
+		}
+	}
+	r.deliverLocal(msg, excludeUserID)
+
+	if r.outbox != nil {
+		select {
+		case r.outbox <- msg:
+		default:
+			metrics.RoomEventsDropped.WithLabelValues("publish").Inc()
+		}
 	}
 }
 
-func (r *Room) handleBroadcast(msg interface{}) {
+// runPersister stores queued chat messages until Run closes the queue. A
+// panic in persist is handed to the loop like a publisher's.
+func (r *Room) runPersister() {
+	defer func() {
+		p := recover()
+		// Run waits for this before closing done
+		close(r.persisted)
+		if p != nil {
+			select {
+			case r.events <- roomEvent{kind: roomEventPanic, msg: p}:
+			case <-r.done:
+			}
+		}
+	}()
+	for ev := range r.persistQ {
+		r.persist(ev)
+	}
+}
+
+// runPublisher publishes queued broadcasts until Run closes the outbox. A
+// panic in publish is handed to the loop, which closes the room as it would
+// for one of its own.
+func (r *Room) runPublisher() {
+	defer func() {
+		if p := recover(); p != nil {
+			select {
+			case r.events <- roomEvent{kind: roomEventPanic, msg: p}:
+			case <-r.done:
+			}
+		}
+	}()
+	for msg := range r.outbox {
+		r.publish(msg)
+	}
+}
+
+// clientSend returns the send channel of userID's attached connection
+func (r *Room) clientSend(userID string) (chan<- interface{}, bool) {
 	r.mu.RLock()
-	clients := make([]chan<- interface{}, 0, len(r.clients))
-	for _, send := range r.clients {
-		clients = append(clients, send)
+	defer r.mu.RUnlock()
+	send, ok := r.clients[userID]
+	return send, ok
+}
+
+// handleRemote delivers another instance's broadcast to local members without
+// re-publishing. Sequenced events are renumbered so local members see one
+// gap-free sequence.
+func (r *Room) handleRemote(msg interface{}) {
+	msg = r.stampSeq(msg)
+	r.recordHistory(msg)
+	r.deliverLocal(msg, "")
+}
+
+// stampSeq gives sequenced events the room's next sequence number. Only the
+// Run goroutine calls it, so the counter needs no locking.
+func (r *Room) stampSeq(msg interface{}) interface{} {
+	switch ev := msg.(type) {
+	case messages.RoomMessageEvent:
+		r.seq++
+		ev.Seq = r.seq
+		return ev
+	case messages.UserJoinedEvent:
+		r.seq++
+		ev.Seq = r.seq
+		return ev
+	case messages.UserLeftEvent:
+		r.seq++
+		ev.Seq = r.seq
+		return ev
+	}
+	return msg
+}
+
+// handleReact toggles the reaction and broadcasts which way it went. Reactions
+// on messages that have left the history buffer are forgotten.
+func (r *Room) handleReact(userID, messageID, emoji string) {
+	added := r.reactions.Toggle(messageID, emoji, userID)
+
+	r.pruneReactions()
+
+	r.handleBroadcast(messages.NewReactionEvent(r.ID, messageID, userID, emoji, added), "")
+}
+
+// pruneReactions forgets reactions on messages no longer in history
+func (r *Room) pruneReactions() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	r.reactions.Prune(func(id string) bool {
+		_, found := r.history.Find(id)
+		return found
+	})
+}
+
+// handleModerator applies a grant or revoke for requesterID, who must be the
+// author or a moderator. Moderators are members: granting needs the target
+// present, and leaving the room gives the role up. Granting an existing
+// moderator or revoking someone who isn't one changes nothing and sends no
+// event.
+func (r *Room) handleModerator(requesterID, targetID string, grant bool) error {
+	changed, err := r.setModerator(requesterID, targetID, grant)
+	if err != nil {
+		return err
+	}
+	if changed {
+		r.handleBroadcast(messages.NewModeratorChangedEvent(r.ID, targetID, grant, requesterID), "")
+	}
+	return nil
+}
+
+// setModerator is the state change behind handleModerator, reporting whether
+// the target's role changed
+func (r *Room) setModerator(requesterID, targetID string, grant bool) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.canModerateLocked(requesterID) {
+		return false, fmt.Errorf("only the room author or a moderator can change moderators")
+	}
+	if targetID == r.AuthorID {
+		return false, fmt.Errorf("the room author is always a moderator")
+	}
+	_, isMod := r.moderators[targetID]
+	if grant {
+		if _, member := r.users[targetID]; !member {
+			return false, fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, targetID, r.ID)
+		}
+		r.moderators[targetID] = struct{}{}
+	} else {
+		delete(r.moderators, targetID)
+	}
+	return isMod != grant, nil
+}
+
+// handleMeta sets the topic and description for requesterID, who must be the
+// author or a moderator. Setting the current values sends no event.
+func (r *Room) handleMeta(requesterID, topic, description string) error {
+	ev, changed, err := r.setMeta(requesterID, topic, description)
+	if err != nil {
+		return err
+	}
+	if changed {
+		r.handleBroadcast(ev, "")
+	}
+	return nil
+}
+
+// setMeta is the state change behind handleMeta, returning the event to
+// broadcast if the metadata changed
+func (r *Room) setMeta(requesterID, topic, description string) (messages.RoomMetaUpdatedEvent, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.canModerateLocked(requesterID) {
+		return messages.RoomMetaUpdatedEvent{}, false, fmt.Errorf("only the room author or a moderator can change room metadata")
+	}
+	if r.meta.Topic == topic && r.meta.Description == description {
+		return messages.RoomMetaUpdatedEvent{}, false, nil
+	}
+	r.meta = messages.NewRoomMetaUpdatedEvent(r.ID, topic, description, requesterID)
+	return r.meta, true, nil
+}
+
+// handleMute mutes or unmutes targetID for requesterID, who must be the
+// author or a moderator. Those who moderate can't be muted, and only members
+// can be, though a mute outlasts leaving so rejoining doesn't lift it.
+// Repeating the current state sends no event.
+func (r *Room) handleMute(requesterID, targetID string, mute bool) error {
+	changed, err := r.setMuted(requesterID, targetID, mute)
+	if err != nil {
+		return err
+	}
+	switch {
+	case changed && mute:
+		r.handleBroadcast(messages.NewUserMutedEvent(r.ID, targetID, requesterID), "")
+	case changed:
+		r.handleBroadcast(messages.NewUserUnmutedEvent(r.ID, targetID, requesterID), "")
+	}
+	return nil
+}
+
+// setMuted is the state change behind handleMute, reporting whether the
+// target's mute changed
+func (r *Room) setMuted(requesterID, targetID string, mute bool) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.canModerateLocked(requesterID) {
+		return false, fmt.Errorf("only the room author or a moderator can mute users")
+	}
+	if r.canModerateLocked(targetID) {
+		return false, fmt.Errorf("cannot mute the room author or a moderator")
+	}
+	_, wasMuted := r.muted[targetID]
+	if mute {
+		if _, member := r.users[targetID]; !member {
+			return false, fmt.Errorf("%w: %s in %s", app.ErrUserNotInRoom, targetID, r.ID)
+		}
+		r.muted[targetID] = struct{}{}
+	} else {
+		delete(r.muted, targetID)
+	}
+	return wasMuted != mute, nil
+}
+
+// handleMarkRead moves a member's read position forward and tells the room.
+// Positions past the latest broadcast are capped at it, and ones that don't
+// move forward are ignored. Sequence numbers are per instance, so receipts
+// reach local members only.
+func (r *Room) handleMarkRead(userID string, seq uint64) {
+	if seq > r.seq {
+		seq = r.seq
+	}
+
+	if r.advanceReadPos(userID, seq) {
+		r.deliverLocal(messages.NewReadReceiptEvent(r.ID, userID, seq), "")
+	}
+}
+
+// advanceReadPos moves userID's read position to seq, reporting whether it
+// moved forward
+func (r *Room) advanceReadPos(userID string, seq uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, member := r.users[userID]; !member || seq <= r.readPos[userID] {
+		return false
+	}
+	r.readPos[userID] = seq
+	return true
+}
+
+// ReadPositions returns every member's read position; members who haven't
+// marked anything read are left out
+func (r *Room) ReadPositions() map[string]uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	positions := make(map[string]uint64, len(r.readPos))
+	for userID, seq := range r.readPos {
+		positions[userID] = seq
+	}
+	return positions
+}
+
+func (r *Room) recordHistory(msg interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch ev := msg.(type) {
+	case messages.RoomMessageEvent:
+		r.history.Append(ev)
+		r.applyRetentionLocked(time.Now())
+		if ev.Message.ExpiresInSeconds > 0 {
+			r.scheduleExpiry(ev.MessageID, time.Duration(ev.Message.ExpiresInSeconds)*time.Second)
+		}
+	case messages.MessageDeletedEvent:
+		r.history.MarkDeleted(ev.MessageID)
+	}
+}
+
+// scheduleExpiry queues messageID to disappear after ttl. Each instance
+// expires its own copy, so the expiry event isn't published.
+func (r *Room) scheduleExpiry(messageID string, ttl time.Duration) {
+	at := time.Now().Add(ttl)
+	i := sort.Search(len(r.expiring), func(i int) bool { return r.expiring[i].at.After(at) })
+	r.expiring = slices.Insert(r.expiring, i, expiry{at: at, messageID: messageID})
+	r.armExpiry()
+}
+
+// armExpiry points the expiry timer at the soonest disappearing message
+func (r *Room) armExpiry() {
+	if r.expiry != nil {
+		r.expiry.Stop()
+	}
+	if len(r.expiring) == 0 {
+		r.expiry, r.expireDue = nil, nil
+		return
+	}
+	r.expiry = time.NewTimer(time.Until(r.expiring[0].at))
+	r.expireDue = r.expiry.C
+}
+
+// expireMessages drops every disappearing message that is due from history
+// and tells members
+func (r *Room) expireMessages() {
+	now := time.Now()
+	for len(r.expiring) > 0 && !r.expiring[0].at.After(now) {
+		messageID := r.expiring[0].messageID
+		r.expiring = r.expiring[1:]
+
+		r.removeHistory(messageID)
+		r.deliverLocal(messages.NewMessageExpiredEvent(r.ID, messageID), "")
+	}
+	r.armExpiry()
+}
+
+// removeHistory drops messageID from history
+func (r *Room) removeHistory(messageID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.history.Remove(messageID)
+}
+
+func (r *Room) deliverLocal(msg interface{}, excludeUserID string) {
+	type member struct {
+		userID string
+		send   chan<- interface{}
 	}
-	r.mu.RUnlock()
+	members := func() []member {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		members := make([]member, 0, len(r.clients))
+		for userID, send := range r.clients {
+			if excludeUserID != "" && userID == excludeUserID {
+				continue
+			}
+			members = append(members, member{userID, send})
+		}
+		return members
+	}()
 
-	for _, send := range clients {
+	for _, m := range members {
+		// a pending drop notice goes first so it arrives ahead of newer events
+		r.sendDropNotice(m.userID, m.send)
+		if !deliver(m.send, msg) {
+			r.dropped[m.userID]++
+			if r.dropsDue == nil {
+				r.dropsDue = time.After(dropNoticeRetry)
+			}
+		}
+	}
+}
+
+// sendDropNotice tells userID how many broadcasts it missed, if any, without
+// waiting: a client whose buffer is still full hears about it on a later try.
+func (r *Room) sendDropNotice(userID string, send chan<- interface{}) {
+	n := r.dropped[userID]
+	if n == 0 {
+		return
+	}
+	select {
+	case send <- messages.NewMessagesDroppedEvent(r.ID, n):
+		delete(r.dropped, userID)
+	default:
+	}
+}
+
+// retryDropNotices delivers pending drop notices to clients whose buffers
+// have drained since, even if the room has gone quiet.
+func (r *Room) retryDropNotices() {
+	for userID := range r.dropped {
+		send, ok := r.clientSend(userID)
+		if !ok {
+			// detached; the resumed connection resyncs anyway
+			delete(r.dropped, userID)
+			continue
+		}
+		r.sendDropNotice(userID, send)
+	}
+	if len(r.dropped) > 0 {
+		r.dropsDue = time.After(dropNoticeRetry)
+	}
+}
+
+// handleEphemeral delivers short-lived events (e.g. typing) that are never
+// stored in history and are dropped for any client whose buffer is full.
+func (r *Room) handleEphemeral(msg interface{}, senderID string) {
+	for _, send := range r.clientSends(senderID) {
 		select {
 		case send <- msg:
-		case <-time.After(100 * time.Millisecond):
-			// If client is slow, skip this message to avoid blocking
+		default:
+		}
+	}
+}
+
+// clientSends returns the send channels of every attached connection but
+// excludeUserID's
+func (r *Room) clientSends(excludeUserID string) []chan<- interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clients := make([]chan<- interface{}, 0, len(r.clients))
+	for userID, send := range r.clients {
+		if userID == excludeUserID {
+			continue
 		}
+		clients = append(clients, send)
+	}
+	return clients
+}
+
+// ReplayHistory sends buffered chat messages to send, oldest first, marked as historical
+func (r *Room) ReplayHistory(send chan<- interface{}) {
+	for _, ev := range r.historySnapshot() {
+		ev.Historical = true
+		deliver(send, ev)
+	}
+}
+
+// historySnapshot returns a copy of the buffered chat messages, oldest first
+func (r *Room) historySnapshot() []messages.RoomMessageEvent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.history.Snapshot()
+}
+
+// deliver reports whether msg made it into send before the slow-client timeout
+func deliver(send chan<- interface{}, msg interface{}) bool {
+	select {
+	case send <- msg:
+		return true
+	case <-time.After(100 * time.Millisecond):
+		// If client is slow, skip this message to avoid blocking
+		metrics.MessagesDropped.Inc()
+		return false
 	}
 }
 
+// isStale reports whether the room has no members and no live client
+// channels, so nobody can be waiting on it
+func (r *Room) isStale() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.users) == 0 && len(r.clients) == 0
+}
+
 func (r *Room) cleanup() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	r.clients = make(map[string]chan<- interface{})
 	r.users = make(map[string]*User)
+	r.joinOrder = make(map[string]uint64)
+	r.readPos = make(map[string]uint64)
+	r.dropped = make(map[string]int)
+}
+
+// Author returns the current room author
+func (r *Room) Author() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.AuthorID
+}
+
+// CanModerate reports whether userID may kick members and delete others'
+// messages: the author and granted moderators can
+func (r *Room) CanModerate(userID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.canModerateLocked(userID)
+}
+
+// IsModerator reports whether userID was granted the moderator role; the
+// author moderates without it
+func (r *Room) IsModerator(userID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.moderators[userID]
+	return ok
+}
+
+// Meta returns the room's topic and description
+func (r *Room) Meta() (topic, description string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.meta.Topic, r.meta.Description
+}
+
+// IsMuted reports whether userID is muted in the room
+func (r *Room) IsMuted(userID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.muted[userID]
+	return ok
+}
+
+func (r *Room) canModerateLocked(userID string) bool {
+	if userID == r.AuthorID {
+		return true
+	}
+	_, ok := r.moderators[userID]
+	return ok
+}
+
+// MaxMessageSize returns the room's own message size limit, or 0 when it uses
+// the coordinator's
+func (r *Room) MaxMessageSize() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.maxMessage
+}
+
+// SetMaxMessageSize overrides the message size limit for this room; 0 clears
+// the override
+func (r *Room) SetMaxMessageSize(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxMessage = size
+}
+
+// Retention returns the room's history retention policy
+func (r *Room) Retention() RetentionPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.retention
+}
+
+// SetRetention replaces the room's history retention policy, pruning history
+// to match straight away
+func (r *Room) SetRetention(policy RetentionPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retention = policy
+	r.applyRetentionLocked(time.Now())
+}
+
+// applyRetention is applyRetentionLocked for callers that don't hold r.mu
+func (r *Room) applyRetention(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.applyRetentionLocked(now)
+}
+
+// applyRetentionLocked prunes history to the retention policy. Callers hold r.mu.
+func (r *Room) applyRetentionLocked(now time.Time) {
+	if r.retention.MaxMessages > 0 {
+		r.history.TrimTo(r.retention.MaxMessages)
+	}
+	if r.retention.MaxAge > 0 {
+		r.history.DropBefore(now.Add(-r.retention.MaxAge))
+	}
+}
+
+// UniqueNames reports whether a joiner must pick a name no present member has
+func (r *Room) UniqueNames() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.uniqueNames
+}
+
+// SetUniqueNames turns the unique-name rule for joiners on or off
+func (r *Room) SetUniqueNames(unique bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.uniqueNames = unique
+}
+
+// IsPrivate reports whether joining requires a password
+func (r *Room) IsPrivate() bool {
+	return r.passwordHash != nil
+}
+
+// CheckPassword reports whether password grants access to the room.
+// Open rooms accept any password.
+func (r *Room) CheckPassword(password string) bool {
+	if r.passwordHash == nil {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword(r.passwordHash, []byte(password)) == nil
+}
+
+// SetAPIKey replaces the key integrations post with; only its hash is kept
+func (r *Room) SetAPIKey(key string) {
+	sum := sha256.Sum256([]byte(key))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.apiKeyHash = sum[:]
+}
+
+// CheckAPIKey reports whether key is the room's integration API key. Rooms
+// without one refuse every key.
+func (r *Room) CheckAPIKey(key string) bool {
+	sum := sha256.Sum256([]byte(key))
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.apiKeyHash != nil && subtle.ConstantTimeCompare(r.apiKeyHash, sum[:]) == 1
 }
 
 // GetUserCount returns the number of users in the room
@@ -150,6 +1446,22 @@ func (r *Room) GetUserCount() int {
 	return len(r.users)
 }
 
+// Summary returns the room's listing data with the current author, metadata
+// and user count
+func (r *Room) Summary() RoomSummary {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return RoomSummary{
+		ID:          r.ID,
+		Name:        r.Name,
+		AuthorID:    r.AuthorID,
+		Topic:       r.meta.Topic,
+		Description: r.meta.Description,
+		CreatedAt:   r.CreatedAt,
+		UserCount:   len(r.users),
+	}
+}
+
 // GetUsers returns a copy of users in the room
 func (r *Room) GetUsers() map[string]*User {
 	r.mu.RLock()
@@ -0,0 +1,107 @@
+package coordinator
+
+import (
+	"sync"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+)
+
+// onlineRegistry maps online users to the send channels of their connections.
+// A user may be connected more than once, e.g. from several devices, and only
+// goes offline when the last connection is removed. Presence changes are
+// pushed to subscribers while the lock is held so they arrive in order.
+type onlineRegistry struct {
+	mu          sync.RWMutex
+	users       map[string]map[chan<- interface{}]struct{}
+	subscribers map[chan<- interface{}]struct{}
+}
+
+func newOnlineRegistry() *onlineRegistry {
+	return &onlineRegistry{
+		users:       make(map[string]map[chan<- interface{}]struct{}),
+		subscribers: make(map[chan<- interface{}]struct{}),
+	}
+}
+
+// Add registers a connection and reports whether it brought the user online
+func (o *onlineRegistry) Add(userID string, send chan<- interface{}) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	conns, ok := o.users[userID]
+	if !ok {
+		conns = make(map[chan<- interface{}]struct{})
+		o.users[userID] = conns
+	}
+	conns[send] = struct{}{}
+
+	if ok {
+		return false
+	}
+	o.notify(messages.NewPresenceEvent(userID, messages.PresenceOnline))
+	return true
+}
+
+// Remove drops a connection and reports whether it was the user's last one
+func (o *onlineRegistry) Remove(userID string, send chan<- interface{}) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	conns, ok := o.users[userID]
+	if !ok {
+		return false
+	}
+	if _, ok := conns[send]; !ok {
+		return false
+	}
+	delete(conns, send)
+	if len(conns) > 0 {
+		return false
+	}
+
+	delete(o.users, userID)
+	o.notify(messages.NewPresenceEvent(userID, messages.PresenceOffline))
+	return true
+}
+
+// Count returns how many connections userID has open
+func (o *onlineRegistry) Count(userID string) int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.users[userID])
+}
+
+// Conns returns a snapshot of the user's send channels; empty when offline
+func (o *onlineRegistry) Conns(userID string) []chan<- interface{} {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	out := make([]chan<- interface{}, 0, len(o.users[userID]))
+	for send := range o.users[userID] {
+		out = append(out, send)
+	}
+	return out
+}
+
+func (o *onlineRegistry) Subscribe(send chan<- interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.subscribers[send] = struct{}{}
+}
+
+func (o *onlineRegistry) Unsubscribe(send chan<- interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.subscribers, send)
+}
+
+// notify must be called with o.mu held. Presence is best effort: a subscriber
+// with a full buffer misses the event instead of stalling every connect.
+func (o *onlineRegistry) notify(ev messages.PresenceEvent) {
+	for send := range o.subscribers {
+		select {
+		case send <- ev:
+		default:
+		}
+	}
+}
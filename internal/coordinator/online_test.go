@@ -0,0 +1,57 @@
+package coordinator
+
+import (
+	"testing"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnlineRegistryMultipleConnections(t *testing.T) {
+	o := newOnlineRegistry()
+	phone := make(chan interface{}, 1)
+	laptop := make(chan interface{}, 1)
+
+	assert.True(t, o.Add("user1", phone))
+	assert.False(t, o.Add("user1", laptop))
+	assert.False(t, o.Add("user1", laptop), "re-adding a connection is a no-op")
+	assert.Equal(t, 2, o.Count("user1"))
+	assert.Len(t, o.Conns("user1"), 2)
+
+	assert.False(t, o.Remove("user1", phone))
+	assert.Equal(t, 1, o.Count("user1"))
+
+	assert.True(t, o.Remove("user1", laptop))
+	assert.Empty(t, o.Conns("user1"))
+	assert.False(t, o.Remove("user1", laptop))
+}
+
+func TestOnlineRegistryPresenceTransitions(t *testing.T) {
+	o := newOnlineRegistry()
+	watcher := make(chan interface{}, 10)
+	o.Subscribe(watcher)
+
+	phone := make(chan interface{}, 1)
+	laptop := make(chan interface{}, 1)
+
+	o.Add("user1", phone)
+	o.Add("user1", laptop) // second connection: still online, no event
+	o.Remove("user1", phone)
+	o.Remove("user1", laptop) // last connection: offline
+	o.Remove("user1", laptop) // already gone: no event
+
+	var got []messages.PresenceEvent
+	for len(watcher) > 0 {
+		ev, ok := (<-watcher).(messages.PresenceEvent)
+		require.True(t, ok)
+		got = append(got, ev)
+	}
+	require.Len(t, got, 2)
+	assert.Equal(t, messages.NewPresenceEvent("user1", messages.PresenceOnline), got[0])
+	assert.Equal(t, messages.NewPresenceEvent("user1", messages.PresenceOffline), got[1])
+
+	o.Unsubscribe(watcher)
+	o.Add("user2", phone)
+	assert.Empty(t, watcher)
+}
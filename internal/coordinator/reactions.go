@@ -0,0 +1,47 @@
+package coordinator
+
+// reactionSet records who reacted to which message with which emoji:
+// messageID -> emoji -> userIDs. It is owned by the room loop and not locked.
+type reactionSet map[string]map[string]map[string]struct{}
+
+// Toggle adds userID's emoji reaction to messageID, or removes it if present,
+// and reports whether it was added.
+func (rs reactionSet) Toggle(messageID, emoji, userID string) bool {
+	byEmoji, ok := rs[messageID]
+	if !ok {
+		byEmoji = make(map[string]map[string]struct{})
+		rs[messageID] = byEmoji
+	}
+	users, ok := byEmoji[emoji]
+	if !ok {
+		users = make(map[string]struct{})
+		byEmoji[emoji] = users
+	}
+
+	if _, reacted := users[userID]; reacted {
+		delete(users, userID)
+		if len(users) == 0 {
+			delete(byEmoji, emoji)
+		}
+		if len(byEmoji) == 0 {
+			delete(rs, messageID)
+		}
+		return false
+	}
+	users[userID] = struct{}{}
+	return true
+}
+
+// Count returns how many users reacted to messageID with emoji
+func (rs reactionSet) Count(messageID, emoji string) int {
+	return len(rs[messageID][emoji])
+}
+
+// Prune forgets reactions on messages for which keep returns false
+func (rs reactionSet) Prune(keep func(messageID string) bool) {
+	for messageID := range rs {
+		if !keep(messageID) {
+			delete(rs, messageID)
+		}
+	}
+}
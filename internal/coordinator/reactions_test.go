@@ -0,0 +1,32 @@
+package coordinator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReactionSetToggle(t *testing.T) {
+	rs := make(reactionSet)
+
+	assert.True(t, rs.Toggle("m1", "👍", "user1"))
+	assert.True(t, rs.Toggle("m1", "👍", "user2"))
+	assert.Equal(t, 2, rs.Count("m1", "👍"))
+
+	assert.False(t, rs.Toggle("m1", "👍", "user1"))
+	assert.Equal(t, 1, rs.Count("m1", "👍"))
+
+	assert.False(t, rs.Toggle("m1", "👍", "user2"))
+	assert.Empty(t, rs, "empty reaction entries should be removed")
+}
+
+func TestReactionSetPrune(t *testing.T) {
+	rs := make(reactionSet)
+	rs.Toggle("m1", "🎉", "user1")
+	rs.Toggle("m2", "🎉", "user1")
+
+	rs.Prune(func(messageID string) bool { return messageID == "m2" })
+
+	assert.Zero(t, rs.Count("m1", "🎉"))
+	assert.Equal(t, 1, rs.Count("m2", "🎉"))
+}
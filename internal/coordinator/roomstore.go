@@ -28,21 +28,54 @@ func (s *roomStore) Store(id string, r *Room) {
 	s.rooms[id] = r
 }
 
+// StoreIfAbsent adds r under id unless id is already taken
+func (s *roomStore) StoreIfAbsent(id string, r *Room) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[id]; ok {
+		return false
+	}
+	s.rooms[id] = r
+	return true
+}
+
 func (s *roomStore) Delete(id string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	delete(s.rooms, id)
 }
 
-func (s *roomStore) Range(f func(*Room) bool) {
+// CompareAndDelete removes id only while it still maps to r
+func (s *roomStore) CompareAndDelete(id string, r *Room) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rooms[id] != r {
+		return false
+	}
+	delete(s.rooms, id)
+	return true
+}
+
+func (s *roomStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.rooms)
+}
+
+// Snapshot copies the current rooms so callers can use them without holding
+// the lock
+func (s *roomStore) Snapshot() []*Room {
 	s.mu.RLock()
+	defer s.mu.RUnlock()
 	rooms := make([]*Room, 0, len(s.rooms))
 	for _, r := range s.rooms {
 		rooms = append(rooms, r)
 	}
-	s.mu.RUnlock()
+	return rooms
+}
 
-	for _, r := range rooms {
+func (s *roomStore) Range(f func(*Room) bool) {
+	for _, r := range s.Snapshot() {
 		if !f(r) {
 			return
 		}
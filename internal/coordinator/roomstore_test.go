@@ -23,6 +23,7 @@ func TestRoomStoreBasicOperations(t *testing.T) {
 	got, ok := s.Load("room1")
 	require.True(t, ok)
 	assert.Same(t, r1, got)
+	assert.Equal(t, 1, s.Len())
 
 	// Load non-existent
 	got, ok = s.Load("nope")
@@ -34,6 +35,7 @@ func TestRoomStoreBasicOperations(t *testing.T) {
 	got, ok = s.Load("room1")
 	require.False(t, ok)
 	assert.Nil(t, got)
+	assert.Equal(t, 0, s.Len())
 }
 
 func TestRoomStoreRange(t *testing.T) {
@@ -55,6 +57,24 @@ func TestRoomStoreRange(t *testing.T) {
 	assert.Len(t, seen, 2)
 }
 
+func TestRoomStoreSnapshotAndStoreIfAbsent(t *testing.T) {
+	s := newRoomStore()
+
+	r1 := newTestRoom("room1")
+	require.True(t, s.StoreIfAbsent("room1", r1))
+	// a second room under the same id is refused and the first one kept
+	assert.False(t, s.StoreIfAbsent("room1", newTestRoom("room1")))
+
+	snap := s.Snapshot()
+	require.Len(t, snap, 1)
+	assert.Same(t, r1, snap[0])
+
+	// the snapshot is a copy, unaffected by later changes
+	s.Delete("room1")
+	assert.Len(t, snap, 1)
+	assert.Empty(t, s.Snapshot())
+}
+
 func TestRoomStoreConcurrentAccess(t *testing.T) {
 	s := newRoomStore()
 
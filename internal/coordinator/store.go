@@ -0,0 +1,48 @@
+package coordinator
+
+import (
+	"sync"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+)
+
+// MessageStore persists chat messages beyond the lifetime of a room
+type MessageStore interface {
+	Append(roomID string, ev messages.RoomMessageEvent) error
+	// Load returns up to limit of the most recent messages, oldest first.
+	// A limit <= 0 returns everything stored for the room.
+	Load(roomID string, limit int) ([]messages.RoomMessageEvent, error)
+}
+
+// MemoryMessageStore is an in-process MessageStore, mainly for tests and single-node setups
+type MemoryMessageStore struct {
+	mu       sync.RWMutex
+	messages map[string][]messages.RoomMessageEvent
+}
+
+func NewMemoryMessageStore() *MemoryMessageStore {
+	return &MemoryMessageStore{
+		messages: make(map[string][]messages.RoomMessageEvent),
+	}
+}
+
+func (s *MemoryMessageStore) Append(roomID string, ev messages.RoomMessageEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[roomID] = append(s.messages[roomID], ev)
+	return nil
+}
+
+func (s *MemoryMessageStore) Load(roomID string, limit int) ([]messages.RoomMessageEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored := s.messages[roomID]
+	if limit > 0 && len(stored) > limit {
+		stored = stored[len(stored)-limit:]
+	}
+
+	out := make([]messages.RoomMessageEvent, len(stored))
+	copy(out, stored)
+	return out, nil
+}
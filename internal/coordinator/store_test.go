@@ -0,0 +1,34 @@
+package coordinator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryMessageStoreLoad(t *testing.T) {
+	s := NewMemoryMessageStore()
+
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, s.Append("room_1", messages.NewRoomMessageEvent("room_1", "u1", "User One", fmt.Sprintf("m%d", i))))
+	}
+	require.NoError(t, s.Append("room_2", messages.NewRoomMessageEvent("room_2", "u1", "User One", "other")))
+
+	all, err := s.Load("room_1", 0)
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	assert.Equal(t, "m1", all[0].Message.Message)
+
+	last, err := s.Load("room_1", 2)
+	require.NoError(t, err)
+	require.Len(t, last, 2)
+	assert.Equal(t, "m3", last[0].Message.Message)
+	assert.Equal(t, "m4", last[1].Message.Message)
+
+	none, err := s.Load("missing", 10)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
@@ -0,0 +1,173 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebhookQueueSize = 256
+	defaultWebhookAttempts  = 3
+	defaultWebhookBackoff   = 500 * time.Millisecond
+	webhookTimeout          = 5 * time.Second
+)
+
+// WebhookNotifier POSTs room lifecycle events as JSON to a URL operators
+// register: new_room, room_closed, user_joined, user_left and
+// user_kicked. Delivery happens on its own goroutine, in order, retrying a
+// failed POST a bounded number of times; when the queue is full new events
+// are dropped, so callers never wait on the remote end.
+type WebhookNotifier struct {
+	url         string
+	client      *http.Client
+	attempts    int
+	backoff     time.Duration // doubles after each failed attempt
+	logger      *slog.Logger
+	queue       chan interface{}
+	done        chan struct{}
+	ctx         context.Context // cancelled by Close to cut retries short
+	stopRetries context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool // guards queue against sends after Close
+}
+
+// WebhookOption configures optional WebhookNotifier settings
+type WebhookOption func(*WebhookNotifier)
+
+// WithWebhookRetries sets how many times each event is attempted and the wait
+// before the first retry
+func WithWebhookRetries(attempts int, backoff time.Duration) WebhookOption {
+	return func(n *WebhookNotifier) {
+		if attempts > 0 {
+			n.attempts = attempts
+		}
+		n.backoff = backoff
+	}
+}
+
+// WithWebhookHTTPClient replaces the client used for deliveries
+func WithWebhookHTTPClient(client *http.Client) WebhookOption {
+	return func(n *WebhookNotifier) {
+		if client != nil {
+			n.client = client
+		}
+	}
+}
+
+// WithWebhookLogger sets the logger for failed deliveries
+func WithWebhookLogger(l *slog.Logger) WebhookOption {
+	return func(n *WebhookNotifier) {
+		if l != nil {
+			n.logger = l
+		}
+	}
+}
+
+// NewWebhookNotifier starts delivering to url; Close stops it
+func NewWebhookNotifier(url string, opts ...WebhookOption) *WebhookNotifier {
+	n := &WebhookNotifier{
+		url:      url,
+		client:   &http.Client{Timeout: webhookTimeout},
+		attempts: defaultWebhookAttempts,
+		backoff:  defaultWebhookBackoff,
+		logger:   slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		queue:    make(chan interface{}, defaultWebhookQueueSize),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	n.ctx, n.stopRetries = context.WithCancel(context.Background())
+
+	go n.run()
+	return n
+}
+
+// Notify queues ev for delivery without blocking
+func (n *WebhookNotifier) Notify(ev interface{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	select {
+	case n.queue <- ev:
+	default:
+		n.logger.Warn("webhook queue full, dropping event", "event", "webhook", "url", n.url)
+	}
+}
+
+// Close delivers what is already queued, giving up on retries, and waits for
+// the delivery goroutine to exit
+func (n *WebhookNotifier) Close() {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	n.stopRetries()
+	<-n.done
+}
+
+func (n *WebhookNotifier) run() {
+	defer close(n.done)
+	for ev := range n.queue {
+		n.deliver(ev)
+	}
+}
+
+func (n *WebhookNotifier) deliver(ev interface{}) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		n.logger.Error("webhook: encode event", "event", "webhook", "error", err)
+		return
+	}
+
+	wait := n.backoff
+	attempt := 1
+retry:
+	for ; ; attempt++ {
+		if err = n.post(body); err == nil {
+			return
+		}
+		if attempt >= n.attempts {
+			break
+		}
+		select {
+		case <-time.After(wait):
+			wait *= 2
+		case <-n.ctx.Done():
+			// closing: no more retries
+			break retry
+		}
+	}
+	n.logger.Warn("webhook delivery failed", "event", "webhook", "url", n.url, "attempts", attempt, "error", err)
+}
+
+func (n *WebhookNotifier) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
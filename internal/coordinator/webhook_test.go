@@ -0,0 +1,98 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookSink records the events POSTed to it; the first failFirst requests
+// are answered with a 500
+func webhookSink(t *testing.T, failFirst int32) (*httptest.Server, <-chan map[string]interface{}, *atomic.Int32) {
+	t.Helper()
+	received := make(chan map[string]interface{}, 16)
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failFirst {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var ev map[string]interface{}
+		assert.NoError(t, json.Unmarshal(body, &ev))
+		received <- ev
+	}))
+	t.Cleanup(ts.Close)
+	return ts, received, &requests
+}
+
+func nextWebhook(t *testing.T, received <-chan map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	select {
+	case ev := <-received:
+		return ev
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "expected a webhook delivery")
+		return nil
+	}
+}
+
+func TestWebhookNotifierReceivesJoin(t *testing.T) {
+	ts, received, _ := webhookSink(t, 0)
+	n := NewWebhookNotifier(ts.URL, WithWebhookLogger(slog.New(slog.DiscardHandler)))
+	defer n.Close()
+
+	c := NewCoordinator(WithWebhookNotifier(n))
+	send := make(chan interface{}, 10)
+	require.NoError(t, c.CreateRoom("room_1", "author1", "Room", "", send))
+	require.NoError(t, c.JoinRoom("room_1", "user2", "User Two", "", make(chan interface{}, 10)))
+
+	created := nextWebhook(t, received)
+	assert.Equal(t, "new_room", created["type"])
+	assert.Equal(t, "room_1", created["room_id"])
+
+	joined := nextWebhook(t, received)
+	assert.Equal(t, "user_joined", joined["type"])
+	assert.Equal(t, "room_1", joined["room_id"])
+	assert.Equal(t, "user2", joined["user_id"])
+}
+
+func TestWebhookNotifierRetries(t *testing.T) {
+	ts, received, requests := webhookSink(t, 2)
+	n := NewWebhookNotifier(ts.URL,
+		WithWebhookRetries(3, time.Millisecond),
+		WithWebhookLogger(slog.New(slog.DiscardHandler)),
+	)
+	defer n.Close()
+
+	n.Notify(map[string]string{"type": "user_left"})
+	assert.Equal(t, "user_left", nextWebhook(t, received)["type"])
+	assert.EqualValues(t, 3, requests.Load())
+}
+
+func TestWebhookNotifierGivesUp(t *testing.T) {
+	ts, received, requests := webhookSink(t, 100)
+	n := NewWebhookNotifier(ts.URL,
+		WithWebhookRetries(2, time.Millisecond),
+		WithWebhookLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	n.Notify(map[string]string{"type": "user_left"})
+	require.Eventually(t, func() bool { return requests.Load() == 2 }, time.Second, time.Millisecond)
+	n.Close()
+	assert.Empty(t, received)
+	assert.EqualValues(t, 2, requests.Load())
+
+	// events after Close are ignored
+	n.Notify(map[string]string{"type": "user_left"})
+}
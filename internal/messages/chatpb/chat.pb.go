@@ -0,0 +1,1619 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: chat.proto
+
+package chatpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// WsMessage is the inbound envelope. type names the action; the payload set
+// must be the one for that action, and actions without a payload set none.
+type WsMessage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*WsMessage_CreateRoom
+	//	*WsMessage_Join
+	//	*WsMessage_Leave
+	//	*WsMessage_Message
+	//	*WsMessage_ListMembers
+	//	*WsMessage_Kick
+	//	*WsMessage_Rename
+	//	*WsMessage_Typing
+	//	*WsMessage_Resume
+	//	*WsMessage_Delete
+	//	*WsMessage_React
+	//	*WsMessage_DirectMessage
+	//	*WsMessage_History
+	//	*WsMessage_MarkRead
+	//	*WsMessage_GrantMod
+	//	*WsMessage_RevokeMod
+	//	*WsMessage_Mute
+	//	*WsMessage_Unmute
+	//	*WsMessage_SetRoomMeta
+	Payload       isWsMessage_Payload `protobuf_oneof:"payload"`
+	RequestId     *string             `protobuf:"bytes,16,opt,name=request_id,json=requestId,proto3,oneof" json:"request_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WsMessage) Reset() {
+	*x = WsMessage{}
+	mi := &file_chat_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WsMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WsMessage) ProtoMessage() {}
+
+func (x *WsMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WsMessage.ProtoReflect.Descriptor instead.
+func (*WsMessage) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *WsMessage) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *WsMessage) GetPayload() isWsMessage_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *WsMessage) GetCreateRoom() *CreateRoomPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_CreateRoom); ok {
+			return x.CreateRoom
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetJoin() *JoinRoomPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_Join); ok {
+			return x.Join
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetLeave() *LeaveRoomPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_Leave); ok {
+			return x.Leave
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetMessage() *MessagePayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_Message); ok {
+			return x.Message
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetListMembers() *ListMembersPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_ListMembers); ok {
+			return x.ListMembers
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetKick() *KickPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_Kick); ok {
+			return x.Kick
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetRename() *RenamePayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_Rename); ok {
+			return x.Rename
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetTyping() *TypingPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_Typing); ok {
+			return x.Typing
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetResume() *ResumePayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_Resume); ok {
+			return x.Resume
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetDelete() *DeletePayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_Delete); ok {
+			return x.Delete
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetReact() *ReactPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_React); ok {
+			return x.React
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetDirectMessage() *DirectMessagePayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_DirectMessage); ok {
+			return x.DirectMessage
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetHistory() *HistoryPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_History); ok {
+			return x.History
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetMarkRead() *MarkReadPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_MarkRead); ok {
+			return x.MarkRead
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetGrantMod() *ModeratorPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_GrantMod); ok {
+			return x.GrantMod
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetRevokeMod() *ModeratorPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_RevokeMod); ok {
+			return x.RevokeMod
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetMute() *MutePayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_Mute); ok {
+			return x.Mute
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetUnmute() *MutePayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_Unmute); ok {
+			return x.Unmute
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetSetRoomMeta() *RoomMetaPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*WsMessage_SetRoomMeta); ok {
+			return x.SetRoomMeta
+		}
+	}
+	return nil
+}
+
+func (x *WsMessage) GetRequestId() string {
+	if x != nil && x.RequestId != nil {
+		return *x.RequestId
+	}
+	return ""
+}
+
+type isWsMessage_Payload interface {
+	isWsMessage_Payload()
+}
+
+type WsMessage_CreateRoom struct {
+	CreateRoom *CreateRoomPayload `protobuf:"bytes,2,opt,name=create_room,json=createRoom,proto3,oneof"`
+}
+
+type WsMessage_Join struct {
+	Join *JoinRoomPayload `protobuf:"bytes,3,opt,name=join,proto3,oneof"`
+}
+
+type WsMessage_Leave struct {
+	Leave *LeaveRoomPayload `protobuf:"bytes,4,opt,name=leave,proto3,oneof"`
+}
+
+type WsMessage_Message struct {
+	Message *MessagePayload `protobuf:"bytes,5,opt,name=message,proto3,oneof"`
+}
+
+type WsMessage_ListMembers struct {
+	ListMembers *ListMembersPayload `protobuf:"bytes,6,opt,name=list_members,json=listMembers,proto3,oneof"`
+}
+
+type WsMessage_Kick struct {
+	Kick *KickPayload `protobuf:"bytes,7,opt,name=kick,proto3,oneof"`
+}
+
+type WsMessage_Rename struct {
+	Rename *RenamePayload `protobuf:"bytes,8,opt,name=rename,proto3,oneof"`
+}
+
+type WsMessage_Typing struct {
+	Typing *TypingPayload `protobuf:"bytes,9,opt,name=typing,proto3,oneof"`
+}
+
+type WsMessage_Resume struct {
+	Resume *ResumePayload `protobuf:"bytes,10,opt,name=resume,proto3,oneof"`
+}
+
+type WsMessage_Delete struct {
+	Delete *DeletePayload `protobuf:"bytes,11,opt,name=delete,proto3,oneof"`
+}
+
+type WsMessage_React struct {
+	React *ReactPayload `protobuf:"bytes,12,opt,name=react,proto3,oneof"`
+}
+
+type WsMessage_DirectMessage struct {
+	DirectMessage *DirectMessagePayload `protobuf:"bytes,13,opt,name=direct_message,json=directMessage,proto3,oneof"`
+}
+
+type WsMessage_History struct {
+	History *HistoryPayload `protobuf:"bytes,14,opt,name=history,proto3,oneof"`
+}
+
+type WsMessage_MarkRead struct {
+	MarkRead *MarkReadPayload `protobuf:"bytes,15,opt,name=mark_read,json=markRead,proto3,oneof"`
+}
+
+type WsMessage_GrantMod struct {
+	GrantMod *ModeratorPayload `protobuf:"bytes,17,opt,name=grant_mod,json=grantMod,proto3,oneof"`
+}
+
+type WsMessage_RevokeMod struct {
+	RevokeMod *ModeratorPayload `protobuf:"bytes,18,opt,name=revoke_mod,json=revokeMod,proto3,oneof"`
+}
+
+type WsMessage_Mute struct {
+	Mute *MutePayload `protobuf:"bytes,19,opt,name=mute,proto3,oneof"`
+}
+
+type WsMessage_Unmute struct {
+	Unmute *MutePayload `protobuf:"bytes,20,opt,name=unmute,proto3,oneof"`
+}
+
+type WsMessage_SetRoomMeta struct {
+	SetRoomMeta *RoomMetaPayload `protobuf:"bytes,21,opt,name=set_room_meta,json=setRoomMeta,proto3,oneof"`
+}
+
+func (*WsMessage_CreateRoom) isWsMessage_Payload() {}
+
+func (*WsMessage_Join) isWsMessage_Payload() {}
+
+func (*WsMessage_Leave) isWsMessage_Payload() {}
+
+func (*WsMessage_Message) isWsMessage_Payload() {}
+
+func (*WsMessage_ListMembers) isWsMessage_Payload() {}
+
+func (*WsMessage_Kick) isWsMessage_Payload() {}
+
+func (*WsMessage_Rename) isWsMessage_Payload() {}
+
+func (*WsMessage_Typing) isWsMessage_Payload() {}
+
+func (*WsMessage_Resume) isWsMessage_Payload() {}
+
+func (*WsMessage_Delete) isWsMessage_Payload() {}
+
+func (*WsMessage_React) isWsMessage_Payload() {}
+
+func (*WsMessage_DirectMessage) isWsMessage_Payload() {}
+
+func (*WsMessage_History) isWsMessage_Payload() {}
+
+func (*WsMessage_MarkRead) isWsMessage_Payload() {}
+
+func (*WsMessage_GrantMod) isWsMessage_Payload() {}
+
+func (*WsMessage_RevokeMod) isWsMessage_Payload() {}
+
+func (*WsMessage_Mute) isWsMessage_Payload() {}
+
+func (*WsMessage_Unmute) isWsMessage_Payload() {}
+
+func (*WsMessage_SetRoomMeta) isWsMessage_Payload() {}
+
+type CreateRoomPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	RoomName      string                 `protobuf:"bytes,2,opt,name=room_name,json=roomName,proto3" json:"room_name,omitempty"`
+	UserId        string                 `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	UserName      string                 `protobuf:"bytes,4,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty"`
+	Password      *string                `protobuf:"bytes,5,opt,name=password,proto3,oneof" json:"password,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateRoomPayload) Reset() {
+	*x = CreateRoomPayload{}
+	mi := &file_chat_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateRoomPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateRoomPayload) ProtoMessage() {}
+
+func (x *CreateRoomPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateRoomPayload.ProtoReflect.Descriptor instead.
+func (*CreateRoomPayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateRoomPayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *CreateRoomPayload) GetRoomName() string {
+	if x != nil {
+		return x.RoomName
+	}
+	return ""
+}
+
+func (x *CreateRoomPayload) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *CreateRoomPayload) GetUserName() string {
+	if x != nil {
+		return x.UserName
+	}
+	return ""
+}
+
+func (x *CreateRoomPayload) GetPassword() string {
+	if x != nil && x.Password != nil {
+		return *x.Password
+	}
+	return ""
+}
+
+type JoinRoomPayload struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	RoomId          string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	UserId          string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	UserName        string                 `protobuf:"bytes,3,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty"`
+	Password        *string                `protobuf:"bytes,4,opt,name=password,proto3,oneof" json:"password,omitempty"`
+	CreateIfMissing *bool                  `protobuf:"varint,5,opt,name=create_if_missing,json=createIfMissing,proto3,oneof" json:"create_if_missing,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *JoinRoomPayload) Reset() {
+	*x = JoinRoomPayload{}
+	mi := &file_chat_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JoinRoomPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JoinRoomPayload) ProtoMessage() {}
+
+func (x *JoinRoomPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JoinRoomPayload.ProtoReflect.Descriptor instead.
+func (*JoinRoomPayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *JoinRoomPayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *JoinRoomPayload) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *JoinRoomPayload) GetUserName() string {
+	if x != nil {
+		return x.UserName
+	}
+	return ""
+}
+
+func (x *JoinRoomPayload) GetPassword() string {
+	if x != nil && x.Password != nil {
+		return *x.Password
+	}
+	return ""
+}
+
+func (x *JoinRoomPayload) GetCreateIfMissing() bool {
+	if x != nil && x.CreateIfMissing != nil {
+		return *x.CreateIfMissing
+	}
+	return false
+}
+
+type LeaveRoomPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LeaveRoomPayload) Reset() {
+	*x = LeaveRoomPayload{}
+	mi := &file_chat_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LeaveRoomPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaveRoomPayload) ProtoMessage() {}
+
+func (x *LeaveRoomPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaveRoomPayload.ProtoReflect.Descriptor instead.
+func (*LeaveRoomPayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LeaveRoomPayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+type MessagePayload struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	RoomId           string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	Message          string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	ClientMsgId      *string                `protobuf:"bytes,3,opt,name=client_msg_id,json=clientMsgId,proto3,oneof" json:"client_msg_id,omitempty"`
+	ParentMessageId  *string                `protobuf:"bytes,4,opt,name=parent_message_id,json=parentMessageId,proto3,oneof" json:"parent_message_id,omitempty"`
+	ExpiresInSeconds *int64                 `protobuf:"varint,5,opt,name=expires_in_seconds,json=expiresInSeconds,proto3,oneof" json:"expires_in_seconds,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *MessagePayload) Reset() {
+	*x = MessagePayload{}
+	mi := &file_chat_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessagePayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessagePayload) ProtoMessage() {}
+
+func (x *MessagePayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessagePayload.ProtoReflect.Descriptor instead.
+func (*MessagePayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *MessagePayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *MessagePayload) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *MessagePayload) GetClientMsgId() string {
+	if x != nil && x.ClientMsgId != nil {
+		return *x.ClientMsgId
+	}
+	return ""
+}
+
+func (x *MessagePayload) GetParentMessageId() string {
+	if x != nil && x.ParentMessageId != nil {
+		return *x.ParentMessageId
+	}
+	return ""
+}
+
+func (x *MessagePayload) GetExpiresInSeconds() int64 {
+	if x != nil && x.ExpiresInSeconds != nil {
+		return *x.ExpiresInSeconds
+	}
+	return 0
+}
+
+type ListMembersPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMembersPayload) Reset() {
+	*x = ListMembersPayload{}
+	mi := &file_chat_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMembersPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMembersPayload) ProtoMessage() {}
+
+func (x *ListMembersPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMembersPayload.ProtoReflect.Descriptor instead.
+func (*ListMembersPayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListMembersPayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+type KickPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	TargetUserId  string                 `protobuf:"bytes,2,opt,name=target_user_id,json=targetUserId,proto3" json:"target_user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KickPayload) Reset() {
+	*x = KickPayload{}
+	mi := &file_chat_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KickPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KickPayload) ProtoMessage() {}
+
+func (x *KickPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KickPayload.ProtoReflect.Descriptor instead.
+func (*KickPayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *KickPayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *KickPayload) GetTargetUserId() string {
+	if x != nil {
+		return x.TargetUserId
+	}
+	return ""
+}
+
+type ModeratorPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	TargetUserId  string                 `protobuf:"bytes,2,opt,name=target_user_id,json=targetUserId,proto3" json:"target_user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ModeratorPayload) Reset() {
+	*x = ModeratorPayload{}
+	mi := &file_chat_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModeratorPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModeratorPayload) ProtoMessage() {}
+
+func (x *ModeratorPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModeratorPayload.ProtoReflect.Descriptor instead.
+func (*ModeratorPayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ModeratorPayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *ModeratorPayload) GetTargetUserId() string {
+	if x != nil {
+		return x.TargetUserId
+	}
+	return ""
+}
+
+type MutePayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	TargetUserId  string                 `protobuf:"bytes,2,opt,name=target_user_id,json=targetUserId,proto3" json:"target_user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MutePayload) Reset() {
+	*x = MutePayload{}
+	mi := &file_chat_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MutePayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MutePayload) ProtoMessage() {}
+
+func (x *MutePayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MutePayload.ProtoReflect.Descriptor instead.
+func (*MutePayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *MutePayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *MutePayload) GetTargetUserId() string {
+	if x != nil {
+		return x.TargetUserId
+	}
+	return ""
+}
+
+// an empty topic or description clears it
+type RoomMetaPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	Topic         string                 `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoomMetaPayload) Reset() {
+	*x = RoomMetaPayload{}
+	mi := &file_chat_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomMetaPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomMetaPayload) ProtoMessage() {}
+
+func (x *RoomMetaPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomMetaPayload.ProtoReflect.Descriptor instead.
+func (*RoomMetaPayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RoomMetaPayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *RoomMetaPayload) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *RoomMetaPayload) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type RenamePayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NewName       string                 `protobuf:"bytes,1,opt,name=new_name,json=newName,proto3" json:"new_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenamePayload) Reset() {
+	*x = RenamePayload{}
+	mi := &file_chat_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenamePayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenamePayload) ProtoMessage() {}
+
+func (x *RenamePayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenamePayload.ProtoReflect.Descriptor instead.
+func (*RenamePayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RenamePayload) GetNewName() string {
+	if x != nil {
+		return x.NewName
+	}
+	return ""
+}
+
+type TypingPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	IsTyping      bool                   `protobuf:"varint,2,opt,name=is_typing,json=isTyping,proto3" json:"is_typing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TypingPayload) Reset() {
+	*x = TypingPayload{}
+	mi := &file_chat_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TypingPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TypingPayload) ProtoMessage() {}
+
+func (x *TypingPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TypingPayload.ProtoReflect.Descriptor instead.
+func (*TypingPayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *TypingPayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *TypingPayload) GetIsTyping() bool {
+	if x != nil {
+		return x.IsTyping
+	}
+	return false
+}
+
+type ResumePayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ResumeToken   string                 `protobuf:"bytes,1,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumePayload) Reset() {
+	*x = ResumePayload{}
+	mi := &file_chat_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumePayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumePayload) ProtoMessage() {}
+
+func (x *ResumePayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumePayload.ProtoReflect.Descriptor instead.
+func (*ResumePayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ResumePayload) GetResumeToken() string {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return ""
+}
+
+type DeletePayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	MessageId     string                 `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePayload) Reset() {
+	*x = DeletePayload{}
+	mi := &file_chat_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePayload) ProtoMessage() {}
+
+func (x *DeletePayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePayload.ProtoReflect.Descriptor instead.
+func (*DeletePayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DeletePayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *DeletePayload) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+type ReactPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	MessageId     string                 `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Emoji         string                 `protobuf:"bytes,3,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReactPayload) Reset() {
+	*x = ReactPayload{}
+	mi := &file_chat_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReactPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReactPayload) ProtoMessage() {}
+
+func (x *ReactPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReactPayload.ProtoReflect.Descriptor instead.
+func (*ReactPayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ReactPayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *ReactPayload) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *ReactPayload) GetEmoji() string {
+	if x != nil {
+		return x.Emoji
+	}
+	return ""
+}
+
+type DirectMessagePayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ToUserId      string                 `protobuf:"bytes,1,opt,name=to_user_id,json=toUserId,proto3" json:"to_user_id,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DirectMessagePayload) Reset() {
+	*x = DirectMessagePayload{}
+	mi := &file_chat_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DirectMessagePayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DirectMessagePayload) ProtoMessage() {}
+
+func (x *DirectMessagePayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DirectMessagePayload.ProtoReflect.Descriptor instead.
+func (*DirectMessagePayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *DirectMessagePayload) GetToUserId() string {
+	if x != nil {
+		return x.ToUserId
+	}
+	return ""
+}
+
+func (x *DirectMessagePayload) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type HistoryPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	BeforeSeq     *uint64                `protobuf:"varint,2,opt,name=before_seq,json=beforeSeq,proto3,oneof" json:"before_seq,omitempty"`
+	Limit         *int64                 `protobuf:"varint,3,opt,name=limit,proto3,oneof" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryPayload) Reset() {
+	*x = HistoryPayload{}
+	mi := &file_chat_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryPayload) ProtoMessage() {}
+
+func (x *HistoryPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryPayload.ProtoReflect.Descriptor instead.
+func (*HistoryPayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *HistoryPayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *HistoryPayload) GetBeforeSeq() uint64 {
+	if x != nil && x.BeforeSeq != nil {
+		return *x.BeforeSeq
+	}
+	return 0
+}
+
+func (x *HistoryPayload) GetLimit() int64 {
+	if x != nil && x.Limit != nil {
+		return *x.Limit
+	}
+	return 0
+}
+
+type MarkReadPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	Seq           uint64                 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MarkReadPayload) Reset() {
+	*x = MarkReadPayload{}
+	mi := &file_chat_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MarkReadPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MarkReadPayload) ProtoMessage() {}
+
+func (x *MarkReadPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MarkReadPayload.ProtoReflect.Descriptor instead.
+func (*MarkReadPayload) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *MarkReadPayload) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *MarkReadPayload) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+// ServerEvent is every outbound frame. data holds the event exactly as the
+// JSON protocol sends it, including its type.
+type ServerEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Data          *structpb.Struct       `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerEvent) Reset() {
+	*x = ServerEvent{}
+	mi := &file_chat_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerEvent) ProtoMessage() {}
+
+func (x *ServerEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerEvent.ProtoReflect.Descriptor instead.
+func (*ServerEvent) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ServerEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ServerEvent) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_chat_proto protoreflect.FileDescriptor
+
+const file_chat_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"chat.proto\x12\achat.v1\x1a\x1cgoogle/protobuf/struct.proto\"\xe1\b\n" +
+	"\tWsMessage\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12=\n" +
+	"\vcreate_room\x18\x02 \x01(\v2\x1a.chat.v1.CreateRoomPayloadH\x00R\n" +
+	"createRoom\x12.\n" +
+	"\x04join\x18\x03 \x01(\v2\x18.chat.v1.JoinRoomPayloadH\x00R\x04join\x121\n" +
+	"\x05leave\x18\x04 \x01(\v2\x19.chat.v1.LeaveRoomPayloadH\x00R\x05leave\x123\n" +
+	"\amessage\x18\x05 \x01(\v2\x17.chat.v1.MessagePayloadH\x00R\amessage\x12@\n" +
+	"\flist_members\x18\x06 \x01(\v2\x1b.chat.v1.ListMembersPayloadH\x00R\vlistMembers\x12*\n" +
+	"\x04kick\x18\a \x01(\v2\x14.chat.v1.KickPayloadH\x00R\x04kick\x120\n" +
+	"\x06rename\x18\b \x01(\v2\x16.chat.v1.RenamePayloadH\x00R\x06rename\x120\n" +
+	"\x06typing\x18\t \x01(\v2\x16.chat.v1.TypingPayloadH\x00R\x06typing\x120\n" +
+	"\x06resume\x18\n" +
+	" \x01(\v2\x16.chat.v1.ResumePayloadH\x00R\x06resume\x120\n" +
+	"\x06delete\x18\v \x01(\v2\x16.chat.v1.DeletePayloadH\x00R\x06delete\x12-\n" +
+	"\x05react\x18\f \x01(\v2\x15.chat.v1.ReactPayloadH\x00R\x05react\x12F\n" +
+	"\x0edirect_message\x18\r \x01(\v2\x1d.chat.v1.DirectMessagePayloadH\x00R\rdirectMessage\x123\n" +
+	"\ahistory\x18\x0e \x01(\v2\x17.chat.v1.HistoryPayloadH\x00R\ahistory\x127\n" +
+	"\tmark_read\x18\x0f \x01(\v2\x18.chat.v1.MarkReadPayloadH\x00R\bmarkRead\x128\n" +
+	"\tgrant_mod\x18\x11 \x01(\v2\x19.chat.v1.ModeratorPayloadH\x00R\bgrantMod\x12:\n" +
+	"\n" +
+	"revoke_mod\x18\x12 \x01(\v2\x19.chat.v1.ModeratorPayloadH\x00R\trevokeMod\x12*\n" +
+	"\x04mute\x18\x13 \x01(\v2\x14.chat.v1.MutePayloadH\x00R\x04mute\x12.\n" +
+	"\x06unmute\x18\x14 \x01(\v2\x14.chat.v1.MutePayloadH\x00R\x06unmute\x12>\n" +
+	"\rset_room_meta\x18\x15 \x01(\v2\x18.chat.v1.RoomMetaPayloadH\x00R\vsetRoomMeta\x12\"\n" +
+	"\n" +
+	"request_id\x18\x10 \x01(\tH\x01R\trequestId\x88\x01\x01B\t\n" +
+	"\apayloadB\r\n" +
+	"\v_request_id\"\xad\x01\n" +
+	"\x11CreateRoomPayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12\x1b\n" +
+	"\troom_name\x18\x02 \x01(\tR\broomName\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tuser_name\x18\x04 \x01(\tR\buserName\x12\x1f\n" +
+	"\bpassword\x18\x05 \x01(\tH\x00R\bpassword\x88\x01\x01B\v\n" +
+	"\t_password\"\xd5\x01\n" +
+	"\x0fJoinRoomPayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tuser_name\x18\x03 \x01(\tR\buserName\x12\x1f\n" +
+	"\bpassword\x18\x04 \x01(\tH\x00R\bpassword\x88\x01\x01\x12/\n" +
+	"\x11create_if_missing\x18\x05 \x01(\bH\x01R\x0fcreateIfMissing\x88\x01\x01B\v\n" +
+	"\t_passwordB\x14\n" +
+	"\x12_create_if_missing\"+\n" +
+	"\x10LeaveRoomPayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\"\x8f\x02\n" +
+	"\x0eMessagePayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12'\n" +
+	"\rclient_msg_id\x18\x03 \x01(\tH\x00R\vclientMsgId\x88\x01\x01\x12/\n" +
+	"\x11parent_message_id\x18\x04 \x01(\tH\x01R\x0fparentMessageId\x88\x01\x01\x121\n" +
+	"\x12expires_in_seconds\x18\x05 \x01(\x03H\x02R\x10expiresInSeconds\x88\x01\x01B\x10\n" +
+	"\x0e_client_msg_idB\x14\n" +
+	"\x12_parent_message_idB\x15\n" +
+	"\x13_expires_in_seconds\"-\n" +
+	"\x12ListMembersPayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\"L\n" +
+	"\vKickPayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12$\n" +
+	"\x0etarget_user_id\x18\x02 \x01(\tR\ftargetUserId\"Q\n" +
+	"\x10ModeratorPayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12$\n" +
+	"\x0etarget_user_id\x18\x02 \x01(\tR\ftargetUserId\"L\n" +
+	"\vMutePayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12$\n" +
+	"\x0etarget_user_id\x18\x02 \x01(\tR\ftargetUserId\"b\n" +
+	"\x0fRoomMetaPayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12\x14\n" +
+	"\x05topic\x18\x02 \x01(\tR\x05topic\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\"*\n" +
+	"\rRenamePayload\x12\x19\n" +
+	"\bnew_name\x18\x01 \x01(\tR\anewName\"E\n" +
+	"\rTypingPayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12\x1b\n" +
+	"\tis_typing\x18\x02 \x01(\bR\bisTyping\"2\n" +
+	"\rResumePayload\x12!\n" +
+	"\fresume_token\x18\x01 \x01(\tR\vresumeToken\"G\n" +
+	"\rDeletePayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x02 \x01(\tR\tmessageId\"\\\n" +
+	"\fReactPayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x02 \x01(\tR\tmessageId\x12\x14\n" +
+	"\x05emoji\x18\x03 \x01(\tR\x05emoji\"N\n" +
+	"\x14DirectMessagePayload\x12\x1c\n" +
+	"\n" +
+	"to_user_id\x18\x01 \x01(\tR\btoUserId\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\x81\x01\n" +
+	"\x0eHistoryPayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12\"\n" +
+	"\n" +
+	"before_seq\x18\x02 \x01(\x04H\x00R\tbeforeSeq\x88\x01\x01\x12\x19\n" +
+	"\x05limit\x18\x03 \x01(\x03H\x01R\x05limit\x88\x01\x01B\r\n" +
+	"\v_before_seqB\b\n" +
+	"\x06_limit\"<\n" +
+	"\x0fMarkReadPayload\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x04R\x03seq\"N\n" +
+	"\vServerEvent\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12+\n" +
+	"\x04data\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x04dataB=Z;github.com/arturskrzydlo/chat-room/internal/messages/chatpbb\x06proto3"
+
+var (
+	file_chat_proto_rawDescOnce sync.Once
+	file_chat_proto_rawDescData []byte
+)
+
+func file_chat_proto_rawDescGZIP() []byte {
+	file_chat_proto_rawDescOnce.Do(func() {
+		file_chat_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_chat_proto_rawDesc), len(file_chat_proto_rawDesc)))
+	})
+	return file_chat_proto_rawDescData
+}
+
+var file_chat_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_chat_proto_goTypes = []any{
+	(*WsMessage)(nil),            // 0: chat.v1.WsMessage
+	(*CreateRoomPayload)(nil),    // 1: chat.v1.CreateRoomPayload
+	(*JoinRoomPayload)(nil),      // 2: chat.v1.JoinRoomPayload
+	(*LeaveRoomPayload)(nil),     // 3: chat.v1.LeaveRoomPayload
+	(*MessagePayload)(nil),       // 4: chat.v1.MessagePayload
+	(*ListMembersPayload)(nil),   // 5: chat.v1.ListMembersPayload
+	(*KickPayload)(nil),          // 6: chat.v1.KickPayload
+	(*ModeratorPayload)(nil),     // 7: chat.v1.ModeratorPayload
+	(*MutePayload)(nil),          // 8: chat.v1.MutePayload
+	(*RoomMetaPayload)(nil),      // 9: chat.v1.RoomMetaPayload
+	(*RenamePayload)(nil),        // 10: chat.v1.RenamePayload
+	(*TypingPayload)(nil),        // 11: chat.v1.TypingPayload
+	(*ResumePayload)(nil),        // 12: chat.v1.ResumePayload
+	(*DeletePayload)(nil),        // 13: chat.v1.DeletePayload
+	(*ReactPayload)(nil),         // 14: chat.v1.ReactPayload
+	(*DirectMessagePayload)(nil), // 15: chat.v1.DirectMessagePayload
+	(*HistoryPayload)(nil),       // 16: chat.v1.HistoryPayload
+	(*MarkReadPayload)(nil),      // 17: chat.v1.MarkReadPayload
+	(*ServerEvent)(nil),          // 18: chat.v1.ServerEvent
+	(*structpb.Struct)(nil),      // 19: google.protobuf.Struct
+}
+var file_chat_proto_depIdxs = []int32{
+	1,  // 0: chat.v1.WsMessage.create_room:type_name -> chat.v1.CreateRoomPayload
+	2,  // 1: chat.v1.WsMessage.join:type_name -> chat.v1.JoinRoomPayload
+	3,  // 2: chat.v1.WsMessage.leave:type_name -> chat.v1.LeaveRoomPayload
+	4,  // 3: chat.v1.WsMessage.message:type_name -> chat.v1.MessagePayload
+	5,  // 4: chat.v1.WsMessage.list_members:type_name -> chat.v1.ListMembersPayload
+	6,  // 5: chat.v1.WsMessage.kick:type_name -> chat.v1.KickPayload
+	10, // 6: chat.v1.WsMessage.rename:type_name -> chat.v1.RenamePayload
+	11, // 7: chat.v1.WsMessage.typing:type_name -> chat.v1.TypingPayload
+	12, // 8: chat.v1.WsMessage.resume:type_name -> chat.v1.ResumePayload
+	13, // 9: chat.v1.WsMessage.delete:type_name -> chat.v1.DeletePayload
+	14, // 10: chat.v1.WsMessage.react:type_name -> chat.v1.ReactPayload
+	15, // 11: chat.v1.WsMessage.direct_message:type_name -> chat.v1.DirectMessagePayload
+	16, // 12: chat.v1.WsMessage.history:type_name -> chat.v1.HistoryPayload
+	17, // 13: chat.v1.WsMessage.mark_read:type_name -> chat.v1.MarkReadPayload
+	7,  // 14: chat.v1.WsMessage.grant_mod:type_name -> chat.v1.ModeratorPayload
+	7,  // 15: chat.v1.WsMessage.revoke_mod:type_name -> chat.v1.ModeratorPayload
+	8,  // 16: chat.v1.WsMessage.mute:type_name -> chat.v1.MutePayload
+	8,  // 17: chat.v1.WsMessage.unmute:type_name -> chat.v1.MutePayload
+	9,  // 18: chat.v1.WsMessage.set_room_meta:type_name -> chat.v1.RoomMetaPayload
+	19, // 19: chat.v1.ServerEvent.data:type_name -> google.protobuf.Struct
+	20, // [20:20] is the sub-list for method output_type
+	20, // [20:20] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
+}
+
+func init() { file_chat_proto_init() }
+func file_chat_proto_init() {
+	if File_chat_proto != nil {
+		return
+	}
+	file_chat_proto_msgTypes[0].OneofWrappers = []any{
+		(*WsMessage_CreateRoom)(nil),
+		(*WsMessage_Join)(nil),
+		(*WsMessage_Leave)(nil),
+		(*WsMessage_Message)(nil),
+		(*WsMessage_ListMembers)(nil),
+		(*WsMessage_Kick)(nil),
+		(*WsMessage_Rename)(nil),
+		(*WsMessage_Typing)(nil),
+		(*WsMessage_Resume)(nil),
+		(*WsMessage_Delete)(nil),
+		(*WsMessage_React)(nil),
+		(*WsMessage_DirectMessage)(nil),
+		(*WsMessage_History)(nil),
+		(*WsMessage_MarkRead)(nil),
+		(*WsMessage_GrantMod)(nil),
+		(*WsMessage_RevokeMod)(nil),
+		(*WsMessage_Mute)(nil),
+		(*WsMessage_Unmute)(nil),
+		(*WsMessage_SetRoomMeta)(nil),
+	}
+	file_chat_proto_msgTypes[1].OneofWrappers = []any{}
+	file_chat_proto_msgTypes[2].OneofWrappers = []any{}
+	file_chat_proto_msgTypes[4].OneofWrappers = []any{}
+	file_chat_proto_msgTypes[16].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_chat_proto_rawDesc), len(file_chat_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_chat_proto_goTypes,
+		DependencyIndexes: file_chat_proto_depIdxs,
+		MessageInfos:      file_chat_proto_msgTypes,
+	}.Build()
+	File_chat_proto = out.File
+	file_chat_proto_goTypes = nil
+	file_chat_proto_depIdxs = nil
+}
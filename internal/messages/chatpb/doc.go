@@ -0,0 +1,6 @@
+// Package chatpb is the protobuf form of the websocket protocol, for clients
+// that negotiate the chat.proto.v1 subprotocol. chat.pb.go is generated from
+// chat.proto; keep the field names in step with the JSON payloads.
+package chatpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative chat.proto
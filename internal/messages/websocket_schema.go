@@ -1,6 +1,8 @@
 package messages
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"time"
 )
@@ -8,22 +10,45 @@ import (
 type InputMessageActionType string
 
 const (
-	MessageActionTypeJoin       InputMessageActionType = "join"
-	MessageActionTypeLeave      InputMessageActionType = "leave"
-	MessageActionTypeMessage    InputMessageActionType = "message"
-	MessageActionTypeCreateRoom InputMessageActionType = "create_room"
-	MessageActionTypePing       InputMessageActionType = "ping"
+	MessageActionTypeJoin              InputMessageActionType = "join"
+	MessageActionTypeLeave             InputMessageActionType = "leave"
+	MessageActionTypeLeaveAll          InputMessageActionType = "leave_all"
+	MessageActionTypeMessage           InputMessageActionType = "message"
+	MessageActionTypeCreateRoom        InputMessageActionType = "create_room"
+	MessageActionTypePing              InputMessageActionType = "ping"
+	MessageActionTypeListMembers       InputMessageActionType = "list_members"
+	MessageActionTypeKick              InputMessageActionType = "kick"
+	MessageActionTypeRename            InputMessageActionType = "rename"
+	MessageActionTypeTyping            InputMessageActionType = "typing"
+	MessageActionTypeResume            InputMessageActionType = "resume"
+	MessageActionTypeDelete            InputMessageActionType = "delete"
+	MessageActionTypeReact             InputMessageActionType = "react"
+	MessageActionTypeDirectMessage     InputMessageActionType = "direct_message"
+	MessageActionTypeSubscribePresence InputMessageActionType = "subscribe_presence"
+	MessageActionTypeHistory           InputMessageActionType = "history"
+	MessageActionTypeMarkRead          InputMessageActionType = "mark_read"
+	MessageActionTypeWhoAmI            InputMessageActionType = "whoami"
+	MessageActionTypeGrantMod          InputMessageActionType = "grant_mod"
+	MessageActionTypeRevokeMod         InputMessageActionType = "revoke_mod"
+	MessageActionTypeMute              InputMessageActionType = "mute"
+	MessageActionTypeUnmute            InputMessageActionType = "unmute"
+	MessageActionTypeSetRoomMeta       InputMessageActionType = "set_room_meta"
 )
 
 type WsMessage struct {
-	Type    InputMessageActionType `json:"type"`
-	Payload json.RawMessage        `json:"payload"`
+	Type      InputMessageActionType `json:"type"`
+	Payload   json.RawMessage        `json:"payload"`
+	RequestID string                 `json:"request_id,omitempty"` // optional; echoed on the reply or error this message causes
 }
 
 type JoinRoomPayload struct {
 	RoomID   string `json:"room_id"`
 	UserID   string `json:"user_id"`
 	UserName string `json:"user_name"`
+	Password string `json:"password,omitempty"`
+	// CreateIfMissing creates the room, with the joiner as author, instead
+	// of failing when it doesn't exist
+	CreateIfMissing bool `json:"create_if_missing,omitempty"`
 }
 
 type LeaveRoomPayload struct {
@@ -31,8 +56,85 @@ type LeaveRoomPayload struct {
 }
 
 type MessagePayload struct {
-	RoomID  string `json:"room_id"`
-	Message string `json:"message"`
+	RoomID          string `json:"room_id"`
+	Message         string `json:"message"`
+	ClientMsgID     string `json:"client_msg_id,omitempty"`     // optional idempotency key, echoed in the broadcast
+	ParentMessageID string `json:"parent_message_id,omitempty"` // set on replies; the parent must be in room history
+	// ExpiresInSeconds makes the message disappear that long after it is
+	// sent: members get a MessageExpiredEvent and it leaves room history
+	ExpiresInSeconds int `json:"expires_in_seconds,omitempty"`
+}
+
+type ListMembersPayload struct {
+	RoomID string `json:"room_id"`
+}
+
+type KickPayload struct {
+	RoomID       string `json:"room_id"`
+	TargetUserID string `json:"target_user_id"`
+}
+
+// ModeratorPayload names the member grant_mod or revoke_mod applies to
+type ModeratorPayload struct {
+	RoomID       string `json:"room_id"`
+	TargetUserID string `json:"target_user_id"`
+}
+
+// MutePayload names the member mute or unmute applies to
+type MutePayload struct {
+	RoomID       string `json:"room_id"`
+	TargetUserID string `json:"target_user_id"`
+}
+
+// RoomMetaPayload replaces a room's topic and description; an empty field
+// clears it
+type RoomMetaPayload struct {
+	RoomID      string `json:"room_id"`
+	Topic       string `json:"topic"`
+	Description string `json:"description"`
+}
+
+type RenamePayload struct {
+	NewName string `json:"new_name"`
+}
+
+type TypingPayload struct {
+	RoomID   string `json:"room_id"`
+	IsTyping bool   `json:"is_typing"`
+}
+
+type ResumePayload struct {
+	ResumeToken string `json:"resume_token"`
+}
+
+type DeletePayload struct {
+	RoomID    string `json:"room_id"`
+	MessageID string `json:"message_id"`
+}
+
+type ReactPayload struct {
+	RoomID    string `json:"room_id"`
+	MessageID string `json:"message_id"`
+	Emoji     string `json:"emoji"`
+}
+
+type DirectMessagePayload struct {
+	ToUserID string `json:"to_user_id"`
+	Message  string `json:"message"`
+}
+
+// HistoryPayload asks for up to Limit messages older than BeforeSeq;
+// a BeforeSeq of 0 starts from the newest message
+type HistoryPayload struct {
+	RoomID    string `json:"room_id"`
+	BeforeSeq uint64 `json:"before_seq,omitempty"`
+	Limit     int    `json:"limit,omitempty"`
+}
+
+// MarkReadPayload records that the sender has read the room up to Seq
+type MarkReadPayload struct {
+	RoomID string `json:"room_id"`
+	Seq    uint64 `json:"seq"`
 }
 
 type CreateRoomPayload struct {
@@ -40,41 +142,156 @@ type CreateRoomPayload struct {
 	RoomName string `json:"room_name"`
 	UserID   string `json:"user_id"`
 	UserName string `json:"user_name"`
+	Password string `json:"password,omitempty"` // optional; makes the room private
 }
 
 type EventType string
 
 const (
-	EventUserJoinedRoom EventType = "user_joined"
-	EventUserLeftRoom   EventType = "user_left"
-	EventNewMessage     EventType = "new_message"
-	EventNewRoom        EventType = "new_room"
+	EventUserJoinedRoom   EventType = "user_joined"
+	EventUserLeftRoom     EventType = "user_left"
+	EventNewMessage       EventType = "new_message"
+	EventNewRoom          EventType = "new_room"
+	EventMembersList      EventType = "members_list"
+	EventUserKicked       EventType = "user_kicked"
+	EventUserRenamed      EventType = "user_renamed"
+	EventTyping           EventType = "typing"
+	EventMessageDeleted   EventType = "message_deleted"
+	EventMessageExpired   EventType = "message_expired"
+	EventReaction         EventType = "reaction"
+	EventDirectMessage    EventType = "direct_message"
+	EventPresence         EventType = "presence"
+	EventRoomStats        EventType = "room_stats"
+	EventAuthorChanged    EventType = "author_changed"
+	EventHistoryBatch     EventType = "history_batch"
+	EventAttachment       EventType = "attachment"
+	EventMessagesDropped  EventType = "messages_dropped"
+	EventRoomClosed       EventType = "room_closed"
+	EventAnnouncement     EventType = "system_announcement"
+	EventReadReceipt      EventType = "read_receipt"
+	EventRoomError        EventType = "room_error"
+	EventModeratorChanged EventType = "moderator_changed"
+	EventUserMuted        EventType = "user_muted"
+	EventUserUnmuted      EventType = "user_unmuted"
+	EventRoomMetaUpdated  EventType = "room_meta_updated"
 )
 
 // WsMessage is the envelope for all WS messages
 
 type ErrorPayload struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Fields  []string `json:"fields,omitempty"` // offending fields for schema_validation_failed
+	// Details carries values a client can act on, such as the size limit a
+	// rejected message broke
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
 }
 
 type JoinSuccess struct {
-	Type   string `json:"type"` // "join_success"
-	RoomID string `json:"room_id"`
-	UserID string `json:"user_id"`
+	Type      string `json:"type"` // "join_success"
+	RoomID    string `json:"room_id"`
+	UserID    string `json:"user_id"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// CreateRoomSuccess acknowledges create_room to its sender, who is then a
+// member; RoomID is the generated id when the request had none
+type CreateRoomSuccess struct {
+	Type      string `json:"type"` // "create_room_success"
+	RoomID    string `json:"room_id"`
+	UserID    string `json:"user_id"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// LeaveSuccess acknowledges leave to its sender
+type LeaveSuccess struct {
+	Type      string `json:"type"` // "leave_success"
+	RoomID    string `json:"room_id"`
+	UserID    string `json:"user_id"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// LeaveAllSuccess acknowledges leave_all with every room the sender left
+type LeaveAllSuccess struct {
+	Type      string   `json:"type"` // "leave_all_success"
+	UserID    string   `json:"user_id"`
+	RoomIDs   []string `json:"room_ids"`
+	RequestID string   `json:"request_id,omitempty"`
+}
+
+// DuplicateMessageAck tells a sender its message with ClientMsgID was already
+// broadcast, so the resend was dropped
+type DuplicateMessageAck struct {
+	Type        string `json:"type"` // "message_duplicate"
+	RoomID      string `json:"room_id"`
+	ClientMsgID string `json:"client_msg_id"`
+	RequestID   string `json:"request_id,omitempty"`
+}
+
+// MessageAck tells a sender the room accepted its message, with the sequence
+// number and id the broadcast carries, so an optimistically shown message can
+// be matched to it. It arrives ahead of the sender's own copy of the broadcast.
+type MessageAck struct {
+	Type      string `json:"type"` // "message_ack"
+	RequestID string `json:"request_id,omitempty"`
+	RoomID    string `json:"room_id"`
+	Seq       uint64 `json:"seq"`
+	MessageID string `json:"message_id"`
+}
+
+// SessionEvent hands the client a token it can use to resume its rooms after a disconnect
+type SessionEvent struct {
+	Type        string `json:"type"` // "session"
+	UserID      string `json:"user_id"`
+	ResumeToken string `json:"resume_token"`
+}
+
+type ResumeSuccess struct {
+	Type      string   `json:"type"` // "resume_success"
+	UserID    string   `json:"user_id"`
+	Rooms     []string `json:"rooms"`
+	RequestID string   `json:"request_id,omitempty"`
+}
+
+// SessionInfoEvent answers whoami with the connection's bound identity and
+// the rooms it is in. Before an identity is bound every field is empty.
+type SessionInfoEvent struct {
+	Type      string   `json:"type"` // "session_info"
+	UserID    string   `json:"user_id"`
+	UserName  string   `json:"user_name"`
+	Rooms     []string `json:"rooms"`
+	RequestID string   `json:"request_id,omitempty"`
+}
+
+// BackpressureEvent warns a client it is reading more slowly than events
+// arrive. Active is true once its send buffer is nearly full, when the server
+// may soon drop room events for it, and false once it has drained again.
+type BackpressureEvent struct {
+	Type     string `json:"type"` // "backpressure"
+	Active   bool   `json:"active"`
+	Buffered int    `json:"buffered"` // events waiting to be written
+	Capacity int    `json:"capacity"` // events the buffer holds
 }
 
 type Pong struct {
-	Type string `json:"type"` // "pong"
+	Type      string `json:"type"` // "pong"
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type RoomMessageEvent struct {
-	Type        EventType      `json:"type"`
-	RoomID      string         `json:"room_id"`
-	UserID      string         `json:"user_id"`
-	UserName    string         `json:"user_name"`
-	Message     MessagePayload `json:"message"`
-	MessageTime string         `json:"message_time"` // ISO8601 string
+	Type             EventType      `json:"type"`
+	RoomID           string         `json:"room_id"`
+	UserID           string         `json:"user_id"`
+	UserName         string         `json:"user_name"`
+	Message          MessagePayload `json:"message"`
+	MessageTime      string         `json:"message_time"`         // ISO8601 string
+	ServerReceivedAt string         `json:"server_received_at"`   // RFC3339 to the nanosecond; when the server accepted the message
+	Historical       bool           `json:"historical,omitempty"` // replayed from room history on join
+	Seq              uint64         `json:"seq"`                  // per-room broadcast sequence, assigned by the room loop
+	MessageID        string         `json:"message_id"`
+	Deleted          bool           `json:"deleted,omitempty"` // tombstone; Message.Message is cleared
+	System           bool           `json:"system,omitempty"`  // posted by an integration, not a member; UserID is empty
 }
 
 type RoomCreateEvent struct {
@@ -90,6 +307,7 @@ type UserJoinedEvent struct {
 	UserID      string    `json:"user_id"`
 	UserName    string    `json:"user_name"`
 	MessageTime string    `json:"message_time"`
+	Seq         uint64    `json:"seq"`
 }
 
 type UserLeftEvent struct {
@@ -98,9 +316,229 @@ type UserLeftEvent struct {
 	UserID      string    `json:"user_id"`
 	UserName    string    `json:"user_name"`
 	MessageTime string    `json:"message_time"`
+	Seq         uint64    `json:"seq"`
+}
+
+type UserKickedEvent struct {
+	Type        EventType `json:"type"`
+	RoomID      string    `json:"room_id"`
+	UserID      string    `json:"user_id"`
+	UserName    string    `json:"user_name"`
+	KickedBy    string    `json:"kicked_by"`
+	MessageTime string    `json:"message_time"`
+}
+
+// MessageExpiredEvent tells members a disappearing message's time is up and
+// it should be removed from view
+type MessageExpiredEvent struct {
+	Type      EventType `json:"type"`
+	RoomID    string    `json:"room_id"`
+	MessageID string    `json:"message_id"`
+}
+
+type MessageDeletedEvent struct {
+	Type        EventType `json:"type"`
+	RoomID      string    `json:"room_id"`
+	MessageID   string    `json:"message_id"`
+	DeletedBy   string    `json:"deleted_by"`
+	MessageTime string    `json:"message_time"`
+}
+
+// ReactionEvent reports a reaction being toggled; Added is false when it was removed
+type ReactionEvent struct {
+	Type      EventType `json:"type"`
+	RoomID    string    `json:"room_id"`
+	MessageID string    `json:"message_id"`
+	UserID    string    `json:"user_id"`
+	Emoji     string    `json:"emoji"`
+	Added     bool      `json:"added"`
+}
+
+// DirectMessageEvent is a 1:1 message; it goes to every connection of the
+// recipient and, as an echo, of the sender
+type DirectMessageEvent struct {
+	Type         EventType `json:"type"`
+	FromUserID   string    `json:"from_user_id"`
+	FromUserName string    `json:"from_user_name"`
+	ToUserID     string    `json:"to_user_id"`
+	Message      string    `json:"message"`
+	MessageTime  string    `json:"message_time"`
+}
+
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceOffline PresenceStatus = "offline"
+)
+
+// PresenceEvent tells presence subscribers a user came online or went offline
+type PresenceEvent struct {
+	Type   EventType      `json:"type"`
+	UserID string         `json:"user_id"`
+	Status PresenceStatus `json:"status"`
+}
+
+// RoomStatsEvent reports a room's occupancy after membership changes settle
+type RoomStatsEvent struct {
+	Type      EventType `json:"type"`
+	RoomID    string    `json:"room_id"`
+	UserCount int       `json:"user_count"`
+}
+
+// HistoryBatchEvent answers a history request, newest message first. A batch
+// shorter than the requested limit means there is nothing older to fetch.
+type HistoryBatchEvent struct {
+	Type      EventType          `json:"type"`
+	RoomID    string             `json:"room_id"`
+	Messages  []RoomMessageEvent `json:"messages"`
+	RequestID string             `json:"request_id,omitempty"`
+}
+
+// AttachmentHeader is the JSON line, ended by '\n', that starts a binary
+// attachment frame; the attachment bytes follow it
+type AttachmentHeader struct {
+	RoomID string `json:"room_id"`
+}
+
+// AttachmentEvent carries an image sent as a binary frame. Data is base64 in
+// JSON; SHA256 is the hex digest of the raw bytes, so clients can cache by it.
+type AttachmentEvent struct {
+	Type        EventType `json:"type"`
+	RoomID      string    `json:"room_id"`
+	UserID      string    `json:"user_id"`
+	UserName    string    `json:"user_name"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Data        []byte    `json:"data"`
+	MessageTime string    `json:"message_time"`
+}
+
+// MessagesDroppedEvent tells a slow client how many room events it missed
+// while its buffer was full, so it can resync from history
+type MessagesDroppedEvent struct {
+	Type   EventType `json:"type"`
+	RoomID string    `json:"room_id"`
+	Count  int       `json:"count"`
+}
+
+// RoomErrorEvent tells members the room hit an internal error; it is
+// followed by a RoomClosedEvent
+type RoomErrorEvent struct {
+	Type    EventType `json:"type"`
+	RoomID  string    `json:"room_id"`
+	Message string    `json:"message"`
+}
+
+// RoomClosedEvent is the last event a room sends its members before it stops
+type RoomClosedEvent struct {
+	Type   EventType `json:"type"`
+	RoomID string    `json:"room_id"`
+}
+
+// SystemAnnouncementEvent is an operator notice sent to every room. A
+// connection in several rooms gets one per room.
+type SystemAnnouncementEvent struct {
+	Type        EventType `json:"type"`
+	RoomID      string    `json:"room_id"`
+	Content     string    `json:"content"`
+	MessageTime string    `json:"message_time"` // ISO8601 string
+}
+
+// ReadReceiptEvent tells a room how far one member has read
+type ReadReceiptEvent struct {
+	Type   EventType `json:"type"`
+	RoomID string    `json:"room_id"`
+	UserID string    `json:"user_id"`
+	Seq    uint64    `json:"seq"`
+}
+
+// ModeratorChangedEvent announces a member being made a moderator, or no
+// longer being one when IsModerator is false
+type ModeratorChangedEvent struct {
+	Type        EventType `json:"type"`
+	RoomID      string    `json:"room_id"`
+	UserID      string    `json:"user_id"`
+	IsModerator bool      `json:"is_moderator"`
+	ChangedBy   string    `json:"changed_by"`
+	MessageTime string    `json:"message_time"`
+}
+
+// UserMutedEvent announces a member who may no longer post to the room
+type UserMutedEvent struct {
+	Type        EventType `json:"type"`
+	RoomID      string    `json:"room_id"`
+	UserID      string    `json:"user_id"`
+	MutedBy     string    `json:"muted_by"`
+	MessageTime string    `json:"message_time"`
+}
+
+// UserUnmutedEvent announces a muted member may post again
+type UserUnmutedEvent struct {
+	Type        EventType `json:"type"`
+	RoomID      string    `json:"room_id"`
+	UserID      string    `json:"user_id"`
+	UnmutedBy   string    `json:"unmuted_by"`
+	MessageTime string    `json:"message_time"`
+}
+
+// RoomMetaUpdatedEvent carries a room's topic and description. Members get
+// it when they change, and joiners get the current ones if any are set.
+type RoomMetaUpdatedEvent struct {
+	Type        EventType `json:"type"`
+	RoomID      string    `json:"room_id"`
+	Topic       string    `json:"topic"`
+	Description string    `json:"description"`
+	UpdatedBy   string    `json:"updated_by"`
+	MessageTime string    `json:"message_time"`
+}
+
+// AuthorChangedEvent announces the member who took over a room after its author left
+type AuthorChangedEvent struct {
+	Type        EventType `json:"type"`
+	RoomID      string    `json:"room_id"`
+	NewAuthorID string    `json:"new_author_id"`
+}
+
+type UserRenamedEvent struct {
+	Type        EventType `json:"type"`
+	RoomID      string    `json:"room_id"`
+	UserID      string    `json:"user_id"`
+	OldName     string    `json:"old_name"`
+	NewName     string    `json:"new_name"`
+	MessageTime string    `json:"message_time"`
+}
+
+type TypingEvent struct {
+	Type     EventType `json:"type"`
+	RoomID   string    `json:"room_id"`
+	UserID   string    `json:"user_id"`
+	UserName string    `json:"user_name"`
+	IsTyping bool      `json:"is_typing"`
+}
+
+type Member struct {
+	UserID   string `json:"user_id"`
+	UserName string `json:"user_name"`
+}
+
+type MembersListEvent struct {
+	Type      EventType `json:"type"`
+	RoomID    string    `json:"room_id"`
+	Members   []Member  `json:"members"`
+	RequestID string    `json:"request_id,omitempty"`
 }
 
+// formatTime renders t the way every event's message_time is sent: RFC3339 in UTC
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// NewRoomMessageEvent stamps the message once, as it is ingested. Replays and
+// history pages carry the original times; nothing rewrites them.
 func NewRoomMessageEvent(roomID string, userID string, userName string, message string) RoomMessageEvent {
+	now := time.Now().UTC()
 	return RoomMessageEvent{
 		Type:     EventNewMessage,
 		RoomID:   roomID,
@@ -109,7 +547,16 @@ func NewRoomMessageEvent(roomID string, userID string, userName string, message
 		Message: MessagePayload{
 			RoomID:  roomID,
 			Message: message},
-		MessageTime: time.Now().UTC().Format(time.RFC3339),
+		MessageTime:      formatTime(now),
+		ServerReceivedAt: now.Format(time.RFC3339Nano),
+	}
+}
+
+func NewMessageExpiredEvent(roomID, messageID string) MessageExpiredEvent {
+	return MessageExpiredEvent{
+		Type:      EventMessageExpired,
+		RoomID:    roomID,
+		MessageID: messageID,
 	}
 }
 
@@ -119,7 +566,7 @@ func NewUserJoinedEvent(roomID string, userID string, userName string) UserJoine
 		RoomID:      roomID,
 		UserID:      userID,
 		UserName:    userName,
-		MessageTime: time.Now().UTC().Format(time.RFC3339),
+		MessageTime: formatTime(time.Now()),
 	}
 }
 
@@ -129,7 +576,201 @@ func NewUserLeftEvent(roomID string, userID string, userName string) UserLeftEve
 		RoomID:      roomID,
 		UserID:      userID,
 		UserName:    userName,
-		MessageTime: time.Now().UTC().Format(time.RFC3339),
+		MessageTime: formatTime(time.Now()),
+	}
+}
+
+func NewUserKickedEvent(roomID string, userID string, userName string, kickedBy string) UserKickedEvent {
+	return UserKickedEvent{
+		Type:        EventUserKicked,
+		RoomID:      roomID,
+		UserID:      userID,
+		UserName:    userName,
+		KickedBy:    kickedBy,
+		MessageTime: formatTime(time.Now()),
+	}
+}
+
+func NewMessageDeletedEvent(roomID string, messageID string, deletedBy string) MessageDeletedEvent {
+	return MessageDeletedEvent{
+		Type:        EventMessageDeleted,
+		RoomID:      roomID,
+		MessageID:   messageID,
+		DeletedBy:   deletedBy,
+		MessageTime: formatTime(time.Now()),
+	}
+}
+
+func NewReactionEvent(roomID string, messageID string, userID string, emoji string, added bool) ReactionEvent {
+	return ReactionEvent{
+		Type:      EventReaction,
+		RoomID:    roomID,
+		MessageID: messageID,
+		UserID:    userID,
+		Emoji:     emoji,
+		Added:     added,
+	}
+}
+
+func NewDirectMessageEvent(fromID string, fromName string, toID string, message string) DirectMessageEvent {
+	return DirectMessageEvent{
+		Type:         EventDirectMessage,
+		FromUserID:   fromID,
+		FromUserName: fromName,
+		ToUserID:     toID,
+		Message:      message,
+		MessageTime:  formatTime(time.Now()),
+	}
+}
+
+func NewPresenceEvent(userID string, status PresenceStatus) PresenceEvent {
+	return PresenceEvent{
+		Type:   EventPresence,
+		UserID: userID,
+		Status: status,
+	}
+}
+
+func NewRoomStatsEvent(roomID string, userCount int) RoomStatsEvent {
+	return RoomStatsEvent{
+		Type:      EventRoomStats,
+		RoomID:    roomID,
+		UserCount: userCount,
+	}
+}
+
+func NewHistoryBatchEvent(roomID string, msgs []RoomMessageEvent) HistoryBatchEvent {
+	return HistoryBatchEvent{
+		Type:     EventHistoryBatch,
+		RoomID:   roomID,
+		Messages: msgs,
+	}
+}
+
+func NewAttachmentEvent(roomID string, userID string, userName string, contentType string, data []byte) AttachmentEvent {
+	sum := sha256.Sum256(data)
+	return AttachmentEvent{
+		Type:        EventAttachment,
+		RoomID:      roomID,
+		UserID:      userID,
+		UserName:    userName,
+		ContentType: contentType,
+		Size:        len(data),
+		SHA256:      hex.EncodeToString(sum[:]),
+		Data:        data,
+		MessageTime: formatTime(time.Now()),
+	}
+}
+
+func NewMessagesDroppedEvent(roomID string, count int) MessagesDroppedEvent {
+	return MessagesDroppedEvent{
+		Type:   EventMessagesDropped,
+		RoomID: roomID,
+		Count:  count,
+	}
+}
+
+func NewRoomErrorEvent(roomID, message string) RoomErrorEvent {
+	return RoomErrorEvent{
+		Type:    EventRoomError,
+		RoomID:  roomID,
+		Message: message,
+	}
+}
+
+func NewRoomClosedEvent(roomID string) RoomClosedEvent {
+	return RoomClosedEvent{
+		Type:   EventRoomClosed,
+		RoomID: roomID,
+	}
+}
+
+func NewSystemAnnouncementEvent(roomID, content string) SystemAnnouncementEvent {
+	return SystemAnnouncementEvent{
+		Type:        EventAnnouncement,
+		RoomID:      roomID,
+		Content:     content,
+		MessageTime: formatTime(time.Now()),
+	}
+}
+
+func NewReadReceiptEvent(roomID, userID string, seq uint64) ReadReceiptEvent {
+	return ReadReceiptEvent{
+		Type:   EventReadReceipt,
+		RoomID: roomID,
+		UserID: userID,
+		Seq:    seq,
+	}
+}
+
+func NewAuthorChangedEvent(roomID string, newAuthorID string) AuthorChangedEvent {
+	return AuthorChangedEvent{
+		Type:        EventAuthorChanged,
+		RoomID:      roomID,
+		NewAuthorID: newAuthorID,
+	}
+}
+
+func NewModeratorChangedEvent(roomID string, userID string, isModerator bool, changedBy string) ModeratorChangedEvent {
+	return ModeratorChangedEvent{
+		Type:        EventModeratorChanged,
+		RoomID:      roomID,
+		UserID:      userID,
+		IsModerator: isModerator,
+		ChangedBy:   changedBy,
+		MessageTime: formatTime(time.Now()),
+	}
+}
+
+func NewUserMutedEvent(roomID string, userID string, mutedBy string) UserMutedEvent {
+	return UserMutedEvent{
+		Type:        EventUserMuted,
+		RoomID:      roomID,
+		UserID:      userID,
+		MutedBy:     mutedBy,
+		MessageTime: formatTime(time.Now()),
+	}
+}
+
+func NewUserUnmutedEvent(roomID string, userID string, unmutedBy string) UserUnmutedEvent {
+	return UserUnmutedEvent{
+		Type:        EventUserUnmuted,
+		RoomID:      roomID,
+		UserID:      userID,
+		UnmutedBy:   unmutedBy,
+		MessageTime: formatTime(time.Now()),
+	}
+}
+
+func NewRoomMetaUpdatedEvent(roomID string, topic string, description string, updatedBy string) RoomMetaUpdatedEvent {
+	return RoomMetaUpdatedEvent{
+		Type:        EventRoomMetaUpdated,
+		RoomID:      roomID,
+		Topic:       topic,
+		Description: description,
+		UpdatedBy:   updatedBy,
+		MessageTime: formatTime(time.Now()),
+	}
+}
+
+func NewUserRenamedEvent(roomID string, userID string, oldName string, newName string) UserRenamedEvent {
+	return UserRenamedEvent{
+		Type:        EventUserRenamed,
+		RoomID:      roomID,
+		UserID:      userID,
+		OldName:     oldName,
+		NewName:     newName,
+		MessageTime: formatTime(time.Now()),
+	}
+}
+
+func NewTypingEvent(roomID string, userID string, userName string, isTyping bool) TypingEvent {
+	return TypingEvent{
+		Type:     EventTyping,
+		RoomID:   roomID,
+		UserID:   userID,
+		UserName: userName,
+		IsTyping: isTyping,
 	}
 }
 
@@ -149,3 +790,86 @@ func NewJoinSuccess(roomID string, userID string) JoinSuccess {
 		UserID: userID,
 	}
 }
+
+func NewCreateRoomSuccess(roomID string, userID string) CreateRoomSuccess {
+	return CreateRoomSuccess{
+		Type:   "create_room_success",
+		RoomID: roomID,
+		UserID: userID,
+	}
+}
+
+func NewLeaveSuccess(roomID string, userID string) LeaveSuccess {
+	return LeaveSuccess{
+		Type:   "leave_success",
+		RoomID: roomID,
+		UserID: userID,
+	}
+}
+
+func NewLeaveAllSuccess(userID string, roomIDs []string) LeaveAllSuccess {
+	return LeaveAllSuccess{
+		Type:    "leave_all_success",
+		UserID:  userID,
+		RoomIDs: roomIDs,
+	}
+}
+
+func NewDuplicateMessageAck(roomID string, clientMsgID string) DuplicateMessageAck {
+	return DuplicateMessageAck{
+		Type:        "message_duplicate",
+		RoomID:      roomID,
+		ClientMsgID: clientMsgID,
+	}
+}
+
+func NewMessageAck(roomID, messageID string, seq uint64) MessageAck {
+	return MessageAck{
+		Type:      "message_ack",
+		RoomID:    roomID,
+		Seq:       seq,
+		MessageID: messageID,
+	}
+}
+
+func NewMembersListEvent(roomID string, members []Member) MembersListEvent {
+	return MembersListEvent{
+		Type:    EventMembersList,
+		RoomID:  roomID,
+		Members: members,
+	}
+}
+
+func NewBackpressureEvent(active bool, buffered int, capacity int) BackpressureEvent {
+	return BackpressureEvent{
+		Type:     "backpressure",
+		Active:   active,
+		Buffered: buffered,
+		Capacity: capacity,
+	}
+}
+
+func NewSessionEvent(userID string, resumeToken string) SessionEvent {
+	return SessionEvent{
+		Type:        "session",
+		UserID:      userID,
+		ResumeToken: resumeToken,
+	}
+}
+
+func NewSessionInfoEvent(userID string, userName string, rooms []string) SessionInfoEvent {
+	return SessionInfoEvent{
+		Type:     "session_info",
+		UserID:   userID,
+		UserName: userName,
+		Rooms:    rooms,
+	}
+}
+
+func NewResumeSuccess(userID string, rooms []string) ResumeSuccess {
+	return ResumeSuccess{
+		Type:   "resume_success",
+		UserID: userID,
+		Rooms:  rooms,
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "chatroom"
+
+var (
+	MessagesBroadcast = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_broadcast_total",
+		Help:      "Events broadcast to room members.",
+	})
+
+	Joins = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "joins_total",
+		Help:      "Users added to rooms.",
+	})
+
+	Leaves = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leaves_total",
+		Help:      "Users removed from rooms.",
+	})
+
+	MessagesDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_dropped_total",
+		Help:      "Messages not delivered because a client's send buffer stayed full.",
+	})
+
+	RoomEventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "room_events_dropped_total",
+		Help:      "Events refused or dropped because a room's event or publish queue was full, by kind.",
+	}, []string{"kind"})
+
+	RoomPanics = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "room_panics_total",
+		Help:      "Rooms closed because their event loop panicked.",
+	})
+)
+
+// RegisterGauges exposes the current room and client counts, read at scrape time.
+// Call it once per process.
+func RegisterGauges(activeRooms func() int, connectedClients func() int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rooms_active",
+		Help:      "Rooms currently open.",
+	}, func() float64 { return float64(activeRooms()) })
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "clients_connected",
+		Help:      "WebSocket clients currently connected.",
+	}, func() float64 { return float64(connectedClients()) })
+}
+
+// Handler serves the default registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
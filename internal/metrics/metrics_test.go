@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerExposesMetrics(t *testing.T) {
+	RegisterGauges(func() int { return 2 }, func() int { return 3 })
+	MessagesDropped.Inc()
+
+	ts := httptest.NewServer(Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	out := string(body)
+
+	for _, name := range []string{
+		"chatroom_messages_broadcast_total",
+		"chatroom_joins_total",
+		"chatroom_leaves_total",
+		"chatroom_messages_dropped_total",
+	} {
+		assert.Contains(t, out, name)
+	}
+	assert.Contains(t, out, "chatroom_rooms_active 2")
+	assert.Contains(t, out, "chatroom_clients_connected 3")
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/arturskrzydlo/chat-room/internal/app"
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+)
+
+// maxAttachmentHeaderSize bounds the JSON header line of an attachment frame
+const maxAttachmentHeaderSize = 512
+
+// handleBinaryAttachment takes a binary frame made of a JSON
+// messages.AttachmentHeader line and the image bytes, and broadcasts the image
+// to the header's room. Only sniffed image types are accepted, whatever the
+// client claims. Attachments share the chat message rate limit.
+func (c *Client) handleBinaryAttachment(frame []byte) {
+	if c.cfg.maxAttachmentSize <= 0 {
+		c.sendError("attachment_error", "attachments are disabled")
+		return
+	}
+
+	if c.limiter != nil && !c.limiter.Allow() {
+		c.sendError("rate_limited", "too many messages, slow down")
+		return
+	}
+
+	end := bytes.IndexByte(frame, '\n')
+	if end < 0 || end > maxAttachmentHeaderSize {
+		c.sendError("attachment_error", "attachment frame must start with a JSON header line")
+		return
+	}
+	var h messages.AttachmentHeader
+	if err := json.Unmarshal(frame[:end], &h); err != nil || h.RoomID == "" {
+		c.sendError("attachment_error", "attachment header needs a room_id")
+		return
+	}
+
+	data := frame[end+1:]
+	if len(data) == 0 {
+		c.sendError("attachment_error", "attachment is empty")
+		return
+	}
+	if int64(len(data)) > c.cfg.maxAttachmentSize {
+		c.sendError("attachment_too_large", fmt.Sprintf("attachment exceeds %d byte limit", c.cfg.maxAttachmentSize))
+		return
+	}
+
+	if !c.inRoom(h.RoomID) {
+		c.sendError("attachment_error", "not in this room")
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		c.sendError("attachment_error", "only images can be attached")
+		return
+	}
+
+	if err := c.coordinator.SendAttachment(h.RoomID, c.userID, c.userName, contentType, data); err != nil {
+		if errors.Is(err, app.ErrRoomBusy) {
+			c.sendError("room_busy", err.Error())
+			return
+		}
+		if errors.Is(err, app.ErrMuted) {
+			c.sendError("muted", err.Error())
+			return
+		}
+		c.sendError("attachment_error", err.Error())
+		return
+	}
+}
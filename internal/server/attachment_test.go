@@ -0,0 +1,76 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngBytes = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+
+func attachmentFrame(header string, data []byte) []byte {
+	return append([]byte(header+"\n"), data...)
+}
+
+func TestClientHandleBinaryAttachment(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	c.cfg.maxAttachmentSize = 1024
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.handleBinaryAttachment(attachmentFrame(`{"room_id":"room_1"}`, pngBytes))
+
+	require.Len(t, mc.attachmentCalls, 1)
+	call := mc.attachmentCalls[0]
+	assert.Equal(t, "room_1", call.roomID)
+	assert.Equal(t, "user1", call.userID)
+	assert.Equal(t, "image/png", call.contentType)
+	assert.Equal(t, pngBytes, call.data)
+	assert.Empty(t, c.send)
+}
+
+func TestClientHandleBinaryAttachmentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxSize  int64
+		frame    []byte
+		wantCode string
+	}{
+		{name: "disabled", frame: attachmentFrame(`{"room_id":"room_1"}`, pngBytes), wantCode: "attachment_error"},
+		{name: "no header", maxSize: 1024, frame: pngBytes[8:], wantCode: "attachment_error"},
+		{name: "no room", maxSize: 1024, frame: attachmentFrame(`{}`, pngBytes), wantCode: "attachment_error"},
+		{name: "empty", maxSize: 1024, frame: attachmentFrame(`{"room_id":"room_1"}`, nil), wantCode: "attachment_error"},
+		{name: "too large", maxSize: 16, frame: attachmentFrame(`{"room_id":"room_1"}`, pngBytes), wantCode: "attachment_too_large"},
+		{name: "not in room", maxSize: 1024, frame: attachmentFrame(`{"room_id":"room_2"}`, pngBytes), wantCode: "attachment_error"},
+		{name: "not an image", maxSize: 1024, frame: attachmentFrame(`{"room_id":"room_1"}`, []byte("just some text")), wantCode: "attachment_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := &mockCoordinator{}
+			c := newTestClientWithMock(t, mc)
+			c.cfg.maxAttachmentSize = tt.maxSize
+			require.NoError(t, c.ensureIdentity("user1", "User One"))
+			c.rooms["room_1"] = struct{}{}
+
+			c.handleBinaryAttachment(tt.frame)
+
+			assert.Empty(t, mc.attachmentCalls)
+			ev := <-c.send
+			errPayload, ok := ev.(messages.ErrorPayload)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantCode, errPayload.Code)
+		})
+	}
+}
+
+func TestReadLimitCoversAttachments(t *testing.T) {
+	cfg := defaultClientConfig()
+	assert.Equal(t, cfg.maxMessageSize, cfg.readLimit())
+
+	cfg.maxAttachmentSize = 64 * 1024
+	assert.Equal(t, int64(64*1024+maxAttachmentHeaderSize), cfg.readLimit())
+}
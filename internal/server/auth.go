@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries no
+// credentials at all, as opposed to credentials that fail verification.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Identity is a verified user; once set on a connection, payload user ids
+// can no longer change it.
+type Identity struct {
+	UserID   string
+	UserName string
+}
+
+// Authenticator verifies a websocket upgrade request before the upgrade happens.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+// TokenVerifier checks a raw token and returns the identity it belongs to
+type TokenVerifier func(token string) (Identity, error)
+
+// TokenAuthenticator reads a token from "Authorization: Bearer <token>" or,
+// for browsers that can't set headers on websocket requests, the "token"
+// query parameter, and hands it to Verify.
+type TokenAuthenticator struct {
+	Verify TokenVerifier
+}
+
+func (a TokenAuthenticator) Authenticate(r *http.Request) (Identity, error) {
+	token := bearerToken(r)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		return Identity{}, ErrNoCredentials
+	}
+
+	id, err := a.Verify(token)
+	if err != nil {
+		return Identity{}, err
+	}
+	if id.UserID == "" {
+		return Identity{}, errors.New("token has no user id")
+	}
+	return id, nil
+}
+
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(h) < len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(h[len(prefix):])
+}
+
+// authenticate resolves the identity for an upgrade request. ok is false when
+// the request must be rejected; a zero Identity with ok means anonymous.
+func (s *WsServer) authenticate(r *http.Request) (id Identity, ok bool) {
+	if s.authenticator == nil {
+		return Identity{}, true
+	}
+
+	id, err := s.authenticator.Authenticate(r)
+	if err == nil {
+		return id, true
+	}
+	if s.allowAnonymous && errors.Is(err, ErrNoCredentials) {
+		return Identity{}, true
+	}
+	return Identity{}, false
+}
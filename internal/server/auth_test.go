@@ -0,0 +1,166 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTokens = TokenAuthenticator{
+	Verify: func(token string) (Identity, error) {
+		if token == "alice-token" {
+			return Identity{UserID: "alice", UserName: "Alice"}, nil
+		}
+		return Identity{}, errors.New("unknown token")
+	},
+}
+
+func dialWithHeader(t *testing.T, ts *httptest.Server, path string, header http.Header) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + path
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if conn != nil {
+		t.Cleanup(func() { _ = conn.Close() })
+	}
+	if resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+	return conn, resp, err
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": []string{"Bearer " + token}}
+}
+
+func readJSON(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var out map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&out))
+	return out
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		url     string
+		wantID  string
+		wantErr error
+	}{
+		{name: "bearer header", header: "Bearer alice-token", url: "/ws", wantID: "alice"},
+		{name: "lowercase scheme", header: "bearer alice-token", url: "/ws", wantID: "alice"},
+		{name: "query token", url: "/ws?token=alice-token", wantID: "alice"},
+		{name: "no credentials", url: "/ws", wantErr: ErrNoCredentials},
+		{name: "non-bearer scheme", header: "Basic abc", url: "/ws", wantErr: ErrNoCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			id, err := testTokens.Authenticate(req)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantID, id.UserID)
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set("Authorization", "Bearer forged")
+	_, err := testTokens.Authenticate(req)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNoCredentials)
+}
+
+func TestAuthValidToken(t *testing.T) {
+	s := newTestServer(t, WithAuthenticator(testTokens, false))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	conn, resp, err := dialWithHeader(t, ts, "", bearer("alice-token"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	// join without an id uses the verified identity
+	require.NoError(t, conn.WriteJSON(messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room1"}),
+	}))
+	got := readJSON(t, conn)
+	assert.Equal(t, "join_success", got["type"])
+	assert.Equal(t, "alice", got["user_id"])
+
+	// a payload id can't replace the verified one
+	require.NoError(t, conn.WriteJSON(messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room2", UserID: "mallory"}),
+	}))
+	got = readJSON(t, conn)
+	assert.Equal(t, "identity_error", got["code"])
+}
+
+func TestAuthInvalidToken(t *testing.T) {
+	s := newTestServer(t, WithAuthenticator(testTokens, true))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	// allowAnonymous doesn't cover credentials that fail verification
+	_, resp, err := dialWithHeader(t, ts, "", bearer("forged"))
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+}
+
+func TestAuthMissingTokenRejected(t *testing.T) {
+	s := newTestServer(t, WithAuthenticator(testTokens, false))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	_, resp, err := dialWithHeader(t, ts, "", nil)
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestAuthAnonymousMode(t *testing.T) {
+	s := newTestServer(t, WithAuthenticator(testTokens, true))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	conn, _, err := dialWithHeader(t, ts, "", nil)
+	require.NoError(t, err)
+
+	// anonymous clients still identify themselves through payloads
+	require.NoError(t, conn.WriteJSON(messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room1", UserID: "bob", UserName: "Bob"}),
+	}))
+	got := readJSON(t, conn)
+	assert.Equal(t, "join_success", got["type"])
+	assert.Equal(t, "bob", got["user_id"])
+
+	// query tokens are honoured too
+	conn, _, err = dialWithHeader(t, ts, "?token=alice-token", nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteJSON(messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room1"}),
+	}))
+	got = readJSON(t, conn)
+	assert.Equal(t, "alice", got["user_id"])
+}
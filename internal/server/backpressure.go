@@ -0,0 +1,53 @@
+package server
+
+import (
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+)
+
+// how often a connection's send buffer is checked for backing up
+const backpressureCheckInterval = 50 * time.Millisecond
+
+// watchBackpressure tells the client when its send buffer backs up, well
+// before rooms start giving up on it, and again once it has caught up.
+func (c *Client) watchBackpressure() {
+	ticker := time.NewTicker(backpressureCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.checkBackpressure()
+		}
+	}
+}
+
+// checkBackpressure sends a BackpressureEvent when the send buffer passes
+// three quarters full, and another once it is under a quarter full again.
+// The gap between the marks keeps a buffer hovering near one of them from
+// flapping. The event is queued like any other, so it goes out only if it
+// fits; otherwise the next check tries again.
+func (c *Client) checkBackpressure() {
+	capacity := cap(c.send)
+	buffered := len(c.send)
+	highWater, lowWater := capacity*3/4, capacity/4
+
+	var active bool
+	switch {
+	case !c.backpressured && buffered >= highWater:
+		active = true
+	case c.backpressured && buffered < lowWater:
+		active = false
+	default:
+		return
+	}
+
+	select {
+	case c.send <- messages.NewBackpressureEvent(active, buffered, capacity):
+		c.backpressured = active
+	default:
+	}
+}
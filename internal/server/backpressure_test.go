@@ -0,0 +1,61 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackpressureSignal(t *testing.T) {
+	c := newTestClientWithMock(t, &mockCoordinator{})
+	c.send = make(chan interface{}, 8) // high-water mark 6, low-water mark 2
+
+	// below the high-water mark nothing is sent
+	for i := 0; i < 5; i++ {
+		c.send <- i
+	}
+	c.checkBackpressure()
+	assert.Len(t, c.send, 5)
+
+	c.send <- 5
+	c.checkBackpressure()
+	c.checkBackpressure()
+	require.Len(t, c.send, 7, "the warning is sent once")
+	for i := 0; i < 6; i++ {
+		<-c.send
+	}
+	warning, ok := (<-c.send).(messages.BackpressureEvent)
+	require.True(t, ok)
+	assert.Equal(t, messages.NewBackpressureEvent(true, 6, 8), warning)
+
+	// between the marks the client still counts as backed up
+	c.send <- "a"
+	c.send <- "b"
+	c.checkBackpressure()
+	require.Len(t, c.send, 2)
+	<-c.send
+	<-c.send
+
+	c.checkBackpressure()
+	cleared, ok := (<-c.send).(messages.BackpressureEvent)
+	require.True(t, ok)
+	assert.Equal(t, messages.NewBackpressureEvent(false, 0, 8), cleared)
+}
+
+func TestBackpressureWaitsForRoom(t *testing.T) {
+	c := newTestClientWithMock(t, &mockCoordinator{})
+	c.send = make(chan interface{}, 4)
+	for i := 0; i < 4; i++ {
+		c.send <- i
+	}
+
+	// a full buffer has no room for the warning; it goes out once there is
+	c.checkBackpressure()
+	assert.False(t, c.backpressured)
+	<-c.send
+	c.checkBackpressure()
+	assert.True(t, c.backpressured)
+	assert.Len(t, c.send, 4)
+}
@@ -0,0 +1,65 @@
+package server
+
+import "bytes"
+
+// collectPending returns first along with the events already queued behind
+// it, so a burst can be coalesced and, for batching clients, go out in one
+// write. It stops early at a closeRequest, which it returns, or when the queue
+// has been closed.
+func (c *Client) collectPending(first interface{}) (pending []interface{}, req *closeRequest, closed bool) {
+	pending = []interface{}{first}
+	for n := len(c.send); n > 0; n-- {
+		// never block here: writePump must keep pinging and watching ctx
+		select {
+		case msg, ok := <-c.send:
+			if !ok {
+				return pending, nil, true
+			}
+			if r, isClose := msg.(closeRequest); isClose {
+				return pending, &r, false
+			}
+			pending = append(pending, msg)
+		default:
+			return pending, nil, false
+		}
+	}
+	return pending, nil, false
+}
+
+// writePending writes pending as one batch for clients that negotiated
+// batching and one frame per event for everyone else
+func (c *Client) writePending(pending []interface{}) error {
+	if c.batchFrames {
+		return c.writeBatch(pending)
+	}
+	for _, msg := range pending {
+		if err := c.writeFrame(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBatch writes batch as one frame. A lone event is written as usual, so
+// a quiet connection sees the same frames as without batching; several are
+// written as a JSON array of events, in queue order.
+func (c *Client) writeBatch(batch []interface{}) error {
+	if len(batch) == 1 {
+		return c.writeFrame(batch[0])
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, msg := range batch {
+		data, err := c.codec.marshal(msg)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(data)
+	}
+	buf.WriteByte(']')
+	return c.writeData(buf.Bytes())
+}
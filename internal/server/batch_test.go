@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// servePump upgrades one connection and runs writePump for a client whose
+// send buffer already holds queued
+func servePump(t *testing.T, batchFrames bool, queued ...interface{}) *websocket.Conn {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		c := &Client{
+			conn:        conn,
+			send:        make(chan interface{}, 32),
+			cfg:         defaultClientConfig(),
+			codec:       jsonCodec{},
+			batchFrames: batchFrames,
+			logger:      slog.New(slog.DiscardHandler),
+			ctx:         ctx,
+			cancel:      cancel,
+		}
+		for _, msg := range queued {
+			c.send <- msg
+		}
+		c.writePump()
+	}))
+	t.Cleanup(ts.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestWritePumpBatchesQueuedEvents(t *testing.T) {
+	events := []interface{}{
+		messages.NewRoomMessageEvent("room_1", "user1", "User One", "one"),
+		messages.NewRoomMessageEvent("room_1", "user1", "User One", "two"),
+		json.RawMessage(`{"type":"user_joined","room_id":"room_1"}`),
+	}
+	conn := servePump(t, true, append(events, closeRequest{code: websocket.CloseNormalClosure, reason: "bye"})...)
+
+	var batch []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(readFrame(t, conn)), &batch), "queued events share one frame")
+	require.Len(t, batch, 3)
+	assert.Equal(t, "one", batch[0]["message"].(map[string]interface{})["message"])
+	assert.Equal(t, "two", batch[1]["message"].(map[string]interface{})["message"])
+	assert.Equal(t, "user_joined", batch[2]["type"])
+
+	// the close request queued behind them is still honoured
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, "bye", closeErr.Text)
+}
+
+func TestWritePumpWithoutBatching(t *testing.T) {
+	conn := servePump(t, false, messages.Pong{Type: "pong"}, messages.Pong{Type: "pong"})
+
+	assert.JSONEq(t, `{"type":"pong"}`, readFrame(t, conn))
+	assert.JSONEq(t, `{"type":"pong"}`, readFrame(t, conn))
+}
+
+func TestBatchSubprotocol(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolJSONBatch, SubprotocolJSON}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, SubprotocolJSONBatch, conn.Subprotocol())
+	assert.Equal(t, 1, connectedVersion(t, s))
+
+	// a lone event is not wrapped in an array
+	require.NoError(t, conn.WriteJSON(messages.WsMessage{Type: messages.MessageActionTypePing}))
+	assert.JSONEq(t, `{"type":"pong"}`, readFrame(t, conn))
+}
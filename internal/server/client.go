@@ -3,23 +3,84 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/arturskrzydlo/chat-room/internal/app"
 	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+// Close codes the server ends a connection with, so clients can tell whether
+// and how to reconnect. Shutdown uses websocket.CloseGoingAway: reconnect,
+// ideally to another instance. Frames that can't be read close with
+// websocket.CloseMessageTooBig or websocket.CloseInvalidFramePayloadData.
+const (
+	CloseRateLimited = 4001 // back off before reconnecting
+	CloseIdle        = 4002 // reconnect once there is something to send
+	CloseSlowClient  = 4003 // reconnect and resync; events were missed
+)
+
+// maxRateLimitStrikes is how many chat messages in a row may be refused by the
+// rate limiter before the connection is closed with CloseRateLimited
+const maxRateLimitStrikes = 10
+
+// errClosing is returned by readMessage once a close frame has been queued;
+// readPump then stops reading and waits for writePump to send it
+var errClosing = errors.New("closing connection")
+
+// Client is one websocket connection. It may be a member of many rooms at once:
+// all of them deliver onto the single send channel drained by writePump, and
+// rooms tracks which ones this connection has joined.
 type Client struct {
+	// userID is only written by the goroutine running readPump, which reads
+	// it freely; it writes, and other goroutines read, under stateMu. rooms is
+	// also written by writePump when a room drops the connection, so every
+	// access to it takes stateMu.
+	stateMu     sync.RWMutex
 	rooms       map[string]struct{}
+	departed    bool // set by cleanup before it gives up the rooms; guarded by stateMu
 	userID      string
 	userName    string
 	conn        *websocket.Conn
 	send        chan interface{}
 	coordinator CoordinatorPort
-	ctx         context.Context
-	cancel      context.CancelFunc
+	cfg         clientConfig
+	codec       codec // wire format negotiated at upgrade
+	version     int   // protocol version negotiated at upgrade
+	// batchFrames lets writePump pack queued events into one frame
+	batchFrames bool
+	logger      *slog.Logger
+	limiter     *tokenBucket  // nil means chat messages are not rate limited
+	strikes     int           // chat messages refused in a row by limiter
+	requestID   string        // RequestID of the message being dispatched, echoed on replies and errors
+	sessions    *sessionStore // nil means disconnects are not resumable
+	resumeToken string
+	online      bool // registered with the coordinator for direct messages
+	presence    bool // subscribed to presence events
+
+	lastActivity atomic.Int64 // unix nanos of the last application message
+
+	closed    atomic.Bool // set once by disconnect; queue drops messages afterwards
+	closeOnce sync.Once
+	// goAway is the close frame writePump sends when ctx is cancelled; nil sends none
+	goAway atomic.Pointer[closeRequest]
+	// a BackpressureEvent told the client to slow down; only touched by watchBackpressure
+	backpressured bool
+
+	// slow-client handling; inbox is nil under the default policy and rooms write to send directly
+	inbox            chan interface{}
+	slowPolicy       SlowClientPolicy
+	maxSlowDrops     int
+	consecutiveDrops int
+	ctx              context.Context
+	cancel           context.CancelFunc
 }
 
 func (c *Client) readPump() {
@@ -27,49 +88,98 @@ func (c *Client) readPump() {
 
 	for {
 		msg, err := c.readMessage()
+		if errors.Is(err, errClosing) {
+			<-c.ctx.Done()
+			break
+		}
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket unexpected close during read: %v", err)
+				c.logger.Warn("websocket closed unexpectedly", "user_id", c.userID, "error", err)
 			}
 			break
 		}
 
+		if msg == nil {
+			// rejected but recoverable; the client was already told why
+			continue
+		}
+
 		c.dispatchMessage(msg)
 	}
 }
 
 func (c *Client) readMessage() (*messages.WsMessage, error) {
-	_, rawMsg, err := c.conn.ReadMessage()
+	frameType, frame, err := c.conn.ReadMessage()
 	if err != nil {
 		return nil, err
 	}
 
+	// binary frames only carry attachments when messages themselves are text
+	if frameType == websocket.BinaryMessage && c.codec.frameType() == websocket.TextMessage {
+		c.touch()
+		c.handleBinaryAttachment(frame)
+		return nil, nil
+	}
+
 	// Check message size
-	if len(rawMsg) > maxMessageSize {
-		c.sendError("message_too_large", "message exceeds 10KB limit")
-		return nil, fmt.Errorf("message too large")
+	if int64(len(frame)) > c.cfg.maxMessageSize {
+		c.closeWith(
+			messages.ErrorPayload{
+				Code:    "message_too_large",
+				Message: fmt.Sprintf("message exceeds %d byte limit", c.cfg.maxMessageSize),
+				Details: sizeDetails(int(c.cfg.maxMessageSize), len(frame)),
+			},
+			closeRequest{code: websocket.CloseMessageTooBig, reason: "message too large"},
+		)
+		return nil, errClosing
+	}
+
+	rawMsg, err := c.codec.toJSON(frame)
+	if err != nil {
+		c.closeWith(
+			messages.ErrorPayload{Code: "malformed_message", Message: fmt.Sprintf("invalid %s message", c.codec.name())},
+			closeRequest{code: websocket.CloseInvalidFramePayloadData, reason: "malformed message"},
+		)
+		return nil, errClosing
 	}
 
 	var msg messages.WsMessage
 	err = json.Unmarshal(rawMsg, &msg)
 	if err != nil {
-		c.sendError("malformed_json", "invalid JSON message")
-		return nil, fmt.Errorf("malformed json message")
+		c.closeWith(
+			messages.ErrorPayload{Code: "malformed_json", Message: "invalid JSON message"},
+			closeRequest{code: websocket.CloseInvalidFramePayloadData, reason: "malformed message"},
+		)
+		return nil, errClosing
+	}
+
+	if err := ValidateWebSocketMessage(rawMsg); err != nil {
+		var sve *SchemaValidationError
+		if errors.As(err, &sve) {
+			c.queue(messages.ErrorPayload{
+				Code:      "schema_validation_failed",
+				Message:   "message does not match schema",
+				Fields:    sve.Fields,
+				RequestID: msg.RequestID,
+			})
+			return nil, nil
+		}
+		c.logger.Error("schema validation unavailable", "error", err)
 	}
 
 	return &msg, nil
 }
 
 func (c *Client) setupReadTimeouts() {
-	c.conn.SetReadLimit(maxMessageSize)
-	if err := c.conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
-		log.Printf("readPump: SetReadDeadline error: %v", err)
+	c.conn.SetReadLimit(c.cfg.readLimit())
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.cfg.pongWait)); err != nil {
+		c.logger.Warn("readPump: set read deadline", "user_id", c.userID, "error", err)
 		return
 	}
 
 	c.conn.SetPongHandler(func(string) error {
-		if err := c.conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
-			log.Printf("readPump: pong handler deadline error: %v", err)
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.cfg.pongWait)); err != nil {
+			c.logger.Warn("readPump: pong handler read deadline", "user_id", c.userID, "error", err)
 			return err
 		}
 		return nil
@@ -77,6 +187,10 @@ func (c *Client) setupReadTimeouts() {
 }
 
 func (c *Client) dispatchMessage(msg *messages.WsMessage) {
+	c.touch()
+	c.requestID = msg.RequestID
+	defer func() { c.requestID = "" }()
+
 	switch msg.Type {
 	case messages.MessageActionTypeCreateRoom:
 		c.handleCreateRoom(msg)
@@ -87,11 +201,66 @@ func (c *Client) dispatchMessage(msg *messages.WsMessage) {
 	case messages.MessageActionTypeLeave:
 		c.handleLeaveRoom(msg)
 
+	case messages.MessageActionTypeLeaveAll:
+		c.handleLeaveAll()
+
 	case messages.MessageActionTypeMessage:
 		c.handleChatMessage(msg)
 
+	case messages.MessageActionTypeListMembers:
+		c.handleListMembers(msg)
+
+	case messages.MessageActionTypeKick:
+		c.handleKick(msg)
+
+	case messages.MessageActionTypeGrantMod:
+		c.handleModerator(msg, true)
+
+	case messages.MessageActionTypeRevokeMod:
+		c.handleModerator(msg, false)
+
+	case messages.MessageActionTypeMute:
+		c.handleMute(msg, true)
+
+	case messages.MessageActionTypeUnmute:
+		c.handleMute(msg, false)
+
+	case messages.MessageActionTypeSetRoomMeta:
+		c.handleSetRoomMeta(msg)
+
+	case messages.MessageActionTypeRename:
+		c.handleRename(msg)
+
+	case messages.MessageActionTypeTyping:
+		c.handleTyping(msg)
+
+	case messages.MessageActionTypeResume:
+		c.handleResume(msg)
+
+	case messages.MessageActionTypeDelete:
+		c.handleDelete(msg)
+
+	case messages.MessageActionTypeReact:
+		c.handleReact(msg)
+
+	case messages.MessageActionTypeDirectMessage:
+		c.handleDirectMessage(msg)
+
+	case messages.MessageActionTypeMarkRead:
+		c.handleMarkRead(msg)
+
+	case messages.MessageActionTypeSubscribePresence:
+		if !c.presence {
+			c.coordinator.SubscribePresence(c.roomSend())
+			c.presence = true
+		}
+
+	case messages.MessageActionTypeHistory:
+		c.handleHistory(msg)
+	case messages.MessageActionTypeWhoAmI:
+		c.handleWhoAmI()
 	case messages.MessageActionTypePing:
-		c.send <- messages.Pong{Type: "pong"}
+		c.queue(messages.Pong{Type: "pong", RequestID: c.requestID})
 
 	default:
 		c.sendError("invalid_message_type", fmt.Sprintf("unknown message type: %s", msg.Type))
@@ -116,13 +285,47 @@ func (c *Client) handleCreateRoom(msg *messages.WsMessage) {
 		c.sendError("identity_error", "user not identified yet")
 		return
 	}
+	if c.atRoomLimit() {
+		c.sendError("room_limit_per_connection", fmt.Sprintf("connection is already in %d rooms", c.roomCount()))
+		return
+	}
 
-	c.rooms[p.RoomID] = struct{}{}
+	// without a room_id the coordinator mints one and reports it in new_room
+	if p.RoomID == "" {
+		roomID, err := c.coordinator.CreateRoomWithGeneratedID(c.userID, p.RoomName, p.Password, c.roomSend())
+		if err != nil {
+			c.sendError("create_room_error", err.Error())
+			return
+		}
+		if !c.trackJoined(roomID) {
+			return
+		}
+		c.ackCreated(roomID)
+		return
+	}
 
-	if err := c.coordinator.CreateRoom(p.RoomID, c.userID, p.RoomName, c.send); err != nil {
+	// only a room that was actually created is this connection's to leave
+	if err := c.coordinator.CreateRoom(p.RoomID, c.userID, p.RoomName, p.Password, c.roomSend()); err != nil {
 		c.sendError("create_room_error", err.Error())
 		return
 	}
+	if !c.trackJoined(p.RoomID) {
+		return
+	}
+	c.ackCreated(p.RoomID)
+}
+
+// ackCreated tells the author their room exists and, as the coordinator has
+// joined them to it, that they are in it, in that order: new_room has already
+// gone out, then create_room_success, then the join_success any joiner gets.
+func (c *Client) ackCreated(roomID string) {
+	created := messages.NewCreateRoomSuccess(roomID, c.userID)
+	created.RequestID = c.requestID
+	c.queue(created)
+
+	joined := messages.NewJoinSuccess(roomID, c.userID)
+	joined.RequestID = c.requestID
+	c.queue(joined)
 }
 
 func (c *Client) handleJoinRoom(msg *messages.WsMessage) {
@@ -132,30 +335,70 @@ func (c *Client) handleJoinRoom(msg *messages.WsMessage) {
 		return
 	}
 
-	userID := p.UserID
-	userName := p.UserName
-	if userID == "" {
-		userID = c.userID
-	}
-	if userName == "" {
-		userName = c.userName
+	// Once the connection is bound (by an earlier message or an Authenticator)
+	// the coordinator only ever sees the bound identity: payload user_id must
+	// match it or be omitted, and payload user_name is ignored (use rename).
+	// Only an unbound connection takes its identity from the payload.
+	if c.userID != "" {
+		if p.UserID != "" && p.UserID != c.userID {
+			c.sendError("identity_error", fmt.Sprintf("connection already bound to user %s", c.userID))
+			return
+		}
+	} else {
+		if p.UserID == "" {
+			c.sendError("identity_error", "user not identified yet")
+			return
+		}
+		if err := c.ensureIdentity(p.UserID, p.UserName); err != nil {
+			c.sendError("identity_error", err.Error())
+			return
+		}
 	}
 
-	if err := c.ensureIdentity(userID, userName); err != nil {
-		c.sendError("identity_error", err.Error())
+	// a client that went away while this message waited would join only to
+	// leave again, showing the room a join and a leave for nobody; trackJoined
+	// settles a disconnect that lands after this check
+	if c.ctx.Err() != nil {
+		return
+	}
+	if c.atRoomLimit() {
+		c.sendError("room_limit_per_connection", fmt.Sprintf("connection is already in %d rooms", c.roomCount()))
 		return
 	}
 
-	if err := c.coordinator.JoinRoom(p.RoomID, c.userID, c.userName, c.send); err != nil {
-		c.sendError("join_room_error", err.Error())
+	join := c.coordinator.JoinRoom
+	if p.CreateIfMissing {
+		join = c.coordinator.JoinOrCreateRoom
+	}
+	if err := join(p.RoomID, c.userID, c.userName, p.Password, c.roomSend()); err != nil {
+		c.sendError(joinErrorCode(err), err.Error())
+		return
+	}
+	if !c.trackJoined(p.RoomID) {
 		return
 	}
 
-	c.rooms[p.RoomID] = struct{}{}
+	c.logger.Info("user joined room", "event", "join", "room_id", p.RoomID, "user_id", c.userID, "user_name", c.userName)
 
-	log.Printf("User %s joined room: %s", c.userName, p.RoomID)
+	ack := messages.NewJoinSuccess(p.RoomID, c.userID)
+	ack.RequestID = c.requestID
+	c.queue(ack)
+}
 
-	c.send <- messages.NewJoinSuccess(p.RoomID, c.userID)
+// joinErrorCode maps coordinator join failures to client error codes
+func joinErrorCode(err error) string {
+	switch {
+	case errors.Is(err, app.ErrRoomNotFound):
+		return "room_not_found"
+	case errors.Is(err, app.ErrUserAlreadyInRoom):
+		return "already_in_room"
+	case errors.Is(err, app.ErrInvalidPassword):
+		return "invalid_password"
+	case errors.Is(err, app.ErrIdentityRequired), errors.Is(err, app.ErrInvalidIdentity):
+		return "identity_error"
+	default:
+		return "join_room_error"
+	}
 }
 
 func (c *Client) handleLeaveRoom(msg *messages.WsMessage) {
@@ -165,24 +408,53 @@ func (c *Client) handleLeaveRoom(msg *messages.WsMessage) {
 		return
 	}
 
-	if _, ok := c.rooms[p.RoomID]; !ok {
+	if !c.inRoom(p.RoomID) {
 		c.sendError("leave_room_error", "user not in this room")
 		return
 	}
 
-	delete(c.rooms, p.RoomID)
+	c.removeRoom(p.RoomID)
 
 	if err := c.coordinator.LeaveRoom(p.RoomID, c.userID); err != nil {
-		c.rooms[p.RoomID] = struct{}{}
+		// a room that is gone or no longer has us stays forgotten
+		if !alreadyLeft(err) {
+			c.addRoom(p.RoomID)
+		}
 		c.sendError("leave_room_error", err.Error())
 		return
 	}
 
-	log.Printf("User %s left room: %s", c.userID, p.RoomID)
+	c.logger.Info("user left room", "event", "leave", "room_id", p.RoomID, "user_id", c.userID)
 
+	ack := messages.NewLeaveSuccess(p.RoomID, c.userID)
+	ack.RequestID = c.requestID
+	c.queue(ack)
+}
+
+// handleLeaveAll leaves every room the connection is in and acknowledges
+// once, listing them. Rooms that can't be left stay joined and are left out
+// of the list.
+func (c *Client) handleLeaveAll() {
+	ack := messages.NewLeaveAllSuccess(c.userID, c.leaveAllRooms())
+	ack.RequestID = c.requestID
+	c.queue(ack)
 }
 
 func (c *Client) handleChatMessage(msg *messages.WsMessage) {
+	if c.limiter != nil && !c.limiter.Allow() {
+		c.strikes++
+		if c.strikes >= maxRateLimitStrikes {
+			c.closeWith(
+				messages.ErrorPayload{Code: "rate_limited", Message: "too many messages, disconnecting", RequestID: c.requestID},
+				closeRequest{code: CloseRateLimited, reason: "rate limited"},
+			)
+			return
+		}
+		c.sendError("rate_limited", "too many messages, slow down")
+		return
+	}
+	c.strikes = 0
+
 	var p messages.MessagePayload
 	if err := marshalPayload(msg.Payload, &p); err != nil {
 		c.sendError("invalid_payload", err.Error())
@@ -194,70 +466,683 @@ func (c *Client) handleChatMessage(msg *messages.WsMessage) {
 		return
 	}
 
-	if _, ok := c.rooms[p.RoomID]; !ok {
+	if !c.inRoom(p.RoomID) {
 		c.sendError("message_error", "not in this room")
 		return
 	}
 
-	if err := c.coordinator.SendMessage(p.RoomID, c.userID, p.Message); err != nil {
+	// the room acks the message once it has a sequence number
+	err := c.coordinator.SendMessageAcked(p.RoomID, c.userID, p.Message, p.ClientMsgID, p.ParentMessageID, p.ExpiresInSeconds, c.requestID)
+	if err != nil {
+		if errors.Is(err, app.ErrDuplicateMessage) {
+			// already broadcast; tell the sender so it can stop retrying
+			ack := messages.NewDuplicateMessageAck(p.RoomID, p.ClientMsgID)
+			ack.RequestID = c.requestID
+			c.queue(ack)
+			return
+		}
+		if errors.Is(err, app.ErrContentRejected) {
+			c.sendError("content_rejected", err.Error())
+			return
+		}
+		if errors.Is(err, app.ErrRoomBusy) {
+			c.sendError("room_busy", err.Error())
+			return
+		}
+		if errors.Is(err, app.ErrMuted) {
+			c.sendError("muted", err.Error())
+			return
+		}
+		var tooLong *app.ContentTooLongError
+		if errors.As(err, &tooLong) {
+			c.sendErrorDetails("message_error", err.Error(), sizeDetails(tooLong.Limit, tooLong.Size))
+			return
+		}
 		c.sendError("message_error", err.Error())
 		return
 	}
 }
 
+func (c *Client) handleListMembers(msg *messages.WsMessage) {
+	var p messages.ListMembersPayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if p.RoomID == "" {
+		c.sendError("list_members_error", "room_id is required")
+		return
+	}
+
+	if !c.inRoom(p.RoomID) {
+		c.sendError("list_members_error", "not in this room")
+		return
+	}
+
+	members, err := c.coordinator.ListMembers(p.RoomID)
+	if err != nil {
+		c.sendError("list_members_error", err.Error())
+		return
+	}
+
+	list := messages.NewMembersListEvent(p.RoomID, members)
+	list.RequestID = c.requestID
+	c.queue(list)
+}
+
+func (c *Client) handleHistory(msg *messages.WsMessage) {
+	var p messages.HistoryPayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if p.RoomID == "" {
+		c.sendError("history_error", "room_id is required")
+		return
+	}
+
+	if !c.inRoom(p.RoomID) {
+		c.sendError("history_error", "not in this room")
+		return
+	}
+
+	page, err := c.coordinator.GetHistory(p.RoomID, p.BeforeSeq, p.Limit)
+	if err != nil {
+		c.sendError("history_error", err.Error())
+		return
+	}
+
+	batch := messages.NewHistoryBatchEvent(p.RoomID, page)
+	batch.RequestID = c.requestID
+	c.queue(batch)
+}
+
+func (c *Client) handleKick(msg *messages.WsMessage) {
+	var p messages.KickPayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if p.RoomID == "" || p.TargetUserID == "" {
+		c.sendError("kick_error", "room_id and target_user_id are required")
+		return
+	}
+
+	if !c.inRoom(p.RoomID) {
+		c.sendError("kick_error", "not in this room")
+		return
+	}
+
+	if err := c.coordinator.KickUser(p.RoomID, c.userID, p.TargetUserID); err != nil {
+		c.sendError("kick_error", err.Error())
+		return
+	}
+}
+
+// handleModerator grants or revokes a member's moderator role. There is no
+// reply on success: the room broadcasts a moderator_changed event to everyone,
+// the sender included.
+func (c *Client) handleModerator(msg *messages.WsMessage, grant bool) {
+	var p messages.ModeratorPayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if p.RoomID == "" || p.TargetUserID == "" {
+		c.sendError("moderator_error", "room_id and target_user_id are required")
+		return
+	}
+
+	if !c.inRoom(p.RoomID) {
+		c.sendError("moderator_error", "not in this room")
+		return
+	}
+
+	set := c.coordinator.RevokeModerator
+	if grant {
+		set = c.coordinator.GrantModerator
+	}
+	if err := set(p.RoomID, c.userID, p.TargetUserID); err != nil {
+		c.sendError("moderator_error", err.Error())
+		return
+	}
+}
+
+// handleMute mutes or unmutes a member. Like handleModerator it replies only
+// on failure; the room broadcasts the change.
+func (c *Client) handleMute(msg *messages.WsMessage, mute bool) {
+	var p messages.MutePayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if p.RoomID == "" || p.TargetUserID == "" {
+		c.sendError("mute_error", "room_id and target_user_id are required")
+		return
+	}
+
+	if !c.inRoom(p.RoomID) {
+		c.sendError("mute_error", "not in this room")
+		return
+	}
+
+	set := c.coordinator.UnmuteUser
+	if mute {
+		set = c.coordinator.MuteUser
+	}
+	if err := set(p.RoomID, c.userID, p.TargetUserID); err != nil {
+		c.sendError("mute_error", err.Error())
+		return
+	}
+}
+
+// handleSetRoomMeta sets a room's topic and description. It replies only on
+// failure; the room broadcasts the change.
+func (c *Client) handleSetRoomMeta(msg *messages.WsMessage) {
+	var p messages.RoomMetaPayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if p.RoomID == "" {
+		c.sendError("room_meta_error", "room_id is required")
+		return
+	}
+
+	if !c.inRoom(p.RoomID) {
+		c.sendError("room_meta_error", "not in this room")
+		return
+	}
+
+	if err := c.coordinator.SetRoomMeta(p.RoomID, c.userID, p.Topic, p.Description); err != nil {
+		c.sendError("room_meta_error", err.Error())
+		return
+	}
+}
+
+func (c *Client) handleRename(msg *messages.WsMessage) {
+	var p messages.RenamePayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if c.userID == "" {
+		c.sendError("identity_error", "user not identified yet")
+		return
+	}
+
+	if p.NewName == "" {
+		c.sendError("rename_error", "new_name is required")
+		return
+	}
+	newName, err := app.NormalizeIdentity("new_name", p.NewName)
+	if err != nil {
+		c.sendError("rename_error", err.Error())
+		return
+	}
+
+	if err := c.coordinator.RenameUser(c.userID, newName); err != nil {
+		c.sendError("rename_error", err.Error())
+		return
+	}
+
+	c.userName = newName
+}
+
+func (c *Client) handleTyping(msg *messages.WsMessage) {
+	var p messages.TypingPayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if p.RoomID == "" {
+		c.sendError("typing_error", "room_id is required")
+		return
+	}
+
+	if !c.inRoom(p.RoomID) {
+		c.sendError("typing_error", "not in this room")
+		return
+	}
+
+	if err := c.coordinator.BroadcastTyping(p.RoomID, c.userID, c.userName, p.IsTyping); err != nil {
+		c.sendError("typing_error", err.Error())
+		return
+	}
+}
+
+func (c *Client) handleDelete(msg *messages.WsMessage) {
+	var p messages.DeletePayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if p.RoomID == "" || p.MessageID == "" {
+		c.sendError("delete_error", "room_id and message_id are required")
+		return
+	}
+
+	if !c.inRoom(p.RoomID) {
+		c.sendError("delete_error", "not in this room")
+		return
+	}
+
+	if err := c.coordinator.DeleteMessage(p.RoomID, c.userID, p.MessageID); err != nil {
+		if errors.Is(err, app.ErrEditWindowExpired) {
+			c.sendError("edit_window_expired", err.Error())
+			return
+		}
+		c.sendError("delete_error", err.Error())
+		return
+	}
+}
+
+func (c *Client) handleReact(msg *messages.WsMessage) {
+	var p messages.ReactPayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if p.RoomID == "" || p.MessageID == "" {
+		c.sendError("react_error", "room_id and message_id are required")
+		return
+	}
+
+	if !c.inRoom(p.RoomID) {
+		c.sendError("react_error", "not in this room")
+		return
+	}
+
+	if err := c.coordinator.React(p.RoomID, c.userID, p.MessageID, p.Emoji); err != nil {
+		c.sendError("react_error", err.Error())
+		return
+	}
+}
+
+func (c *Client) handleMarkRead(msg *messages.WsMessage) {
+	var p messages.MarkReadPayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if !c.inRoom(p.RoomID) {
+		c.sendError("mark_read_error", "not in this room")
+		return
+	}
+
+	if err := c.coordinator.MarkRead(p.RoomID, c.userID, p.Seq); err != nil {
+		c.sendError("mark_read_error", err.Error())
+		return
+	}
+}
+
+// handleWhoAmI tells the client who the connection is bound to and which
+// rooms it is in, so a reconnecting client can resync its own view
+func (c *Client) handleWhoAmI() {
+	userID, rooms := c.state()
+	info := messages.NewSessionInfoEvent(userID, c.userName, rooms)
+	info.RequestID = c.requestID
+	c.queue(info)
+}
+
+func (c *Client) handleDirectMessage(msg *messages.WsMessage) {
+	var p messages.DirectMessagePayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if c.userID == "" {
+		c.sendError("identity_error", "user not identified yet")
+		return
+	}
+
+	if err := c.coordinator.SendDirect(c.userID, c.userName, p.ToUserID, p.Message); err != nil {
+		c.sendError("direct_message_error", err.Error())
+		return
+	}
+}
+
+func (c *Client) handleResume(msg *messages.WsMessage) {
+	var p messages.ResumePayload
+	if err := marshalPayload(msg.Payload, &p); err != nil {
+		c.sendError("invalid_payload", err.Error())
+		return
+	}
+
+	if c.sessions == nil {
+		c.sendError("resume_error", "resume is not enabled")
+		return
+	}
+
+	if p.ResumeToken == "" {
+		c.sendError("resume_error", "resume_token is required")
+		return
+	}
+
+	sess, ok := c.sessions.take(p.ResumeToken)
+	if !ok {
+		c.sendError("resume_error", "invalid or expired resume token")
+		return
+	}
+
+	if c.userID != "" && c.userID != sess.userID {
+		// not ours to resume on this connection; put it back for its owner
+		c.sessions.park(p.ResumeToken, sess, c.expireSession)
+		c.sendError("identity_error", fmt.Sprintf("connection already bound to user %s", c.userID))
+		return
+	}
+
+	c.stateMu.Lock()
+	c.userID = sess.userID
+	c.stateMu.Unlock()
+	c.userName = sess.userName
+	c.resumeToken = p.ResumeToken
+	c.goOnline()
+
+	resumed := make([]string, 0, len(sess.rooms))
+	for _, roomID := range sess.rooms {
+		if err := c.coordinator.ReattachClient(roomID, c.userID, c.roomSend()); err != nil {
+			c.logger.Warn("resume: couldn't reattach room", "room_id", roomID, "user_id", c.userID, "error", err)
+			continue
+		}
+		c.addRoom(roomID)
+		resumed = append(resumed, roomID)
+	}
+
+	c.logger.Info("user resumed rooms", "event", "resume", "user_id", c.userID, "rooms", resumed)
+
+	ack := messages.NewResumeSuccess(c.userID, resumed)
+	ack.RequestID = c.requestID
+	c.queue(ack)
+}
+
+// sendError reports a failure to the client, tagged with the request that
+// caused it when the client gave one
 func (c *Client) sendError(code, message string) {
-	c.send <- messages.ErrorPayload{
-		Code:    code,
-		Message: message,
+	c.sendErrorDetails(code, message, nil)
+}
+
+// sendErrorDetails is sendError with structured details for the client
+func (c *Client) sendErrorDetails(code, message string, details map[string]interface{}) {
+	c.queue(messages.ErrorPayload{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: c.requestID,
+	})
+}
+
+// sizeDetails reports a size limit and the size that broke it
+func sizeDetails(limit, size int) map[string]interface{} {
+	return map[string]interface{}{"limit": limit, "size": size}
+}
+
+// queue hands msg to writePump and is how handlers reply. Once the client is
+// disconnected it drops msg instead of blocking on a buffer nobody drains.
+// send itself is never closed: rooms keep a reference until cleanup removes
+// the client from them, and a send on a closed channel would panic.
+func (c *Client) queue(msg interface{}) bool {
+	if c.closed.Load() {
+		return false
+	}
+	select {
+	case c.send <- msg:
+		return true
+	case <-c.ctx.Done():
+		return false
 	}
 }
 
+// writePump owns the socket's write side. Whatever makes it stop, the client
+// is disconnected so handlers stop queueing and the read pump unblocks.
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.cfg.pingPeriod())
 	defer ticker.Stop()
+	defer c.disconnect()
 
 	for {
 		select {
 		case msg, ok := <-c.send:
-			if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
-				log.Printf("writePump: SetWriteDeadline error: %v", err)
+			if err := c.conn.SetWriteDeadline(time.Now().Add(c.cfg.writeWait)); err != nil {
+				c.logger.Warn("writePump: set write deadline", "user_id", c.userID, "error", err)
 				return
 			}
 
 			if !ok {
 				// channel closed
 				if err := c.conn.WriteMessage(websocket.CloseMessage, []byte{}); err != nil {
-					log.Printf("writePump: WriteMessage close error: %v", err)
+					c.logger.Warn("writePump: write close", "user_id", c.userID, "error", err)
 				}
 				return
 			}
 
-			if err := c.conn.WriteJSON(msg); err != nil {
-				log.Printf("writePump: WriteJSON error: %v", err)
+			if req, isClose := msg.(closeRequest); isClose {
+				c.closeWithReason(req.code, req.reason)
+				return
+			}
+
+			pending, req, closed := c.collectPending(msg)
+			for _, msg := range pending {
+				c.trackDeparture(msg)
+			}
+			if err := c.writePending(coalesce(pending)); err != nil {
+				c.logger.Warn("writePump: write message", "user_id", c.userID, "error", err)
+				return
+			}
+			switch {
+			case req != nil:
+				c.closeWithReason(req.code, req.reason)
+				return
+			case closed:
+				if err := c.conn.WriteMessage(websocket.CloseMessage, []byte{}); err != nil {
+					c.logger.Warn("writePump: write close", "user_id", c.userID, "error", err)
+				}
 				return
 			}
 
 		case <-ticker.C:
-			if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
-				log.Printf("writePump: SetWriteDeadline error: %v", err)
+			if err := c.conn.SetWriteDeadline(time.Now().Add(c.cfg.writeWait)); err != nil {
+				c.logger.Warn("writePump: set write deadline", "user_id", c.userID, "error", err)
 				return
 			}
 
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				log.Printf("writePump: WriteMessage ping error: %v", err)
+				c.logger.Warn("writePump: write ping", "user_id", c.userID, "error", err)
 				return
 			}
 
 		case <-c.ctx.Done():
+			if req := c.goAway.Load(); req != nil {
+				c.closeWithReason(req.code, req.reason)
+			}
 			return
 		}
 	}
 }
 
+// writeFrame encodes msg with the connection's codec and writes it as one frame
+func (c *Client) writeFrame(msg interface{}) error {
+	data, err := c.codec.marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.writeData(data)
+}
+
+// writeData writes an encoded frame, deflating it if compression was
+// negotiated and the frame is large enough to benefit
+func (c *Client) writeData(data []byte) error {
+	if c.cfg.compression {
+		c.conn.EnableWriteCompression(len(data) >= minCompressSize)
+	}
+	return c.conn.WriteMessage(c.codec.frameType(), data)
+}
+
+// closeRequest asks writePump to send a close frame once everything queued
+// before it has been written, then drop the connection
+type closeRequest struct {
+	code   int
+	reason string
+}
+
+// closeWithReason sends a close frame with code and reason, then drops the
+// connection. writePump calls it for closeRequests; since control frames may
+// be written alongside writePump, other goroutines that can't wait for the
+// queue to drain call it directly.
+func (c *Client) closeWithReason(code int, reason string) {
+	if c.conn != nil {
+		msg := websocket.FormatCloseMessage(code, reason)
+		if err := c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(c.cfg.writeWait)); err != nil {
+			c.logger.Warn("write close", "user_id", c.userID, "code", code, "error", err)
+		}
+	}
+	c.disconnect()
+}
+
+// closeNow has writePump send req ahead of anything still queued and then
+// drop the connection, without waiting on the send buffer
+func (c *Client) closeNow(req closeRequest) {
+	c.goAway.Store(&req)
+	if c.cancel == nil {
+		c.disconnect()
+		return
+	}
+	c.cancel()
+}
+
+// closeWith queues msg followed by a close frame. If the send buffer stays
+// full the connection is dropped without them.
+func (c *Client) closeWith(msg interface{}, req closeRequest) {
+	timeout := time.NewTimer(c.cfg.writeWait)
+	defer timeout.Stop()
+
+	for _, out := range []interface{}{msg, req} {
+		select {
+		case c.send <- out:
+		case <-c.ctx.Done():
+			return
+		case <-timeout.C:
+			c.disconnect()
+			return
+		}
+	}
+}
+
+// atRoomLimit reports whether the connection is in as many rooms as it may be
+func (c *Client) atRoomLimit() bool {
+	return c.cfg.maxRooms > 0 && c.roomCount() >= c.cfg.maxRooms
+}
+
+// inRoom reports whether the connection is in roomID
+func (c *Client) inRoom(roomID string) bool {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	_, ok := c.rooms[roomID]
+	return ok
+}
+
+func (c *Client) roomCount() int {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return len(c.rooms)
+}
+
+func (c *Client) addRoom(roomID string) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.rooms[roomID] = struct{}{}
+}
+
+// trackJoined records a room the coordinator has just joined the connection
+// to. If cleanup has already given up the connection's rooms, nobody would
+// leave this one, so it is left here instead and false is returned.
+func (c *Client) trackJoined(roomID string) bool {
+	c.stateMu.Lock()
+	departed := c.departed
+	if !departed {
+		c.rooms[roomID] = struct{}{}
+	}
+	c.stateMu.Unlock()
+	if !departed {
+		return true
+	}
+
+	if err := c.coordinator.LeaveRoom(roomID, c.userID); err != nil && !alreadyLeft(err) {
+		c.logger.Warn("couldn't leave room joined during disconnect", "room_id", roomID, "user_id", c.userID, "error", err)
+	}
+	return false
+}
+
+func (c *Client) removeRoom(roomID string) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	delete(c.rooms, roomID)
+}
+
+// trackDeparture stops tracking a room that has dropped the connection, so a
+// later leave or the room cap doesn't count it. writePump calls it for each
+// event on its way out; a user_kicked for this user or a room_closed means
+// the room has already let them go.
+func (c *Client) trackDeparture(msg interface{}) {
+	switch ev := msg.(type) {
+	case messages.UserKickedEvent:
+		c.stateMu.RLock()
+		self := ev.UserID == c.userID
+		c.stateMu.RUnlock()
+		if self {
+			c.removeRoom(ev.RoomID)
+		}
+	case messages.RoomClosedEvent:
+		c.removeRoom(ev.RoomID)
+	}
+}
+
+// state returns the connection's user and the rooms it has joined; safe to
+// call from any goroutine
+func (c *Client) state() (userID string, rooms []string) {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	rooms = make([]string, 0, len(c.rooms))
+	for roomID := range c.rooms {
+		rooms = append(rooms, roomID)
+	}
+	sort.Strings(rooms)
+	return c.userID, rooms
+}
+
+// ensureIdentity binds the connection to userID the first time it is called.
+// Connections verified by an Authenticator are bound before the first message,
+// so payload ids on them can only match, never replace, the verified identity.
+// The ids, and the name when binding, are normalized with
+// app.NormalizeIdentity first.
 func (c *Client) ensureIdentity(userID, userName string) error {
+	userID, err := app.NormalizeIdentity("user_id", userID)
+	if err != nil {
+		return err
+	}
+
 	if c.userID == "" {
+		if userName, err = app.NormalizeIdentity("user_name", userName); err != nil {
+			return err
+		}
+		c.stateMu.Lock()
 		c.userID = userID
+		c.stateMu.Unlock()
 		c.userName = userName
+		c.goOnline()
+		c.issueResumeToken()
 		return nil
 	}
 	if c.userID != userID {
@@ -266,20 +1151,112 @@ func (c *Client) ensureIdentity(userID, userName string) error {
 	return nil
 }
 
+// goOnline registers the bound identity so it can receive direct messages
+func (c *Client) goOnline() {
+	if c.online || c.userID == "" {
+		return
+	}
+	c.coordinator.RegisterClient(c.userID, c.roomSend())
+	c.online = true
+}
+
+// issueResumeToken gives a newly identified client a token for resuming after a drop
+func (c *Client) issueResumeToken() {
+	if c.sessions == nil || c.resumeToken != "" {
+		return
+	}
+	c.resumeToken = uuid.NewString()
+	c.queue(messages.NewSessionEvent(c.userID, c.resumeToken))
+}
+
 func (c *Client) cleanup() {
-	if c.cancel != nil {
-		c.cancel()
+	c.disconnect()
+	// from here on a finishing join leaves its room itself
+	c.stateMu.Lock()
+	c.departed = true
+	c.stateMu.Unlock()
+
+	if c.presence {
+		c.coordinator.UnsubscribePresence(c.roomSend())
+		c.presence = false
 	}
 
-	// leave all joined rooms
-	if c.userID != "" {
-		for roomID := range c.rooms {
-			err := c.coordinator.LeaveRoom(roomID, c.userID)
-			if err != nil {
-				log.Printf("couldn't leave room : %s err: %v", roomID, err)
-			}
+	if c.userID == "" {
+		return
+	}
+
+	if c.online {
+		c.coordinator.UnregisterClient(c.userID, c.roomSend())
+		c.online = false
+	}
+
+	if c.park() {
+		return
+	}
+
+	c.leaveAllRooms()
+}
+
+// leaveAllRooms leaves every joined room and returns the ids of those it
+// left, sorted. A room that can't be left is logged and stays tracked.
+func (c *Client) leaveAllRooms() []string {
+	_, rooms := c.state()
+	left := make([]string, 0, len(rooms))
+	for _, roomID := range rooms {
+		if err := c.coordinator.LeaveRoom(roomID, c.userID); err != nil && !alreadyLeft(err) {
+			c.logger.Warn("couldn't leave room", "room_id", roomID, "user_id", c.userID, "error", err)
+			continue
+		}
+		c.removeRoom(roomID)
+		left = append(left, roomID)
+	}
+	return left
+}
+
+// alreadyLeft reports whether a LeaveRoom error means the connection is out
+// of the room anyway: the room is gone, or it dropped the user first
+func alreadyLeft(err error) bool {
+	return errors.Is(err, app.ErrRoomNotFound) || errors.Is(err, app.ErrUserNotInRoom)
+}
+
+// park detaches the client from its rooms and holds the memberships under its
+// resume token, so a reconnect can pick them up without a fresh join.
+func (c *Client) park() bool {
+	_, rooms := c.state()
+	if c.sessions == nil || c.resumeToken == "" || len(rooms) == 0 {
+		return false
+	}
+
+	sess := &parkedSession{
+		userID:   c.userID,
+		userName: c.userName,
+		rooms:    make([]string, 0, len(rooms)),
+	}
+	for _, roomID := range rooms {
+		if err := c.coordinator.DetachClient(roomID, c.userID); err != nil {
+			c.logger.Warn("couldn't detach from room", "room_id", roomID, "user_id", c.userID, "error", err)
+			continue
+		}
+		sess.rooms = append(sess.rooms, roomID)
+	}
+
+	if !c.sessions.park(c.resumeToken, sess, c.expireSession) {
+		// store is closed (shutting down); fall back to leaving
+		return false
+	}
+
+	c.logger.Info("user disconnected; rooms held for resume", "event", "park", "user_id", c.userID, "rooms", sess.rooms)
+	return true
+}
+
+// expireSession leaves the rooms of a session nobody resumed in time
+func (c *Client) expireSession(sess *parkedSession) {
+	for _, roomID := range sess.rooms {
+		if err := c.coordinator.LeaveRoom(roomID, sess.userID); err != nil {
+			c.logger.Warn("couldn't leave room", "room_id", roomID, "user_id", sess.userID, "error", err)
 		}
 	}
+	c.logger.Info("resume window expired; left rooms", "event", "session_expired", "user_id", sess.userID, "rooms", sess.rooms)
 }
 
 func marshalPayload(payload interface{}, target interface{}) error {
@@ -1,11 +1,18 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/arturskrzydlo/chat-room/internal/app"
 	"github.com/arturskrzydlo/chat-room/internal/messages"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,57 +20,303 @@ import (
 
 // mockCoordinator implements CoordinatorPort
 type mockCoordinator struct {
+	mu sync.Mutex // guards leaveCalls and registered, which timers and connection goroutines touch
+
 	createCalls []struct {
-		roomID, authorID, roomName string
-		send                       chan<- interface{}
+		roomID, authorID, roomName, password string
+		send                                 chan<- interface{}
 	}
 	joinCalls []struct {
-		roomID, userID, userName string
-		send                     chan<- interface{}
+		roomID, userID, userName, password string
+		send                               chan<- interface{}
 	}
 	leaveCalls []struct {
 		roomID, userID string
 	}
 	sendMsgCalls []struct {
-		roomID, userID, content string
+		roomID, userID, content, clientMsgID, parentMessageID string
+	}
+	deleteCalls []struct {
+		roomID, requesterID, messageID string
+	}
+	reactCalls []struct {
+		roomID, userID, messageID, emoji string
+	}
+	markReadCalls []struct {
+		roomID, userID string
+		seq            uint64
+	}
+	directCalls []struct {
+		fromID, fromName, toID, content string
+	}
+	registered       map[string]int // userID -> registered connections
+	presenceSubs     int
+	listMembersCalls []string
+	historyCalls     []struct {
+		roomID    string
+		beforeSeq uint64
+		limit     int
+	}
+	kickCalls []struct {
+		roomID, requesterID, targetID string
+	}
+	modCalls []struct {
+		roomID, requesterID, targetID string
+		grant                         bool
+	}
+	muteCalls []struct {
+		roomID, requesterID, targetID string
+		mute                          bool
+	}
+	metaCalls []struct {
+		roomID, requesterID, topic, description string
+	}
+	renameCalls []struct {
+		userID, newName string
+	}
+	typingCalls []struct {
+		roomID, userID, userName string
+		isTyping                 bool
+	}
+	detachCalls []struct {
+		roomID, userID string
+	}
+	reattachCalls []struct {
+		roomID, userID string
+		send           chan<- interface{}
+	}
+	attachmentCalls []struct {
+		roomID, userID, contentType string
+		data                        []byte
 	}
 
-	createErr error
-	joinErr   error
-	leaveErr  error
-	sendErr   error
+	members      []messages.Member
+	history      []messages.RoomMessageEvent
+	generatedID  string // room id CreateRoomWithGeneratedID returns
+	joinOrCreate int    // joinCalls that came through JoinOrCreateRoom
+	onJoin       func() // optional; runs inside JoinRoom, as if the join took a while
+	// expiresInSeconds and requestID of each sendMsgCalls entry made through SendMessageAcked
+	expiresIn     []int
+	ackRequestIDs []string
+
+	createErr     error
+	joinErr       error
+	leaveErr      error
+	sendErr       error
+	deleteErr     error
+	reactErr      error
+	directErr     error
+	listErr       error
+	historyErr    error
+	kickErr       error
+	modErr        error
+	muteErr       error
+	metaErr       error
+	renameErr     error
+	typingErr     error
+	reattachErr   error
+	attachmentErr error
 }
 
-func (m *mockCoordinator) CreateRoom(roomID, authorID, roomName string, send chan<- interface{}) error {
+func (m *mockCoordinator) CreateRoom(roomID, authorID, roomName, password string, send chan<- interface{}) error {
 	m.createCalls = append(m.createCalls, struct {
-		roomID, authorID, roomName string
-		send                       chan<- interface{}
-	}{roomID, authorID, roomName, send})
+		roomID, authorID, roomName, password string
+		send                                 chan<- interface{}
+	}{roomID, authorID, roomName, password, send})
 	return m.createErr
 }
 
-func (m *mockCoordinator) JoinRoom(roomID, userID, userName string, send chan<- interface{}) error {
+func (m *mockCoordinator) CreateRoomWithGeneratedID(authorID, roomName, password string, send chan<- interface{}) (string, error) {
+	if err := m.CreateRoom("", authorID, roomName, password, send); err != nil {
+		return "", err
+	}
+	return m.generatedID, nil
+}
+
+func (m *mockCoordinator) JoinRoom(roomID, userID, userName, password string, send chan<- interface{}) error {
 	m.joinCalls = append(m.joinCalls, struct {
-		roomID, userID, userName string
-		send                     chan<- interface{}
-	}{roomID, userID, userName, send})
+		roomID, userID, userName, password string
+		send                               chan<- interface{}
+	}{roomID, userID, userName, password, send})
+	if m.onJoin != nil {
+		m.onJoin()
+	}
 	return m.joinErr
 }
 
+func (m *mockCoordinator) JoinOrCreateRoom(roomID, userID, userName, password string, send chan<- interface{}) error {
+	m.joinOrCreate++
+	return m.JoinRoom(roomID, userID, userName, password, send)
+}
+
 func (m *mockCoordinator) LeaveRoom(roomID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.leaveCalls = append(m.leaveCalls, struct {
 		roomID, userID string
 	}{roomID, userID})
 	return m.leaveErr
 }
 
-func (m *mockCoordinator) SendMessage(roomID, userID, content string) error {
+func (m *mockCoordinator) SendMessageAcked(roomID, userID, content, clientMsgID, parentMessageID string, expiresInSeconds int, requestID string) error {
+	m.expiresIn = append(m.expiresIn, expiresInSeconds)
+	m.ackRequestIDs = append(m.ackRequestIDs, requestID)
 	m.sendMsgCalls = append(m.sendMsgCalls, struct {
-		roomID, userID, content string
-	}{roomID, userID, content})
+		roomID, userID, content, clientMsgID, parentMessageID string
+	}{roomID, userID, content, clientMsgID, parentMessageID})
 	return m.sendErr
 }
 
+func (m *mockCoordinator) DeleteMessage(roomID, requesterID, messageID string) error {
+	m.deleteCalls = append(m.deleteCalls, struct {
+		roomID, requesterID, messageID string
+	}{roomID, requesterID, messageID})
+	return m.deleteErr
+}
+
+func (m *mockCoordinator) React(roomID, userID, messageID, emoji string) error {
+	m.reactCalls = append(m.reactCalls, struct {
+		roomID, userID, messageID, emoji string
+	}{roomID, userID, messageID, emoji})
+	return m.reactErr
+}
+
+func (m *mockCoordinator) MarkRead(roomID, userID string, seq uint64) error {
+	m.markReadCalls = append(m.markReadCalls, struct {
+		roomID, userID string
+		seq            uint64
+	}{roomID, userID, seq})
+	return nil
+}
+
+func (m *mockCoordinator) RegisterClient(userID string, send chan<- interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.registered == nil {
+		m.registered = make(map[string]int)
+	}
+	m.registered[userID]++
+}
+
+func (m *mockCoordinator) UnregisterClient(userID string, send chan<- interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.registered[userID]--
+}
+
+func (m *mockCoordinator) SubscribePresence(send chan<- interface{}) {
+	m.presenceSubs++
+}
+
+func (m *mockCoordinator) UnsubscribePresence(send chan<- interface{}) {
+	m.presenceSubs--
+}
+
+func (m *mockCoordinator) SendDirect(fromID, fromName, toID, content string) error {
+	m.directCalls = append(m.directCalls, struct {
+		fromID, fromName, toID, content string
+	}{fromID, fromName, toID, content})
+	return m.directErr
+}
+
+func (m *mockCoordinator) ListMembers(roomID string) ([]messages.Member, error) {
+	m.listMembersCalls = append(m.listMembersCalls, roomID)
+	return m.members, m.listErr
+}
+
+func (m *mockCoordinator) GetHistory(roomID string, beforeSeq uint64, limit int) ([]messages.RoomMessageEvent, error) {
+	m.historyCalls = append(m.historyCalls, struct {
+		roomID    string
+		beforeSeq uint64
+		limit     int
+	}{roomID, beforeSeq, limit})
+	return m.history, m.historyErr
+}
+
+func (m *mockCoordinator) KickUser(roomID, requesterID, targetID string) error {
+	m.kickCalls = append(m.kickCalls, struct {
+		roomID, requesterID, targetID string
+	}{roomID, requesterID, targetID})
+	return m.kickErr
+}
+
+func (m *mockCoordinator) GrantModerator(roomID, requesterID, targetID string) error {
+	return m.setModerator(roomID, requesterID, targetID, true)
+}
+
+func (m *mockCoordinator) RevokeModerator(roomID, requesterID, targetID string) error {
+	return m.setModerator(roomID, requesterID, targetID, false)
+}
+
+func (m *mockCoordinator) setModerator(roomID, requesterID, targetID string, grant bool) error {
+	m.modCalls = append(m.modCalls, struct {
+		roomID, requesterID, targetID string
+		grant                         bool
+	}{roomID, requesterID, targetID, grant})
+	return m.modErr
+}
+
+func (m *mockCoordinator) MuteUser(roomID, requesterID, targetID string) error {
+	return m.setMuted(roomID, requesterID, targetID, true)
+}
+
+func (m *mockCoordinator) UnmuteUser(roomID, requesterID, targetID string) error {
+	return m.setMuted(roomID, requesterID, targetID, false)
+}
+
+func (m *mockCoordinator) SetRoomMeta(roomID, requesterID, topic, description string) error {
+	m.metaCalls = append(m.metaCalls, struct {
+		roomID, requesterID, topic, description string
+	}{roomID, requesterID, topic, description})
+	return m.metaErr
+}
+
+func (m *mockCoordinator) setMuted(roomID, requesterID, targetID string, mute bool) error {
+	m.muteCalls = append(m.muteCalls, struct {
+		roomID, requesterID, targetID string
+		mute                          bool
+	}{roomID, requesterID, targetID, mute})
+	return m.muteErr
+}
+
+func (m *mockCoordinator) RenameUser(userID, newName string) error {
+	m.renameCalls = append(m.renameCalls, struct {
+		userID, newName string
+	}{userID, newName})
+	return m.renameErr
+}
+
+func (m *mockCoordinator) BroadcastTyping(roomID, userID, userName string, isTyping bool) error {
+	m.typingCalls = append(m.typingCalls, struct {
+		roomID, userID, userName string
+		isTyping                 bool
+	}{roomID, userID, userName, isTyping})
+	return m.typingErr
+}
+
+func (m *mockCoordinator) DetachClient(roomID, userID string) error {
+	m.detachCalls = append(m.detachCalls, struct {
+		roomID, userID string
+	}{roomID, userID})
+	return nil
+}
+
+func (m *mockCoordinator) ReattachClient(roomID, userID string, send chan<- interface{}) error {
+	m.reattachCalls = append(m.reattachCalls, struct {
+		roomID, userID string
+		send           chan<- interface{}
+	}{roomID, userID, send})
+	return m.reattachErr
+}
+
+func (m *mockCoordinator) SendAttachment(roomID, userID, userName, contentType string, data []byte) error {
+	m.attachmentCalls = append(m.attachmentCalls, struct {
+		roomID, userID, contentType string
+		data                        []byte
+	}{roomID, userID, contentType, data})
+	return m.attachmentErr
+}
+
 func newTestClientWithMock(t *testing.T, mc *mockCoordinator) *Client {
 	t.Helper()
 	// nil *websocket.Conn is fine because we only test handlers writing to c.send
@@ -71,6 +324,9 @@ func newTestClientWithMock(t *testing.T, mc *mockCoordinator) *Client {
 		rooms:       make(map[string]struct{}),
 		send:        make(chan interface{}, 32),
 		coordinator: mc,
+		cfg:         defaultClientConfig(),
+		codec:       jsonCodec{},
+		logger:      slog.New(slog.DiscardHandler),
 		ctx:         context.Background(),
 		cancel:      func() {},
 	}
@@ -102,6 +358,49 @@ func TestClientEnsureIdentity(t *testing.T) {
 	assert.Contains(t, err.Error(), "connection already bound")
 }
 
+func TestClientEnsureIdentityValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		userName string
+		wantErr  string
+	}{
+		{"oversized name", "user1", strings.Repeat("n", app.MaxIdentityLength+1), "user_name exceeds 64 characters"},
+		{"oversized id", strings.Repeat("u", app.MaxIdentityLength+1), "User One", "user_id exceeds 64 characters"},
+		{"whitespace-only name", "user1", " \t ", "user_name is blank"},
+		{"control characters only", "\x00\x1b", "User One", "user_id is blank"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClientWithMock(t, &mockCoordinator{})
+			err := c.ensureIdentity(tt.userID, tt.userName)
+			require.ErrorIs(t, err, app.ErrInvalidIdentity)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			assert.Empty(t, c.userID, "a rejected identity must not bind the connection")
+		})
+	}
+
+	t.Run("normalized", func(t *testing.T) {
+		c := newTestClientWithMock(t, &mockCoordinator{})
+		require.NoError(t, c.ensureIdentity("  user1\n", "\x1b[31mUser\u0007 One "))
+		assert.Equal(t, "user1", c.userID)
+		assert.Equal(t, "[31mUser One", c.userName)
+		require.NoError(t, c.ensureIdentity("user1 ", ""), "a bound id matches after trimming")
+	})
+
+	t.Run("join reports identity_error", func(t *testing.T) {
+		c := newTestClientWithMock(t, &mockCoordinator{})
+		c.handleJoinRoom(&messages.WsMessage{
+			Type:    messages.MessageActionTypeJoin,
+			Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room_1", UserID: "user1", UserName: "   "}),
+		})
+		errEv, ok := (<-c.send).(messages.ErrorPayload)
+		require.True(t, ok)
+		assert.Equal(t, "identity_error", errEv.Code)
+		assert.Contains(t, errEv.Message, "user_name is blank")
+	})
+}
+
 func TestClientHandleCreateRoomSuccess(t *testing.T) {
 	mc := &mockCoordinator{}
 	c := newTestClientWithMock(t, mc)
@@ -124,6 +423,18 @@ func TestClientHandleCreateRoomSuccess(t *testing.T) {
 	assert.Equal(t, "room_1", mc.createCalls[0].roomID)
 	assert.Equal(t, "user1", mc.createCalls[0].authorID)
 	assert.Equal(t, "Room One", mc.createCalls[0].roomName)
+	assert.Empty(t, mc.createCalls[0].password)
+
+	ack, ok := (<-c.send).(messages.CreateRoomSuccess)
+	require.True(t, ok)
+	assert.Equal(t, "create_room_success", ack.Type)
+	assert.Equal(t, "room_1", ack.RoomID)
+	assert.Equal(t, "user1", ack.UserID)
+
+	joined, ok := (<-c.send).(messages.JoinSuccess)
+	require.True(t, ok, "the author hears they joined, like any joiner")
+	assert.Equal(t, "room_1", joined.RoomID)
+	assert.Equal(t, "user1", joined.UserID)
 }
 
 func TestClientHandleCreateRoomError(t *testing.T) {
@@ -150,6 +461,45 @@ func TestClientHandleCreateRoomError(t *testing.T) {
 	assert.Equal(t, "create_room_error", errEv.Code)
 }
 
+func TestClientHandleCreateRoomFailureNotTracked(t *testing.T) {
+	mc := &mockCoordinator{createErr: fmt.Errorf("%w: room_1", app.ErrRoomExists)}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	c.handleCreateRoom(&messages.WsMessage{
+		Type:    messages.MessageActionTypeCreateRoom,
+		Payload: mustRaw(messages.CreateRoomPayload{RoomID: "room_1", RoomName: "Room One"}),
+	})
+
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "create_room_error", errEv.Code)
+	assert.NotContains(t, c.rooms, "room_1", "a room that wasn't created must not be left on disconnect")
+}
+
+func TestClientHandleCreateRoomGeneratedID(t *testing.T) {
+	mc := &mockCoordinator{generatedID: "3f0c9a"}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	c.handleCreateRoom(&messages.WsMessage{
+		Type:    messages.MessageActionTypeCreateRoom,
+		Payload: mustRaw(messages.CreateRoomPayload{RoomName: "Room One"}),
+	})
+
+	require.Len(t, mc.createCalls, 1)
+	assert.Empty(t, mc.createCalls[0].roomID)
+	assert.Equal(t, "Room One", mc.createCalls[0].roomName)
+	_, ok := c.rooms["3f0c9a"]
+	assert.True(t, ok, "the generated room is tracked as joined")
+	_, ok = c.rooms[""]
+	assert.False(t, ok)
+
+	ack, ok := (<-c.send).(messages.CreateRoomSuccess)
+	require.True(t, ok)
+	assert.Equal(t, "3f0c9a", ack.RoomID)
+}
+
 func TestClientHandleJoinRoomSuccess(t *testing.T) {
 	mc := &mockCoordinator{}
 	c := newTestClientWithMock(t, mc)
@@ -183,6 +533,152 @@ func TestClientHandleJoinRoomSuccess(t *testing.T) {
 	assert.Equal(t, "user1", js.UserID)
 }
 
+func TestClientHandleJoinRoomLogs(t *testing.T) {
+	var buf bytes.Buffer
+	c := newTestClientWithMock(t, &mockCoordinator{})
+	c.logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	c.handleJoinRoom(&messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room_1"}),
+	})
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "INFO", entry["level"])
+	assert.Equal(t, "join", entry["event"])
+	assert.Equal(t, "room_1", entry["room_id"])
+	assert.Equal(t, "user1", entry["user_id"])
+	assert.Equal(t, "User One", entry["user_name"])
+}
+
+func TestClientHandleJoinRoomPassesPassword(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	wsMsg := messages.WsMessage{
+		Type: messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{
+			RoomID:   "room_1",
+			Password: "s3cret",
+		}),
+	}
+
+	c.handleJoinRoom(&wsMsg)
+
+	require.Len(t, mc.joinCalls, 1)
+	assert.Equal(t, "s3cret", mc.joinCalls[0].password)
+}
+
+func TestClientHandleJoinRoomCreateIfMissing(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	c.handleJoinRoom(&messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room_1"}),
+	})
+	assert.Zero(t, mc.joinOrCreate, "plain joins must not create rooms")
+
+	c.handleJoinRoom(&messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room_2", CreateIfMissing: true}),
+	})
+	assert.Equal(t, 1, mc.joinOrCreate)
+	require.Len(t, mc.joinCalls, 2)
+	assert.Equal(t, "room_2", mc.joinCalls[1].roomID)
+}
+
+func TestClientHandleJoinRoomIgnoresPayloadIdentityOnceBound(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	// a different name is ignored; the bound name reaches the coordinator
+	c.handleJoinRoom(&messages.WsMessage{
+		Type: messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{
+			RoomID:   "room_1",
+			UserID:   "user1",
+			UserName: "Someone Else",
+		}),
+	})
+	require.Len(t, mc.joinCalls, 1)
+	assert.Equal(t, "user1", mc.joinCalls[0].userID)
+	assert.Equal(t, "User One", mc.joinCalls[0].userName)
+	<-c.send // join_success
+
+	// a different id is rejected before reaching the coordinator
+	c.handleJoinRoom(&messages.WsMessage{
+		Type: messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{
+			RoomID:   "room_2",
+			UserID:   "user2",
+			UserName: "User Two",
+		}),
+	})
+	require.Len(t, mc.joinCalls, 1)
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "identity_error", errEv.Code)
+	assert.Equal(t, "user1", c.userID)
+	assert.Equal(t, "User One", c.userName)
+	_, joined := c.rooms["room_2"]
+	assert.False(t, joined)
+}
+
+func TestClientHandleJoinRoomRequiresIdentity(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+
+	c.handleJoinRoom(&messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room_1"}),
+	})
+
+	assert.Empty(t, mc.joinCalls)
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "identity_error", errEv.Code)
+}
+
+func TestClientHandleJoinRoomErrorCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+	}{
+		{"room not found", fmt.Errorf("%w: room_1", app.ErrRoomNotFound), "room_not_found"},
+		{"already in room", fmt.Errorf("%w: user1", app.ErrUserAlreadyInRoom), "already_in_room"},
+		{"invalid password", fmt.Errorf("%w for room room_1", app.ErrInvalidPassword), "invalid_password"},
+		{"missing identity", app.ErrIdentityRequired, "identity_error"},
+		{"name taken", fmt.Errorf("%w: User One", app.ErrNameTaken), "join_room_error"},
+		{"anything else", errors.New("boom"), "join_room_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := &mockCoordinator{joinErr: tt.err}
+			c := newTestClientWithMock(t, mc)
+			require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+			c.handleJoinRoom(&messages.WsMessage{
+				Type:    messages.MessageActionTypeJoin,
+				Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room_1"}),
+			})
+
+			errEv, ok := (<-c.send).(messages.ErrorPayload)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantCode, errEv.Code)
+			assert.Equal(t, tt.err.Error(), errEv.Message)
+			_, joined := c.rooms["room_1"]
+			assert.False(t, joined)
+		})
+	}
+}
+
 func TestClientHandleJoinRoomError(t *testing.T) {
 	mc := &mockCoordinator{joinErr: errors.New("join-fail")}
 	c := newTestClientWithMock(t, mc)
@@ -206,6 +702,46 @@ func TestClientHandleJoinRoomError(t *testing.T) {
 	assert.Equal(t, "join_room_error", errEv.Code)
 }
 
+func TestClientRoomLimitPerConnection(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	c.cfg.maxRooms = 2
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	join := func(roomID string) interface{} {
+		c.handleJoinRoom(&messages.WsMessage{
+			Type:    messages.MessageActionTypeJoin,
+			Payload: mustRaw(messages.JoinRoomPayload{RoomID: roomID}),
+		})
+		return <-c.send
+	}
+	expectLimit := func(ev interface{}) {
+		t.Helper()
+		errEv, ok := ev.(messages.ErrorPayload)
+		require.True(t, ok, "got %T", ev)
+		assert.Equal(t, "room_limit_per_connection", errEv.Code)
+	}
+
+	require.IsType(t, messages.JoinSuccess{}, join("room_1"))
+	require.IsType(t, messages.JoinSuccess{}, join("room_2"))
+	expectLimit(join("room_3"))
+
+	c.handleCreateRoom(&messages.WsMessage{
+		Type:    messages.MessageActionTypeCreateRoom,
+		Payload: mustRaw(messages.CreateRoomPayload{RoomID: "room_4", RoomName: "Room Four"}),
+	})
+	expectLimit(<-c.send)
+	assert.Len(t, mc.joinCalls, 2)
+	assert.Empty(t, mc.createCalls)
+
+	c.handleLeaveRoom(&messages.WsMessage{
+		Type:    messages.MessageActionTypeLeave,
+		Payload: mustRaw(messages.LeaveRoomPayload{RoomID: "room_1"}),
+	})
+	require.IsType(t, messages.LeaveSuccess{}, <-c.send)
+	assert.IsType(t, messages.JoinSuccess{}, join("room_3"))
+}
+
 func TestClientHandleLeaveRoomSuccess(t *testing.T) {
 	mc := &mockCoordinator{}
 	c := newTestClientWithMock(t, mc)
@@ -226,6 +762,12 @@ func TestClientHandleLeaveRoomSuccess(t *testing.T) {
 
 	_, ok := c.rooms["room_1"]
 	assert.False(t, ok, "expected room_1 removed from client.rooms")
+
+	ack, ok := (<-c.send).(messages.LeaveSuccess)
+	require.True(t, ok)
+	assert.Equal(t, "leave_success", ack.Type)
+	assert.Equal(t, "room_1", ack.RoomID)
+	assert.Equal(t, "user1", ack.UserID)
 }
 
 func TestClientHandleLeaveRoomError(t *testing.T) {
@@ -248,6 +790,73 @@ func TestClientHandleLeaveRoomError(t *testing.T) {
 	assert.Equal(t, "leave_room_error", errEv.Code)
 }
 
+func TestClientHandleLeaveAll(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	for _, roomID := range []string{"room_2", "room_1"} {
+		c.dispatchMessage(&messages.WsMessage{
+			Type:    messages.MessageActionTypeJoin,
+			Payload: mustRaw(messages.JoinRoomPayload{RoomID: roomID, UserID: "user1", UserName: "User One"}),
+		})
+		_, ok := (<-c.send).(messages.JoinSuccess)
+		require.True(t, ok)
+	}
+
+	c.dispatchMessage(&messages.WsMessage{Type: messages.MessageActionTypeLeaveAll, RequestID: "req-1"})
+
+	require.Len(t, mc.leaveCalls, 2)
+	assert.Empty(t, c.rooms)
+	ack, ok := (<-c.send).(messages.LeaveAllSuccess)
+	require.True(t, ok)
+	assert.Equal(t, "leave_all_success", ack.Type)
+	assert.Equal(t, "user1", ack.UserID)
+	assert.Equal(t, []string{"room_1", "room_2"}, ack.RoomIDs)
+	assert.Equal(t, "req-1", ack.RequestID)
+
+	// with nothing left to leave the list is empty
+	c.dispatchMessage(&messages.WsMessage{Type: messages.MessageActionTypeLeaveAll})
+	ack, ok = (<-c.send).(messages.LeaveAllSuccess)
+	require.True(t, ok)
+	assert.Empty(t, ack.RoomIDs)
+	assert.Len(t, mc.leaveCalls, 2)
+}
+
+func TestClientHandleLeaveAllKeepsRoomsItCouldNotLeave(t *testing.T) {
+	mc := &mockCoordinator{leaveErr: errors.New("leave-fail")}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.handleLeaveAll()
+
+	ack, ok := (<-c.send).(messages.LeaveAllSuccess)
+	require.True(t, ok)
+	assert.Empty(t, ack.RoomIDs)
+	assert.Contains(t, c.rooms, "room_1")
+}
+
+func TestClientHandleLeaveRoomAlreadyGone(t *testing.T) {
+	for _, leaveErr := range []error{
+		fmt.Errorf("%w: room_1", app.ErrRoomNotFound),
+		fmt.Errorf("%w: user1 in room_1", app.ErrUserNotInRoom),
+	} {
+		mc := &mockCoordinator{leaveErr: leaveErr}
+		c := newTestClientWithMock(t, mc)
+		require.NoError(t, c.ensureIdentity("user1", "User One"))
+		c.rooms["room_1"] = struct{}{}
+
+		c.handleLeaveRoom(&messages.WsMessage{
+			Type:    messages.MessageActionTypeLeave,
+			Payload: mustRaw(messages.LeaveRoomPayload{RoomID: "room_1"}),
+		})
+
+		errEv, ok := (<-c.send).(messages.ErrorPayload)
+		require.True(t, ok)
+		assert.Equal(t, "leave_room_error", errEv.Code)
+		assert.NotContains(t, c.rooms, "room_1", "a room that is gone is not tracked again")
+	}
+}
+
 func TestClientHandleLeaveRoomNotInRoom(t *testing.T) {
 	mc := &mockCoordinator{}
 	c := newTestClientWithMock(t, mc)
@@ -291,81 +900,542 @@ func TestClientHandleChatMessageSuccess(t *testing.T) {
 	assert.Equal(t, "hello", mc.sendMsgCalls[0].content)
 }
 
-func TestClientHandleChatMessageNoRoomID(t *testing.T) {
-	mc := &mockCoordinator{}
+func TestClientHandleChatMessageReplyParentNotFound(t *testing.T) {
+	mc := &mockCoordinator{sendErr: errors.New("parent not found")}
 	c := newTestClientWithMock(t, mc)
 	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
 
-	payload := messages.MessagePayload{
-		RoomID:  "",
-		Message: "hello",
-	}
-	wsMsg := messages.WsMessage{
+	c.handleChatMessage(&messages.WsMessage{
 		Type:    messages.MessageActionTypeMessage,
-		Payload: mustRaw(payload),
-	}
-
-	c.handleChatMessage(&wsMsg)
+		Payload: mustRaw(messages.MessagePayload{RoomID: "room_1", Message: "re", ParentMessageID: "m1"}),
+	})
 
-	ev := <-c.send
-	errEv, ok := ev.(messages.ErrorPayload)
+	require.Len(t, mc.sendMsgCalls, 1)
+	assert.Equal(t, "m1", mc.sendMsgCalls[0].parentMessageID)
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
 	require.True(t, ok)
 	assert.Equal(t, "message_error", errEv.Code)
-	assert.Equal(t, "room_id is required", errEv.Message)
+	assert.Equal(t, "parent not found", errEv.Message)
 }
 
-func TestClientHandleChatMessageNotInRoom(t *testing.T) {
-	mc := &mockCoordinator{}
+func TestClientHandleChatMessageDuplicateAck(t *testing.T) {
+	mc := &mockCoordinator{sendErr: fmt.Errorf("send: %w", app.ErrDuplicateMessage)}
 	c := newTestClientWithMock(t, mc)
 	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
 
-	payload := messages.MessagePayload{
-		RoomID:  "room_1",
-		Message: "hello",
-	}
-	wsMsg := messages.WsMessage{
+	c.handleChatMessage(&messages.WsMessage{
 		Type:    messages.MessageActionTypeMessage,
-		Payload: mustRaw(payload),
-	}
+		Payload: mustRaw(messages.MessagePayload{RoomID: "room_1", Message: "hello", ClientMsgID: "k1"}),
+	})
 
-	c.handleChatMessage(&wsMsg)
+	require.Len(t, mc.sendMsgCalls, 1)
+	assert.Equal(t, "k1", mc.sendMsgCalls[0].clientMsgID)
 
-	ev := <-c.send
-	errEv, ok := ev.(messages.ErrorPayload)
-	require.True(t, ok)
-	assert.Equal(t, "message_error", errEv.Code)
-	assert.Equal(t, "not in this room", errEv.Message)
+	ack, ok := (<-c.send).(messages.DuplicateMessageAck)
+	require.True(t, ok, "duplicate should be acked, not reported as an error")
+	assert.Equal(t, "message_duplicate", ack.Type)
+	assert.Equal(t, "room_1", ack.RoomID)
+	assert.Equal(t, "k1", ack.ClientMsgID)
 }
 
-func TestClientHandleChatMessageCoordinatorError(t *testing.T) {
-	mc := &mockCoordinator{sendErr: errors.New("send-fail")}
+func TestClientHandleDelete(t *testing.T) {
+	mc := &mockCoordinator{}
 	c := newTestClientWithMock(t, mc)
 	require.NoError(t, c.ensureIdentity("user1", "User One"))
 	c.rooms["room_1"] = struct{}{}
 
-	payload := messages.MessagePayload{
-		RoomID:  "room_1",
-		Message: "hello",
+	c.handleDelete(&messages.WsMessage{
+		Type:    messages.MessageActionTypeDelete,
+		Payload: mustRaw(messages.DeletePayload{RoomID: "room_1", MessageID: "m1"}),
+	})
+
+	require.Len(t, mc.deleteCalls, 1)
+	assert.Equal(t, "room_1", mc.deleteCalls[0].roomID)
+	assert.Equal(t, "user1", mc.deleteCalls[0].requesterID)
+	assert.Equal(t, "m1", mc.deleteCalls[0].messageID)
+	assert.Empty(t, c.send)
+}
+
+func TestClientHandleDeleteErrors(t *testing.T) {
+	mc := &mockCoordinator{deleteErr: errors.New("only the message author, room author or a moderator can delete messages")}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	tests := []struct {
+		name    string
+		payload messages.DeletePayload
+	}{
+		{name: "missing message id", payload: messages.DeletePayload{RoomID: "room_1"}},
+		{name: "not in room", payload: messages.DeletePayload{RoomID: "room_2", MessageID: "m1"}},
+		{name: "coordinator refuses", payload: messages.DeletePayload{RoomID: "room_1", MessageID: "m1"}},
 	}
-	wsMsg := messages.WsMessage{
-		Type:    messages.MessageActionTypeMessage,
-		Payload: mustRaw(payload),
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.handleDelete(&messages.WsMessage{
+				Type:    messages.MessageActionTypeDelete,
+				Payload: mustRaw(tt.payload),
+			})
+			errEv, ok := (<-c.send).(messages.ErrorPayload)
+			require.True(t, ok)
+			assert.Equal(t, "delete_error", errEv.Code)
+		})
 	}
+	assert.Len(t, mc.deleteCalls, 1)
+}
 
-	c.handleChatMessage(&wsMsg)
+func TestClientHandleDeleteEditWindowExpired(t *testing.T) {
+	mc := &mockCoordinator{deleteErr: fmt.Errorf("%w: message m1", app.ErrEditWindowExpired)}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
 
-	ev := <-c.send
-	errEv, ok := ev.(messages.ErrorPayload)
+	c.handleDelete(&messages.WsMessage{
+		Type:    messages.MessageActionTypeDelete,
+		Payload: mustRaw(messages.DeletePayload{RoomID: "room_1", MessageID: "m1"}),
+	})
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
 	require.True(t, ok)
-	assert.Equal(t, "message_error", errEv.Code)
+	assert.Equal(t, "edit_window_expired", errEv.Code)
 }
 
-func TestClientCleanupLeavesAllRooms(t *testing.T) {
+func TestClientHandleReact(t *testing.T) {
 	mc := &mockCoordinator{}
 	c := newTestClientWithMock(t, mc)
-	c.userID = "user1"
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
 	c.rooms["room_1"] = struct{}{}
-	c.rooms["room_2"] = struct{}{}
+
+	c.handleReact(&messages.WsMessage{
+		Type:    messages.MessageActionTypeReact,
+		Payload: mustRaw(messages.ReactPayload{RoomID: "room_1", MessageID: "m1", Emoji: "🎉"}),
+	})
+	require.Len(t, mc.reactCalls, 1)
+	assert.Equal(t, "user1", mc.reactCalls[0].userID)
+	assert.Equal(t, "🎉", mc.reactCalls[0].emoji)
+
+	mc.reactErr = errors.New("emoji must be a single character")
+	c.handleReact(&messages.WsMessage{
+		Type:    messages.MessageActionTypeReact,
+		Payload: mustRaw(messages.ReactPayload{RoomID: "room_1", MessageID: "m1", Emoji: "ab"}),
+	})
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "react_error", errEv.Code)
+}
+
+func TestClientHandleDirectMessage(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+
+	c.handleDirectMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeDirectMessage,
+		Payload: mustRaw(messages.DirectMessagePayload{ToUserID: "user2", Message: "hi"}),
+	})
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "identity_error", errEv.Code)
+
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.handleDirectMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeDirectMessage,
+		Payload: mustRaw(messages.DirectMessagePayload{ToUserID: "user2", Message: "hi"}),
+	})
+	require.Len(t, mc.directCalls, 1)
+	assert.Equal(t, "user1", mc.directCalls[0].fromID)
+	assert.Equal(t, "User One", mc.directCalls[0].fromName)
+	assert.Equal(t, "user2", mc.directCalls[0].toID)
+
+	mc.directErr = errors.New("user user3 is offline")
+	c.handleDirectMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeDirectMessage,
+		Payload: mustRaw(messages.DirectMessagePayload{ToUserID: "user3", Message: "hi"}),
+	})
+	errEv, ok = (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "direct_message_error", errEv.Code)
+}
+
+func TestClientRegistersOnlineOnceIdentified(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	assert.Equal(t, 1, mc.registered["user1"], "identity binds once")
+
+	c.cleanup()
+	assert.Equal(t, 0, mc.registered["user1"])
+	assert.False(t, c.online)
+}
+
+func TestClientSubscribePresence(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+
+	sub := &messages.WsMessage{Type: messages.MessageActionTypeSubscribePresence}
+	c.dispatchMessage(sub)
+	c.dispatchMessage(sub)
+	assert.Equal(t, 1, mc.presenceSubs, "subscribing twice is a no-op")
+
+	// anonymous connections are unsubscribed too
+	c.cleanup()
+	assert.Equal(t, 0, mc.presenceSubs)
+}
+
+func TestClientHandleChatMessageNoRoomID(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	payload := messages.MessagePayload{
+		RoomID:  "",
+		Message: "hello",
+	}
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeMessage,
+		Payload: mustRaw(payload),
+	}
+
+	c.handleChatMessage(&wsMsg)
+
+	ev := <-c.send
+	errEv, ok := ev.(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "message_error", errEv.Code)
+	assert.Equal(t, "room_id is required", errEv.Message)
+}
+
+func TestClientHandleChatMessageNotInRoom(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	payload := messages.MessagePayload{
+		RoomID:  "room_1",
+		Message: "hello",
+	}
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeMessage,
+		Payload: mustRaw(payload),
+	}
+
+	c.handleChatMessage(&wsMsg)
+
+	ev := <-c.send
+	errEv, ok := ev.(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "message_error", errEv.Code)
+	assert.Equal(t, "not in this room", errEv.Message)
+}
+
+func TestClientHandleChatMessageCoordinatorError(t *testing.T) {
+	mc := &mockCoordinator{sendErr: errors.New("send-fail")}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	payload := messages.MessagePayload{
+		RoomID:  "room_1",
+		Message: "hello",
+	}
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeMessage,
+		Payload: mustRaw(payload),
+	}
+
+	c.handleChatMessage(&wsMsg)
+
+	ev := <-c.send
+	errEv, ok := ev.(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "message_error", errEv.Code)
+}
+
+func TestClientHandleChatMessageContentRejected(t *testing.T) {
+	mc := &mockCoordinator{sendErr: app.ErrContentRejected}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.handleChatMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeMessage,
+		Payload: mustRaw(messages.MessagePayload{RoomID: "room_1", Message: "darn"}),
+	})
+
+	ev := <-c.send
+	errEv, ok := ev.(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "content_rejected", errEv.Code)
+}
+
+func TestClientHandleMarkRead(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	msg := &messages.WsMessage{
+		Type:    messages.MessageActionTypeMarkRead,
+		Payload: mustRaw(messages.MarkReadPayload{RoomID: "room_1", Seq: 7}),
+	}
+	c.dispatchMessage(msg)
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "mark_read_error", errEv.Code)
+	assert.Empty(t, mc.markReadCalls)
+
+	c.rooms["room_1"] = struct{}{}
+	c.dispatchMessage(msg)
+	require.Len(t, mc.markReadCalls, 1)
+	assert.Equal(t, "user1", mc.markReadCalls[0].userID)
+	assert.Equal(t, uint64(7), mc.markReadCalls[0].seq)
+}
+
+func TestClientHandleChatMessageExpiresIn(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.handleChatMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeMessage,
+		Payload: mustRaw(messages.MessagePayload{RoomID: "room_1", Message: "hello"}),
+	})
+	c.handleChatMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeMessage,
+		Payload: mustRaw(messages.MessagePayload{RoomID: "room_1", Message: "psst", ExpiresInSeconds: 30}),
+	})
+
+	require.Len(t, mc.sendMsgCalls, 2)
+	assert.Equal(t, []int{0, 30}, mc.expiresIn)
+}
+
+func TestClientHandleChatMessagePassesRequestIDForAck(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.dispatchMessage(&messages.WsMessage{
+		Type:      messages.MessageActionTypeMessage,
+		RequestID: "req-1",
+		Payload:   mustRaw(messages.MessagePayload{RoomID: "room_1", Message: "hello"}),
+	})
+
+	assert.Equal(t, []string{"req-1"}, mc.ackRequestIDs)
+}
+
+func TestClientHandleChatMessageTooLongDetails(t *testing.T) {
+	mc := &mockCoordinator{sendErr: &app.ContentTooLongError{Limit: 10240, Size: 12000}}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.handleChatMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeMessage,
+		Payload: mustRaw(messages.MessagePayload{RoomID: "room_1", Message: "hello"}),
+	})
+
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "message_error", errEv.Code)
+	assert.Equal(t, map[string]interface{}{"limit": 10240, "size": 12000}, errEv.Details)
+}
+
+func TestClientHandleChatMessageRoomBusy(t *testing.T) {
+	mc := &mockCoordinator{sendErr: app.ErrRoomBusy}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.handleChatMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeMessage,
+		Payload: mustRaw(messages.MessagePayload{RoomID: "room_1", Message: "hello"}),
+	})
+
+	ev := <-c.send
+	errEv, ok := ev.(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "room_busy", errEv.Code)
+}
+
+func TestClientHandleChatMessageRateLimited(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	c.limiter = newTokenBucket(1, 3)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeMessage,
+		Payload: mustRaw(messages.MessagePayload{RoomID: "room_1", Message: "spam"}),
+	}
+
+	for i := 0; i < 6; i++ {
+		c.handleChatMessage(&wsMsg)
+	}
+
+	// only the burst reaches the coordinator; the rest are rejected
+	assert.Len(t, mc.sendMsgCalls, 3)
+
+	rejected := 0
+	for len(c.send) > 0 {
+		errEv, ok := (<-c.send).(messages.ErrorPayload)
+		require.True(t, ok)
+		assert.Equal(t, "rate_limited", errEv.Code)
+		rejected++
+	}
+	assert.Equal(t, 3, rejected)
+}
+
+func TestClientJoinAfterDisconnectIsDropped(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ctx, c.cancel = ctx, cancel
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	// the payload is parsed, then the connection drops before the join is enqueued
+	msg := &messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room_1"}),
+	}
+	cancel()
+	c.handleJoinRoom(msg)
+	c.cleanup()
+
+	assert.Empty(t, mc.joinCalls, "the coordinator must not see the join, so nothing is broadcast")
+	assert.Empty(t, mc.leaveCalls, "cleanup must not leave a room that was never joined")
+	assert.NotContains(t, c.rooms, "room_1")
+}
+
+func TestClientEchoesRequestID(t *testing.T) {
+	mc := &mockCoordinator{joinErr: fmt.Errorf("%w: room_9", app.ErrRoomNotFound)}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.dispatchMessage(&messages.WsMessage{
+		Type:      messages.MessageActionTypeJoin,
+		Payload:   mustRaw(messages.JoinRoomPayload{RoomID: "room_9"}),
+		RequestID: "req-1",
+	})
+	c.dispatchMessage(&messages.WsMessage{
+		Type:      messages.MessageActionTypeMessage,
+		Payload:   mustRaw(messages.MessagePayload{RoomID: "room_2", Message: "hi"}),
+		RequestID: "req-2",
+	})
+	c.dispatchMessage(&messages.WsMessage{
+		Type:      messages.MessageActionTypeLeave,
+		Payload:   mustRaw(messages.LeaveRoomPayload{RoomID: "room_1"}),
+		RequestID: "req-3",
+	})
+	c.dispatchMessage(&messages.WsMessage{Type: messages.MessageActionTypePing})
+
+	first, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "room_not_found", first.Code)
+	assert.Equal(t, "req-1", first.RequestID)
+
+	second, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "req-2", second.RequestID)
+
+	ack, ok := (<-c.send).(messages.LeaveSuccess)
+	require.True(t, ok)
+	assert.Equal(t, "req-3", ack.RequestID)
+
+	pong, ok := (<-c.send).(messages.Pong)
+	require.True(t, ok)
+	assert.Empty(t, pong.RequestID, "a message without a request id gets a reply without one")
+}
+
+func TestClientHandleWhoAmI(t *testing.T) {
+	c := newTestClientWithMock(t, &mockCoordinator{})
+	whoami := &messages.WsMessage{Type: messages.MessageActionTypeWhoAmI}
+
+	// unbound connections get empty fields, not an error
+	c.dispatchMessage(whoami)
+	info, ok := (<-c.send).(messages.SessionInfoEvent)
+	require.True(t, ok)
+	assert.Equal(t, "session_info", info.Type)
+	assert.Empty(t, info.UserID)
+	assert.Empty(t, info.UserName)
+	assert.Empty(t, info.Rooms)
+
+	for _, roomID := range []string{"room_b", "room_a"} {
+		c.handleJoinRoom(&messages.WsMessage{
+			Type:    messages.MessageActionTypeJoin,
+			Payload: mustRaw(messages.JoinRoomPayload{RoomID: roomID, UserID: "user1", UserName: "User One"}),
+		})
+		_, ok := (<-c.send).(messages.JoinSuccess)
+		require.True(t, ok)
+	}
+
+	c.dispatchMessage(whoami)
+	info, ok = (<-c.send).(messages.SessionInfoEvent)
+	require.True(t, ok)
+	assert.Equal(t, "user1", info.UserID)
+	assert.Equal(t, "User One", info.UserName)
+	assert.Equal(t, []string{"room_a", "room_b"}, info.Rooms)
+}
+
+func TestClientDisconnectDuringJoinLeavesRoom(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.ctx, c.cancel = ctx, cancel
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	// the connection drops after the disconnect check, while the join is in flight
+	mc.onJoin = c.cleanup
+	c.handleJoinRoom(&messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room_1"}),
+	})
+
+	require.Len(t, mc.joinCalls, 1)
+	require.Len(t, mc.leaveCalls, 1, "the join cleanup missed must be undone")
+	assert.Equal(t, "room_1", mc.leaveCalls[0].roomID)
+	assert.NotContains(t, c.rooms, "room_1")
+	assert.Empty(t, c.send, "no join_success for a connection that is gone")
+}
+
+func TestClientJoinRacingDisconnectLeavesNoMembership(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		mc := &mockCoordinator{}
+		c := newTestClientWithMock(t, mc)
+		ctx, cancel := context.WithCancel(context.Background())
+		c.ctx, c.cancel = ctx, cancel
+		require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.cleanup()
+		}()
+		c.handleJoinRoom(&messages.WsMessage{
+			Type:    messages.MessageActionTypeJoin,
+			Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room_1"}),
+		})
+		wg.Wait()
+
+		mc.mu.Lock()
+		left := len(mc.leaveCalls)
+		mc.mu.Unlock()
+		assert.Equal(t, len(mc.joinCalls), left, "every join is matched by a leave")
+		assert.Empty(t, c.rooms)
+	}
+}
+
+func TestClientCleanupLeavesAllRooms(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	c.userID = "user1"
+	c.rooms["room_1"] = struct{}{}
+	c.rooms["room_2"] = struct{}{}
 
 	c.cleanup()
 
@@ -374,3 +1444,460 @@ func TestClientCleanupLeavesAllRooms(t *testing.T) {
 	assert.Contains(t, roomIDs, "room_1")
 	assert.Contains(t, roomIDs, "room_2")
 }
+
+func TestClientHandleListMembersSuccess(t *testing.T) {
+	mc := &mockCoordinator{members: []messages.Member{
+		{UserID: "user1", UserName: "User One"},
+		{UserID: "user2", UserName: "User Two"},
+	}}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeListMembers,
+		Payload: mustRaw(messages.ListMembersPayload{RoomID: "room_1"}),
+	}
+
+	c.handleListMembers(&wsMsg)
+
+	require.Equal(t, []string{"room_1"}, mc.listMembersCalls)
+
+	ev := <-c.send
+	list, ok := ev.(messages.MembersListEvent)
+	require.True(t, ok)
+	assert.Equal(t, messages.EventMembersList, list.Type)
+	assert.Equal(t, "room_1", list.RoomID)
+	assert.Equal(t, mc.members, list.Members)
+}
+
+func TestClientHandleListMembersNotInRoom(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeListMembers,
+		Payload: mustRaw(messages.ListMembersPayload{RoomID: "room_1"}),
+	}
+
+	c.handleListMembers(&wsMsg)
+
+	assert.Empty(t, mc.listMembersCalls)
+
+	ev := <-c.send
+	errEv, ok := ev.(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "list_members_error", errEv.Code)
+	assert.Equal(t, "not in this room", errEv.Message)
+}
+
+func TestClientHandleHistory(t *testing.T) {
+	mc := &mockCoordinator{history: []messages.RoomMessageEvent{{Seq: 9}, {Seq: 8}}}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.handleHistory(&messages.WsMessage{
+		Type:    messages.MessageActionTypeHistory,
+		Payload: mustRaw(messages.HistoryPayload{RoomID: "room_1", BeforeSeq: 10, Limit: 2}),
+	})
+
+	require.Len(t, mc.historyCalls, 1)
+	assert.Equal(t, uint64(10), mc.historyCalls[0].beforeSeq)
+	assert.Equal(t, 2, mc.historyCalls[0].limit)
+
+	batch, ok := (<-c.send).(messages.HistoryBatchEvent)
+	require.True(t, ok)
+	assert.Equal(t, messages.EventHistoryBatch, batch.Type)
+	assert.Equal(t, mc.history, batch.Messages)
+
+	// only members may page through a room
+	c.handleHistory(&messages.WsMessage{
+		Type:    messages.MessageActionTypeHistory,
+		Payload: mustRaw(messages.HistoryPayload{RoomID: "room_2"}),
+	})
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "history_error", errEv.Code)
+	assert.Len(t, mc.historyCalls, 1)
+}
+
+func TestClientHandleKickSuccess(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("author1", "Author"))
+	c.rooms["room_1"] = struct{}{}
+
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeKick,
+		Payload: mustRaw(messages.KickPayload{RoomID: "room_1", TargetUserID: "user2"}),
+	}
+
+	c.handleKick(&wsMsg)
+
+	require.Len(t, mc.kickCalls, 1)
+	assert.Equal(t, "room_1", mc.kickCalls[0].roomID)
+	assert.Equal(t, "author1", mc.kickCalls[0].requesterID)
+	assert.Equal(t, "user2", mc.kickCalls[0].targetID)
+	assert.Empty(t, c.send)
+}
+
+func TestClientHandleKickPermissionError(t *testing.T) {
+	mc := &mockCoordinator{kickErr: errors.New("only the room author or a moderator can kick users")}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user2", "User Two"))
+	c.rooms["room_1"] = struct{}{}
+
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeKick,
+		Payload: mustRaw(messages.KickPayload{RoomID: "room_1", TargetUserID: "user3"}),
+	}
+
+	c.handleKick(&wsMsg)
+
+	ev := <-c.send
+	errEv, ok := ev.(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "kick_error", errEv.Code)
+	assert.Equal(t, "only the room author or a moderator can kick users", errEv.Message)
+}
+
+func TestClientHandleModerator(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("author1", "Author"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.dispatchMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeGrantMod,
+		Payload: mustRaw(messages.ModeratorPayload{RoomID: "room_1", TargetUserID: "user2"}),
+	})
+	c.dispatchMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeRevokeMod,
+		Payload: mustRaw(messages.ModeratorPayload{RoomID: "room_1", TargetUserID: "user2"}),
+	})
+
+	require.Len(t, mc.modCalls, 2)
+	assert.Equal(t, "author1", mc.modCalls[0].requesterID)
+	assert.Equal(t, "user2", mc.modCalls[0].targetID)
+	assert.True(t, mc.modCalls[0].grant)
+	assert.False(t, mc.modCalls[1].grant)
+	assert.Empty(t, c.send)
+
+	// rooms the connection isn't in, and refusals, come back as moderator_error
+	c.dispatchMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeGrantMod,
+		Payload: mustRaw(messages.ModeratorPayload{RoomID: "room_2", TargetUserID: "user2"}),
+	})
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "moderator_error", errEv.Code)
+	assert.Len(t, mc.modCalls, 2)
+
+	mc.modErr = errors.New("only the room author or a moderator can change moderators")
+	c.dispatchMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeGrantMod,
+		Payload: mustRaw(messages.ModeratorPayload{RoomID: "room_1", TargetUserID: "user3"}),
+	})
+	errEv, ok = (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "moderator_error", errEv.Code)
+	assert.Equal(t, "only the room author or a moderator can change moderators", errEv.Message)
+}
+
+func TestClientHandleMute(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("author1", "Author"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.dispatchMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeMute,
+		Payload: mustRaw(messages.MutePayload{RoomID: "room_1", TargetUserID: "user2"}),
+	})
+	c.dispatchMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeUnmute,
+		Payload: mustRaw(messages.MutePayload{RoomID: "room_1", TargetUserID: "user2"}),
+	})
+
+	require.Len(t, mc.muteCalls, 2)
+	assert.Equal(t, "author1", mc.muteCalls[0].requesterID)
+	assert.Equal(t, "user2", mc.muteCalls[0].targetID)
+	assert.True(t, mc.muteCalls[0].mute)
+	assert.False(t, mc.muteCalls[1].mute)
+	assert.Empty(t, c.send)
+
+	mc.muteErr = errors.New("only the room author or a moderator can mute users")
+	c.dispatchMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeMute,
+		Payload: mustRaw(messages.MutePayload{RoomID: "room_1", TargetUserID: "user3"}),
+	})
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "mute_error", errEv.Code)
+}
+
+func TestClientHandleSetRoomMeta(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("author1", "Author"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.dispatchMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeSetRoomMeta,
+		Payload: mustRaw(messages.RoomMetaPayload{RoomID: "room_1", Topic: "Release planning", Description: "Weekly sync"}),
+	})
+
+	require.Len(t, mc.metaCalls, 1)
+	assert.Equal(t, "room_1", mc.metaCalls[0].roomID)
+	assert.Equal(t, "author1", mc.metaCalls[0].requesterID)
+	assert.Equal(t, "Release planning", mc.metaCalls[0].topic)
+	assert.Equal(t, "Weekly sync", mc.metaCalls[0].description)
+	assert.Empty(t, c.send)
+
+	// rooms the connection isn't in are refused before the coordinator
+	c.dispatchMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeSetRoomMeta,
+		Payload: mustRaw(messages.RoomMetaPayload{RoomID: "room_2", Topic: "Elsewhere"}),
+	})
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "room_meta_error", errEv.Code)
+	assert.Len(t, mc.metaCalls, 1)
+
+	mc.metaErr = errors.New("only the room author or a moderator can change room metadata")
+	c.dispatchMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeSetRoomMeta,
+		Payload: mustRaw(messages.RoomMetaPayload{RoomID: "room_1", Topic: "Mine now"}),
+	})
+	errEv, ok = (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "room_meta_error", errEv.Code)
+	assert.Contains(t, errEv.Message, "only the room author or a moderator")
+}
+
+func TestClientHandleChatMessageMuted(t *testing.T) {
+	mc := &mockCoordinator{sendErr: fmt.Errorf("%w: user1 in room_1", app.ErrMuted)}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	c.handleChatMessage(&messages.WsMessage{
+		Type:    messages.MessageActionTypeMessage,
+		Payload: mustRaw(messages.MessagePayload{RoomID: "room_1", Message: "hello"}),
+	})
+
+	errEv, ok := (<-c.send).(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "muted", errEv.Code)
+}
+
+func TestClientHandleRenameSuccess(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeRename,
+		Payload: mustRaw(messages.RenamePayload{NewName: "Renamed"}),
+	}
+
+	c.handleRename(&wsMsg)
+
+	require.Len(t, mc.renameCalls, 1)
+	assert.Equal(t, "user1", mc.renameCalls[0].userID)
+	assert.Equal(t, "Renamed", mc.renameCalls[0].newName)
+	assert.Equal(t, "Renamed", c.userName)
+}
+
+func TestClientHandleRenameError(t *testing.T) {
+	mc := &mockCoordinator{renameErr: errors.New("rename-fail")}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeRename,
+		Payload: mustRaw(messages.RenamePayload{NewName: "Renamed"}),
+	}
+
+	c.handleRename(&wsMsg)
+
+	ev := <-c.send
+	errEv, ok := ev.(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "rename_error", errEv.Code)
+	assert.Equal(t, "User One", c.userName, "name must not change when rename fails")
+}
+
+func TestClientHandleTyping(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+	c.rooms["room_1"] = struct{}{}
+
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeTyping,
+		Payload: mustRaw(messages.TypingPayload{RoomID: "room_1", IsTyping: true}),
+	}
+
+	c.handleTyping(&wsMsg)
+
+	require.Len(t, mc.typingCalls, 1)
+	assert.Equal(t, "room_1", mc.typingCalls[0].roomID)
+	assert.Equal(t, "user1", mc.typingCalls[0].userID)
+	assert.Equal(t, "User One", mc.typingCalls[0].userName)
+	assert.True(t, mc.typingCalls[0].isTyping)
+}
+
+func TestClientHandleTypingNotInRoom(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeTyping,
+		Payload: mustRaw(messages.TypingPayload{RoomID: "room_1", IsTyping: true}),
+	}
+
+	c.handleTyping(&wsMsg)
+
+	assert.Empty(t, mc.typingCalls)
+
+	ev := <-c.send
+	errEv, ok := ev.(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "typing_error", errEv.Code)
+}
+
+func TestClientIssuesResumeTokenOnIdentity(t *testing.T) {
+	c := newTestClientWithMock(t, &mockCoordinator{})
+	c.sessions = newSessionStore(time.Second)
+
+	require.NoError(t, c.ensureIdentity("user1", "User One"))
+
+	ev := <-c.send
+	sessEv, ok := ev.(messages.SessionEvent)
+	require.True(t, ok)
+	assert.Equal(t, "user1", sessEv.UserID)
+	assert.NotEmpty(t, sessEv.ResumeToken)
+	assert.Equal(t, c.resumeToken, sessEv.ResumeToken)
+}
+
+func TestClientCleanupParksSessionWhenResumeEnabled(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	c.sessions = newSessionStore(time.Second)
+	c.userID = "user1"
+	c.resumeToken = "tok"
+	c.rooms["room_1"] = struct{}{}
+
+	c.cleanup()
+
+	assert.Empty(t, mc.leaveCalls, "parked client should not leave its rooms")
+	require.Len(t, mc.detachCalls, 1)
+	assert.Equal(t, "room_1", mc.detachCalls[0].roomID)
+
+	sess, ok := c.sessions.take("tok")
+	require.True(t, ok)
+	assert.Equal(t, []string{"room_1"}, sess.rooms)
+}
+
+func TestClientCleanupLeavesRoomsWhenResumeExpires(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	c.sessions = newSessionStore(10 * time.Millisecond)
+	c.userID = "user1"
+	c.resumeToken = "tok"
+	c.rooms["room_1"] = struct{}{}
+
+	c.cleanup()
+
+	// expiry runs on a timer goroutine, so read the mock under its lock
+	require.Eventually(t, func() bool {
+		mc.mu.Lock()
+		defer mc.mu.Unlock()
+		return len(mc.leaveCalls) == 1 && mc.leaveCalls[0].roomID == "room_1"
+	}, time.Second, 5*time.Millisecond)
+
+	_, ok := c.sessions.take("tok")
+	assert.False(t, ok, "expired session must not be resumable")
+}
+
+func TestClientHandleResumeSuccess(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	c.sessions = newSessionStore(time.Second)
+	c.sessions.park("tok", &parkedSession{
+		userID:   "user1",
+		userName: "User One",
+		rooms:    []string{"room_1", "room_2"},
+	}, func(*parkedSession) {})
+
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeResume,
+		Payload: mustRaw(messages.ResumePayload{ResumeToken: "tok"}),
+	}
+
+	c.handleResume(&wsMsg)
+
+	assert.Equal(t, "user1", c.userID)
+	assert.Equal(t, "User One", c.userName)
+	assert.Contains(t, c.rooms, "room_1")
+	assert.Contains(t, c.rooms, "room_2")
+	require.Len(t, mc.reattachCalls, 2)
+	assert.Empty(t, mc.joinCalls, "resume must not trigger a fresh join")
+
+	ev := <-c.send
+	rs, ok := ev.(messages.ResumeSuccess)
+	require.True(t, ok)
+	assert.ElementsMatch(t, []string{"room_1", "room_2"}, rs.Rooms)
+}
+
+func TestClientHandleResumeUnknownToken(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	c.sessions = newSessionStore(time.Second)
+
+	wsMsg := messages.WsMessage{
+		Type:    messages.MessageActionTypeResume,
+		Payload: mustRaw(messages.ResumePayload{ResumeToken: "nope"}),
+	}
+
+	c.handleResume(&wsMsg)
+
+	ev := <-c.send
+	errEv, ok := ev.(messages.ErrorPayload)
+	require.True(t, ok)
+	assert.Equal(t, "resume_error", errEv.Code)
+	assert.Empty(t, mc.reattachCalls)
+}
+
+func TestClientQueueAfterDisconnect(t *testing.T) {
+	mc := &mockCoordinator{}
+	c := newTestClientWithMock(t, mc)
+	c.send = make(chan interface{}, 1) // no writePump drains it
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	ping := &messages.WsMessage{Type: messages.MessageActionTypePing, Payload: json.RawMessage("null")}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// replies outnumber the buffer, so this blocks until the disconnect
+		for i := 0; i < 1000; i++ {
+			c.dispatchMessage(ping)
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	c.disconnect()
+	c.disconnect() // idempotent
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "handlers kept blocking on send after disconnect")
+	}
+	assert.False(t, c.queue(messages.Pong{Type: "pong"}))
+}
@@ -0,0 +1,53 @@
+package server
+
+import "github.com/arturskrzydlo/chat-room/internal/messages"
+
+// coalesceKey identifies a stream of state updates in which only the latest
+// matters to the client: a user's typing state in a room, or their presence
+type coalesceKey struct {
+	typ    messages.EventType
+	roomID string
+	userID string
+}
+
+// coalesceKeyOf returns msg's key if it is a typing or presence event. Chat
+// messages and everything else are never coalesced, nor are remote events,
+// which arrive already encoded.
+func coalesceKeyOf(msg interface{}) (coalesceKey, bool) {
+	switch ev := msg.(type) {
+	case messages.TypingEvent:
+		return coalesceKey{typ: ev.Type, roomID: ev.RoomID, userID: ev.UserID}, true
+	case messages.PresenceEvent:
+		return coalesceKey{typ: ev.Type, userID: ev.UserID}, true
+	default:
+		return coalesceKey{}, false
+	}
+}
+
+// coalesce drops the typing and presence events in pending that a later one
+// with the same key supersedes, so a user toggling typing while the client
+// falls behind costs it one event rather than a dozen. The survivors keep
+// their order. pending is reused for the result.
+func coalesce(pending []interface{}) []interface{} {
+	if len(pending) < 2 {
+		return pending
+	}
+	latest := make(map[coalesceKey]int)
+	for i, msg := range pending {
+		if key, ok := coalesceKeyOf(msg); ok {
+			latest[key] = i
+		}
+	}
+	if len(latest) == 0 {
+		return pending
+	}
+
+	out := pending[:0]
+	for i, msg := range pending {
+		if key, ok := coalesceKeyOf(msg); ok && latest[key] != i {
+			continue
+		}
+		out = append(out, msg)
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalesce(t *testing.T) {
+	chat := messages.NewRoomMessageEvent("room_1", "user1", "User One", "hi")
+	pending := []interface{}{
+		messages.NewTypingEvent("room_1", "user1", "User One", true),
+		chat,
+		messages.NewPresenceEvent("user2", messages.PresenceOnline),
+		messages.NewTypingEvent("room_1", "user1", "User One", false),
+		messages.NewTypingEvent("room_2", "user1", "User One", true),
+		messages.NewPresenceEvent("user2", messages.PresenceOffline),
+		chat,
+		messages.NewTypingEvent("room_1", "user1", "User One", true),
+	}
+
+	assert.Equal(t, []interface{}{
+		chat,
+		messages.NewTypingEvent("room_2", "user1", "User One", true),
+		messages.NewPresenceEvent("user2", messages.PresenceOffline),
+		chat,
+		messages.NewTypingEvent("room_1", "user1", "User One", true),
+	}, coalesce(pending))
+
+	// nothing to coalesce
+	only := []interface{}{chat, chat}
+	assert.Equal(t, []interface{}{chat, chat}, coalesce(only))
+}
+
+func TestWritePumpCoalescesTyping(t *testing.T) {
+	queued := []interface{}{
+		messages.NewTypingEvent("room_1", "user1", "User One", true),
+		messages.NewTypingEvent("room_1", "user1", "User One", false),
+		messages.NewRoomMessageEvent("room_1", "user2", "User Two", "hello"),
+		messages.NewTypingEvent("room_1", "user1", "User One", true),
+		messages.NewTypingEvent("room_1", "user1", "User One", false),
+		closeRequest{code: websocket.CloseNormalClosure, reason: "bye"},
+	}
+	conn := servePump(t, false, queued...)
+
+	var chat messages.RoomMessageEvent
+	require.NoError(t, json.Unmarshal([]byte(readFrame(t, conn)), &chat))
+	assert.Equal(t, "hello", chat.Message.Message)
+
+	var typing messages.TypingEvent
+	require.NoError(t, json.Unmarshal([]byte(readFrame(t, conn)), &typing))
+	assert.Equal(t, messages.NewTypingEvent("room_1", "user1", "User One", false), typing)
+
+	// only the final state was sent before the close
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+}
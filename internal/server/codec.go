@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages/chatpb"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Subprotocols a client may offer in Sec-WebSocket-Protocol. Each names a
+// wire format and a protocol version; a client that offers none gets JSON at
+// the current version, while one that offers only unknown ones is refused.
+const (
+	SubprotocolJSON     = "chat.v1"
+	SubprotocolMsgpack  = "chat.msgpack.v1"
+	SubprotocolProtobuf = "chat.proto.v1"
+	// SubprotocolJSONBatch is JSON whose outbound frames may each hold an
+	// array of several events; see writeBatch
+	SubprotocolJSONBatch = "chat.batch.v1"
+)
+
+// currentProtocolVersion is the version of connections that negotiate no
+// subprotocol
+const currentProtocolVersion = 1
+
+// protocolVersionFor returns the protocol version a negotiated subprotocol
+// speaks. Every subprotocol is at version 1 for now.
+func protocolVersionFor(subprotocol string) int {
+	switch subprotocol {
+	case SubprotocolJSON, SubprotocolMsgpack, SubprotocolProtobuf, SubprotocolJSONBatch:
+		return 1
+	default:
+		return currentProtocolVersion
+	}
+}
+
+// codec is a connection's wire format. Handlers and schema validation work on
+// JSON whatever the format, so inbound frames are turned into JSON first;
+// outbound messages are encoded from the Go event types.
+type codec interface {
+	// name appears in errors about frames that fail to decode
+	name() string
+	// frameType is the websocket message type outbound frames are written as
+	frameType() int
+	marshal(v interface{}) ([]byte, error)
+	// toJSON re-expresses an inbound frame as JSON
+	toJSON(frame []byte) ([]byte, error)
+}
+
+// codecFor returns the codec for a negotiated subprotocol
+func codecFor(subprotocol string) codec {
+	switch subprotocol {
+	case SubprotocolMsgpack:
+		return msgpackCodec{}
+	case SubprotocolProtobuf:
+		return protoCodec{}
+	default:
+		return jsonCodec{}
+	}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) name() string { return "JSON" }
+
+func (jsonCodec) frameType() int { return websocket.TextMessage }
+
+func (jsonCodec) marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) toJSON(frame []byte) ([]byte, error) { return frame, nil }
+
+// msgpackCodec encodes with the json struct tags, so field names, omitempty
+// and the message types are identical in both formats and the messages
+// package needs no second set of tags.
+type msgpackCodec struct{}
+
+func (msgpackCodec) name() string { return "MessagePack" }
+
+func (msgpackCodec) frameType() int { return websocket.BinaryMessage }
+
+func (msgpackCodec) marshal(v interface{}) ([]byte, error) {
+	// remote events arrive already encoded as JSON
+	if raw, ok := v.(json.RawMessage); ok {
+		var decoded interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			return nil, err
+		}
+		v = decoded
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) toJSON(frame []byte) ([]byte, error) {
+	var decoded interface{}
+	if err := msgpack.Unmarshal(frame, &decoded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(decoded)
+}
+
+// protoCodec reads chatpb.WsMessage frames and writes every event as a
+// chatpb.ServerEvent whose data is the event's JSON form.
+type protoCodec struct{}
+
+func (protoCodec) name() string { return "protobuf" }
+
+func (protoCodec) frameType() int { return websocket.BinaryMessage }
+
+func (protoCodec) marshal(v interface{}) ([]byte, error) {
+	raw, ok := v.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	data, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, err
+	}
+	typ, _ := fields["type"].(string)
+	return proto.Marshal(&chatpb.ServerEvent{Type: typ, Data: data})
+}
+
+func (protoCodec) toJSON(frame []byte) ([]byte, error) {
+	var msg chatpb.WsMessage
+	if err := proto.Unmarshal(frame, &msg); err != nil {
+		return nil, err
+	}
+
+	out := map[string]interface{}{"type": msg.GetType()}
+	if msg.RequestId != nil {
+		out["request_id"] = msg.GetRequestId()
+	}
+	m := msg.ProtoReflect()
+	if fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("payload")); fd != nil {
+		payload, err := protoPayloadFields(m.Get(fd).Message())
+		if err != nil {
+			return nil, err
+		}
+		out["payload"] = payload
+	}
+	return json.Marshal(out)
+}
+
+// protoPayloadFields maps a flat payload message to its JSON fields. Fields
+// marked optional in chat.proto are left out when unset, like the omitempty
+// fields they mirror; every other field is always present.
+func protoPayloadFields(m protoreflect.Message) (map[string]interface{}, error) {
+	fields := m.Descriptor().Fields()
+	out := make(map[string]interface{}, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Kind() == protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return nil, errors.New("unsupported payload field " + string(fd.FullName()))
+		}
+		if fd.HasPresence() && !m.Has(fd) {
+			continue
+		}
+		out[string(fd.Name())] = m.Get(fd).Interface()
+	}
+	return out, nil
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/arturskrzydlo/chat-room/internal/messages/chatpb"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+func readMsgpack(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	frameType, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, websocket.BinaryMessage, frameType)
+	var out map[string]interface{}
+	require.NoError(t, msgpack.Unmarshal(data, &out))
+	return out
+}
+
+func writeMsgpack(t *testing.T, conn *websocket.Conn, v interface{}) {
+	t.Helper()
+	data, err := msgpack.Marshal(v)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, data))
+}
+
+func TestMsgpackSubprotocol(t *testing.T) {
+	mc := &mockCoordinator{members: []messages.Member{{UserID: "user1", UserName: "User One"}}}
+	s := NewWsServer(context.Background(), mc)
+	t.Cleanup(s.cancel)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolMsgpack}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, SubprotocolMsgpack, conn.Subprotocol())
+
+	writeMsgpack(t, conn, map[string]interface{}{
+		"type":    "join",
+		"payload": map[string]interface{}{"room_id": "room_1", "user_id": "user1", "user_name": "User One"},
+	})
+	got := readMsgpack(t, conn)
+	assert.Equal(t, "join_success", got["type"])
+	assert.Equal(t, "room_1", got["room_id"])
+	require.Len(t, mc.joinCalls, 1)
+	assert.Equal(t, "User One", mc.joinCalls[0].userName)
+
+	writeMsgpack(t, conn, map[string]interface{}{
+		"type":    "list_members",
+		"payload": map[string]interface{}{"room_id": "room_1"},
+	})
+	got = readMsgpack(t, conn)
+	assert.Equal(t, "members_list", got["type"])
+	require.Len(t, got["members"], 1)
+	assert.Equal(t, "user1", got["members"].([]interface{})[0].(map[string]interface{})["user_id"])
+
+	// schema validation still applies to decoded frames
+	writeMsgpack(t, conn, map[string]interface{}{"type": "join", "payload": map[string]interface{}{}})
+	assert.Equal(t, "schema_validation_failed", readMsgpack(t, conn)["code"])
+}
+
+func TestJSONWithoutSubprotocol(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	conn, _, err := dialWithOrigin(t, ts, "")
+	require.NoError(t, err)
+	assert.Empty(t, conn.Subprotocol())
+
+	require.NoError(t, conn.WriteJSON(messages.WsMessage{Type: messages.MessageActionTypePing}))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	frameType, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.TextMessage, frameType)
+	assert.JSONEq(t, `{"type":"pong"}`, string(data))
+}
+
+func TestMsgpackCodecEncodesRawJSON(t *testing.T) {
+	// remote events reach clients as raw JSON and must still go out as msgpack
+	data, err := msgpackCodec{}.marshal(json.RawMessage(`{"type":"user_joined","seq":7}`))
+	require.NoError(t, err)
+
+	var out map[string]interface{}
+	require.NoError(t, msgpack.Unmarshal(data, &out))
+	assert.Equal(t, "user_joined", out["type"])
+	assert.EqualValues(t, 7, out["seq"])
+}
+
+func TestProtobufSubprotocol(t *testing.T) {
+	mc := &mockCoordinator{}
+	s := NewWsServer(context.Background(), mc)
+	t.Cleanup(s.cancel)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolProtobuf}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, SubprotocolProtobuf, conn.Subprotocol())
+
+	write := func(msg *chatpb.WsMessage) {
+		t.Helper()
+		data, err := proto.Marshal(msg)
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, data))
+	}
+	read := func() *chatpb.ServerEvent {
+		t.Helper()
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		frameType, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, websocket.BinaryMessage, frameType)
+		var ev chatpb.ServerEvent
+		require.NoError(t, proto.Unmarshal(data, &ev))
+		return &ev
+	}
+
+	write(&chatpb.WsMessage{
+		Type:    "join",
+		Payload: &chatpb.WsMessage_Join{Join: &chatpb.JoinRoomPayload{RoomId: "room_1", UserId: "user1", UserName: "User One"}},
+	})
+	ev := read()
+	assert.Equal(t, "join_success", ev.GetType())
+	assert.Equal(t, "room_1", ev.GetData().GetFields()["room_id"].GetStringValue())
+	require.Len(t, mc.joinCalls, 1)
+	assert.Equal(t, "User One", mc.joinCalls[0].userName)
+
+	// unset optional fields are omitted rather than sent as zero
+	write(&chatpb.WsMessage{
+		Type:    "history",
+		Payload: &chatpb.WsMessage_History{History: &chatpb.HistoryPayload{RoomId: "room_1", Limit: proto.Int64(5)}},
+	})
+	assert.Equal(t, "history_batch", read().GetType())
+	require.Len(t, mc.historyCalls, 1)
+	assert.Equal(t, uint64(0), mc.historyCalls[0].beforeSeq)
+	assert.Equal(t, 5, mc.historyCalls[0].limit)
+
+	// the typing flag is sent even when false, as the schema requires it
+	write(&chatpb.WsMessage{
+		Type:    "typing",
+		Payload: &chatpb.WsMessage_Typing{Typing: &chatpb.TypingPayload{RoomId: "room_1"}},
+	})
+	write(&chatpb.WsMessage{Type: "ping", RequestId: proto.String("req-1")})
+	ev = read()
+	assert.Equal(t, "pong", ev.GetType())
+	assert.Equal(t, "req-1", ev.GetData().GetFields()["request_id"].GetStringValue())
+}
+
+// connectedVersion waits for the server to register one client and returns
+// the protocol version it negotiated
+func connectedVersion(t *testing.T, s *WsServer) int {
+	t.Helper()
+	var version int
+	require.Eventually(t, func() bool {
+		s.clientsMu.Lock()
+		defer s.clientsMu.Unlock()
+		for c := range s.clients {
+			version = c.version
+			return true
+		}
+		return false
+	}, time.Second, 5*time.Millisecond)
+	return version
+}
+
+func TestSubprotocolVersionNegotiation(t *testing.T) {
+	dial := func(t *testing.T, ts *httptest.Server, protocols ...string) *websocket.Conn {
+		t.Helper()
+		dialer := websocket.Dialer{Subprotocols: protocols}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+
+	t.Run("supported version", func(t *testing.T) {
+		s := newTestServer(t)
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		conn := dial(t, ts, "chat.v2", SubprotocolJSON)
+		assert.Equal(t, SubprotocolJSON, conn.Subprotocol())
+		assert.Equal(t, 1, connectedVersion(t, s))
+
+		require.NoError(t, conn.WriteJSON(messages.WsMessage{Type: messages.MessageActionTypePing}))
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		frameType, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, websocket.TextMessage, frameType)
+		assert.JSONEq(t, `{"type":"pong"}`, string(data))
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		s := newTestServer(t)
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		conn := dial(t, ts, "chat.v2")
+		assert.Empty(t, conn.Subprotocol())
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		_, _, err := conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		require.ErrorAs(t, err, &closeErr)
+		assert.Equal(t, websocket.CloseProtocolError, closeErr.Code)
+		assert.Contains(t, closeErr.Text, "chat.v2")
+		assert.Zero(t, s.ClientCount())
+	})
+
+	t.Run("none", func(t *testing.T) {
+		s := newTestServer(t)
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		conn := dial(t, ts)
+		assert.Empty(t, conn.Subprotocol())
+		assert.Equal(t, currentProtocolVersion, connectedVersion(t, s))
+	})
+}
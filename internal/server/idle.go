@@ -0,0 +1,42 @@
+package server
+
+import (
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+)
+
+// touch records application activity for the idle timeout
+func (c *Client) touch() {
+	c.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (c *Client) idleFor() time.Duration {
+	return time.Since(time.Unix(0, c.lastActivity.Load()))
+}
+
+// watchIdle closes the connection once it has been idle for cfg.idleTimeout,
+// telling the client why first.
+func (c *Client) watchIdle() {
+	timer := time.NewTimer(c.cfg.idleTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-timer.C:
+			idle := c.idleFor()
+			if idle < c.cfg.idleTimeout {
+				timer.Reset(c.cfg.idleTimeout - idle)
+				continue
+			}
+
+			c.closeWith(
+				messages.ErrorPayload{Code: "idle_timeout", Message: "closing idle connection"},
+				closeRequest{code: CloseIdle, reason: "idle timeout"},
+			)
+			return
+		}
+	}
+}
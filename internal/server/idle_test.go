@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdleTimeoutDisconnects(t *testing.T) {
+	s := newTestServer(t, WithIdleTimeout(100*time.Millisecond))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	conn, _, err := dialWithHeader(t, ts, "", nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	got := readJSON(t, conn)
+	assert.Equal(t, "idle_timeout", got["code"])
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseIdle, closeErr.Code)
+	assert.Equal(t, "idle timeout", closeErr.Text)
+
+	require.Eventually(t, func() bool { return s.ClientCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestIdleTimeoutResetByActivity(t *testing.T) {
+	s := newTestServer(t, WithIdleTimeout(150*time.Millisecond))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	conn, _, err := dialWithHeader(t, ts, "", nil)
+	require.NoError(t, err)
+
+	// keep talking past the timeout; every ping is answered, never cut off
+	for i := 0; i < 4; i++ {
+		time.Sleep(75 * time.Millisecond)
+		require.NoError(t, conn.WriteJSON(messages.WsMessage{Type: messages.MessageActionTypePing}))
+		assert.Equal(t, "pong", readJSON(t, conn)["type"])
+	}
+
+	assert.Equal(t, "idle_timeout", readJSON(t, conn)["code"])
+}
+
+func TestIdleTimeoutDisabledByDefault(t *testing.T) {
+	s := newTestServer(t)
+	assert.Zero(t, s.clientCfg.idleTimeout)
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"time"
+)
+
+const (
+	defaultMessageRate  = 5.0 // messages per second
+	defaultMessageBurst = 10
+)
+
+// tokenBucket is a simple token-bucket limiter. It is not safe for concurrent
+// use; each Client owns one and only touches it from its read pump.
+type tokenBucket struct {
+	rate   float64 // tokens added per second
+	burst  float64 // bucket capacity
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Allow reports whether one more event may happen now, consuming a token if so.
+func (b *tokenBucket) Allow() bool {
+	now := b.now()
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+
+	b.tokens += elapsed * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
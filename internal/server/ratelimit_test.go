@@ -0,0 +1,33 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketBurstThenRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(2, 3)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	// full burst is available immediately
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.Allow(), "burst token %d", i)
+	}
+	assert.False(t, b.Allow(), "bucket should be empty after burst")
+
+	// 500ms at 2 tokens/sec refills exactly one token
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+
+	// a long pause never refills beyond the burst size
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.Allow(), "refilled token %d", i)
+	}
+	assert.False(t, b.Allow())
+}
@@ -2,47 +2,323 @@ package server
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/arturskrzydlo/chat-room/internal/app"
 	"github.com/gorilla/websocket"
 )
 
 const (
-	pongWait       = 60 * time.Second
-	pingPeriod     = (pongWait * 9) / 10
-	writeWait      = 10 * time.Second
-	maxMessageSize = 10 * 1024 // 10KB
+	defaultPongWait        = 60 * time.Second
+	defaultWriteWait       = 10 * time.Second
+	defaultMaxMessageSize  = app.DefaultMaxMessageSize
+	defaultReadBufferSize  = 1024
+	defaultWriteBufferSize = 1024
+	defaultSendBufferSize  = 32
+
+	// frames smaller than this aren't worth deflating when compression is on
+	minCompressSize = 256
 )
 
+// clientConfig holds the per-connection settings copied into every Client
+type clientConfig struct {
+	pongWait       time.Duration
+	pingInterval   time.Duration // 0 derives the ping period from pongWait
+	writeWait      time.Duration
+	maxMessageSize int64
+	sendBufferSize int
+	idleTimeout    time.Duration // 0 disables the idle disconnect
+	compression    bool
+	// largest image accepted in a binary attachment frame; 0 disables attachments
+	maxAttachmentSize int64
+	maxRooms          int // rooms one connection may be in at once; 0 means no limit
+}
+
+func defaultClientConfig() clientConfig {
+	return clientConfig{
+		pongWait:       defaultPongWait,
+		writeWait:      defaultWriteWait,
+		maxMessageSize: defaultMaxMessageSize,
+		sendBufferSize: defaultSendBufferSize,
+	}
+}
+
+// readLimit is the largest frame a connection may read. Text frames are still
+// held to maxMessageSize once read.
+func (cfg clientConfig) readLimit() int64 {
+	if limit := cfg.maxAttachmentSize + maxAttachmentHeaderSize; cfg.maxAttachmentSize > 0 && limit > cfg.maxMessageSize {
+		return limit
+	}
+	return cfg.maxMessageSize
+}
+
+// pingPeriod must stay below pongWait so pings arrive before the read deadline;
+// an explicit interval that doesn't is ignored
+func (cfg clientConfig) pingPeriod() time.Duration {
+	if cfg.pingInterval > 0 && cfg.pingInterval < cfg.pongWait {
+		return cfg.pingInterval
+	}
+	return (cfg.pongWait * 9) / 10
+}
+
 type WsServer struct {
-	coordinator CoordinatorPort
-	upgrader    websocket.Upgrader
+	coordinator    CoordinatorPort
+	upgrader       websocket.Upgrader
+	clientCfg      clientConfig
+	allowedOrigins map[string]struct{} // empty means every origin is accepted
+	authenticator  Authenticator       // nil means clients identify themselves in payloads
+	allowAnonymous bool
+
+	messageRate  float64
+	messageBurst int
+	sessions     *sessionStore // nil when resume is disabled
+	slowPolicy   SlowClientPolicy
+	maxSlowDrops int
+
+	maxConnections int // 0 means unlimited
+	logger         *slog.Logger
 
 	ctx        context.Context
 	cancel     context.CancelFunc
+	draining   atomic.Bool
 	clientsMu  sync.RWMutex
 	clients    map[*Client]struct{}
 	clientDone chan *Client
+	watchDone  chan struct{} // closed once watchClients has removed every client after ctx is done
+}
+
+// Option configures optional WsServer settings
+type Option func(*WsServer)
+
+// WithMessageRateLimit limits how many chat messages each client may send,
+// refilling rate tokens per second up to burst. A rate <= 0 disables limiting.
+func WithMessageRateLimit(rate float64, burst int) Option {
+	return func(s *WsServer) {
+		s.messageRate = rate
+		s.messageBurst = burst
+	}
+}
+
+// WithResumeTTL lets dropped clients resume their room memberships with a
+// resume token for up to ttl before they are removed from their rooms.
+// A ttl <= 0 (the default) disables resume.
+func WithResumeTTL(ttl time.Duration) Option {
+	return func(s *WsServer) {
+		if ttl <= 0 {
+			s.sessions = nil
+			return
+		}
+		s.sessions = newSessionStore(ttl)
+	}
+}
+
+// WithSlowClientPolicy sets how room events are handled for clients whose send
+// buffer is full. maxDrops is the number of consecutive drops tolerated by
+// SlowClientDisconnect before the connection is closed.
+func WithSlowClientPolicy(policy SlowClientPolicy, maxDrops int) Option {
+	return func(s *WsServer) {
+		s.slowPolicy = policy
+		if maxDrops > 0 {
+			s.maxSlowDrops = maxDrops
+		}
+	}
+}
+
+// WithReadBufferSize sets the websocket read buffer size in bytes
+func WithReadBufferSize(size int) Option {
+	return func(s *WsServer) {
+		if size > 0 {
+			s.upgrader.ReadBufferSize = size
+		}
+	}
+}
+
+// WithWriteBufferSize sets the websocket write buffer size in bytes
+func WithWriteBufferSize(size int) Option {
+	return func(s *WsServer) {
+		if size > 0 {
+			s.upgrader.WriteBufferSize = size
+		}
+	}
+}
+
+// WithMaxMessageSize sets the largest inbound frame, in bytes, a client may send
+func WithMaxMessageSize(size int64) Option {
+	return func(s *WsServer) {
+		if size > 0 {
+			s.clientCfg.maxMessageSize = size
+		}
+	}
+}
+
+// WithPongWait sets how long a connection may stay silent before it is closed.
+// Pings are sent at 9/10 of this interval.
+func WithPongWait(d time.Duration) Option {
+	return func(s *WsServer) {
+		if d > 0 {
+			s.clientCfg.pongWait = d
+		}
+	}
+}
+
+// WithPingPeriod sets how often the server pings each client. It must be
+// shorter than the pong wait; by default it is 9/10 of it.
+func WithPingPeriod(d time.Duration) Option {
+	return func(s *WsServer) {
+		if d > 0 {
+			s.clientCfg.pingInterval = d
+		}
+	}
+}
+
+// WithWriteWait sets how long a single write to a client may take
+func WithWriteWait(d time.Duration) Option {
+	return func(s *WsServer) {
+		if d > 0 {
+			s.clientCfg.writeWait = d
+		}
+	}
+}
+
+// WithSendBufferSize sets how many outbound messages are buffered per client
+func WithSendBufferSize(size int) Option {
+	return func(s *WsServer) {
+		if size > 0 {
+			s.clientCfg.sendBufferSize = size
+		}
+	}
+}
+
+// WithIdleTimeout disconnects clients that send no application messages for d.
+// Pings and pongs don't count as activity. A d <= 0 (the default) disables it.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *WsServer) {
+		if d < 0 {
+			d = 0
+		}
+		s.clientCfg.idleTimeout = d
+	}
+}
+
+// WithMaxAttachmentSize accepts image attachments of up to size bytes, sent as
+// binary frames on JSON connections. It is separate from WithMaxMessageSize,
+// which keeps limiting text frames. size <= 0 (the default) disables
+// attachments.
+func WithMaxAttachmentSize(size int64) Option {
+	return func(s *WsServer) {
+		if size < 0 {
+			size = 0
+		}
+		s.clientCfg.maxAttachmentSize = size
+	}
+}
+
+// WithCompression negotiates permessage-deflate with clients that offer it.
+// Compression costs CPU and a flate writer per busy connection, in exchange
+// for much smaller frames on chatty rooms and history replays, which mostly
+// pays off for mobile clients on metered links. Frames under 256 bytes are
+// sent uncompressed. Off by default.
+func WithCompression(enabled bool) Option {
+	return func(s *WsServer) {
+		s.upgrader.EnableCompression = enabled
+		s.clientCfg.compression = enabled
+	}
+}
+
+// WithLogger sets the logger used by the server and its clients. The default
+// is a text handler on stderr at info level; pass a logger with another
+// handler or level to change either.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *WsServer) {
+		if l != nil {
+			s.logger = l
+		}
+	}
+}
+
+// WithMaxRoomsPerConnection caps how many rooms one connection may be in at
+// once; joins and creates past it fail with room_limit_per_connection until
+// the client leaves a room. n <= 0 (the default) means no limit.
+func WithMaxRoomsPerConnection(n int) Option {
+	return func(s *WsServer) {
+		if n < 0 {
+			n = 0
+		}
+		s.clientCfg.maxRooms = n
+	}
 }
 
-func NewWsServer(ctx context.Context, coordinator CoordinatorPort) *WsServer {
+// WithMaxConnections caps how many clients may be connected at once; further
+// upgrades are refused with 503 until a client disconnects. n <= 0 (the
+// default) means no limit.
+func WithMaxConnections(n int) Option {
+	return func(s *WsServer) {
+		if n < 0 {
+			n = 0
+		}
+		s.maxConnections = n
+	}
+}
+
+// WithAllowedOrigins restricts upgrades to requests whose Origin header is in
+// origins; other browser origins get a 403 before the upgrade. Origins are
+// compared case-insensitively, ignoring a trailing slash, e.g.
+// "https://chat.example.com". An empty list keeps accepting every origin.
+func WithAllowedOrigins(origins []string) Option {
+	return func(s *WsServer) {
+		s.allowedOrigins = make(map[string]struct{}, len(origins))
+		for _, o := range origins {
+			if o = normalizeOrigin(o); o != "" {
+				s.allowedOrigins[o] = struct{}{}
+			}
+		}
+	}
+}
+
+// WithAuthenticator verifies every upgrade request with a. Requests that fail
+// are rejected with 401 and verified connections are bound to the returned
+// identity. With allowAnonymous, requests carrying no credentials at all still
+// connect and identify themselves through payloads as before.
+func WithAuthenticator(a Authenticator, allowAnonymous bool) Option {
+	return func(s *WsServer) {
+		s.authenticator = a
+		s.allowAnonymous = allowAnonymous
+	}
+}
+
+func NewWsServer(ctx context.Context, coordinator CoordinatorPort, opts ...Option) *WsServer {
 	ctx, cancel := context.WithCancel(ctx)
 
 	s := &WsServer{
 		coordinator: coordinator,
 		upgrader: websocket.Upgrader{
-			CheckOrigin:     func(r *http.Request) bool { return true },
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
+			ReadBufferSize:  defaultReadBufferSize,
+			WriteBufferSize: defaultWriteBufferSize,
 		},
-		ctx:        ctx,
-		cancel:     cancel,
-		clients:    make(map[*Client]struct{}),
-		clientDone: make(chan *Client, 128),
+		clientCfg:    defaultClientConfig(),
+		messageRate:  defaultMessageRate,
+		messageBurst: defaultMessageBurst,
+		maxSlowDrops: defaultMaxSlowDrops,
+		ctx:          ctx,
+		cancel:       cancel,
+		clients:      make(map[*Client]struct{}),
+		clientDone:   make(chan *Client, 128),
+		watchDone:    make(chan struct{}),
+		logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.upgrader.CheckOrigin = s.checkOrigin
+	// binary formats first: a client offering several gets the most compact
+	s.upgrader.Subprotocols = []string{SubprotocolMsgpack, SubprotocolProtobuf, SubprotocolJSONBatch, SubprotocolJSON}
 
 	go s.watchClients()
 
@@ -50,27 +326,103 @@ func NewWsServer(ctx context.Context, coordinator CoordinatorPort) *WsServer {
 }
 
 func (s *WsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Draining() {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	if s.atCapacity() {
+		http.Error(w, "too many connections", http.StatusServiceUnavailable)
+		return
+	}
+
+	identity, ok := s.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade failed: %v", err)
+		s.logger.Warn("websocket upgrade failed", "error", err)
 		return
 	}
 
+	// the handshake picks nothing when none of the offered subprotocols is
+	// ours, so a client asking for a version we don't speak is told why here
+	if conn.Subprotocol() == "" {
+		if offered := websocket.Subprotocols(r); len(offered) > 0 {
+			s.logger.Info("unsupported subprotocol", "event", "upgrade", "offered", offered)
+			msg := websocket.FormatCloseMessage(websocket.CloseProtocolError, "unsupported subprotocol: "+strings.Join(offered, ", "))
+			_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(s.clientCfg.writeWait))
+			_ = conn.Close()
+			return
+		}
+	}
+
 	ctx, cancel := context.WithCancel(r.Context())
 	client := &Client{
-		rooms:       make(map[string]struct{}),
-		conn:        conn,
-		send:        make(chan interface{}, 32), // buffered for concurrency
-		coordinator: s.coordinator,
-		ctx:         ctx,
-		cancel:      cancel,
+		rooms:        make(map[string]struct{}),
+		userID:       identity.UserID,
+		userName:     identity.UserName,
+		conn:         conn,
+		send:         make(chan interface{}, s.clientCfg.sendBufferSize), // buffered for concurrency
+		cfg:          s.clientCfg,
+		codec:        codecFor(conn.Subprotocol()),
+		version:      protocolVersionFor(conn.Subprotocol()),
+		batchFrames:  conn.Subprotocol() == SubprotocolJSONBatch,
+		logger:       s.logger,
+		coordinator:  s.coordinator,
+		sessions:     s.sessions,
+		slowPolicy:   s.slowPolicy,
+		maxSlowDrops: s.maxSlowDrops,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	if s.slowPolicy != SlowClientSkip {
+		client.inbox = make(chan interface{}, clientInboxBufferSize)
+	}
+	if s.messageRate > 0 {
+		client.limiter = newTokenBucket(s.messageRate, s.messageBurst)
 	}
 
+	// Shutdown drains before it snapshots the clients, so a connection that
+	// upgraded in the meantime is either in the snapshot or sees draining here
 	s.clientsMu.Lock()
+	if s.Draining() {
+		s.clientsMu.Unlock()
+		cancel()
+		_ = conn.Close()
+		return
+	}
+	// concurrent upgrades can all pass the check above, so it is repeated
+	// here; by now the only way to refuse is a close frame
+	if s.maxConnections > 0 && len(s.clients) >= s.maxConnections {
+		s.clientsMu.Unlock()
+		cancel()
+		msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many connections")
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(s.clientCfg.writeWait))
+		_ = conn.Close()
+		return
+	}
 	s.clients[client] = struct{}{}
 	s.clientsMu.Unlock()
 
+	if client.userID != "" {
+		client.goOnline()
+		client.issueResumeToken()
+	}
+
 	go client.writePump()
+	go client.watchBackpressure()
+	if client.inbox != nil {
+		go client.deliveryPump()
+	}
+	if client.cfg.idleTimeout > 0 {
+		client.touch()
+		go client.watchIdle()
+	}
 
 	func() {
 		defer func() {
@@ -81,25 +433,109 @@ func (s *WsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}()
 }
 
+// checkOrigin is the upgrader's CheckOrigin; a false result makes the upgrade
+// fail with 403 Forbidden. Requests without an Origin header come from
+// non-browser clients, which cannot be used for cross-site hijacking, so they
+// are let through.
+func (s *WsServer) checkOrigin(r *http.Request) bool {
+	if len(s.allowedOrigins) == 0 {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	_, ok := s.allowedOrigins[normalizeOrigin(origin)]
+	return ok
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
+
+// ClientCount returns the number of connected clients
+func (s *WsServer) ClientCount() int {
+	s.clientsMu.RLock()
+	defer s.clientsMu.RUnlock()
+	return len(s.clients)
+}
+
+// ClientState is a read-only view of one connection for debugging
+type ClientState struct {
+	UserID string   `json:"user_id"` // empty until the connection identifies
+	Rooms  []string `json:"rooms"`
+}
+
+// ClientStates reports every connected client and the rooms it has joined
+func (s *WsServer) ClientStates() []ClientState {
+	s.clientsMu.RLock()
+	clients := make([]*Client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.clientsMu.RUnlock()
+
+	states := make([]ClientState, 0, len(clients))
+	for _, c := range clients {
+		userID, rooms := c.state()
+		states = append(states, ClientState{UserID: userID, Rooms: rooms})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].UserID < states[j].UserID })
+	return states
+}
+
+// atCapacity reports whether MaxConnections clients are already connected.
+// Clients count until watchClients removes them after they disconnect.
+func (s *WsServer) atCapacity() bool {
+	return s.maxConnections > 0 && s.ClientCount() >= s.maxConnections
+}
+
+// Drain marks the server as shutting down so readiness checks fail and load
+// balancers stop sending new connections. Shutdown drains first.
+func (s *WsServer) Drain() {
+	s.draining.Store(true)
+}
+
+// Draining reports whether Drain was called or the server's context is done
+func (s *WsServer) Draining() bool {
+	return s.draining.Load() || s.ctx.Err() != nil
+}
+
+// watchClients removes finished clients. It keeps running after ctx is done
+// until the last client is gone: ServeHTTP refuses new clients by then, and
+// exiting earlier would strand clients still blocked sending on clientDone.
 func (s *WsServer) watchClients() {
+	defer close(s.watchDone)
+
+	done := s.ctx.Done()
 	for {
 		select {
 		case c := <-s.clientDone:
 			s.clientsMu.Lock()
 			delete(s.clients, c)
+			remaining := len(s.clients)
 			s.clientsMu.Unlock()
-		case <-s.ctx.Done():
-			return
+			if done == nil && remaining == 0 {
+				return
+			}
+		case <-done:
+			done = nil
+			if s.ClientCount() == 0 {
+				return
+			}
 		}
 	}
 }
 
+// Shutdown closes every client and waits until all of them are removed or
+// ctx is done
 func (s *WsServer) Shutdown(ctx context.Context) error {
-	defer func() {
-		if s.cancel != nil {
-			s.cancel()
-		}
-	}()
+	s.Drain()
+
+	// nothing will be resumed once we stop serving
+	if s.sessions != nil {
+		s.sessions.close()
+	}
 
 	s.clientsMu.Lock()
 	clients := make([]*Client, 0, len(s.clients))
@@ -108,28 +544,18 @@ func (s *WsServer) Shutdown(ctx context.Context) error {
 	}
 	s.clientsMu.Unlock()
 
+	// writePump sends the close frame, then closes the connection, which
+	// ends readPump
 	for _, c := range clients {
-		if c.cancel != nil {
-			c.cancel()
-		}
-		_ = c.conn.Close()
+		c.closeNow(closeRequest{code: websocket.CloseGoingAway, reason: "server shutting down"})
 	}
 
-	ticker := time.NewTicker(50 * time.Millisecond)
-	defer ticker.Stop()
-	for {
-		s.clientsMu.RLock()
-		count := len(s.clients)
-		s.clientsMu.RUnlock()
-
-		if count == 0 {
-			return nil
-		}
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-		}
+	// watchClients exits once the closed clients have all been removed
+	s.cancel()
+	select {
+	case <-s.watchDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
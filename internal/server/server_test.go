@@ -0,0 +1,533 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, opts ...Option) *WsServer {
+	t.Helper()
+	s := NewWsServer(context.Background(), &mockCoordinator{}, opts...)
+	t.Cleanup(s.cancel)
+	return s
+}
+
+func TestNewWsServerDefaults(t *testing.T) {
+	s := newTestServer(t)
+
+	assert.Equal(t, defaultReadBufferSize, s.upgrader.ReadBufferSize)
+	assert.Equal(t, defaultWriteBufferSize, s.upgrader.WriteBufferSize)
+	assert.Equal(t, defaultClientConfig(), s.clientCfg)
+	assert.Equal(t, 54*time.Second, s.clientCfg.pingPeriod())
+	assert.Empty(t, s.allowedOrigins)
+}
+
+func TestWithReadBufferSize(t *testing.T) {
+	s := newTestServer(t, WithReadBufferSize(4096))
+	assert.Equal(t, 4096, s.upgrader.ReadBufferSize)
+
+	// non-positive sizes keep the default
+	s = newTestServer(t, WithReadBufferSize(0))
+	assert.Equal(t, defaultReadBufferSize, s.upgrader.ReadBufferSize)
+}
+
+func TestWithWriteBufferSize(t *testing.T) {
+	s := newTestServer(t, WithWriteBufferSize(2048))
+	assert.Equal(t, 2048, s.upgrader.WriteBufferSize)
+}
+
+func TestWithMaxMessageSize(t *testing.T) {
+	s := newTestServer(t, WithMaxMessageSize(512))
+	assert.Equal(t, int64(512), s.clientCfg.maxMessageSize)
+
+	s = newTestServer(t, WithMaxMessageSize(-1))
+	assert.Equal(t, int64(defaultMaxMessageSize), s.clientCfg.maxMessageSize)
+}
+
+func TestWithPongWait(t *testing.T) {
+	s := newTestServer(t, WithPongWait(10*time.Second))
+	assert.Equal(t, 10*time.Second, s.clientCfg.pongWait)
+	assert.Equal(t, 9*time.Second, s.clientCfg.pingPeriod())
+}
+
+func TestWithPingPeriod(t *testing.T) {
+	s := newTestServer(t, WithPongWait(10*time.Second), WithPingPeriod(2*time.Second))
+	assert.Equal(t, 2*time.Second, s.clientCfg.pingPeriod())
+
+	// a period that would let the read deadline expire first is ignored
+	s = newTestServer(t, WithPongWait(10*time.Second), WithPingPeriod(time.Minute))
+	assert.Equal(t, 9*time.Second, s.clientCfg.pingPeriod())
+}
+
+func TestWithWriteWait(t *testing.T) {
+	s := newTestServer(t, WithWriteWait(time.Second))
+	assert.Equal(t, time.Second, s.clientCfg.writeWait)
+
+	s = newTestServer(t, WithWriteWait(0))
+	assert.Equal(t, defaultWriteWait, s.clientCfg.writeWait)
+}
+
+func TestReadPumpExitsWithoutPong(t *testing.T) {
+	s := newTestServer(t, WithPongWait(100*time.Millisecond))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	// the dialer only answers pings while reading, so never reading means no pongs
+	_, _, err := dialWithOrigin(t, ts, "")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return s.ClientCount() == 1 }, time.Second, 5*time.Millisecond)
+
+	require.Eventually(t, func() bool { return s.ClientCount() == 0 }, time.Second, 10*time.Millisecond,
+		"server should drop the connection once pongWait passes without a pong")
+}
+
+func TestPongKeepsConnectionAlive(t *testing.T) {
+	s := newTestServer(t, WithPongWait(100*time.Millisecond))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	conn, _, err := dialWithOrigin(t, ts, "")
+	require.NoError(t, err)
+
+	// reading lets the default ping handler answer with pongs
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, 1, s.ClientCount())
+}
+
+func TestWithSendBufferSize(t *testing.T) {
+	s := newTestServer(t, WithSendBufferSize(128))
+	assert.Equal(t, 128, s.clientCfg.sendBufferSize)
+}
+
+func TestWithAllowedOrigins(t *testing.T) {
+	s := newTestServer(t, WithAllowedOrigins([]string{"https://chat.example.com"}))
+	require.NotNil(t, s.upgrader.CheckOrigin)
+
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set("Origin", "https://chat.example.com")
+	assert.True(t, s.upgrader.CheckOrigin(req))
+
+	req.Header.Set("Origin", "https://evil.example.com")
+	assert.False(t, s.upgrader.CheckOrigin(req))
+}
+
+func TestCheckOriginPermissiveWithoutAllowlist(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set("Origin", "https://anything.example.com")
+	assert.True(t, s.upgrader.CheckOrigin(req))
+}
+
+func dialWithOrigin(t *testing.T, ts *httptest.Server, origin string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if conn != nil {
+		t.Cleanup(func() { _ = conn.Close() })
+	}
+	if resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+	return conn, resp, err
+}
+
+func TestOriginAllowlistDial(t *testing.T) {
+	s := newTestServer(t, WithAllowedOrigins([]string{"https://chat.example.com/", "http://localhost:3000"}))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{name: "exact match", origin: "http://localhost:3000", allowed: true},
+		{name: "case and trailing slash ignored", origin: "HTTPS://Chat.Example.com", allowed: true},
+		{name: "no origin header", origin: "", allowed: true},
+		{name: "unknown origin", origin: "https://evil.example.com", allowed: false},
+		{name: "different port", origin: "http://localhost:4000", allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, resp, err := dialWithOrigin(t, ts, tt.origin)
+			if tt.allowed {
+				require.NoError(t, err)
+				require.NotNil(t, conn)
+				assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+				return
+			}
+			require.ErrorIs(t, err, websocket.ErrBadHandshake)
+			require.NotNil(t, resp)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		})
+	}
+}
+
+func TestEmptyAllowlistAcceptsAnyOriginDial(t *testing.T) {
+	s := newTestServer(t, WithAllowedOrigins(nil))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	_, resp, err := dialWithOrigin(t, ts, "https://anything.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+}
+
+func TestCompressionNegotiated(t *testing.T) {
+	mc := &mockCoordinator{}
+	for i := 0; i < 50; i++ {
+		mc.members = append(mc.members, messages.Member{UserID: fmt.Sprintf("user%d", i), UserName: "Some Rather Long Name"})
+	}
+	s := NewWsServer(context.Background(), mc, WithCompression(true))
+	t.Cleanup(s.cancel)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Contains(t, resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+	require.NoError(t, conn.WriteJSON(messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room_1", UserID: "user0", UserName: "User Zero"}),
+	}))
+	assert.Equal(t, "join_success", readJSON(t, conn)["type"])
+
+	// a members list this size goes out deflated
+	require.NoError(t, conn.WriteJSON(messages.WsMessage{
+		Type:    messages.MessageActionTypeListMembers,
+		Payload: mustRaw(messages.ListMembersPayload{RoomID: "room_1"}),
+	}))
+	got := readJSON(t, conn)
+	assert.Equal(t, "members_list", got["type"])
+	assert.Len(t, got["members"], 50)
+}
+
+func TestCompressionOffByDefault(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Empty(t, resp.Header.Get("Sec-WebSocket-Extensions"))
+}
+
+func TestDraining(t *testing.T) {
+	s := newTestServer(t)
+	assert.False(t, s.Draining())
+	require.NoError(t, s.Shutdown(context.Background()))
+	assert.True(t, s.Draining())
+
+	// cancelling the root context drains too
+	ctx, cancel := context.WithCancel(context.Background())
+	s = NewWsServer(ctx, &mockCoordinator{})
+	cancel()
+	assert.True(t, s.Draining())
+}
+
+func TestUpgradeRefusedWhileDraining(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	// keep Shutdown busy with a connected client while we dial
+	_, _, err := dialWithOrigin(t, ts, "")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return s.ClientCount() == 1 }, time.Second, 5*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Shutdown(context.Background()) }()
+	require.Eventually(t, s.Draining, time.Second, time.Millisecond)
+
+	_, resp, err := dialWithOrigin(t, ts, "")
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	require.NoError(t, <-done)
+}
+
+func TestMaxConnections(t *testing.T) {
+	s := newTestServer(t, WithMaxConnections(2))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	first, _, err := dialWithOrigin(t, ts, "")
+	require.NoError(t, err)
+	_, _, err = dialWithOrigin(t, ts, "")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return s.ClientCount() == 2 }, time.Second, 5*time.Millisecond)
+
+	_, resp, err := dialWithOrigin(t, ts, "")
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// a disconnect frees its slot once watchClients removes the client
+	require.NoError(t, first.Close())
+	require.Eventually(t, func() bool { return s.ClientCount() == 1 }, time.Second, 5*time.Millisecond)
+
+	_, _, err = dialWithOrigin(t, ts, "")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return s.ClientCount() == 2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestShutdownReturnsOnceClientsDisconnect(t *testing.T) {
+	for _, cancelFirst := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		s := NewWsServer(ctx, &mockCoordinator{})
+		ts := httptest.NewServer(s)
+
+		for i := 0; i < 5; i++ {
+			_, _, err := dialWithOrigin(t, ts, "")
+			require.NoError(t, err)
+		}
+		require.Eventually(t, func() bool { return s.ClientCount() == 5 }, time.Second, 5*time.Millisecond)
+
+		// cancelling the root context first used to stop watchClients before
+		// it had removed the clients, so Shutdown ran into its deadline
+		if cancelFirst {
+			cancel()
+		}
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		start := time.Now()
+		require.NoError(t, s.Shutdown(shutdownCtx), "cancelFirst=%v", cancelFirst)
+		assert.Less(t, time.Since(start), time.Second, "cancelFirst=%v", cancelFirst)
+		assert.Equal(t, 0, s.ClientCount())
+
+		shutdownCancel()
+		cancel()
+		ts.Close()
+	}
+}
+
+func TestShutdownSendsGoingAway(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	conn, _, err := dialWithOrigin(t, ts, "")
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return s.ClientCount() == 1 }, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, s.Shutdown(ctx))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseGoingAway, closeErr.Code)
+	assert.Equal(t, "server shutting down", closeErr.Text)
+}
+
+func TestMessageTooLargeDetails(t *testing.T) {
+	s := newTestServer(t, WithMaxMessageSize(64), WithMaxAttachmentSize(1024))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	conn, _, err := dialWithOrigin(t, ts, "")
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", 128))))
+
+	var errEv messages.ErrorPayload
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	require.NoError(t, conn.ReadJSON(&errEv))
+	assert.Equal(t, "message_too_large", errEv.Code)
+	assert.EqualValues(t, 64, errEv.Details["limit"])
+	assert.EqualValues(t, 128, errEv.Details["size"])
+}
+
+func TestCloseCodePerFailure(t *testing.T) {
+	chat := func(t *testing.T, conn *websocket.Conn) {
+		t.Helper()
+		msg := messages.WsMessage{
+			Type:    messages.MessageActionTypeMessage,
+			Payload: mustRaw(messages.MessagePayload{RoomID: "room_1", Message: "spam"}),
+		}
+		for i := 0; i <= maxRateLimitStrikes; i++ {
+			require.NoError(t, conn.WriteJSON(msg))
+		}
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		send       func(t *testing.T, conn *websocket.Conn)
+		wantCode   int
+		wantReason string
+	}{
+		{
+			name:       "rate limited",
+			opts:       []Option{WithMessageRateLimit(0.001, 1)},
+			send:       chat,
+			wantCode:   CloseRateLimited,
+			wantReason: "rate limited",
+		},
+		{
+			name:       "idle",
+			opts:       []Option{WithIdleTimeout(50 * time.Millisecond)},
+			send:       func(*testing.T, *websocket.Conn) {},
+			wantCode:   CloseIdle,
+			wantReason: "idle timeout",
+		},
+		{
+			name: "message too large",
+			opts: []Option{WithMaxMessageSize(64), WithMaxAttachmentSize(1024)},
+			send: func(t *testing.T, conn *websocket.Conn) {
+				require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", 128))))
+			},
+			wantCode:   websocket.CloseMessageTooBig,
+			wantReason: "message too large",
+		},
+		{
+			name: "malformed json",
+			send: func(t *testing.T, conn *websocket.Conn) {
+				require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("{")))
+			},
+			wantCode:   websocket.CloseInvalidFramePayloadData,
+			wantReason: "malformed message",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.opts...)
+			ts := httptest.NewServer(s)
+			defer ts.Close()
+
+			conn, _, err := dialWithOrigin(t, ts, "")
+			require.NoError(t, err)
+			defer conn.Close()
+			tt.send(t, conn)
+
+			// error events may come first; the close frame ends the stream
+			var closeErr *websocket.CloseError
+			for {
+				require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+				if _, _, err = conn.ReadMessage(); err != nil {
+					break
+				}
+			}
+			require.ErrorAs(t, err, &closeErr)
+			assert.Equal(t, tt.wantCode, closeErr.Code)
+			assert.Equal(t, tt.wantReason, closeErr.Text)
+			require.Eventually(t, func() bool { return s.ClientCount() == 0 }, time.Second, 5*time.Millisecond)
+		})
+	}
+}
+
+// joinOverWire joins roomID on conn and waits for the acknowledgement
+func joinOverWire(t *testing.T, conn *websocket.Conn, roomID string) {
+	t.Helper()
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"type":    "join",
+		"payload": map[string]string{"room_id": roomID, "user_id": "user1", "user_name": "User One"},
+	}))
+	require.Equal(t, "join_success", readType(t, conn))
+}
+
+func readType(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var ev map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&ev))
+	if typ, ok := ev["type"].(string); ok {
+		return typ
+	}
+	return ev["code"].(string)
+}
+
+func TestKickedConnectionForgetsRoom(t *testing.T) {
+	mc := &mockCoordinator{}
+	s := NewWsServer(context.Background(), mc)
+	t.Cleanup(s.cancel)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	conn, _, err := dialWithOrigin(t, ts, "")
+	require.NoError(t, err)
+
+	joinOverWire(t, conn, "room_1")
+	joinOverWire(t, conn, "room_2")
+	require.Len(t, mc.joinCalls, 2)
+	send := mc.joinCalls[0].send
+
+	// someone else's kick changes nothing; our own drops the room
+	send <- messages.NewUserKickedEvent("room_2", "user2", "User Two", "author1")
+	send <- messages.NewUserKickedEvent("room_1", "user1", "User One", "author1")
+	require.Equal(t, "user_kicked", readType(t, conn))
+	require.Equal(t, "user_kicked", readType(t, conn))
+
+	// the room is no longer the connection's to leave
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "leave", "payload": map[string]string{"room_id": "room_1"}}))
+	assert.Equal(t, "leave_room_error", readType(t, conn))
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "leave", "payload": map[string]string{"room_id": "room_2"}}))
+	assert.Equal(t, "leave_success", readType(t, conn))
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	require.Len(t, mc.leaveCalls, 1)
+	assert.Equal(t, "room_2", mc.leaveCalls[0].roomID)
+}
+
+func TestConnectionAtRoomCapCanJoinAfterLosingRooms(t *testing.T) {
+	mc := &mockCoordinator{}
+	s := NewWsServer(context.Background(), mc, WithMaxRoomsPerConnection(1))
+	t.Cleanup(s.cancel)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	conn, _, err := dialWithOrigin(t, ts, "")
+	require.NoError(t, err)
+
+	join := func(roomID string) string {
+		t.Helper()
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "join", "payload": map[string]string{"room_id": roomID}}))
+		return readType(t, conn)
+	}
+
+	joinOverWire(t, conn, "room_1")
+	require.Equal(t, "room_limit_per_connection", join("room_2"))
+
+	// being kicked frees the slot
+	mc.joinCalls[0].send <- messages.NewUserKickedEvent("room_1", "user1", "User One", "author1")
+	require.Equal(t, "user_kicked", readType(t, conn))
+	require.Equal(t, "join_success", join("room_2"))
+
+	// and so does the room closing
+	mc.joinCalls[1].send <- messages.NewRoomClosedEvent("room_2")
+	require.Equal(t, "room_closed", readType(t, conn))
+	require.Equal(t, "join_success", join("room_3"))
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// parkedSession holds the room memberships of a dropped connection so a
+// reconnecting client can resume them with its token.
+type parkedSession struct {
+	userID   string
+	userName string
+	rooms    []string
+	timer    *time.Timer
+}
+
+// sessionStore keeps parked sessions keyed by resume token until their TTL expires.
+type sessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*parkedSession
+	closed   bool
+}
+
+func newSessionStore(ttl time.Duration) *sessionStore {
+	return &sessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]*parkedSession),
+	}
+}
+
+// park stores the session under token. If nobody resumes it within the TTL,
+// the session is removed and onExpire runs so its rooms can be left.
+func (s *sessionStore) park(token string, sess *parkedSession, onExpire func(*parkedSession)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	sess.timer = time.AfterFunc(s.ttl, func() {
+		s.mu.Lock()
+		current, ok := s.sessions[token]
+		if ok && current == sess {
+			delete(s.sessions, token)
+		}
+		s.mu.Unlock()
+
+		if ok && current == sess {
+			onExpire(sess)
+		}
+	})
+	s.sessions[token] = sess
+	return true
+}
+
+// take removes and returns the session for token, stopping its expiry timer.
+func (s *sessionStore) take(token string) (*parkedSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[token]
+	if !ok {
+		return nil, false
+	}
+	delete(s.sessions, token)
+	sess.timer.Stop()
+	return sess, true
+}
+
+// close stops accepting sessions and cancels pending expiries.
+func (s *sessionStore) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for token, sess := range s.sessions {
+		sess.timer.Stop()
+		delete(s.sessions, token)
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionStoreTakeWithinTTL(t *testing.T) {
+	s := newSessionStore(time.Second)
+	var expired atomic.Bool
+
+	ok := s.park("tok", &parkedSession{userID: "user1", rooms: []string{"room_1"}}, func(*parkedSession) {
+		expired.Store(true)
+	})
+	require.True(t, ok)
+
+	sess, ok := s.take("tok")
+	require.True(t, ok)
+	assert.Equal(t, "user1", sess.userID)
+	assert.Equal(t, []string{"room_1"}, sess.rooms)
+
+	// a token can only be used once
+	_, ok = s.take("tok")
+	assert.False(t, ok)
+
+	assert.False(t, expired.Load(), "resumed session must not expire")
+}
+
+func TestSessionStoreExpiresAfterTTL(t *testing.T) {
+	s := newSessionStore(20 * time.Millisecond)
+	expired := make(chan *parkedSession, 1)
+
+	s.park("tok", &parkedSession{userID: "user1"}, func(sess *parkedSession) {
+		expired <- sess
+	})
+
+	select {
+	case sess := <-expired:
+		assert.Equal(t, "user1", sess.userID)
+	case <-time.After(time.Second):
+		require.Fail(t, "session did not expire")
+	}
+
+	_, ok := s.take("tok")
+	assert.False(t, ok, "expired session must not be resumable")
+}
+
+func TestSessionStoreClose(t *testing.T) {
+	s := newSessionStore(20 * time.Millisecond)
+	var expired atomic.Bool
+
+	s.park("tok", &parkedSession{userID: "user1"}, func(*parkedSession) { expired.Store(true) })
+	s.close()
+
+	assert.False(t, s.park("tok2", &parkedSession{userID: "user2"}, func(*parkedSession) {}))
+	_, ok := s.take("tok")
+	assert.False(t, ok)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, expired.Load(), "closing the store cancels pending expiries")
+}
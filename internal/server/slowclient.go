@@ -0,0 +1,121 @@
+package server
+
+import "time"
+
+// SlowClientPolicy decides what happens to room events when a client's send buffer is full
+type SlowClientPolicy int
+
+const (
+	// SlowClientSkip waits briefly in the room, then skips the event (default)
+	SlowClientSkip SlowClientPolicy = iota
+	// SlowClientDropOldest evicts the oldest room event still waiting for the
+	// send buffer to make room for the new one
+	SlowClientDropOldest
+	// SlowClientDisconnect closes the connection after a number of consecutive drops
+	SlowClientDisconnect
+)
+
+const (
+	slowClientWait        = 100 * time.Millisecond
+	defaultMaxSlowDrops   = 10
+	clientInboxBufferSize = 32
+	// room events SlowClientDropOldest holds back while the send buffer is full
+	dropOldestBacklogSize = 32
+)
+
+// deliveryPump moves room events from the inbox into the send buffer,
+// applying the client's slow-client policy when the buffer is full.
+func (c *Client) deliveryPump() {
+	if c.slowPolicy == SlowClientDropOldest {
+		c.dropOldestPump()
+		return
+	}
+	for {
+		select {
+		case msg := <-c.inbox:
+			c.enqueue(msg)
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Client) enqueue(msg interface{}) {
+	select {
+	case c.send <- msg:
+		c.consecutiveDrops = 0
+		return
+	default:
+	}
+
+	switch c.slowPolicy {
+	case SlowClientDisconnect:
+		select {
+		case c.send <- msg:
+			c.consecutiveDrops = 0
+			return
+		case <-time.After(slowClientWait):
+		}
+
+		c.consecutiveDrops++
+		if c.consecutiveDrops >= c.maxSlowDrops {
+			c.logger.Warn("disconnecting slow client", "user_id", c.userID, "dropped", c.consecutiveDrops)
+			c.closeWithReason(CloseSlowClient, "client too slow")
+		}
+
+	default:
+		select {
+		case c.send <- msg:
+		case <-time.After(slowClientWait):
+		}
+	}
+}
+
+// dropOldestPump is deliveryPump for SlowClientDropOldest. Room events wait
+// in a backlog of their own while the send buffer is full, so eviction only
+// ever drops room events, never the replies and close requests handlers
+// queue on send.
+func (c *Client) dropOldestPump() {
+	var backlog []interface{}
+	for {
+		var out chan<- interface{}
+		var next interface{}
+		if len(backlog) > 0 {
+			out, next = c.send, backlog[0]
+		}
+		select {
+		case msg := <-c.inbox:
+			if len(backlog) == dropOldestBacklogSize {
+				backlog = backlog[1:]
+			}
+			backlog = append(backlog, msg)
+		case out <- next:
+			backlog = backlog[1:]
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// disconnect stops the pumps and closes the socket; the read pump then
+// exits and cleanup leaves all rooms. Safe to call from any goroutine, any
+// number of times.
+func (c *Client) disconnect() {
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		if c.cancel != nil {
+			c.cancel()
+		}
+		if c.conn != nil {
+			_ = c.conn.Close()
+		}
+	})
+}
+
+// roomSend is the channel rooms deliver to
+func (c *Client) roomSend() chan<- interface{} {
+	if c.inbox != nil {
+		return c.inbox
+	}
+	return c.send
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStuckClient returns a client whose send buffer is already full and never drained
+func newStuckClient(t *testing.T, policy SlowClientPolicy, maxDrops int) *Client {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	c := newTestClientWithMock(t, &mockCoordinator{})
+	c.send = make(chan interface{}, 2)
+	c.inbox = make(chan interface{}, clientInboxBufferSize)
+	c.slowPolicy = policy
+	c.maxSlowDrops = maxDrops
+	c.ctx = ctx
+	c.cancel = cancel
+
+	c.send <- "old-1"
+	c.send <- "old-2"
+	return c
+}
+
+// fillDropOldestBacklog queues n room events, numbered from 1, through a
+// running drop-oldest pump whose send buffer is full, and waits for the pump
+// to take them off the inbox
+func fillDropOldestBacklog(t *testing.T, c *Client, n int) {
+	t.Helper()
+	go c.dropOldestPump()
+	for i := 1; i <= n; i++ {
+		c.roomSend() <- i
+	}
+	require.Eventually(t, func() bool { return len(c.inbox) == 0 }, time.Second, time.Millisecond)
+}
+
+// drainNumbered receives numbered room events from send until the last one, n
+func drainNumbered(t *testing.T, c *Client, n int) []int {
+	t.Helper()
+	var got []int
+	for len(got) == 0 || got[len(got)-1] != n {
+		select {
+		case msg := <-c.send:
+			got = append(got, msg.(int))
+		case <-time.After(time.Second):
+			require.FailNow(t, "backlog not forwarded", "got %v", got)
+		}
+	}
+	return got
+}
+
+func TestSlowClientDropOldest(t *testing.T) {
+	c := newStuckClient(t, SlowClientDropOldest, 0)
+	fillDropOldestBacklog(t, c, dropOldestBacklogSize+2)
+
+	// what was already queued stays, and the oldest room events were evicted
+	assert.Equal(t, "old-1", <-c.send)
+	assert.Equal(t, "old-2", <-c.send)
+	got := drainNumbered(t, c, dropOldestBacklogSize+2)
+	assert.NotContains(t, got, 1)
+	assert.LessOrEqual(t, len(got), dropOldestBacklogSize+1, "the backlog is bounded")
+	assert.IsIncreasing(t, got)
+	assert.NoError(t, c.ctx.Err(), "drop-oldest must not disconnect")
+}
+
+func TestSlowClientDropOldestKeepsCloseRequest(t *testing.T) {
+	c := newStuckClient(t, SlowClientDropOldest, 0)
+	<-c.send
+	<-c.send
+	// closeWith's error frame and close request fill the buffer
+	c.send <- messages.ErrorPayload{Code: "rate_limited", Message: "too many messages, disconnecting"}
+	c.send <- closeRequest{code: CloseRateLimited, reason: "rate limited"}
+
+	fillDropOldestBacklog(t, c, dropOldestBacklogSize+5)
+
+	_, isError := (<-c.send).(messages.ErrorPayload)
+	assert.True(t, isError, "the error frame must not be evicted")
+	_, isClose := (<-c.send).(closeRequest)
+	assert.True(t, isClose, "the close request must not be evicted")
+	assert.NotContains(t, drainNumbered(t, c, dropOldestBacklogSize+5), 1)
+}
+
+func TestSlowClientDisconnectAfterConsecutiveDrops(t *testing.T) {
+	c := newStuckClient(t, SlowClientDisconnect, 3)
+
+	c.enqueue("m1")
+	c.enqueue("m2")
+	assert.NoError(t, c.ctx.Err(), "below the threshold the client stays connected")
+
+	c.enqueue("m3")
+	assert.ErrorIs(t, c.ctx.Err(), context.Canceled)
+	assert.Equal(t, "old-1", <-c.send, "queued messages are left untouched")
+}
+
+func TestSlowClientDisconnectResetsOnDelivery(t *testing.T) {
+	c := newStuckClient(t, SlowClientDisconnect, 2)
+
+	c.enqueue("m1")
+	<-c.send // a slot frees up
+	c.enqueue("m2")
+	c.enqueue("m3")
+
+	assert.NoError(t, c.ctx.Err(), "a successful delivery resets the drop count")
+	assert.Equal(t, 1, c.consecutiveDrops)
+}
+
+func TestSlowClientDeliveryPumpForwardsInbox(t *testing.T) {
+	c := newStuckClient(t, SlowClientDropOldest, 0)
+	<-c.send
+	<-c.send
+
+	go c.deliveryPump()
+	c.roomSend() <- "hello"
+
+	select {
+	case msg := <-c.send:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(time.Second):
+		require.Fail(t, "inbox message not forwarded to send")
+	}
+}
@@ -1,8 +1,33 @@
 package server
 
+import "github.com/arturskrzydlo/chat-room/internal/messages"
+
 type CoordinatorPort interface {
-	CreateRoom(roomID, authorID, roomName string, send chan<- interface{}) error
-	JoinRoom(roomID, userID, userName string, send chan<- interface{}) error
+	CreateRoom(roomID, authorID, roomName, password string, send chan<- interface{}) error
+	CreateRoomWithGeneratedID(authorID, roomName, password string, send chan<- interface{}) (string, error)
+	JoinRoom(roomID, userID, userName, password string, send chan<- interface{}) error
+	JoinOrCreateRoom(roomID, userID, userName, password string, send chan<- interface{}) error
 	LeaveRoom(roomID, userID string) error
-	SendMessage(roomID, userID, content string) error
+	SendMessageAcked(roomID, userID, content, clientMsgID, parentMessageID string, expiresInSeconds int, requestID string) error
+	DeleteMessage(roomID, requesterID, messageID string) error
+	React(roomID, userID, messageID, emoji string) error
+	MarkRead(roomID, userID string, seq uint64) error
+	ListMembers(roomID string) ([]messages.Member, error)
+	GetHistory(roomID string, beforeSeq uint64, limit int) ([]messages.RoomMessageEvent, error)
+	KickUser(roomID, requesterID, targetID string) error
+	GrantModerator(roomID, requesterID, targetID string) error
+	RevokeModerator(roomID, requesterID, targetID string) error
+	MuteUser(roomID, requesterID, targetID string) error
+	UnmuteUser(roomID, requesterID, targetID string) error
+	SetRoomMeta(roomID, requesterID, topic, description string) error
+	RenameUser(userID, newName string) error
+	BroadcastTyping(roomID, userID, userName string, isTyping bool) error
+	DetachClient(roomID, userID string) error
+	ReattachClient(roomID, userID string, send chan<- interface{}) error
+	RegisterClient(userID string, send chan<- interface{})
+	UnregisterClient(userID string, send chan<- interface{})
+	SubscribePresence(send chan<- interface{})
+	UnsubscribePresence(send chan<- interface{})
+	SendDirect(fromID, fromName, toID, content string) error
+	SendAttachment(roomID, userID, userName, contentType string, data []byte) error
 }
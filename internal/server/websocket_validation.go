@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed message_schema.json
+var messageSchemaJSON []byte
+
+// event_schema.json is generated from the messages types
+//
+//go:generate go run ../../tools/gen-ws-schema -o event_schema.json
+//go:embed event_schema.json
+var eventSchemaJSON []byte
+
+var (
+	inboundSchema  = &embeddedSchema{name: "message_schema.json", data: messageSchemaJSON}
+	outboundSchema = &embeddedSchema{name: "event_schema.json", data: eventSchemaJSON}
+)
+
+// SchemaValidationError lists every field of a message that violates the schema
+type SchemaValidationError struct {
+	Fields []string
+}
+
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("schema validation failed: %s", strings.Join(e.Fields, "; "))
+}
+
+// embeddedSchema compiles an embedded schema the first time it is used
+type embeddedSchema struct {
+	name string
+	data []byte
+
+	once     sync.Once
+	compiled *jsonschema.Schema
+	err      error
+}
+
+func (s *embeddedSchema) load() (*jsonschema.Schema, error) {
+	s.once.Do(func() {
+		compiler := jsonschema.NewCompiler()
+		if err := compiler.AddResource(s.name, bytes.NewReader(s.data)); err != nil {
+			s.err = fmt.Errorf("add schema resource: %w", err)
+			return
+		}
+		s.compiled, s.err = compiler.Compile(s.name)
+	})
+	return s.compiled, s.err
+}
+
+// ValidateWebSocketMessage checks a raw inbound message against the embedded schema.
+// Violations are returned as a *SchemaValidationError.
+func ValidateWebSocketMessage(raw []byte) error {
+	return validateAgainst(inboundSchema, raw)
+}
+
+// ValidateOutbound checks a raw event the server sends against the generated
+// outbound schema. Violations are returned as a *SchemaValidationError.
+func ValidateOutbound(raw []byte) error {
+	return validateAgainst(outboundSchema, raw)
+}
+
+func validateAgainst(s *embeddedSchema, raw []byte) error {
+	schema, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	err = schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+	return &SchemaValidationError{Fields: leafViolations(ve)}
+}
+
+// leafViolations flattens the validation tree into "location: message" entries
+func leafViolations(ve *jsonschema.ValidationError) []string {
+	seen := make(map[string]struct{})
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			loc := e.InstanceLocation
+			if loc == "" {
+				loc = "/"
+			}
+			seen[loc+": "+e.Message] = struct{}{}
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(ve)
+
+	out := make([]string, 0, len(seen))
+	for v := range seen {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
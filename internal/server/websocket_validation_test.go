@@ -0,0 +1,179 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWebSocketMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		wantFields []string
+	}{
+		{"valid create_room", `{"type":"create_room","payload":{"room_id":"r1","room_name":"Room","user_id":"u1","user_name":"U"}}`, nil},
+		{"valid create_room without room_id", `{"type":"create_room","payload":{"room_name":"Room"}}`, nil},
+		{"missing room_name on create_room", `{"type":"create_room","payload":{"room_id":"r1"}}`, []string{"/payload: missing properties: 'room_name'"}},
+		{"valid join without identity", `{"type":"join","payload":{"room_id":"r1"}}`, nil},
+		{"valid message", `{"type":"message","payload":{"room_id":"r1","message":"hi"}}`, nil},
+		{"valid ping with null payload", `{"type":"ping","payload":null}`, nil},
+		{"unknown type left to dispatcher", `{"type":"whatever"}`, nil},
+		{"missing type", `{"payload":{}}`, []string{"/: missing properties: 'type'"}},
+		{"missing room_id on message", `{"type":"message","payload":{"message":"hi"}}`, []string{"/payload: missing properties: 'room_id'"}},
+		{"wrong field type", `{"type":"typing","payload":{"room_id":"r1","is_typing":"yes"}}`, []string{"/payload/is_typing: expected boolean, but got string"}},
+		{"expiry over a week", `{"type":"message","payload":{"room_id":"r1","message":"hi","expires_in_seconds":604801}}`, []string{"/payload/expires_in_seconds: must be <= 604800 but found 604801"}},
+		{"missing payload", `{"type":"leave"}`, []string{"/: missing properties: 'payload'"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWebSocketMessage([]byte(tt.raw))
+			if tt.wantFields == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			var sve *SchemaValidationError
+			require.True(t, errors.As(err, &sve), "expected SchemaValidationError, got %v", err)
+			assert.Equal(t, tt.wantFields, sve.Fields)
+		})
+	}
+}
+
+func TestValidateWebSocketMessageInvalidJSON(t *testing.T) {
+	err := ValidateWebSocketMessage([]byte(`{not json`))
+	require.Error(t, err)
+
+	var sve *SchemaValidationError
+	assert.False(t, errors.As(err, &sve))
+}
+
+func TestValidateOutboundRoomMessageEvent(t *testing.T) {
+	ev := messages.NewRoomMessageEvent("room_1", "user1", "User One", "hello")
+	ev.Seq = 7
+	ev.MessageID = "m1"
+	raw, err := json.Marshal(ev)
+	require.NoError(t, err)
+	require.NoError(t, ValidateOutbound(raw))
+
+	// a renamed field or wrong type is caught
+	err = ValidateOutbound([]byte(`{"type":"new_message","room":"room_1","user_id":"user1","user_name":"User One",` +
+		`"message":{"room_id":"room_1","message":"hello"},"message_time":"t","server_received_at":"t","seq":"7","message_id":"m1"}`))
+	var sve *SchemaValidationError
+	require.True(t, errors.As(err, &sve), "expected SchemaValidationError, got %v", err)
+}
+
+// requireValidOutbound marshals v the way writePump does and fails the test
+// if the result doesn't match the outbound schema
+func requireValidOutbound(t *testing.T, v interface{}) {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	require.NoError(t, err)
+	err = ValidateOutbound(raw)
+	var sve *SchemaValidationError
+	if errors.As(err, &sve) {
+		require.FailNow(t, "event does not match the outbound schema", "%s\n%s", raw, strings.Join(sve.Fields, "\n"))
+	}
+	require.NoError(t, err)
+}
+
+// outboundSchemaTypes returns every type the outbound schema declares
+func outboundSchemaTypes(t *testing.T) []string {
+	t.Helper()
+	var schema struct {
+		Defs map[string]struct {
+			Properties struct {
+				Type struct {
+					Const string `json:"const"`
+				} `json:"type"`
+			} `json:"properties"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(eventSchemaJSON, &schema))
+	var types []string
+	for _, def := range schema.Defs {
+		if c := def.Properties.Type.Const; c != "" {
+			types = append(types, c)
+		}
+	}
+	return types
+}
+
+func TestOutboundEventsMatchSchema(t *testing.T) {
+	chat := messages.NewRoomMessageEvent("room_1", "user1", "User One", "hello")
+	chat.Seq = 3
+	chat.MessageID = "m1"
+	chat.Message.ClientMsgID = "c1"
+	chat.Message.ParentMessageID = "m0"
+	chat.Message.ExpiresInSeconds = 30
+	historical := chat
+	historical.Historical = true
+	historical.Deleted = true
+
+	tests := []struct {
+		typ string
+		ev  interface{}
+	}{
+		{"join_success", messages.NewJoinSuccess("room_1", "user1")},
+		{"create_room_success", messages.NewCreateRoomSuccess("room_1", "user1")},
+		{"leave_success", messages.NewLeaveSuccess("room_1", "user1")},
+		{"leave_all_success", messages.NewLeaveAllSuccess("user1", []string{"room_1", "room_2"})},
+		{"message_duplicate", messages.NewDuplicateMessageAck("room_1", "c1")},
+		{"message_ack", messages.NewMessageAck("room_1", "m1", 3)},
+		{"session", messages.NewSessionEvent("user1", "token")},
+		{"resume_success", messages.NewResumeSuccess("user1", []string{"room_1"})},
+		{"session_info", messages.NewSessionInfoEvent("", "", nil)},
+		{"pong", messages.Pong{Type: "pong", RequestID: "req-1"}},
+		{"backpressure", messages.NewBackpressureEvent(true, 24, 32)},
+		{"new_message", chat},
+		{"new_room", messages.NewRoom("room_1", "user1", "Room One")},
+		{"user_joined", messages.NewUserJoinedEvent("room_1", "user1", "User One")},
+		{"user_left", messages.NewUserLeftEvent("room_1", "user1", "User One")},
+		{"user_kicked", messages.NewUserKickedEvent("room_1", "user2", "User Two", "user1")},
+		{"message_expired", messages.NewMessageExpiredEvent("room_1", "m1")},
+		{"message_deleted", messages.NewMessageDeletedEvent("room_1", "m1", "user1")},
+		{"reaction", messages.NewReactionEvent("room_1", "m1", "user1", "👍", true)},
+		{"direct_message", messages.NewDirectMessageEvent("user1", "User One", "user2", "hi")},
+		{"presence", messages.NewPresenceEvent("user1", messages.PresenceOnline)},
+		{"room_stats", messages.NewRoomStatsEvent("room_1", 2)},
+		{"history_batch", messages.NewHistoryBatchEvent("room_1", []messages.RoomMessageEvent{historical})},
+		{"attachment", messages.NewAttachmentEvent("room_1", "user1", "User One", "image/png", []byte{0x89, 'P', 'N', 'G'})},
+		{"messages_dropped", messages.NewMessagesDroppedEvent("room_1", 4)},
+		{"room_error", messages.NewRoomErrorEvent("room_1", "internal error")},
+		{"room_closed", messages.NewRoomClosedEvent("room_1")},
+		{"system_announcement", messages.NewSystemAnnouncementEvent("room_1", "maintenance at noon")},
+		{"read_receipt", messages.NewReadReceiptEvent("room_1", "user1", 3)},
+		{"author_changed", messages.NewAuthorChangedEvent("room_1", "user2")},
+		{"moderator_changed", messages.NewModeratorChangedEvent("room_1", "user2", true, "user1")},
+		{"user_muted", messages.NewUserMutedEvent("room_1", "user2", "user1")},
+		{"user_unmuted", messages.NewUserUnmutedEvent("room_1", "user2", "user1")},
+		{"room_meta_updated", messages.NewRoomMetaUpdatedEvent("room_1", "Release planning", "", "user1")},
+		{"user_renamed", messages.NewUserRenamedEvent("room_1", "user1", "User One", "Uno")},
+		{"typing", messages.NewTypingEvent("room_1", "user1", "User One", true)},
+		{"members_list", messages.NewMembersListEvent("room_1", []messages.Member{{UserID: "user1", UserName: "User One"}})},
+		{"error", messages.ErrorPayload{
+			Code:      "message_too_large",
+			Message:   "message too large",
+			Fields:    []string{"/payload/message"},
+			Details:   sizeDetails(10, 12),
+			RequestID: "req-1",
+		}},
+	}
+
+	covered := make(map[string]bool, len(tests))
+	for _, tt := range tests {
+		covered[tt.typ] = true
+		t.Run(tt.typ, func(t *testing.T) {
+			requireValidOutbound(t, tt.ev)
+		})
+	}
+	for _, typ := range outboundSchemaTypes(t) {
+		assert.True(t, covered[typ], "no test case for %s", typ)
+	}
+}
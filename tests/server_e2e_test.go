@@ -2,9 +2,13 @@ package tests
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
 	"time"
 
@@ -91,6 +95,11 @@ func TestChatEndToEnd(t *testing.T) {
 	var ev map[string]interface{}
 	readJSON(t, conn1, &ev)
 	require.Equal(t, string(messages.EventNewRoom), ev["type"], "expected new_room event for user1")
+	readJSON(t, conn1, &ev)
+	require.Equal(t, "create_room_success", ev["type"], "expected create_room_success ack for user1")
+	readJSON(t, conn1, &ev)
+	require.Equal(t, "join_success", ev["type"], "expected join_success for the author")
+	require.Equal(t, roomID1, ev["room_id"])
 
 	// 2) user2 joins room_1.
 	joinPayload2 := messages.JoinRoomPayload{
@@ -128,12 +137,19 @@ func TestChatEndToEnd(t *testing.T) {
 	err = conn1.WriteJSON(sendMsg1)
 	require.NoError(t, err, "user1 send message write")
 
-	// On conn1, next message: user1's own chat.
+	// On conn1, the ack comes first and then user1's own chat.
+	var ack1 messages.MessageAck
+	readJSON(t, conn1, &ack1)
+	require.Equal(t, "message_ack", ack1.Type, "user1 ack type")
+	require.Equal(t, roomID1, ack1.RoomID, "user1 ack room id")
+
 	var msgEv1 messages.RoomMessageEvent
 	readJSON(t, conn1, &msgEv1)
 	require.Equal(t, messages.EventNewMessage, msgEv1.Type, "user1 first chat type")
 	require.Equal(t, userID1, msgEv1.UserID, "user1 first chat user id")
 	require.Equal(t, "hello from user1", msgEv1.Message.Message, "user1 first chat content")
+	require.Equal(t, ack1.MessageID, msgEv1.MessageID, "ack carries the chat's message id")
+	require.Equal(t, ack1.Seq, msgEv1.Seq, "ack carries the chat's seq")
 
 	// On conn2, next message is still the system join broadcast ("User Two joined the room").
 	var sysJoinOn2 messages.UserJoinedEvent
@@ -161,7 +177,11 @@ func TestChatEndToEnd(t *testing.T) {
 	err = conn2.WriteJSON(sendMsg2)
 	require.NoError(t, err, "user2 send message write")
 
-	// Next message on conn2: its own chat.
+	// Next messages on conn2: the ack and its own chat.
+	var ack2 messages.MessageAck
+	readJSON(t, conn2, &ack2)
+	require.Equal(t, "message_ack", ack2.Type, "user2 ack type")
+
 	readJSON(t, conn2, &msgEv2)
 	require.Equal(t, messages.EventNewMessage, msgEv2.Type, "user2 reply type")
 	require.Equal(t, userID2, msgEv2.UserID, "user2 reply user id")
@@ -200,3 +220,321 @@ func TestChatEndToEnd(t *testing.T) {
 	err = coord.Shutdown(ctx)
 	assert.NoError(t, err, "coordinator shutdown")
 }
+
+// nextChat reads from c until the next new_message, skipping presence and stats events.
+func nextChat(t *testing.T, c *websocket.Conn) messages.RoomMessageEvent {
+	t.Helper()
+	for {
+		var raw json.RawMessage
+		readJSON(t, c, &raw)
+		var ev messages.RoomMessageEvent
+		require.NoError(t, json.Unmarshal(raw, &ev))
+		if ev.Type == messages.EventNewMessage {
+			return ev
+		}
+	}
+}
+
+/*
+One connection belongs to two rooms at once.
+User 1 creates room_a, user 2 creates room_b, and user 1 also joins room_b.
+Chats from both rooms reach user 1 on the one connection tagged with their room,
+while user 2 never sees anything from room_a.
+*/
+func TestMultiRoomConnection(t *testing.T) {
+	coord := coordinator.NewCoordinator()
+
+	rootCtx, rootCancel := context.WithCancel(context.Background())
+	defer rootCancel()
+
+	wsSrv := server.NewWsServer(rootCtx, coord)
+	ts := httptest.NewServer(wsSrv)
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err, "parse test server url")
+	u.Scheme = "ws"
+
+	conn1, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	require.NoError(t, err, "dial user1")
+	defer conn1.Close()
+	conn2, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	require.NoError(t, err, "dial user2")
+	defer conn2.Close()
+
+	send := func(conn *websocket.Conn, typ messages.InputMessageActionType, payload interface{}) {
+		t.Helper()
+		require.NoError(t, conn.WriteJSON(messages.WsMessage{Type: typ, Payload: mustRaw(payload)}))
+	}
+
+	send(conn1, messages.MessageActionTypeCreateRoom, messages.CreateRoomPayload{
+		RoomID: "room_a", RoomName: "Room A", UserID: "user1", UserName: "User One",
+	})
+	var ev map[string]interface{}
+	readJSON(t, conn1, &ev)
+	require.Equal(t, string(messages.EventNewRoom), ev["type"])
+	readJSON(t, conn1, &ev)
+	require.Equal(t, "create_room_success", ev["type"])
+	readJSON(t, conn1, &ev)
+	require.Equal(t, "join_success", ev["type"])
+
+	send(conn2, messages.MessageActionTypeCreateRoom, messages.CreateRoomPayload{
+		RoomID: "room_b", RoomName: "Room B", UserID: "user2", UserName: "User Two",
+	})
+	readJSON(t, conn2, &ev)
+	require.Equal(t, string(messages.EventNewRoom), ev["type"])
+	readJSON(t, conn2, &ev)
+	require.Equal(t, "create_room_success", ev["type"])
+	readJSON(t, conn2, &ev)
+	require.Equal(t, "join_success", ev["type"])
+
+	send(conn1, messages.MessageActionTypeJoin, messages.JoinRoomPayload{RoomID: "room_b"})
+	readJSON(t, conn1, &ev)
+	require.Equal(t, "join_success", ev["type"])
+	require.Equal(t, "room_b", ev["room_id"])
+
+	send(conn1, messages.MessageActionTypeMessage, messages.MessagePayload{RoomID: "room_a", Message: "in a"})
+	got := nextChat(t, conn1)
+	assert.Equal(t, "room_a", got.RoomID)
+	assert.Equal(t, "in a", got.Message.Message)
+
+	send(conn2, messages.MessageActionTypeMessage, messages.MessagePayload{RoomID: "room_b", Message: "in b"})
+	got = nextChat(t, conn1)
+	assert.Equal(t, "room_b", got.RoomID)
+	assert.Equal(t, "user2", got.UserID)
+	assert.Equal(t, "in b", got.Message.Message)
+
+	got = nextChat(t, conn2)
+	assert.Equal(t, "room_b", got.RoomID, "user2 must not see room_a traffic")
+	assert.Equal(t, "in b", got.Message.Message)
+
+	// leaving room_a keeps room_b flowing on the same connection
+	send(conn1, messages.MessageActionTypeLeave, messages.LeaveRoomPayload{RoomID: "room_a"})
+	send(conn2, messages.MessageActionTypeMessage, messages.MessagePayload{RoomID: "room_b", Message: "still b"})
+	got = nextChat(t, conn1)
+	assert.Equal(t, "room_b", got.RoomID)
+	assert.Equal(t, "still b", got.Message.Message)
+
+	// nothing from room_a ever reached user2
+	require.NoError(t, conn2.SetReadDeadline(time.Now().Add(300*time.Millisecond)))
+	for {
+		var rest map[string]interface{}
+		if err := conn2.ReadJSON(&rest); err != nil {
+			break
+		}
+		assert.NotEqual(t, "room_a", rest["room_id"], "cross-talk: %v", rest)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, coord.Shutdown(ctx), "coordinator shutdown")
+}
+
+// An image sent as a binary frame reaches every room member, sender included.
+func TestAttachmentBroadcast(t *testing.T) {
+	coord := coordinator.NewCoordinator()
+
+	rootCtx, rootCancel := context.WithCancel(context.Background())
+	defer rootCancel()
+
+	wsSrv := server.NewWsServer(rootCtx, coord, server.WithMaxAttachmentSize(4096))
+	ts := httptest.NewServer(wsSrv)
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err, "parse test server url")
+	u.Scheme = "ws"
+
+	conn1, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	require.NoError(t, err, "dial user1")
+	defer conn1.Close()
+	conn2, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	require.NoError(t, err, "dial user2")
+	defer conn2.Close()
+
+	require.NoError(t, conn1.WriteJSON(messages.WsMessage{
+		Type:    messages.MessageActionTypeCreateRoom,
+		Payload: mustRaw(messages.CreateRoomPayload{RoomID: "room_1", RoomName: "Pics", UserID: "user1", UserName: "User One"}),
+	}))
+	var ev map[string]interface{}
+	readJSON(t, conn1, &ev)
+	require.Equal(t, string(messages.EventNewRoom), ev["type"])
+	readJSON(t, conn1, &ev)
+	require.Equal(t, "create_room_success", ev["type"])
+	readJSON(t, conn1, &ev)
+	require.Equal(t, "join_success", ev["type"])
+
+	require.NoError(t, conn2.WriteJSON(messages.WsMessage{
+		Type:    messages.MessageActionTypeJoin,
+		Payload: mustRaw(messages.JoinRoomPayload{RoomID: "room_1", UserID: "user2", UserName: "User Two"}),
+	}))
+	readJSON(t, conn2, &ev)
+	require.Equal(t, "join_success", ev["type"])
+
+	img := append([]byte("\x89PNG\r\n\x1a\n"), []byte("not really pixels")...)
+	frame := append([]byte(`{"room_id":"room_1"}`+"\n"), img...)
+	require.NoError(t, conn2.WriteMessage(websocket.BinaryMessage, frame))
+
+	sum := sha256.Sum256(img)
+	for _, conn := range []*websocket.Conn{conn1, conn2} {
+		var att messages.AttachmentEvent
+		for att.Type != messages.EventAttachment {
+			readJSON(t, conn, &att)
+		}
+		assert.Equal(t, "room_1", att.RoomID)
+		assert.Equal(t, "user2", att.UserID)
+		assert.Equal(t, "image/png", att.ContentType)
+		assert.Equal(t, len(img), att.Size)
+		assert.Equal(t, hex.EncodeToString(sum[:]), att.SHA256)
+		assert.Equal(t, img, att.Data)
+	}
+}
+
+// Schema-violating messages are rejected with a structured error and the connection stays usable.
+func TestSchemaValidationRejectsInvalidPayload(t *testing.T) {
+	coord := coordinator.NewCoordinator()
+
+	rootCtx, rootCancel := context.WithCancel(context.Background())
+	defer rootCancel()
+
+	wsSrv := server.NewWsServer(rootCtx, coord)
+	ts := httptest.NewServer(wsSrv)
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err, "parse test server url")
+	u.Scheme = "ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	require.NoError(t, err, "dial")
+	defer conn.Close()
+
+	// message without room_id
+	err = conn.WriteJSON(map[string]interface{}{
+		"type":    "message",
+		"payload": map[string]interface{}{"message": "hi"},
+	})
+	require.NoError(t, err, "write invalid message")
+
+	var errEv messages.ErrorPayload
+	readJSON(t, conn, &errEv)
+	assert.Equal(t, "schema_validation_failed", errEv.Code)
+	assert.Equal(t, []string{"/payload: missing properties: 'room_id'"}, errEv.Fields)
+
+	// connection still works afterwards
+	err = conn.WriteJSON(messages.WsMessage{Type: messages.MessageActionTypePing, Payload: json.RawMessage("null")})
+	require.NoError(t, err, "write ping")
+
+	var pong messages.Pong
+	readJSON(t, conn, &pong)
+	assert.Equal(t, "pong", pong.Type)
+}
+
+// Clients leaving, rejoining and chatting concurrently, then hanging up with
+// or without leaving, must not panic or wedge the server. Run with -race.
+func TestConcurrentLeaveAndSend(t *testing.T) {
+	coord := coordinator.NewCoordinator()
+
+	rootCtx, rootCancel := context.WithCancel(context.Background())
+	defer rootCancel()
+
+	wsSrv := server.NewWsServer(rootCtx, coord, server.WithMessageRateLimit(0, 0))
+	ts := httptest.NewServer(wsSrv)
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err, "parse test server url")
+	u.Scheme = "ws"
+
+	owner, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	require.NoError(t, err, "dial owner")
+	defer owner.Close()
+	require.NoError(t, owner.WriteJSON(messages.WsMessage{
+		Type:    messages.MessageActionTypeCreateRoom,
+		Payload: mustRaw(messages.CreateRoomPayload{RoomID: "stress", RoomName: "Stress", UserID: "owner", UserName: "Owner"}),
+	}))
+	go func() {
+		for {
+			if _, _, err := owner.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+			if !assert.NoError(t, err, "dial") {
+				return
+			}
+			defer conn.Close()
+
+			userID := fmt.Sprintf("user%d", i)
+			joined := make(chan struct{}, 1)
+			go func() {
+				for {
+					var ev map[string]interface{}
+					if err := conn.ReadJSON(&ev); err != nil {
+						return
+					}
+					// our own join broadcast means the room loop has added us
+					if ev["type"] == string(messages.EventUserJoinedRoom) && ev["user_id"] == userID {
+						joined <- struct{}{}
+					}
+				}
+			}()
+
+			join := func() bool {
+				err := conn.WriteJSON(messages.WsMessage{
+					Type:    messages.MessageActionTypeJoin,
+					Payload: mustRaw(messages.JoinRoomPayload{RoomID: "stress", UserID: userID, UserName: userID}),
+				})
+				if err != nil {
+					return false
+				}
+				select {
+				case <-joined:
+					return true
+				case <-time.After(2 * time.Second):
+					t.Errorf("%s: join not applied", userID)
+					return false
+				}
+			}
+			chat := messages.WsMessage{
+				Type:    messages.MessageActionTypeMessage,
+				Payload: mustRaw(messages.MessagePayload{RoomID: "stress", Message: "hi"}),
+			}
+			leave := messages.WsMessage{
+				Type:    messages.MessageActionTypeLeave,
+				Payload: mustRaw(messages.LeaveRoomPayload{RoomID: "stress"}),
+			}
+
+			for j := 0; j < 10; j++ {
+				if !join() {
+					return
+				}
+				// the trailing chat races the leave on the server
+				for _, m := range []messages.WsMessage{chat, chat, leave, chat} {
+					if err := conn.WriteJSON(m); err != nil {
+						return
+					}
+				}
+			}
+
+			// half of the clients hang up while still in the room
+			if i%2 == 0 {
+				join()
+				_ = conn.WriteJSON(chat)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, wsSrv.Shutdown(ctx), "server shutdown")
+	assert.Equal(t, 0, wsSrv.ClientCount())
+}
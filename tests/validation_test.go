@@ -0,0 +1,19 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/arturskrzydlo/chat-room/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+// The schema is compiled into the binary, so validation must not depend on the working directory.
+func TestValidateWebSocketMessageOutsideRepoRoot(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	err := server.ValidateWebSocketMessage([]byte(`{"type":"join","payload":{"room_id":"room_1"}}`))
+	require.NoError(t, err)
+
+	err = server.ValidateWebSocketMessage([]byte(`{"type":"join","payload":{}}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,213 @@
+// Command gen-ws-schema writes the JSON schema for outbound websocket events
+// by reflecting the messages types, so the schema can't drift from the
+// structs the server marshals. The inbound schema, message_schema.json, is
+// kept by hand because its constraints can't be read off the structs.
+//
+//	go run ./tools/gen-ws-schema -o internal/server/event_schema.json
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/arturskrzydlo/chat-room/internal/messages"
+)
+
+// outboundEvent pairs a wire type with the struct sent for it
+type outboundEvent struct {
+	typ string
+	v   interface{}
+}
+
+// outboundEvents lists every event the server sends. Errors carry no type
+// and are matched by their code instead.
+var outboundEvents = []outboundEvent{
+	{"join_success", messages.JoinSuccess{}},
+	{"create_room_success", messages.CreateRoomSuccess{}},
+	{"leave_success", messages.LeaveSuccess{}},
+	{"leave_all_success", messages.LeaveAllSuccess{}},
+	{"message_duplicate", messages.DuplicateMessageAck{}},
+	{"message_ack", messages.MessageAck{}},
+	{"session", messages.SessionEvent{}},
+	{"resume_success", messages.ResumeSuccess{}},
+	{"session_info", messages.SessionInfoEvent{}},
+	{"pong", messages.Pong{}},
+	{"backpressure", messages.BackpressureEvent{}},
+	{string(messages.EventNewMessage), messages.RoomMessageEvent{}},
+	{string(messages.EventNewRoom), messages.RoomCreateEvent{}},
+	{string(messages.EventUserJoinedRoom), messages.UserJoinedEvent{}},
+	{string(messages.EventUserLeftRoom), messages.UserLeftEvent{}},
+	{string(messages.EventUserKicked), messages.UserKickedEvent{}},
+	{string(messages.EventMessageExpired), messages.MessageExpiredEvent{}},
+	{string(messages.EventMessageDeleted), messages.MessageDeletedEvent{}},
+	{string(messages.EventReaction), messages.ReactionEvent{}},
+	{string(messages.EventDirectMessage), messages.DirectMessageEvent{}},
+	{string(messages.EventPresence), messages.PresenceEvent{}},
+	{string(messages.EventRoomStats), messages.RoomStatsEvent{}},
+	{string(messages.EventHistoryBatch), messages.HistoryBatchEvent{}},
+	{string(messages.EventAttachment), messages.AttachmentEvent{}},
+	{string(messages.EventMessagesDropped), messages.MessagesDroppedEvent{}},
+	{string(messages.EventRoomError), messages.RoomErrorEvent{}},
+	{string(messages.EventRoomClosed), messages.RoomClosedEvent{}},
+	{string(messages.EventAnnouncement), messages.SystemAnnouncementEvent{}},
+	{string(messages.EventReadReceipt), messages.ReadReceiptEvent{}},
+	{string(messages.EventAuthorChanged), messages.AuthorChangedEvent{}},
+	{string(messages.EventModeratorChanged), messages.ModeratorChangedEvent{}},
+	{string(messages.EventUserMuted), messages.UserMutedEvent{}},
+	{string(messages.EventUserUnmuted), messages.UserUnmutedEvent{}},
+	{string(messages.EventRoomMetaUpdated), messages.RoomMetaUpdatedEvent{}},
+	{string(messages.EventUserRenamed), messages.UserRenamedEvent{}},
+	{string(messages.EventTyping), messages.TypingEvent{}},
+	{string(messages.EventMembersList), messages.MembersListEvent{}},
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+func main() {
+	out := flag.String("o", "internal/server/event_schema.json", "file to write the schema to")
+	flag.Parse()
+
+	data, err := generate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the outbound schema: a oneOf over every event, each
+// variant fixing its type, plus one for errors
+func generate() ([]byte, error) {
+	defs := make(map[string]interface{})
+	variants := make([]interface{}, 0, len(outboundEvents)+1)
+
+	errDef, err := structSchema(reflect.TypeOf(messages.ErrorPayload{}), defs)
+	if err != nil {
+		return nil, err
+	}
+	defs["ErrorPayload"] = errDef
+	variants = append(variants, map[string]interface{}{"$ref": "#/$defs/ErrorPayload"})
+
+	for _, ev := range outboundEvents {
+		t := reflect.TypeOf(ev.v)
+		if _, err := typeSchema(t, defs); err != nil {
+			return nil, err
+		}
+		def := defs[t.Name()].(map[string]interface{})
+		props := def["properties"].(map[string]interface{})
+		if _, ok := props["type"]; !ok {
+			return nil, fmt.Errorf("%s has no type field", t.Name())
+		}
+		if prev, ok := props["type"].(map[string]interface{})["const"]; ok && prev != ev.typ {
+			return nil, fmt.Errorf("%s is listed as both %v and %s", t.Name(), prev, ev.typ)
+		}
+		props["type"] = map[string]interface{}{"const": ev.typ}
+		variants = append(variants, map[string]interface{}{"$ref": "#/$defs/" + t.Name()})
+	}
+
+	schema := map[string]interface{}{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         "event_schema.json",
+		"title":       "ServerEvent",
+		"description": "Outbound websocket events, discriminated by type. Generated by tools/gen-ws-schema; do not edit.",
+		"oneOf":       variants,
+		"$defs":       defs,
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(schema); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// typeSchema maps t to a schema, adding named structs to defs and
+// returning a reference to them
+func typeSchema(t reflect.Type, defs map[string]interface{}) (map[string]interface{}, error) {
+	if t == rawMessageType {
+		return map[string]interface{}{}, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}, nil
+	case reflect.Slice:
+		// encoding/json sends []byte as base64 and a nil slice as null
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": []string{"string", "null"}}, nil
+		}
+		items, err := typeSchema(t.Elem(), defs)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key in %s", t)
+		}
+		return map[string]interface{}{"type": []string{"object", "null"}}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			def, err := structSchema(t, defs)
+			if err != nil {
+				return nil, err
+			}
+			defs[t.Name()] = def
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// structSchema describes a struct's JSON form: fields without omitempty are
+// required and no other fields are allowed
+func structSchema(t reflect.Type, defs map[string]interface{}) (map[string]interface{}, error) {
+	props := make(map[string]interface{}, t.NumField())
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fs, err := typeSchema(f.Type, defs)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+		props[name] = fs
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"required":             required,
+		"properties":           props,
+		"additionalProperties": false,
+	}, nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSchemaUpToDate fails when the committed schema no longer matches the
+// messages types; rerun go generate ./internal/server to fix it
+func TestSchemaUpToDate(t *testing.T) {
+	want, err := generate()
+	require.NoError(t, err)
+	got, err := os.ReadFile("../../internal/server/event_schema.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}